@@ -56,6 +56,7 @@ func createRootCmd() *cobra.Command {
 	var tenantID string
 	var subscription string
 	var mouse bool
+	var readOnly bool
 
 	// Start tracking the last node navigated to in storage for the `resume` command
 	go func() {
@@ -86,6 +87,10 @@ func createRootCmd() *cobra.Command {
 				settings.MouseEnabled = mouse
 			}
 
+			if readOnly {
+				settings.ReadOnly = readOnly
+			}
+
 			if debug {
 				settings.EnableTracing = true
 				tracing.EnableDebug()
@@ -156,6 +161,7 @@ func createRootCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&demo, "demo", false, "run in demo mode to filter sensitive output")
 	cmd.Flags().IntVar(&fuzzerDurationMinutes, "fuzzer", -1, "run fuzzer (optionally specify the duration in minutes)")
 	cmd.Flags().BoolVarP(&mouse, "mouse", "m", false, "(optional) enable mouse support. Note this disables normal text selection in the terminal")
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "(optional) refuse any request which could change resources (e.g. delete, update and actions)")
 
 	if err := cmd.RegisterFlagCompletionFunc("subscription", subscriptionAutocompletion); err != nil {
 		panic(err)
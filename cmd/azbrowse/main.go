@@ -54,10 +54,19 @@ func run(settings *config.Settings) {
 		log.Panicln(err)
 	}
 
+	// Load the user's config file
+	userConfig, err := config.Load()
+	if err != nil {
+		log.Panicln(err)
+	}
+
 	// Create an ARMClient instance for us to use
 	armClient := armclient.NewClientFromCLI(settings.TenantID, responseProcessor)
 	armclient.LegacyInstance = armClient
 
+	// Enforce read-only mode for all HTTP requests if requested
+	configureReadOnlyMode(settings, userConfig, armClient)
+
 	// Create a ARM Client for MS-Graph to use
 	graphClient := armclient.NewGraphClientFromCLI(settings.TenantID, responseProcessor)
 
@@ -100,7 +109,7 @@ func run(settings *config.Settings) {
 
 	// Create the views we'll use to display information and
 	// bind up all the keys use to interact with the views
-	list, commandPanel, content := setupViewsAndKeybindings(ctx, g, settings, armClient)
+	list, commandPanel, content := setupViewsAndKeybindings(ctx, g, settings, userConfig, armClient)
 
 	// Initialize the expanders which will let the user walk the tree of
 	// resources in Azure
@@ -210,7 +219,33 @@ func startPopulatingList(ctx context.Context, g *gocui.Gui, list *views.ListWidg
 	}()
 }
 
-func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *config.Settings, client *armclient.Client) (*views.ListWidget, *views.CommandPanelWidget, *views.ItemWidget) {
+func configureReadOnlyMode(settings *config.Settings, userConfig config.Config, client *armclient.Client) {
+	armclient.InstallReadOnlyTransport()
+
+	readOnly := settings.ReadOnly
+	if !readOnly && len(userConfig.ReadOnlyTenants) > 0 {
+		tenantID := settings.TenantID
+		if tenantID == "" {
+			// No tenant specified so find the default tenant from the CLI
+			token, err := client.GetToken()
+			if err != nil {
+				log.Panicln(err)
+			}
+			tenantID = token.Tenant
+		}
+		for _, readOnlyTenant := range userConfig.ReadOnlyTenants {
+			if strings.EqualFold(readOnlyTenant, tenantID) {
+				readOnly = true
+				break
+			}
+		}
+	}
+
+	settings.ReadOnly = readOnly
+	armclient.SetReadOnlyMode(readOnly, userConfig.ReadOnlyAllowListKeys)
+}
+
+func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *config.Settings, userConfig config.Config, client *armclient.Client) (*views.ListWidget, *views.CommandPanelWidget, *views.ItemWidget) {
 	maxX, _ := g.Size()
 	// Padding
 	maxX = maxX - 2
@@ -223,9 +258,11 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 
 	// Create the views used
 	status := views.NewStatusbarWidget(1, -3, 0, settings.HideGuids, g)
+	status.SetReadOnly(settings.ReadOnly)
 	notifications := views.NewNotificationWidget(-45, 0, 45, g, client)
 
 	commandPanel := views.NewCommandPanelWidget(leftColumnWidth+3, 0, maxX-leftColumnWidth-20, g)
+	notifications.SetCommandPanel(commandPanel)
 
 	// Special handler/hack required by view because `/` doesn't trigger correctly in itemWidget
 	// this causes an ordering issue as the ItemWidget needs the command panel and the command panel needs the views as inputs
@@ -234,6 +271,7 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 	commandPanelFilterFuzzyCommand := keybindings.NewCommandPanelFilterHandler(commandPanel, true)
 	content := views.NewItemWidget(leftColumnWidth+2, 0, 0, -4, settings.HideGuids, settings.ShouldRender, "", commandPanelFilterCommand.InvokeWithStartString)
 	list := views.NewListWidget(ctx, 1, 0, leftColumnWidth, -4, []string{"Loading..."}, 0, content, status, settings.EnableTracing, "Subscriptions", settings.ShouldRender, g)
+	list.SetHeadlineMetrics(userConfig.HeadlineMetrics)
 	commandPanelFilterCommand.SetItemWidget(content)
 	commandPanelFilterCommand.SetListWidget(list)
 	commandPanelFilterFuzzyCommand.SetItemWidget(content)
@@ -244,12 +282,16 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 
 	commandPanelAzureSearchQueryCommand := keybindings.NewCommandPanelAzureSearchQueryHandler(commandPanel, content, list)
 	commandPanelContainerAppLogsCommand := keybindings.NewCommandPanelContainerAppLogsHandler(commandPanel, content, list)
+	commandPanelWebAppLogsCommand := keybindings.NewCommandPanelWebAppLogsHandler(commandPanel, content, list)
+	commandPanelContainerInstanceLogsCommand := keybindings.NewCommandPanelContainerInstanceLogsHandler(commandPanel, content, list)
+	commandPanelKubernetesPodLogsCommand := keybindings.NewCommandPanelKubernetesPodLogsHandler(commandPanel, content, list)
 
 	listActionsCommand := keybindings.NewListActionsHandler(list, ctx)
 	listOpenCommand := keybindings.NewListOpenHandler(list, ctx)
 	listUpdateCommand := keybindings.NewListUpdateHandler(list, status, ctx, content, g)
 	listDebugCopyItemDataCommand := keybindings.NewListDebugCopyItemDataHandler(list, status)
 	listSortCommand := keybindings.NewListSortHandler(list)
+	listWatchCommand := keybindings.NewListWatchHandler(list, userConfig.WatchIntervalSeconds)
 
 	itemCopyItemIDCommand := keybindings.NewItemCopyItemIDHandler(content, status)
 
@@ -259,12 +301,16 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 		copyCommand,
 		commandPanelAzureSearchQueryCommand,
 		commandPanelContainerAppLogsCommand,
+		commandPanelWebAppLogsCommand,
+		commandPanelContainerInstanceLogsCommand,
+		commandPanelKubernetesPodLogsCommand,
 		listActionsCommand,
 		listOpenCommand,
 		listUpdateCommand,
 		itemCopyItemIDCommand,
 		toggleDemoModeCommand,
 		listSortCommand,
+		listWatchCommand,
 	}
 	if settings.EnableTracing {
 		commands = append(commands, listDebugCopyItemDataCommand)
@@ -316,8 +362,12 @@ func setupViewsAndKeybindings(ctx context.Context, g *gocui.Gui, settings *confi
 	keybindings.AddHandler(keybindings.NewListClearFilterHandler(list))
 	keybindings.AddHandler(commandPanelAzureSearchQueryCommand)
 	keybindings.AddHandler(commandPanelContainerAppLogsCommand)
+	keybindings.AddHandler(commandPanelWebAppLogsCommand)
+	keybindings.AddHandler(commandPanelContainerInstanceLogsCommand)
+	keybindings.AddHandler(commandPanelKubernetesPodLogsCommand)
 	keybindings.AddHandler(itemCopyItemIDCommand)
 	keybindings.AddHandler(listSortCommand)
+	keybindings.AddHandler(listWatchCommand)
 	if settings.EnableTracing {
 		keybindings.AddHandler(listDebugCopyItemDataCommand)
 	}
@@ -17,12 +17,23 @@ type Settings struct {
 	TenantID              string // the tenant ID to get an access token for from `az account get-access-token`
 	ShouldRender          bool
 	MouseEnabled          bool
+	ReadOnly              bool // refuse requests which could change resources
 }
 
 // Config represents the user configuration options
 type Config struct {
-	KeyBindings map[string]interface{} `json:"keyBindings,omitempty"`
-	Editor      EditorConfig           `json:"editor,omitempty"`
+	KeyBindings           map[string]interface{}          `json:"keyBindings,omitempty"`
+	Editor                EditorConfig                    `json:"editor,omitempty"`
+	WatchIntervalSeconds  int                             `json:"watchIntervalSeconds,omitempty"`  // How often watch mode re-expands the watched item (defaults to 10)
+	HeadlineMetrics       map[string]HeadlineMetricConfig `json:"headlineMetrics,omitempty"`       // The metric shown as a sparkline in resource lists, keyed by resource type
+	ReadOnlyTenants       []string                        `json:"readOnlyTenants,omitempty"`       // Tenant IDs to always run in read-only mode for
+	ReadOnlyAllowListKeys bool                            `json:"readOnlyAllowListKeys,omitempty"` // Allow POSTs which only list keys/credentials (e.g. storage listKeys) in read-only mode
+}
+
+// HeadlineMetricConfig represents the metric to show as a sparkline for a resource type
+type HeadlineMetricConfig struct {
+	Metric      string `json:"metric,omitempty"`      // The metric name (empty to disable sparklines for the resource type)
+	Aggregation string `json:"aggregation,omitempty"` // The aggregation to plot (defaults to average)
 }
 
 // EditorConfig represents the user options for external editor
@@ -0,0 +1,184 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	uuid "github.com/satori/go.uuid"
+)
+
+// amqpClient connects to a Service Bus or Event Hubs namespace over AMQP for the operations the REST API
+// doesn't support, such as peeking messages without locking them or receiving events.
+// Links are authorized with claims-based security (CBS) using a Shared Access Signature created from the namespace keys
+// See https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-amqp-protocol-guide
+type amqpClient struct {
+	conn     *amqp.Conn
+	session  *amqp.Session
+	host     string
+	keyName  string
+	key      string
+	requests map[string]*amqpRequestLink
+}
+
+// amqpRequestLink is a sender and receiver pair for request/response operations on a node such as $cbs or $management
+type amqpRequestLink struct {
+	sender   *amqp.Sender
+	receiver *amqp.Receiver
+	replyTo  string
+}
+
+// dialAMQP opens a connection to the namespace endpoint (https://{namespace}.servicebus.windows.net/) using the key in
+// the item metadata (see sharedAccessClient.ensureConnectionDetails)
+func dialAMQP(ctx context.Context, item *TreeNode) (*amqpClient, error) {
+	endpoint, err := url.Parse(item.Metadata["Endpoint"])
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing endpoint: %s", err)
+	}
+	conn, err := amqp.Dial(ctx, "amqps://"+endpoint.Host, &amqp.ConnOptions{SASLType: amqp.SASLTypeAnonymous()})
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to %s: %s", endpoint.Host, err)
+	}
+	session, err := conn.NewSession(ctx, nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("Error creating AMQP session: %s", err)
+	}
+	return &amqpClient{
+		conn:     conn,
+		session:  session,
+		host:     endpoint.Host,
+		keyName:  item.Metadata["KeyName"],
+		key:      item.Metadata["Key"],
+		requests: map[string]*amqpRequestLink{},
+	}, nil
+}
+
+// Close closes the connection
+func (c *amqpClient) Close() error {
+	return c.conn.Close()
+}
+
+// authorize sends a token for the entity so that links can be opened to it
+func (c *amqpClient) authorize(ctx context.Context, entityPath string) error {
+	audience := "amqps://" + c.host + "/" + entityPath
+	token := sharedAccessSignature(audience, c.keyName, c.key, time.Now().Add(time.Hour))
+	// CBS docs: https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-amqp-protocol-guide#claims-based-authorization
+	_, err := c.request(ctx, "$cbs", &amqp.Message{
+		ApplicationProperties: map[string]any{
+			"operation": "put-token",
+			"type":      "servicebus.windows.net:sastoken",
+			"name":      audience,
+		},
+		Value: token,
+	})
+	if err != nil {
+		return fmt.Errorf("Error authorizing %s: %s", entityPath, err)
+	}
+	return nil
+}
+
+// managementRequest runs an operation on the entity's $management node, returning the response body
+// See https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-amqp-request-response
+func (c *amqpClient) managementRequest(ctx context.Context, entityPath string, operation string, body map[string]any) (*amqp.Message, error) {
+	return c.request(ctx, entityPath+"/$management", &amqp.Message{
+		ApplicationProperties: map[string]any{"operation": operation},
+		Value:                 body,
+	})
+}
+
+// request sends the message to the node and waits for the response, returning an error for unsuccessful status codes
+func (c *amqpClient) request(ctx context.Context, address string, message *amqp.Message) (*amqp.Message, error) {
+	link, err := c.requestLink(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	messageID := uuid.NewV4().String()
+	message.Properties = &amqp.MessageProperties{MessageID: messageID, ReplyTo: &link.replyTo}
+	if err = link.sender.Send(ctx, message, nil); err != nil {
+		return nil, fmt.Errorf("Error sending %s request: %s", address, err)
+	}
+	for {
+		response, err := link.receiver.Receive(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error receiving %s response: %s", address, err)
+		}
+		_ = link.receiver.AcceptMessage(ctx, response)
+		if response.Properties == nil || response.Properties.CorrelationID != messageID {
+			continue // a response to an earlier request which timed out
+		}
+		statusCode, description := amqpResponseStatus(response)
+		if statusCode < 200 || statusCode >= 300 {
+			return nil, fmt.Errorf("Request failed %d for '%s': %s", statusCode, address, description)
+		}
+		return response, nil
+	}
+}
+
+// requestLink returns the links for request/response operations on the node, creating them the first time
+func (c *amqpClient) requestLink(ctx context.Context, address string) (*amqpRequestLink, error) {
+	if link, ok := c.requests[address]; ok {
+		return link, nil
+	}
+	replyTo := "azbrowse-" + uuid.NewV4().String()
+	sender, err := c.session.NewSender(ctx, address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening sender for %s: %s", address, err)
+	}
+	receiver, err := c.session.NewReceiver(ctx, address, &amqp.ReceiverOptions{TargetAddress: replyTo})
+	if err != nil {
+		return nil, fmt.Errorf("Error opening receiver for %s: %s", address, err)
+	}
+	link := &amqpRequestLink{sender: sender, receiver: receiver, replyTo: replyTo}
+	c.requests[address] = link
+	return link, nil
+}
+
+// amqpResponseStatus returns the status code and description from a request/response reply.
+// $cbs uses status-code and $management uses statusCode
+func amqpResponseStatus(response *amqp.Message) (int, string) {
+	statusCode := 0
+	description := ""
+	for name, value := range response.ApplicationProperties {
+		switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+		case "statuscode":
+			switch value := value.(type) {
+			case int32:
+				statusCode = int(value)
+			case int64:
+				statusCode = int(value)
+			case int:
+				statusCode = value
+			}
+		case "statusdescription":
+			description = fmt.Sprint(value)
+		}
+	}
+	return statusCode, description
+}
+
+// amqpAnnotation returns the message annotation, e.g. x-opt-sequence-number
+func amqpAnnotation(message *amqp.Message, name string) (any, bool) {
+	for key, value := range message.Annotations {
+		if fmt.Sprint(key) == name {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// amqpBody returns the message body as a string
+func amqpBody(message *amqp.Message) string {
+	if len(message.Data) > 0 {
+		return string(bytes.Join(message.Data, nil))
+	}
+	if message.Value != nil {
+		return fmt.Sprint(message.Value)
+	}
+	return ""
+}
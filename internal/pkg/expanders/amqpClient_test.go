@@ -0,0 +1,44 @@
+package expanders
+
+import (
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_amqpResponseStatus(t *testing.T) {
+	// $cbs
+	statusCode, _ := amqpResponseStatus(&amqp.Message{ApplicationProperties: map[string]any{"status-code": int32(202)}})
+	assert.Equal(t, 202, statusCode)
+
+	// $management
+	statusCode, description := amqpResponseStatus(&amqp.Message{ApplicationProperties: map[string]any{
+		"statusCode":        int32(401),
+		"statusDescription": "Unauthorized",
+	}})
+	assert.Equal(t, 401, statusCode)
+	assert.Equal(t, "Unauthorized", description)
+
+	statusCode, _ = amqpResponseStatus(&amqp.Message{})
+	assert.Equal(t, 0, statusCode)
+}
+
+func Test_amqpMessageContent(t *testing.T) {
+	encoded, err := (&amqp.Message{
+		Annotations: amqp.Annotations{"x-opt-offset": "4096"},
+		Data:        [][]byte{[]byte("hello "), []byte("world")},
+	}).MarshalBinary()
+	assert.NoError(t, err)
+	var message amqp.Message
+	assert.NoError(t, message.UnmarshalBinary(encoded))
+
+	offset, ok := amqpAnnotation(&message, "x-opt-offset")
+	assert.True(t, ok)
+	assert.Equal(t, "4096", offset)
+	_, ok = amqpAnnotation(&message, "x-opt-sequence-number")
+	assert.False(t, ok)
+	assert.Equal(t, "hello world", amqpBody(&message))
+
+	assert.Equal(t, "42", amqpBody(&amqp.Message{Value: int64(42)}))
+}
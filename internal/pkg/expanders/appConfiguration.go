@@ -0,0 +1,904 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const appConfigurationTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.AppConfiguration/configurationStores/{configStoreName}"
+
+const (
+	appConfigurationAPIVersion = "1.0"
+	appConfigurationResource   = "https://azconfig.io"
+	// appConfigurationNullLabel is the label filter for key-values without a label
+	appConfigurationNullLabel = "\x00"
+	// appConfigurationSnapshotAPIVersion is the first data-plane API version with snapshots
+	appConfigurationSnapshotAPIVersion = "2023-10-01"
+)
+
+const (
+	appConfigurationContentTypeFeatureFlag      = "application/vnd.microsoft.appconfig.ff+json"
+	appConfigurationContentTypeKeyVaultRef      = "application/vnd.microsoft.appconfig.keyvaultref+json"
+	appConfigurationFeatureFlagPrefix           = ".appconfig.featureflag/"
+	appConfigurationKeyValueContentTypeForWrite = "application/vnd.microsoft.appconfig.kv+json"
+)
+
+const (
+	appConfigurationNodeLabels    = "appconfig-labels"
+	appConfigurationNodeKeyValues = "appconfig-keyvalues"
+	appConfigurationNodeKeyValue  = "appconfig-keyvalue"
+	appConfigurationNodeRevisions = "appconfig-revisions"
+	appConfigurationNodeRevision  = "appconfig-revision"
+
+	appConfigurationNodeSnapshots        = "appconfig-snapshots"
+	appConfigurationNodeSnapshot         = "appconfig-snapshot"
+	appConfigurationNodeSnapshotKeyValue = "appconfig-snapshot-keyvalue"
+)
+
+const appConfigurationActionFilter = "appconfig-filter"
+
+// appConfigurationKeyValue is a key-value as returned by the data-plane API
+type appConfigurationKeyValue struct {
+	Etag         string            `json:"etag"`
+	Key          string            `json:"key"`
+	Label        *string           `json:"label"`
+	ContentType  string            `json:"content_type"`
+	Value        *string           `json:"value"`
+	Tags         map[string]string `json:"tags"`
+	Locked       bool              `json:"locked"`
+	LastModified string            `json:"last_modified"`
+}
+
+type appConfigurationKeyValueListResponse struct {
+	Items    []appConfigurationKeyValue `json:"items"`
+	NextLink string                     `json:"@nextLink"`
+}
+
+// appConfigurationSnapshot is a snapshot as returned by the data-plane API
+type appConfigurationSnapshot struct {
+	Name            string            `json:"name"`
+	Status          string            `json:"status"`
+	Filters         []json.RawMessage `json:"filters"`
+	CompositionType string            `json:"composition_type"`
+	Created         string            `json:"created"`
+	Expires         *string           `json:"expires"`
+	RetentionPeriod int64             `json:"retention_period"`
+	Size            int64             `json:"size"`
+	ItemsCount      int64             `json:"items_count"`
+	Tags            map[string]string `json:"tags"`
+	Etag            string            `json:"etag"`
+}
+
+type appConfigurationSnapshotListResponse struct {
+	Items    []appConfigurationSnapshot `json:"items"`
+	NextLink string                     `json:"@nextLink"`
+}
+
+type appConfigurationLabelListResponse struct {
+	Items []struct {
+		Name *string `json:"name"`
+	} `json:"items"`
+	NextLink string `json:"@nextLink"`
+}
+
+// appConfigurationKeyValueView is how a key-value is shown in the content panel. Feature flags and
+// Key Vault references have their value shown as JSON rather than an escaped string to make them
+// easier to read and edit
+type appConfigurationKeyValueView struct {
+	Key               string                       `json:"key"`
+	Label             *string                      `json:"label"`
+	ContentType       string                       `json:"content_type"`
+	Value             json.RawMessage              `json:"value"`
+	KeyVaultReference *appConfigurationKeyVaultRef `json:"keyVaultReference,omitempty"`
+	Tags              map[string]string            `json:"tags"`
+	Locked            bool                         `json:"locked"`
+	LastModified      string                       `json:"last_modified"`
+	Etag              string                       `json:"etag"`
+}
+
+// appConfigurationKeyVaultRef is the decoded secret URI of a Key Vault reference
+type appConfigurationKeyVaultRef struct {
+	Vault   string `json:"vault"`
+	Secret  string `json:"secret"`
+	Version string `json:"version,omitempty"`
+}
+
+type appConfigurationStoreResponse struct {
+	Properties struct {
+		Endpoint string `json:"endpoint"`
+	} `json:"properties"`
+}
+
+// NewAppConfigurationExpander creates a new instance of AppConfigurationExpander
+func NewAppConfigurationExpander(armClient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *AppConfigurationExpander {
+	return &AppConfigurationExpander{
+		client:       &http.Client{},
+		armClient:    armClient,
+		getToken:     armclient.AcquireTokenForResourceFromAzCLI,
+		gui:          gui,
+		commandPanel: commandPanel,
+	}
+}
+
+// Check interface
+var _ Expander = &AppConfigurationExpander{}
+
+// AppConfigurationExpander expands the key-values in an App Configuration store
+type AppConfigurationExpander struct {
+	ExpanderBase
+	client       *http.Client
+	armClient    *armclient.Client
+	getToken     func(subscription string, resource string) (armclient.AzCLIToken, error)
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+}
+
+func (e *AppConfigurationExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *AppConfigurationExpander) Name() string {
+	return "AppConfigurationExpander"
+}
+
+// DoesExpand checks if this is an App Configuration store
+func (e *AppConfigurationExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == appConfigurationTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "appconfig" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the labels, key-values and revisions in the store
+func (e *AppConfigurationExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "appconfig" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == appConfigurationTemplateURL {
+		newItems := []*TreeNode{}
+		for _, child := range []struct{ name, itemType, path string }{
+			{"Key-values", appConfigurationNodeLabels, "keyvalues"},
+			{"Snapshots", appConfigurationNodeSnapshots, "snapshots"},
+		} {
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<" + child.path + ">",
+				Namespace:             "appconfig",
+				Name:                  child.name,
+				Display:               child.name,
+				ItemType:              child.itemType,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"StoreID":        currentItem.ID,
+					"SubscriptionID": armclient.GetSubscriptionIDFromResourceID(currentItem.ID),
+				},
+			})
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "AppConfigurationExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case appConfigurationNodeLabels:
+		return e.expandLabels(ctx, currentItem)
+	case appConfigurationNodeKeyValues:
+		return e.expandKeyValues(ctx, currentItem, "kv", appConfigurationNodeKeyValue)
+	case appConfigurationNodeKeyValue:
+		return e.expandKeyValue(ctx, currentItem)
+	case appConfigurationNodeRevisions:
+		return e.expandKeyValues(ctx, currentItem, "revisions", appConfigurationNodeRevision)
+	case appConfigurationNodeSnapshots:
+		return e.expandSnapshots(ctx, currentItem)
+	case appConfigurationNodeSnapshot:
+		result := e.expandKeyValues(ctx, currentItem, "kv", appConfigurationNodeSnapshotKeyValue)
+		if result.Err == nil {
+			result.Response = ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON}
+		}
+		return result
+	case appConfigurationNodeRevision, appConfigurationNodeSnapshotKeyValue:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "AppConfigurationExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "AppConfigurationExpander request",
+	}
+}
+
+// expandLabels lists the labels in the store, each of which can be expanded to show its key-values
+func (e *AppConfigurationExpander) expandLabels(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	endpoint, err := e.getEndpoint(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+
+	// List Labels docs: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-labels
+	labels := []*string{}
+	listURL := endpoint + "/labels?name=*&api-version=" + appConfigurationAPIVersion
+	var data []byte
+	for listURL != "" {
+		data, _, err = e.doRequest(ctx, "GET", listURL, currentItem.Metadata["SubscriptionID"], nil, nil)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error listing labels: %s", err),
+				SourceDescription: "AppConfigurationExpander request",
+			}
+		}
+		var response appConfigurationLabelListResponse
+		if err = json.Unmarshal(data, &response); err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling labels: %s", err),
+				SourceDescription: "AppConfigurationExpander request",
+			}
+		}
+		for _, label := range response.Items {
+			labels = append(labels, label.Name)
+		}
+		listURL = e.getNextLink(endpoint, response.NextLink)
+	}
+
+	nodes := []*TreeNode{}
+	for _, label := range labels {
+		name := "(no label)"
+		labelFilter := appConfigurationNullLabel
+		if label != nil {
+			name = *label
+			labelFilter = escapeAppConfigurationFilter(*label)
+		}
+		metadata := e.connectionMetadata(currentItem)
+		metadata["KeyFilter"] = "*"
+		metadata["LabelFilter"] = labelFilter
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + name,
+			Namespace: "appconfig",
+			Name:      name,
+			Display:   name,
+			ItemType:  appConfigurationNodeKeyValues,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("%d label(s), use the 'Filter key-values' action to search by key and label", len(labels)), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "AppConfigurationExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandKeyValues lists the key-values (or revisions) matching the key and label filters in the item metadata
+func (e *AppConfigurationExpander) expandKeyValues(ctx context.Context, currentItem *TreeNode, listPath string, childItemType string) ExpanderResult {
+	endpoint, err := e.getEndpoint(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+
+	// List Key-Values docs: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-key-value
+	// List Revisions docs: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-revisions
+	// Get Snapshot Key-Values docs: https://learn.microsoft.com/en-us/azure/azure-app-configuration/rest-api-snapshot
+	listURL := e.getNextLink(endpoint, currentItem.Metadata["NextLink"])
+	if listURL == "" && currentItem.Metadata["Snapshot"] != "" {
+		listURL = fmt.Sprintf("%s/%s?snapshot=%s&api-version=%s", endpoint, listPath, url.QueryEscape(currentItem.Metadata["Snapshot"]), appConfigurationSnapshotAPIVersion)
+	} else if listURL == "" {
+		listURL = fmt.Sprintf("%s/%s?key=%s&label=%s&api-version=%s", endpoint, listPath, url.QueryEscape(currentItem.Metadata["KeyFilter"]), url.QueryEscape(currentItem.Metadata["LabelFilter"]), appConfigurationAPIVersion)
+	}
+	data, _, err := e.doRequest(ctx, "GET", listURL, currentItem.Metadata["SubscriptionID"], nil, nil)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing %s: %s", listPath, err),
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+	var response appConfigurationKeyValueListResponse
+	if err = json.Unmarshal(data, &response); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling %s: %s", listPath, err),
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, keyValue := range response.Items {
+		content, err := appConfigurationContent(keyValue)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "AppConfigurationExpander request",
+			}
+		}
+
+		name := keyValue.Key
+		status := appConfigurationStatus(keyValue)
+		if childItemType == appConfigurationNodeRevision {
+			name = keyValue.LastModified
+			status = appConfigurationValueSummary(keyValue)
+		}
+		metadata := e.connectionMetadata(currentItem)
+		metadata["Key"] = keyValue.Key
+		metadata["Label"] = appConfigurationLabelFilter(keyValue.Label)
+		metadata["Content"] = content
+		id := currentItem.ID + "/" + keyValue.Key
+		if keyValue.Label != nil {
+			id += "?label=" + *keyValue.Label
+			if childItemType == appConfigurationNodeSnapshotKeyValue {
+				status = *keyValue.Label + ", " + status
+			}
+		}
+		node := &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        id,
+			Namespace: "appconfig",
+			Name:      name,
+			Display:   name + "\n  " + style.Subtle(status),
+			ItemType:  childItemType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		}
+		switch childItemType {
+		case appConfigurationNodeRevision:
+			node.ID = currentItem.ID + "/" + keyValue.Etag
+		case appConfigurationNodeKeyValue:
+			node.DeleteURL = id
+		}
+		nodes = append(nodes, node)
+	}
+
+	if response.NextLink != "" {
+		metadata := copyMetadata(currentItem.Metadata)
+		metadata["NextLink"] = response.NextLink
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "appconfig",
+			ID:            currentItem.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      currentItem.ItemType,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("Key filter: %s\nLabel filter: %s\n\n%s", currentItem.Metadata["KeyFilter"], appConfigurationLabelDisplay(currentItem.Metadata["LabelFilter"]), data), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "AppConfigurationExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandSnapshots lists the snapshots in the store, each of which can be expanded to show its key-values
+func (e *AppConfigurationExpander) expandSnapshots(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	endpoint, err := e.getEndpoint(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+
+	// List Snapshots docs: https://learn.microsoft.com/en-us/azure/azure-app-configuration/rest-api-snapshot
+	snapshots := []appConfigurationSnapshot{}
+	listURL := endpoint + "/snapshots?api-version=" + appConfigurationSnapshotAPIVersion
+	for listURL != "" {
+		data, _, err := e.doRequest(ctx, "GET", listURL, currentItem.Metadata["SubscriptionID"], nil, nil)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error listing snapshots: %s", err),
+				SourceDescription: "AppConfigurationExpander request",
+			}
+		}
+		var response appConfigurationSnapshotListResponse
+		if err = json.Unmarshal(data, &response); err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling snapshots: %s", err),
+				SourceDescription: "AppConfigurationExpander request",
+			}
+		}
+		snapshots = append(snapshots, response.Items...)
+		listURL = e.getNextLink(endpoint, response.NextLink)
+	}
+
+	nodes := []*TreeNode{}
+	for _, snapshot := range snapshots {
+		content, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error marshaling snapshot: %s", err),
+				SourceDescription: "AppConfigurationExpander request",
+			}
+		}
+		metadata := e.connectionMetadata(currentItem)
+		metadata["Snapshot"] = snapshot.Name
+		metadata["Content"] = string(content)
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + snapshot.Name,
+			Namespace: "appconfig",
+			Name:      snapshot.Name,
+			Display:   snapshot.Name + "\n  " + style.Subtle(appConfigurationSnapshotStatus(snapshot)),
+			ItemType:  appConfigurationNodeSnapshot,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("%d snapshot(s), expand a snapshot to see its key-values", len(snapshots)), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "AppConfigurationExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandKeyValue gets the latest key-value and adds a node for its revisions
+func (e *AppConfigurationExpander) expandKeyValue(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	keyValue, err := e.getKeyValue(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+	content, err := appConfigurationContent(*keyValue)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppConfigurationExpander request",
+		}
+	}
+	currentItem.Metadata["Etag"] = keyValue.Etag
+	currentItem.Metadata["Locked"] = fmt.Sprint(keyValue.Locked)
+
+	metadata := e.connectionMetadata(currentItem)
+	metadata["KeyFilter"] = escapeAppConfigurationFilter(currentItem.Metadata["Key"])
+	metadata["LabelFilter"] = currentItem.Metadata["Label"]
+	if metadata["LabelFilter"] != appConfigurationNullLabel {
+		metadata["LabelFilter"] = escapeAppConfigurationFilter(metadata["LabelFilter"])
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: content, ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "AppConfigurationExpander request",
+		Nodes: []*TreeNode{
+			{
+				Parentid:  currentItem.ID,
+				ID:        currentItem.ID + "/<revisions>",
+				Namespace: "appconfig",
+				Name:      "History",
+				Display:   "History",
+				ItemType:  appConfigurationNodeRevisions,
+				ExpandURL: ExpandURLNotSupported,
+				Metadata:  metadata,
+			},
+		},
+		IsPrimaryResponse: true,
+	}
+}
+
+// CanUpdate returns true for key-values that aren't locked
+func (e *AppConfigurationExpander) CanUpdate(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == appConfigurationNodeKeyValue && item.Metadata["Etag"] != "" && item.Metadata["Locked"] != "true", nil
+}
+
+// Update sets the value, content type and tags from the edited content
+func (e *AppConfigurationExpander) Update(ctx context.Context, item *TreeNode, updatedContent string) error {
+	var view appConfigurationKeyValueView
+	if err := json.Unmarshal([]byte(updatedContent), &view); err != nil {
+		return fmt.Errorf("Error parsing key-value: %s", err)
+	}
+	if view.Key != item.Metadata["Key"] || appConfigurationLabelFilter(view.Label) != item.Metadata["Label"] {
+		return fmt.Errorf("The key and label can't be changed")
+	}
+	if view.Locked {
+		return fmt.Errorf("The key-value is locked")
+	}
+
+	// Values are strings, but feature flags and Key Vault references are shown (and so can be edited) as JSON
+	var value string
+	if err := json.Unmarshal(view.Value, &value); err != nil {
+		compact := &bytes.Buffer{}
+		if err = json.Compact(compact, view.Value); err != nil {
+			return fmt.Errorf("Error parsing value: %s", err)
+		}
+		value = compact.String()
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"value":        value,
+		"content_type": view.ContentType,
+		"tags":         view.Tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Set Key docs: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-key-value#set-key
+	headers := map[string]string{
+		"Content-Type": appConfigurationKeyValueContentTypeForWrite,
+		"If-Match":     `"` + item.Metadata["Etag"] + `"`,
+	}
+	_, responseHeaders, err := e.doRequest(ctx, "PUT", e.getKeyValueURL(item), item.Metadata["SubscriptionID"], headers, body)
+	if err != nil {
+		if isAppConfigurationRequestStatus(err, http.StatusPreconditionFailed) {
+			return fmt.Errorf("The key-value has been changed since it was loaded - refresh and try again")
+		}
+		return fmt.Errorf("Error setting key-value: %s", err)
+	}
+	item.Metadata["Etag"] = strings.Trim(responseHeaders.Get("ETag"), `"`)
+	return nil
+}
+
+// Delete deletes the key-value
+func (e *AppConfigurationExpander) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.ItemType != appConfigurationNodeKeyValue {
+		return false, nil
+	}
+	// Delete Key docs: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-key-value#delete
+	_, _, err := e.doRequest(ctx, "DELETE", e.getKeyValueURL(item), item.Metadata["SubscriptionID"], nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("Error deleting key-value: %s", err)
+	}
+	return true, nil
+}
+
+// HasActions returns true for the labels and key-value lists which can be filtered
+func (e *AppConfigurationExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	switch item.ItemType {
+	case appConfigurationNodeLabels, appConfigurationNodeKeyValues:
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the filter action
+func (e *AppConfigurationExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	// Filtering waits on the command panel so allow extra time for the user to respond
+	metadata := e.connectionMetadata(item)
+	metadata["ActionID"] = appConfigurationActionFilter
+	metadata["KeyFilter"] = item.Metadata["KeyFilter"]
+	metadata["LabelFilter"] = item.Metadata["LabelFilter"]
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?filter",
+				Namespace:              "appconfig",
+				Name:                   "Filter key-values",
+				Display:                "Filter key-values",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata:               metadata,
+			},
+		},
+		SourceDescription: "AppConfigurationExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the filter action
+func (e *AppConfigurationExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case appConfigurationActionFilter:
+		keyFilter := item.Metadata["KeyFilter"]
+		if keyFilter == "" {
+			keyFilter = "*"
+		}
+		labelFilter := appConfigurationLabelDisplay(item.Metadata["LabelFilter"])
+		if labelFilter == "" {
+			labelFilter = "*"
+		}
+		keyFilter, _ = promptInCommandPanel(e.gui, e.commandPanel, "Key filter (e.g. app1:* or key1,key2):", keyFilter, nil)
+		if keyFilter != "" {
+			labelFilter, _ = promptInCommandPanel(e.gui, e.commandPanel, "Label filter (e.g. prod*, \\0 for no label):", labelFilter, nil)
+		}
+		if keyFilter == "" || labelFilter == "" {
+			return ExpanderResult{
+				Err:               fmt.Errorf("User canceled"),
+				SourceDescription: "AppConfigurationExpander",
+				IsPrimaryResponse: true,
+			}
+		}
+		item.Metadata["KeyFilter"] = keyFilter
+		item.Metadata["LabelFilter"] = strings.ReplaceAll(labelFilter, `\0`, appConfigurationNullLabel)
+		delete(item.Metadata, "NextLink")
+		return e.expandKeyValues(ctx, item, "kv", appConfigurationNodeKeyValue)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "AppConfigurationExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "AppConfigurationExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *AppConfigurationExpander) getKeyValue(ctx context.Context, item *TreeNode) (*appConfigurationKeyValue, error) {
+	// Get Key docs: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-key-value#get-key-value
+	data, _, err := e.doRequest(ctx, "GET", e.getKeyValueURL(item), item.Metadata["SubscriptionID"], nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting key-value: %s", err)
+	}
+	var keyValue appConfigurationKeyValue
+	if err = json.Unmarshal(data, &keyValue); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling key-value: %s", err)
+	}
+	return &keyValue, nil
+}
+
+func (e *AppConfigurationExpander) getKeyValueURL(item *TreeNode) string {
+	keyValueURL := item.Metadata["Endpoint"] + "/kv/" + url.PathEscape(item.Metadata["Key"]) + "?api-version=" + appConfigurationAPIVersion
+	// Omitting the label refers to the key-value without a label
+	if label := item.Metadata["Label"]; label != appConfigurationNullLabel {
+		keyValueURL += "&label=" + url.QueryEscape(label)
+	}
+	return keyValueURL
+}
+
+// getNextLink returns the absolute URL for the (relative) next link
+func (e *AppConfigurationExpander) getNextLink(endpoint string, nextLink string) string {
+	if nextLink == "" || strings.HasPrefix(nextLink, "https://") {
+		return nextLink
+	}
+	return endpoint + nextLink
+}
+
+// getEndpoint gets the data-plane endpoint for the store, saving it in the item metadata
+func (e *AppConfigurationExpander) getEndpoint(ctx context.Context, item *TreeNode) (string, error) {
+	if endpoint := item.Metadata["Endpoint"]; endpoint != "" {
+		return endpoint, nil
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "GET", item.Metadata["StoreID"]+"?api-version=2019-10-01")
+	if err != nil {
+		return "", fmt.Errorf("Error getting configuration store: %s", err)
+	}
+	var response appConfigurationStoreResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return "", fmt.Errorf("Error unmarshalling configuration store: %s", err)
+	}
+	if response.Properties.Endpoint == "" {
+		return "", fmt.Errorf("Endpoint not found for %s", item.Metadata["StoreID"])
+	}
+	item.Metadata["Endpoint"] = strings.TrimSuffix(response.Properties.Endpoint, "/")
+	return item.Metadata["Endpoint"], nil
+}
+
+// connectionMetadata returns a copy of the metadata needed to connect to the store
+func (e *AppConfigurationExpander) connectionMetadata(item *TreeNode) map[string]string {
+	metadata := map[string]string{}
+	for _, key := range []string{"StoreID", "SubscriptionID", "Endpoint"} {
+		metadata[key] = item.Metadata[key]
+	}
+	return metadata
+}
+
+func (e *AppConfigurationExpander) doRequest(ctx context.Context, verb string, url string, subscriptionID string, headers map[string]string, body []byte) ([]byte, http.Header, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(appconfig):"+url, tracing.SetTag("url", url))
+	defer span.Finish()
+
+	token, err := e.getToken(subscriptionID, appConfigurationResource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting token: %s", err)
+	}
+
+	req, err := http.NewRequest(verb, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, nil, &appConfigurationRequestError{StatusCode: response.StatusCode, Status: response.Status, URL: url, Body: string(buf)}
+	}
+	return buf, response.Header, nil
+}
+
+// appConfigurationRequestError is returned when a request to the App Configuration data plane gets an unsuccessful status code
+type appConfigurationRequestError struct {
+	StatusCode int
+	Status     string
+	URL        string
+	Body       string
+}
+
+func (e *appConfigurationRequestError) Error() string {
+	return fmt.Sprintf("Request failed %v for '%s': %s", e.Status, e.URL, e.Body)
+}
+
+// isAppConfigurationRequestStatus returns true if the error is from a request which returned the status code
+func isAppConfigurationRequestStatus(err error, statusCode int) bool {
+	var requestError *appConfigurationRequestError
+	return errors.As(err, &requestError) && requestError.StatusCode == statusCode
+}
+
+// appConfigurationContent formats the key-value for the content panel, decoding feature flags and Key Vault references
+func appConfigurationContent(keyValue appConfigurationKeyValue) (string, error) {
+	view := appConfigurationKeyValueView{
+		Key:          keyValue.Key,
+		Label:        keyValue.Label,
+		ContentType:  keyValue.ContentType,
+		Tags:         keyValue.Tags,
+		Locked:       keyValue.Locked,
+		LastModified: keyValue.LastModified,
+		Etag:         keyValue.Etag,
+	}
+
+	value := ""
+	if keyValue.Value != nil {
+		value = *keyValue.Value
+	}
+	view.Value, _ = json.Marshal(value)
+	if (isAppConfigurationFeatureFlag(keyValue) || isAppConfigurationKeyVaultRef(keyValue)) && json.Valid([]byte(value)) {
+		view.Value = json.RawMessage(value)
+	}
+	if isAppConfigurationKeyVaultRef(keyValue) {
+		view.KeyVaultReference = decodeAppConfigurationKeyVaultRef(value)
+	}
+
+	content, err := json.MarshalIndent(view, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Error marshaling key-value: %s", err)
+	}
+	return string(content), nil
+}
+
+// decodeAppConfigurationKeyVaultRef splits the secret URI of a Key Vault reference into the vault, secret and version
+func decodeAppConfigurationKeyVaultRef(value string) *appConfigurationKeyVaultRef {
+	var reference struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal([]byte(value), &reference); err != nil {
+		return nil
+	}
+	secretURL, err := url.Parse(reference.URI)
+	if err != nil {
+		return nil
+	}
+	// https://<vault>.vault.azure.net/secrets/<secret>[/<version>]
+	segments := strings.Split(strings.Trim(secretURL.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "secrets" {
+		return nil
+	}
+	decoded := &appConfigurationKeyVaultRef{
+		Vault:  strings.Split(secretURL.Host, ".")[0],
+		Secret: segments[1],
+	}
+	if len(segments) > 2 {
+		decoded.Version = segments[2]
+	}
+	return decoded
+}
+
+func isAppConfigurationFeatureFlag(keyValue appConfigurationKeyValue) bool {
+	return strings.HasPrefix(keyValue.ContentType, appConfigurationContentTypeFeatureFlag) || strings.HasPrefix(keyValue.Key, appConfigurationFeatureFlagPrefix)
+}
+
+func isAppConfigurationKeyVaultRef(keyValue appConfigurationKeyValue) bool {
+	return strings.HasPrefix(keyValue.ContentType, appConfigurationContentTypeKeyVaultRef)
+}
+
+// appConfigurationStatus describes the key-value for display in the list
+func appConfigurationStatus(keyValue appConfigurationKeyValue) string {
+	status := "label: " + appConfigurationLabelDisplay(appConfigurationLabelFilter(keyValue.Label)) + ", " + appConfigurationValueSummary(keyValue)
+	if keyValue.Locked {
+		status += ", locked"
+	}
+	return status
+}
+
+// appConfigurationSnapshotStatus describes the snapshot for display in the list
+func appConfigurationSnapshotStatus(snapshot appConfigurationSnapshot) string {
+	status := fmt.Sprintf("%s, %d key-value(s), created %s", snapshot.Status, snapshot.ItemsCount, snapshot.Created)
+	if snapshot.Expires != nil {
+		status += ", expires " + *snapshot.Expires
+	}
+	return status
+}
+
+// appConfigurationValueSummary describes the value, e.g. whether a feature flag is enabled
+func appConfigurationValueSummary(keyValue appConfigurationKeyValue) string {
+	value := ""
+	if keyValue.Value != nil {
+		value = *keyValue.Value
+	}
+	switch {
+	case isAppConfigurationFeatureFlag(keyValue):
+		var featureFlag struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.Unmarshal([]byte(value), &featureFlag); err == nil {
+			if featureFlag.Enabled {
+				return "feature flag: on"
+			}
+			return "feature flag: off"
+		}
+		return "feature flag"
+	case isAppConfigurationKeyVaultRef(keyValue):
+		if reference := decodeAppConfigurationKeyVaultRef(value); reference != nil {
+			return "Key Vault ref: " + reference.Vault + "/" + reference.Secret
+		}
+		return "Key Vault ref"
+	}
+	if len(value) > 40 {
+		value = value[:40] + "..."
+	}
+	return "value: " + strings.ReplaceAll(value, "\n", " ")
+}
+
+// appConfigurationLabelFilter returns the label filter that matches only the label
+func appConfigurationLabelFilter(label *string) string {
+	if label == nil {
+		return appConfigurationNullLabel
+	}
+	return *label
+}
+
+func appConfigurationLabelDisplay(labelFilter string) string {
+	return strings.ReplaceAll(labelFilter, appConfigurationNullLabel, `\0`)
+}
+
+// escapeAppConfigurationFilter escapes the characters which have a special meaning in key and label filters
+func escapeAppConfigurationFilter(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `,`, `\,`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,161 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeAppConfigurationKeyVaultRef(t *testing.T) {
+	reference := decodeAppConfigurationKeyVaultRef(`{"uri":"https://myvault.vault.azure.net/secrets/db-password/0123abcd"}`)
+	assert.Equal(t, &appConfigurationKeyVaultRef{Vault: "myvault", Secret: "db-password", Version: "0123abcd"}, reference)
+
+	reference = decodeAppConfigurationKeyVaultRef(`{"uri":"https://myvault.vault.azure.net/secrets/db-password"}`)
+	assert.Equal(t, &appConfigurationKeyVaultRef{Vault: "myvault", Secret: "db-password"}, reference)
+
+	assert.Nil(t, decodeAppConfigurationKeyVaultRef(`not json`))
+}
+
+func Test_appConfigurationContent_FeatureFlagValueIsShownAsJSON(t *testing.T) {
+	value := `{"id":"beta","enabled":true}`
+	content, err := appConfigurationContent(appConfigurationKeyValue{
+		Key:         ".appconfig.featureflag/beta",
+		ContentType: "application/vnd.microsoft.appconfig.ff+json;charset=utf-8",
+		Value:       &value,
+	})
+	assert.NoError(t, err)
+
+	var view struct {
+		Value map[string]interface{} `json:"value"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(content), &view))
+	assert.Equal(t, true, view.Value["enabled"])
+	assert.Equal(t, "feature flag: on", appConfigurationValueSummary(appConfigurationKeyValue{Key: ".appconfig.featureflag/beta", Value: &value}))
+}
+
+func Test_AppConfiguration_ExpandSnapshots(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer bob", r.Header.Get("Authorization"))
+		assert.Equal(t, "2023-10-01", r.URL.Query().Get("api-version"))
+		switch r.URL.Path {
+		case "/snapshots":
+			if r.URL.Query().Get("after") == "" {
+				_, _ = io.WriteString(w, `{"items": [{"name": "release-1", "status": "ready", "items_count": 2, "created": "2023-11-01T10:00:00Z"}], "@nextLink": "/snapshots?after=release-1&api-version=2023-10-01"}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"items": [{"name": "release-2", "status": "archived", "items_count": 1, "created": "2023-11-02T10:00:00Z", "expires": "2023-12-02T10:00:00Z"}]}`)
+		case "/kv":
+			assert.Equal(t, "release-1", r.URL.Query().Get("snapshot"))
+			_, _ = io.WriteString(w, `{"items": [
+				{"key": "app:color", "label": "prod", "value": "blue", "etag": "e1"},
+				{"key": "app:size", "label": null, "value": "10", "etag": "e2"}
+			]}`)
+		default:
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+	}))
+	defer ts.Close()
+
+	e := &AppConfigurationExpander{
+		client: ts.Client(),
+		getToken: func(subscription string, resource string) (armclient.AzCLIToken, error) {
+			return armclient.AzCLIToken{AccessToken: "bob"}, nil
+		},
+	}
+	ctx := context.Background()
+	snapshots := &TreeNode{
+		ID:        "/store/<snapshots>",
+		Namespace: "appconfig",
+		ItemType:  appConfigurationNodeSnapshots,
+		Metadata:  map[string]string{"Endpoint": ts.URL},
+	}
+
+	result := e.Expand(ctx, snapshots)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Nodes, 2)
+	assert.Contains(t, result.Nodes[1].Display, "archived, 1 key-value(s), created 2023-11-02T10:00:00Z, expires 2023-12-02T10:00:00Z")
+
+	snapshot := result.Nodes[0]
+	assert.Equal(t, "/store/<snapshots>/release-1", snapshot.ID)
+	assert.Equal(t, appConfigurationNodeSnapshot, snapshot.ItemType)
+	assert.Equal(t, "release-1", snapshot.Metadata["Snapshot"])
+
+	result = e.Expand(ctx, snapshot)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, snapshot.Metadata["Content"], result.Response.Response)
+	assert.Len(t, result.Nodes, 2)
+	keyValue := result.Nodes[0]
+	assert.Equal(t, appConfigurationNodeSnapshotKeyValue, keyValue.ItemType)
+	assert.Equal(t, "app:color", keyValue.Name)
+	assert.Equal(t, "", keyValue.DeleteURL)
+
+	// Key-values in a snapshot can't be changed
+	canUpdate, _ := e.CanUpdate(ctx, keyValue)
+	assert.False(t, canUpdate)
+	deleted, _ := e.Delete(ctx, keyValue)
+	assert.False(t, deleted)
+}
+
+func Test_AppConfiguration_CancellingFilterKeepsFilters(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	// The key filter is entered but the label prompt is closed
+	g, commandPanel := newTestPrompt(t, "app2:*")
+	e := &AppConfigurationExpander{client: ts.Client(), gui: g, commandPanel: commandPanel}
+	action := &TreeNode{
+		ItemType: ActionType,
+		Metadata: map[string]string{
+			"ActionID":    appConfigurationActionFilter,
+			"Endpoint":    ts.URL,
+			"KeyFilter":   "app1:*",
+			"LabelFilter": "prod",
+		},
+	}
+	result := e.ExecuteAction(context.Background(), action)
+	assert.EqualError(t, result.Err, "User canceled")
+	assert.Equal(t, "app1:*", action.Metadata["KeyFilter"])
+	assert.Equal(t, "prod", action.Metadata["LabelFilter"])
+	assert.Len(t, commandPanel.titles, 2)
+	assert.Equal(t, 0, requests)
+}
+
+func Test_AppConfiguration_UpdateReportsChangedKeyValue(t *testing.T) {
+	status := http.StatusPreconditionFailed
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `"e1"`, r.Header.Get("If-Match"))
+		w.WriteHeader(status)
+		// The body mentions a status code so only the response status should be checked
+		_, _ = io.WriteString(w, `{"detail": "retry after 412 ms"}`)
+	}))
+	defer ts.Close()
+
+	e := &AppConfigurationExpander{
+		client: ts.Client(),
+		getToken: func(subscription string, resource string) (armclient.AzCLIToken, error) {
+			return armclient.AzCLIToken{AccessToken: "bob"}, nil
+		},
+	}
+	item := &TreeNode{
+		ItemType: appConfigurationNodeKeyValue,
+		Metadata: map[string]string{"Endpoint": ts.URL, "Key": "app:color", "Label": "prod", "Etag": "e1"},
+	}
+	content := `{"key": "app:color", "label": "prod", "value": "green"}`
+
+	err := e.Update(context.Background(), item, content)
+	assert.EqualError(t, err, "The key-value has been changed since it was loaded - refresh and try again")
+
+	status = http.StatusInternalServerError
+	err = e.Update(context.Background(), item, content)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Error setting key-value: Request failed 500")
+}
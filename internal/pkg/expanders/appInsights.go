@@ -4,12 +4,49 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 )
 
+const appInsightsTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Insights/components/{resourceName}"
+
+const (
+	appInsightsAPIVersion = "2015-05-01"
+	appInsightsResource   = "https://api.applicationinsights.io"
+	appInsightsQueryURL   = "https://api.applicationinsights.io/v1/apps/%s/query"
+)
+
+const (
+	appInsightsNodeQueryResultsJSON = "AppInsights.QueryResultsJSON"
+	appInsightsNodeOperation        = "AppInsights.Operation"
+)
+
+const (
+	appInsightsActionExecuteItem = "appinsights-execute-item"
+	appInsightsActionQuery       = "appinsights-query"
+	appInsightsActionTimeRange   = "appinsights-timerange"
+)
+
+const (
+	appInsightsDefaultQuery = "requests | take 10"
+	// appInsightsMaxOperations limits the operations which can be drilled into from query results
+	appInsightsMaxOperations = 100
+)
+
+// appInsightsTransactionQuery gets the telemetry for an operation, i.e. the end-to-end transaction
+const appInsightsTransactionQuery = `union requests, dependencies, exceptions
+| where operation_Id == %s
+| project timestamp, itemType, name, type, target, resultCode, duration, success, outerMessage, id, operation_ParentId
+| order by timestamp asc`
+
 type analyticsItem struct {
 	Content      string `json:"Content"`
 	ID           string `json:"Id"`
@@ -21,13 +58,37 @@ type analyticsItem struct {
 	Version      string `json:"Version"`
 }
 
+type appInsightsComponentResponse struct {
+	Properties struct {
+		AppID string `json:"AppId"`
+	} `json:"properties"`
+}
+
+// NewAppInsightsExpander creates a new instance of AppInsightsExpander
+func NewAppInsightsExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *AppInsightsExpander {
+	return &AppInsightsExpander{
+		client:       armclient,
+		httpClient:   &http.Client{},
+		gui:          gui,
+		commandPanel: commandPanel,
+		appIDs:       map[string]string{},
+	}
+}
+
 // Check interface
 var _ Expander = &AppInsightsExpander{}
 
 // AppInsightsExpander expands aspects of App Insights that don't naturally flow from the api spec
 type AppInsightsExpander struct {
 	ExpanderBase
-	client *armclient.Client
+	client       *armclient.Client
+	httpClient   *http.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+
+	// appIDs are the application IDs used by the query API, keyed by resource ID
+	appIDs      map[string]string
+	appIDsMutex sync.Mutex
 }
 
 func (e *AppInsightsExpander) setClient(c *armclient.Client) {
@@ -43,7 +104,7 @@ func (e *AppInsightsExpander) Name() string {
 func (e *AppInsightsExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
 	swaggerResourceType := currentItem.SwaggerResourceType
 	if currentItem.ItemType == "resource" && swaggerResourceType != nil {
-		if swaggerResourceType.Endpoint.TemplateURL == "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Insights/components/{resourceName}" {
+		if swaggerResourceType.Endpoint.TemplateURL == appInsightsTemplateURL {
 			return true, nil
 		}
 	}
@@ -59,7 +120,7 @@ func (e *AppInsightsExpander) Expand(ctx context.Context, currentItem *TreeNode)
 	swaggerResourceType := currentItem.SwaggerResourceType
 	if currentItem.Namespace != "AppInsights" &&
 		swaggerResourceType != nil &&
-		swaggerResourceType.Endpoint.TemplateURL == "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Insights/components/{resourceName}" {
+		swaggerResourceType.Endpoint.TemplateURL == appInsightsTemplateURL {
 		newItems := []*TreeNode{}
 		resourceAPIVersion, err := armclient.GetAPIVersion(currentItem.ArmType)
 		if err != nil {
@@ -100,6 +161,15 @@ func (e *AppInsightsExpander) Expand(ctx context.Context, currentItem *TreeNode)
 		return e.expandAnalyticsItems(ctx, currentItem)
 	} else if currentItem.ItemType == "AppInsights.AnalyticsItem" {
 		return e.expandAnalyticsItem(ctx, currentItem)
+	} else if currentItem.ItemType == appInsightsNodeOperation {
+		operationID := currentItem.Metadata["OperationID"]
+		return e.runQuery(ctx, currentItem, currentItem.Metadata["AppInsightsID"], fmt.Sprintf(appInsightsTransactionQuery, kqlString(operationID)), "Transaction "+operationID)
+	} else if currentItem.ItemType == appInsightsNodeQueryResultsJSON {
+		return ExpanderResult{
+			IsPrimaryResponse: true,
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Response"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "AppInsightsExpander request",
+		}
 	}
 
 	return ExpanderResult{
@@ -145,12 +215,17 @@ func (e *AppInsightsExpander) expandAnalyticsItems(ctx context.Context, currentI
 		}
 		newItem := TreeNode{
 			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + item.ID,
 			Namespace: "AppInsights",
 			ItemType:  "AppInsights.AnalyticsItem",
 			Name:      item.Name,
 			ExpandURL: appInsightsID + "/" + collectionName + "/item?api-version=" + resourceAPIVersion + "&id=" + item.ID,
 			DeleteURL: appInsightsID + "/" + collectionName + "/item?api-version=" + resourceAPIVersion + "&id=" + item.ID,
 			Display:   style.Subtle("["+item.Type+" - "+item.Scope+"]") + "\n " + item.Name,
+			Metadata: map[string]string{
+				"AppInsightsID":     appInsightsID,
+				"AnalyticsItemType": item.Type,
+			},
 		}
 		newItems = append(newItems, &newItem)
 	}
@@ -184,3 +259,219 @@ func (e *AppInsightsExpander) expandAnalyticsItem(ctx context.Context, currentIt
 		SourceDescription: "AppInsightsExpander request",
 	}
 }
+
+// HasActions returns true for App Insights components and the queries and functions under them
+func (e *AppInsightsExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.Namespace != "AppInsights" && item.ItemType == "resource" && item.SwaggerResourceType != nil && item.SwaggerResourceType.Endpoint.TemplateURL == appInsightsTemplateURL {
+		return true, nil
+	}
+	if item.ItemType == "AppInsights.AnalyticsItem" && item.Metadata["AnalyticsItemType"] != "folder" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the actions for running queries
+func (e *AppInsightsExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	metadata := copyMetadata(item.Metadata)
+	if metadata["AppInsightsID"] == "" {
+		metadata["AppInsightsID"] = item.ID
+	}
+
+	newAction := func(name string, actionID string) *TreeNode {
+		actionMetadata := copyMetadata(metadata)
+		actionMetadata["ActionID"] = actionID
+		return &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + actionID,
+			Namespace:              "AppInsights",
+			Name:                   name,
+			Display:                name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               actionMetadata,
+		}
+	}
+
+	nodes := []*TreeNode{}
+	if item.ItemType == "AppInsights.AnalyticsItem" {
+		execute := newAction("Execute", appInsightsActionExecuteItem)
+		execute.Metadata["ItemURL"] = item.ExpandURL
+		nodes = append(nodes, execute)
+	} else {
+		nodes = append(nodes, newAction("Run Query", appInsightsActionQuery))
+	}
+	timeRange, _ := getQueryTimeRange(metadata["AppInsightsID"])
+	nodes = append(nodes, newAction("Set time range (currently "+timeRange+")", appInsightsActionTimeRange))
+
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "AppInsightsExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs a query against the App Insights component
+func (e *AppInsightsExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+	appInsightsID := item.Metadata["AppInsightsID"]
+
+	switch actionID {
+	case appInsightsActionExecuteItem:
+		data, err := e.client.DoRequest(ctx, "GET", item.Metadata["ItemURL"])
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error getting analytics item: %s", err),
+				SourceDescription: "AppInsightsExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		var analyticsItem analyticsItem
+		if err = json.Unmarshal([]byte(data), &analyticsItem); err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling analytics item: %s", err),
+				SourceDescription: "AppInsightsExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		return e.runQuery(ctx, item, appInsightsID, analyticsItem.Content, analyticsItem.Name)
+	case appInsightsActionQuery:
+		historyKey := appInsightsQueryHistoryKey(appInsightsID)
+		initialQuery := appInsightsDefaultQuery
+		if history := loadQueryHistory(historyKey); len(history) > 0 {
+			initialQuery = history[0]
+		}
+		queryText, _ := promptInCommandPanel(e.gui, e.commandPanel, "KQL query:", initialQuery, nil)
+		if queryText == "" {
+			return ExpanderResult{
+				Err:               fmt.Errorf("No query entered"),
+				SourceDescription: "AppInsightsExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		result := e.runQuery(ctx, item, appInsightsID, queryText, "Query results")
+		if result.Err == nil {
+			if err := saveQueryToHistory(historyKey, queryText); err != nil {
+				eventing.SendStatusEvent(&eventing.StatusEvent{
+					Failure: true,
+					Message: fmt.Sprintf("Failed to save query history: %s", err),
+					Timeout: time.Second * 5,
+				})
+			}
+		}
+		return result
+	case appInsightsActionTimeRange:
+		selected, err := promptForQueryTimeRange(e.gui, e.commandPanel, appInsightsID)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "AppInsightsExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "Queries will cover the last " + selected, ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "AppInsightsExpander request",
+			IsPrimaryResponse: true,
+		}
+	case "":
+		return ExpanderResult{
+			SourceDescription: "AppInsightsExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "AppInsightsExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+// runQuery runs the query and shows the results as a table, with nodes to drill into the operations in the results
+func (e *AppInsightsExpander) runQuery(ctx context.Context, currentItem *TreeNode, appInsightsID string, queryText string, title string) ExpanderResult {
+	appID, err := e.getAppID(ctx, appInsightsID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppInsightsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	timeRange, timespan := getQueryTimeRange(appInsightsID)
+	data, err := doKustoQuery(ctx, e.httpClient, fmt.Sprintf(appInsightsQueryURL, appID), appInsightsResource, armclient.GetSubscriptionIDFromResourceID(appInsightsID), queryText, timespan)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AppInsightsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	result := kustoQueryResult(currentItem, data, title, timeRange, "AppInsights", appInsightsNodeQueryResultsJSON, "AppInsightsExpander request")
+	if result.Err != nil {
+		return result
+	}
+
+	var response kustoQueryResponse
+	_ = json.Unmarshal(data, &response) // already checked by kustoQueryResult
+	operationIDs := kustoColumnValues(response.Tables, "operation_Id")
+	if len(operationIDs) > appInsightsMaxOperations {
+		operationIDs = operationIDs[:appInsightsMaxOperations]
+	}
+	// Transactions are Kusto queries too so can take longer than the default expand timeout
+	timeoutSeconds := logAnalyticsQueryTimeoutSeconds
+	for _, operationID := range operationIDs {
+		result.Nodes = append(result.Nodes, &TreeNode{
+			Parentid:               currentItem.ID,
+			ID:                     currentItem.ID + "/<operation>/" + operationID,
+			Namespace:              "AppInsights",
+			Name:                   operationID,
+			Display:                style.Subtle("[operation]") + "\n  " + operationID,
+			ItemType:               appInsightsNodeOperation,
+			ExpandURL:              ExpandURLNotSupported,
+			SuppressSwaggerExpand:  true,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: &timeoutSeconds,
+			Metadata: map[string]string{
+				"AppInsightsID": appInsightsID,
+				"OperationID":   operationID,
+			},
+		})
+	}
+	return result
+}
+
+// getAppID returns the application ID used by the query API
+func (e *AppInsightsExpander) getAppID(ctx context.Context, appInsightsID string) (string, error) {
+	e.appIDsMutex.Lock()
+	appID, ok := e.appIDs[appInsightsID]
+	e.appIDsMutex.Unlock()
+	if ok {
+		return appID, nil
+	}
+
+	data, err := e.client.DoRequest(ctx, "GET", appInsightsID+"?api-version="+appInsightsAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("Error getting App Insights component: %s", err)
+	}
+	var response appInsightsComponentResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return "", fmt.Errorf("Error unmarshalling App Insights component response: %s", err)
+	}
+	if response.Properties.AppID == "" {
+		return "", fmt.Errorf("Application ID not found for %s", appInsightsID)
+	}
+
+	e.appIDsMutex.Lock()
+	e.appIDs[appInsightsID] = response.Properties.AppID
+	e.appIDsMutex.Unlock()
+	return response.Properties.AppID, nil
+}
+
+// appInsightsQueryHistoryKey returns the query history key, resource IDs aren't case sensitive so
+// the ID is lowercased to share the history however the component was reached
+func appInsightsQueryHistoryKey(appInsightsID string) string {
+	return resourceCacheKey("AppInsightsQueryHistory", strings.ToLower(appInsightsID))
+}
@@ -0,0 +1,315 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const (
+	containerInstanceActionRestart = "aci-restart"
+	containerInstanceActionStop    = "aci-stop"
+	containerInstanceActionStart   = "aci-start"
+)
+
+// containerInstanceLogPollInterval is how often logs are fetched when following them
+const containerInstanceLogPollInterval = 5 * time.Second
+
+// ContainerInstanceExpanderInterface is used by the follow logs command to poll container logs
+type ContainerInstanceExpanderInterface interface {
+	FollowLogs(ctx context.Context, currentItem *TreeNode, onLines func(lines []string, err error)) error
+}
+
+func isContainerGroup(item *TreeNode) bool {
+	return item.ItemType == "resource" &&
+		item.SwaggerResourceType != nil &&
+		item.SwaggerResourceType.Endpoint.TemplateURL == containerInstanceTemplate
+}
+
+// HasActions returns true for container groups
+func (e *ContainerInstanceExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	return isContainerGroup(item), nil
+}
+
+// ListActions returns the restart, stop and start actions for container groups
+func (e *ContainerInstanceExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	actions := []struct{ id, name string }{
+		{containerInstanceActionRestart, "Restart"},
+		{containerInstanceActionStop, "Stop"},
+		{containerInstanceActionStart, "Start"},
+	}
+
+	nodes := []*TreeNode{}
+	for _, action := range actions {
+		metadata := copyMetadata(item.Metadata)
+		metadata["ActionID"] = action.id
+		metadata["ContainerGroupID"] = item.ID
+		nodes = append(nodes, &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + action.id,
+			Namespace:              containerInstanceNamespace,
+			Name:                   action.name,
+			Display:                action.name,
+			ItemType:               ActionType,
+			ArmType:                item.ArmType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               metadata,
+		})
+	}
+
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "ContainerInstanceExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the container group actions
+func (e *ContainerInstanceExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case containerInstanceActionRestart:
+		return e.containerGroupOperation(ctx, item, "restart", "Restart", true)
+	case containerInstanceActionStop:
+		return e.containerGroupOperation(ctx, item, "stop", "Stop", true)
+	case containerInstanceActionStart:
+		return e.containerGroupOperation(ctx, item, "start", "Start", false)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "ContainerInstanceExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "ContainerInstanceExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+// containerGroupOperation POSTs the operation to the container group. The ARM client's response
+// processors pick up any async operation headers so progress shows in the notifications
+func (e *ContainerInstanceExpander) containerGroupOperation(ctx context.Context, item *TreeNode, operation string, displayName string, confirm bool) ExpanderResult {
+	containerGroupID := item.Metadata["ContainerGroupID"]
+	name := lastSegment(containerGroupID)
+
+	if confirm {
+		options := []interfaces.CommandPanelListOption{
+			{ID: operation, DisplayText: displayName + " " + name},
+			{ID: "cancel", DisplayText: "Cancel"},
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, operation+" container group?", "", &options); selected != operation {
+			return ExpanderResult{
+				Err:               fmt.Errorf("%s cancelled", displayName),
+				SourceDescription: "ContainerInstanceExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+	}
+
+	apiVersion, err := armclient.GetAPIVersion(item.ArmType)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get API version for %s: %s", item.ArmType, err),
+			SourceDescription: "ContainerInstanceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	_, err = e.client.DoRequest(ctx, "POST", containerGroupID+"/"+operation+"?api-version="+apiVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to %s %s: %s", operation, name, err),
+			SourceDescription: "ContainerInstanceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     fmt.Sprintf("Requested %s of container group %s, refresh the container group to see its state", operation, name),
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        displayName + " " + name,
+		},
+		SourceDescription: "ContainerInstanceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *ContainerInstanceExpander) expandEvents(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.client.DoRequest(ctx, "GET", currentItem.ExpandURL)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			Response:          ExpanderResponse{Response: "", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "ContainerInstanceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var containerGroupResponse ContainerGroupResponse
+	err = json.Unmarshal([]byte(data), &containerGroupResponse)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling container group: %s", err),
+			SourceDescription: "ContainerInstanceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     formatContainerInstanceEvents(&containerGroupResponse, time.Now()),
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        "Events: " + containerGroupResponse.Name,
+		},
+		SourceDescription: "ContainerInstanceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// formatContainerInstanceEvents lists the events of the container group and its containers, most recent first
+func formatContainerInstanceEvents(group *ContainerGroupResponse, now time.Time) string {
+	type sourcedEvent struct {
+		source string
+		event  containerInstanceEvent
+	}
+	events := []sourcedEvent{}
+	for _, event := range group.Properties.InstanceView.Events {
+		events = append(events, sourcedEvent{source: "(group)", event: event})
+	}
+	for _, container := range group.Properties.Containers {
+		for _, event := range container.Properties.InstanceView.Events {
+			events = append(events, sourcedEvent{source: container.Name, event: event})
+		}
+	}
+	if len(events) == 0 {
+		return "No events found"
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].event.LastTimestamp.After(events[j].event.LastTimestamp)
+	})
+
+	rows := [][]string{{"LAST SEEN", "TYPE", "REASON", "CONTAINER", "COUNT", "MESSAGE"}}
+	for _, e := range events {
+		count := e.event.Count
+		if count == 0 {
+			count = 1
+		}
+		rows = append(rows, []string{
+			kubernetesAge(e.event.LastTimestamp, now),
+			e.event.Type,
+			e.event.Name,
+			e.source,
+			strconv.Itoa(count),
+			strings.ReplaceAll(strings.TrimSpace(e.event.Message), "\n", " "),
+		})
+	}
+
+	return formatKubernetesTable(rows, func(row []string, column int, cell string) string {
+		if column == 1 && row[1] == "Warning" {
+			return style.Warning(cell)
+		}
+		return cell
+	})
+}
+
+// FollowLogs polls the container logs until the context is cancelled, calling onLines with the lines that are new
+// since the last call. Polling stops after calling onLines with an error
+func (e *ContainerInstanceExpander) FollowLogs(ctx context.Context, currentItem *TreeNode, onLines func(lines []string, err error)) error {
+	if currentItem.ExpandReturnType != "containerInstance.logs" {
+		return fmt.Errorf("Item is not a container: %s", currentItem.ID)
+	}
+
+	getLines := func() ([]string, error) {
+		data, err := e.client.DoRequest(ctx, "GET", currentItem.ExpandURL)
+		if err != nil {
+			return nil, err
+		}
+		var containerLogResponse ContainerLogResponse
+		if err = json.Unmarshal([]byte(data), &containerLogResponse); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling logs: %s", err)
+		}
+		content := strings.TrimRight(containerLogResponse.Content, "\n")
+		if content == "" {
+			return []string{}, nil
+		}
+		return strings.Split(content, "\n"), nil
+	}
+
+	lines, err := getLines()
+	if err != nil {
+		return err
+	}
+	onLines(lines, nil)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(containerInstanceLogPollInterval):
+			}
+
+			latest, err := getLines()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				onLines(nil, fmt.Errorf("Failed to fetch logs: %s", err))
+				return
+			}
+			updated := appendNewLogLines(lines, latest)
+			if len(updated) == len(lines) {
+				continue
+			}
+			onLines(updated[len(lines):], nil)
+			// Only the lines which can overlap the next tail are needed to find the new lines
+			lines = updated[max(0, len(updated)-len(latest)):]
+		}
+	}()
+	return nil
+}
+
+// appendNewLogLines adds the lines from the latest tail of the log that aren't already in existing.
+// The tail is matched against the end of the existing lines, if there's no overlap (e.g. the
+// container restarted or more lines were written than the tail returns) all of latest is appended.
+// Runs of identical lines can't be told apart so repeated lines may be missed.
+func appendNewLogLines(existing []string, latest []string) []string {
+	const anchorSize = 10
+	if len(existing) == 0 {
+		return append([]string{}, latest...)
+	}
+
+	for end := len(latest); end > 0; end-- {
+		size := anchorSize
+		if size > end {
+			size = end
+		}
+		if size > len(existing) {
+			size = len(existing)
+		}
+		match := true
+		for i := 1; i <= size; i++ {
+			if latest[end-i] != existing[len(existing)-i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return append(append([]string{}, existing...), latest[end:]...)
+		}
+	}
+	return append(append([]string{}, existing...), latest...)
+}
@@ -0,0 +1,55 @@
+package expanders
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_appendNewLogLines(t *testing.T) {
+	existing := []string{"a", "b", "c"}
+
+	// Tail has moved on by two lines
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, appendNewLogLines(existing, []string{"b", "c", "d", "e"}))
+	// Nothing new
+	assert.Equal(t, existing, appendNewLogLines(existing, []string{"a", "b", "c"}))
+	// No overlap, e.g. the container restarted
+	assert.Equal(t, []string{"a", "b", "c", "x", "y"}, appendNewLogLines(existing, []string{"x", "y"}))
+	// First fetch
+	assert.Equal(t, []string{"x"}, appendNewLogLines(nil, []string{"x"}))
+}
+
+func Test_formatContainerInstanceEvents(t *testing.T) {
+	now := time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)
+	var group ContainerGroupResponse
+	err := json.Unmarshal([]byte(`{
+		"name": "aci",
+		"properties": {
+			"instanceView": {"events": [
+				{"name": "SuccessfulMountAzureFileVolume", "type": "Normal", "lastTimestamp": "2023-01-02T11:00:00Z"}
+			]},
+			"containers": [{
+				"name": "web",
+				"properties": {"instanceView": {"events": [
+					{"name": "Pulling", "type": "Normal", "message": "pulling image \"nginx\"", "count": 2, "lastTimestamp": "2023-01-02T10:00:00Z"},
+					{"name": "BackOff", "type": "Warning", "message": "Back-off restarting\nfailed container", "lastTimestamp": "2023-01-02T11:59:00Z"}
+				]}}
+			}]
+		}
+	}`), &group)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(formatContainerInstanceEvents(&group, now)), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "LAST SEEN"))
+	assert.Contains(t, lines[1], "BackOff")
+	assert.Contains(t, lines[1], "Back-off restarting failed container")
+	assert.Contains(t, lines[2], "(group)")
+	assert.Contains(t, lines[3], "Pulling")
+	assert.Contains(t, lines[3], "web")
+
+	assert.Equal(t, "No events found", formatContainerInstanceEvents(&ContainerGroupResponse{}, now))
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/awesome-gocui/gocui"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
@@ -16,13 +17,25 @@ import (
 const containerInstanceTemplate = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ContainerInstance/containerGroups/{containerGroupName}"
 const containerInstanceNamespace = "containerInstance"
 
+// NewContainerInstanceExpander creates a new instance of ContainerInstanceExpander
+func NewContainerInstanceExpander(client *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *ContainerInstanceExpander {
+	return &ContainerInstanceExpander{
+		client:       client,
+		gui:          gui,
+		commandPanel: commandPanel,
+	}
+}
+
 // Check interface
 var _ Expander = &ContainerInstanceExpander{}
+var _ ContainerInstanceExpanderInterface = &ContainerInstanceExpander{}
 
 // ContainerInstanceExpander expands the data-plane aspects of a Container Instance
 type ContainerInstanceExpander struct {
 	ExpanderBase
-	client *armclient.Client
+	client       *armclient.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
 }
 
 func (e *ContainerInstanceExpander) setClient(c *armclient.Client) {
@@ -42,7 +55,7 @@ func (e *ContainerInstanceExpander) DoesExpand(ctx context.Context, currentItem
 			return true, nil
 		}
 	}
-	if currentItem.ExpandReturnType == "containerInstance.logs" {
+	if currentItem.ExpandReturnType == "containerInstance.logs" || currentItem.ExpandReturnType == "containerInstance.events" {
 		return true, nil
 	}
 	return false, nil
@@ -53,6 +66,9 @@ func (e *ContainerInstanceExpander) Expand(ctx context.Context, currentItem *Tre
 	if currentItem.ExpandReturnType == "containerInstance.logs" {
 		return e.expandLogs(ctx, currentItem)
 	}
+	if currentItem.ExpandReturnType == "containerInstance.events" {
+		return e.expandEvents(ctx, currentItem)
+	}
 
 	swaggerResourceType := currentItem.SwaggerResourceType
 	if currentItem.Namespace != containerInstanceNamespace &&
@@ -90,7 +106,19 @@ func (e *ContainerInstanceExpander) expandContainers(ctx context.Context, curren
 		}
 	}
 
-	newItems := []*TreeNode{}
+	newItems := []*TreeNode{
+		{
+			Name:                  "Events",
+			Display:               "Events",
+			ID:                    currentItem.ID + "/<events>",
+			Parentid:              currentItem.ID,
+			ExpandURL:             containersListURL,
+			ExpandReturnType:      "containerInstance.events",
+			SubscriptionID:        currentItem.SubscriptionID,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+		},
+	}
 
 	var containerGroupResponse ContainerGroupResponse
 	err = json.Unmarshal([]byte(data), &containerGroupResponse)
@@ -149,6 +177,15 @@ func (e *ContainerInstanceExpander) expandLogs(ctx context.Context, currentItem
 	}
 }
 
+type containerInstanceEvent struct {
+	Count          int       `json:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+	Name           string    `json:"name"`
+	Message        string    `json:"message"`
+	Type           string    `json:"type"`
+}
+
 // ContainerLogResponse for container logs
 type ContainerLogResponse struct {
 	Content string `json:"content"`
@@ -160,6 +197,10 @@ type ContainerGroupResponse struct {
 	Location   string `json:"location"`
 	Name       string `json:"name"`
 	Properties struct {
+		InstanceView struct {
+			State  string                   `json:"state"`
+			Events []containerInstanceEvent `json:"events"`
+		} `json:"instanceView"`
 		Containers []struct {
 			Name       string `json:"name"`
 			Properties struct {
@@ -176,14 +217,7 @@ type ContainerGroupResponse struct {
 						StartTime    time.Time `json:"startTime"`
 						DetailStatus string    `json:"detailStatus"`
 					} `json:"currentState"`
-					Events []struct {
-						Count          int       `json:"count"`
-						FirstTimestamp time.Time `json:"firstTimestamp"`
-						LastTimestamp  time.Time `json:"lastTimestamp"`
-						Name           string    `json:"name"`
-						Message        string    `json:"message"`
-						Type           string    `json:"type"`
-					} `json:"events"`
+					Events []containerInstanceEvent `json:"events"`
 				} `json:"instanceView"`
 				Resources struct {
 					Requests struct {
@@ -0,0 +1,598 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const (
+	containerRegistryManifestJSONType = "containerRegistry.repository.manifest.json"
+
+	containerRegistryActionFindUntagged = "acr-find-untagged"
+
+	// containerRegistryManifestAccept lists the manifest formats we can summarise, most specific first
+	containerRegistryManifestAccept = "application/vnd.oci.image.index.v1+json, " +
+		"application/vnd.docker.distribution.manifest.list.v2+json, " +
+		"application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.docker.distribution.manifest.v2+json, " +
+		"application/vnd.oci.artifact.manifest.v1+json"
+
+	// containerRegistryMaxManifestPages stops the untagged search running forever on very large repositories
+	containerRegistryMaxManifestPages = 100
+
+	// containerRegistryMaxQueuedDeletes is how many untagged manifests are added to the pending deletes at once,
+	// the pending delete list only holds as many items as fit on the screen
+	containerRegistryMaxQueuedDeletes = 20
+)
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p *ociPlatform) String() string {
+	if p == nil {
+		return ""
+	}
+	platform := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		platform += "/" + p.Variant
+	}
+	return platform
+}
+
+type ociDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Platform     *ociPlatform      `json:"platform,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest covers both image manifests and indexes (including the docker equivalents)
+type ociManifest struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Config       *ociDescriptor    `json:"config,omitempty"`
+	Layers       []ociDescriptor   `json:"layers,omitempty"`
+	Manifests    []ociDescriptor   `json:"manifests,omitempty"`
+	Subject      *ociDescriptor    `json:"subject,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+func (m *ociManifest) isIndex() bool {
+	return len(m.Manifests) > 0 ||
+		m.MediaType == "application/vnd.oci.image.index.v1+json" ||
+		m.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
+}
+
+type ociImageConfig struct {
+	Created      string `json:"created"`
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant"`
+	Config       struct {
+		User         string              `json:"User"`
+		Env          []string            `json:"Env"`
+		Entrypoint   []string            `json:"Entrypoint"`
+		Cmd          []string            `json:"Cmd"`
+		WorkingDir   string              `json:"WorkingDir"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Labels       map[string]string   `json:"Labels"`
+	} `json:"config"`
+}
+
+type acrManifestAttributes struct {
+	Digest         string   `json:"digest"`
+	ImageSize      int64    `json:"imageSize"`
+	CreatedTime    string   `json:"createdTime"`
+	LastUpdateTime string   `json:"lastUpdateTime"`
+	Architecture   string   `json:"architecture"`
+	OS             string   `json:"os"`
+	MediaType      string   `json:"mediaType"`
+	Tags           []string `json:"tags"`
+}
+
+type acrManifestAttributesResponse struct {
+	Manifest acrManifestAttributes `json:"manifest"`
+}
+
+type acrManifestListResponse struct {
+	Manifests []acrManifestAttributes `json:"manifests"`
+}
+
+func (e *ContainerRegistryExpander) expandRepositoryManifest(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	loginServer := currentItem.Metadata["loginServer"]
+	repository := currentItem.Metadata["repository"]
+	digest := currentItem.Metadata["digest"]
+
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:pull,metadata_read", repository))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	attributesBuf, err := e.doRequest(ctx, "GET", fmt.Sprintf("https://%s/acr/v1/%s/_manifests/%s", loginServer, repository, digest), accessToken)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	var attributes acrManifestAttributesResponse
+	if err = json.Unmarshal(attributesBuf, &attributes); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling manifest attributes: %s, %s", err, string(attributesBuf)),
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	manifestBuf, err := e.doRequestWithAccept(ctx, "GET", fmt.Sprintf("https://%s/v2/%s/manifests/%s", loginServer, repository, digest), accessToken, containerRegistryManifestAccept)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+	var manifest ociManifest
+	if err = json.Unmarshal(manifestBuf, &manifest); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling manifest: %s, %s", err, string(manifestBuf)),
+			SourceDescription: "ContainerRegistryExpander request",
+		}
+	}
+
+	var config *ociImageConfig
+	if !manifest.isIndex() && manifest.Config != nil && manifest.ArtifactType == "" {
+		configBuf, err := e.doRequest(ctx, "GET", fmt.Sprintf("https://%s/v2/%s/blobs/%s", loginServer, repository, manifest.Config.Digest), accessToken)
+		if err == nil {
+			config = &ociImageConfig{}
+			if json.Unmarshal(configBuf, config) != nil {
+				config = nil
+			}
+		}
+	}
+
+	// Registries without referrers support return a 404 so treat any failure as "no referrers"
+	referrers := []ociDescriptor{}
+	referrersBuf, err := e.doRequest(ctx, "GET", fmt.Sprintf("https://%s/v2/%s/referrers/%s", loginServer, repository, digest), accessToken)
+	if err == nil {
+		var referrersIndex ociManifest
+		if json.Unmarshal(referrersBuf, &referrersIndex) == nil {
+			referrers = referrersIndex.Manifests
+		}
+	}
+
+	createManifestNode := e.getCreateManifestNodeFunc(loginServer, repository)
+	newItems := []*TreeNode{}
+	for _, tag := range attributes.Manifest.Tags {
+		newItems = append(newItems, e.getCreateTagNodeFunc(loginServer, repository)(currentItem, tag))
+	}
+	if manifest.isIndex() {
+		for _, child := range manifest.Manifests {
+			node := createManifestNode(currentItem, child.Digest)
+			if platform := child.Platform.String(); platform != "" {
+				node.Display = platform + " " + style.Subtle(child.Digest)
+			}
+			newItems = append(newItems, node)
+		}
+	}
+	for _, referrer := range referrers {
+		node := createManifestNode(currentItem, referrer.Digest)
+		node.Display = "[" + referrer.ArtifactType + "] " + style.Subtle(referrer.Digest)
+		newItems = append(newItems, node)
+	}
+	newItems = append(newItems, &TreeNode{
+		Parentid:              currentItem.ID,
+		ID:                    currentItem.ID + "/<manifest>",
+		Namespace:             "containerRegistry",
+		Name:                  "Manifest JSON",
+		Display:               "Manifest JSON",
+		ItemType:              containerRegistryManifestJSONType,
+		ExpandURL:             ExpandURLNotSupported,
+		SuppressSwaggerExpand: true,
+		SuppressGenericExpand: true,
+		Metadata: map[string]string{
+			"Response": string(manifestBuf),
+		},
+	})
+
+	return ExpanderResult{
+		Err: nil,
+		Response: ExpanderResponse{
+			Response:     formatContainerImageSummary(repository, digest, &attributes.Manifest, &manifest, config, referrers),
+			ResponseType: interfaces.ResponsePlainText,
+		},
+		SourceDescription: "ContainerRegistryExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: true,
+	}
+}
+
+// formatContainerImageSummary describes a manifest, its layers and config for the content panel
+func formatContainerImageSummary(repository string, digest string, attributes *acrManifestAttributes, manifest *ociManifest, config *ociImageConfig, referrers []ociDescriptor) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s@%s\n\n", repository, digest)
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Media type:\t%s\n", manifest.MediaType)
+	if manifest.ArtifactType != "" {
+		fmt.Fprintf(w, "Artifact type:\t%s\n", manifest.ArtifactType)
+	}
+	if len(attributes.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(attributes.Tags, ", "))
+	} else {
+		fmt.Fprintf(w, "Tags:\t%s\n", "<untagged>")
+	}
+	if attributes.CreatedTime != "" {
+		fmt.Fprintf(w, "Pushed:\t%s\n", attributes.CreatedTime)
+	}
+	if attributes.LastUpdateTime != "" {
+		fmt.Fprintf(w, "Last updated:\t%s\n", attributes.LastUpdateTime)
+	}
+	if manifest.Subject != nil {
+		fmt.Fprintf(w, "Subject:\t%s\n", manifest.Subject.Digest)
+	}
+	w.Flush() //nolint: errcheck
+
+	if manifest.isIndex() {
+		b.WriteString("\nPlatforms\n")
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, child := range manifest.Manifests {
+			platform := child.Platform.String()
+			if platform == "" {
+				platform = "<unknown>"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", platform, formatBytes(child.Size), child.Digest)
+		}
+		w.Flush() //nolint: errcheck
+	} else {
+		var total int64
+		for _, layer := range manifest.Layers {
+			total += layer.Size
+		}
+		fmt.Fprintf(&b, "\nLayers (%d, %s compressed)\n", len(manifest.Layers), formatBytes(total))
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for i, layer := range manifest.Layers {
+			fmt.Fprintf(w, "  %d\t%s\t  %s\t\n", i+1, formatBytes(layer.Size), layer.Digest)
+		}
+		w.Flush() //nolint: errcheck
+	}
+
+	if config != nil {
+		b.WriteString("\nConfig\n")
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		platform := (&ociPlatform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}).String()
+		fmt.Fprintf(w, "  Platform:\t%s\n", platform)
+		if config.Created != "" {
+			fmt.Fprintf(w, "  Created:\t%s\n", config.Created)
+		}
+		if len(config.Config.Entrypoint) > 0 {
+			fmt.Fprintf(w, "  Entrypoint:\t%s\n", formatContainerCommand(config.Config.Entrypoint))
+		}
+		if len(config.Config.Cmd) > 0 {
+			fmt.Fprintf(w, "  Cmd:\t%s\n", formatContainerCommand(config.Config.Cmd))
+		}
+		if config.Config.WorkingDir != "" {
+			fmt.Fprintf(w, "  WorkingDir:\t%s\n", config.Config.WorkingDir)
+		}
+		if config.Config.User != "" {
+			fmt.Fprintf(w, "  User:\t%s\n", config.Config.User)
+		}
+		if len(config.Config.ExposedPorts) > 0 {
+			fmt.Fprintf(w, "  Ports:\t%s\n", strings.Join(sortedKeys(config.Config.ExposedPorts), ", "))
+		}
+		w.Flush() //nolint: errcheck
+
+		if len(config.Config.Env) > 0 {
+			b.WriteString("\n  Env\n")
+			for _, env := range config.Config.Env {
+				fmt.Fprintf(&b, "    %s\n", env)
+			}
+		}
+		if len(config.Config.Labels) > 0 {
+			b.WriteString("\n  Labels\n")
+			for _, key := range sortedKeys(config.Config.Labels) {
+				fmt.Fprintf(&b, "    %s=%s\n", key, config.Config.Labels[key])
+			}
+		}
+	}
+
+	if len(referrers) > 0 {
+		b.WriteString("\nReferrers\n")
+		w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, referrer := range referrers {
+			fmt.Fprintf(w, "  %s\t%s\n", referrer.ArtifactType, referrer.Digest)
+		}
+		w.Flush() //nolint: errcheck
+	}
+
+	return b.String()
+}
+
+func formatContainerCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HasActions returns true for repositories
+func (e *ContainerRegistryExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == "containerRegistry.repository", nil
+}
+
+// ListActions returns the clean-up actions for repositories
+func (e *ContainerRegistryExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	metadata := copyMetadata(item.Metadata)
+	metadata["ActionID"] = containerRegistryActionFindUntagged
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?" + containerRegistryActionFindUntagged,
+				Namespace:              "containerRegistry",
+				Name:                   "Find untagged manifests",
+				Display:                "Find untagged manifests",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata:               metadata,
+			},
+		},
+		SourceDescription: "ContainerRegistryExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the repository clean-up actions
+func (e *ContainerRegistryExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case containerRegistryActionFindUntagged:
+		return e.findUntaggedManifests(ctx, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "ContainerRegistryExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "ContainerRegistryExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *ContainerRegistryExpander) findUntaggedManifests(ctx context.Context, item *TreeNode) ExpanderResult {
+	loginServer := item.Metadata["loginServer"]
+	repository := item.Metadata["repository"]
+
+	daysText, _ := promptInCommandPanel(e.gui, e.commandPanel, "untagged for more than (days):", "30", nil)
+	days, err := strconv.Atoi(daysText)
+	if err != nil || days < 0 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Days must be a number, 0 or more: %q", daysText),
+			SourceDescription: "ContainerRegistryExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	accessToken, err := e.getRegistryToken(ctx, loginServer, fmt.Sprintf("repository:%s:pull,metadata_read", repository))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	manifests, complete, err := e.listManifests(ctx, loginServer, repository, accessToken)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	candidates := selectUntaggedManifests(manifests, cutoff, nil)
+
+	protected, err := e.findProtectedManifests(ctx, loginServer, repository, accessToken, manifests, candidates)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ContainerRegistryExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	candidates = selectUntaggedManifests(manifests, cutoff, protected)
+
+	// List the manifests under the repository, as the action node isn't a real parent
+	repositoryNode := &TreeNode{ID: item.Parentid}
+	createManifestNode := e.getCreateManifestNodeFunc(loginServer, repository)
+	nodes := []*TreeNode{}
+	for _, candidate := range candidates {
+		node := createManifestNode(repositoryNode, candidate.Digest)
+		node.Expander = e
+		nodes = append(nodes, node)
+	}
+
+	title := fmt.Sprintf("Untagged manifests in %s older than %d days", repository, days)
+	summary := formatUntaggedManifests(candidates, len(manifests))
+	if !complete {
+		// Indexes beyond the listed manifests weren't read so some candidates may still be in use
+		summary += fmt.Sprintf("\nOnly the first %d pages of manifests were listed, so these can't be queued for deletion as indexes after them may still use them\n", containerRegistryMaxManifestPages)
+	} else if len(candidates) > 0 {
+		options := []interfaces.CommandPanelListOption{
+			{ID: "list", DisplayText: "Only list the manifests"},
+			{ID: "queue", DisplayText: fmt.Sprintf("Add %d manifests to the pending deletes", min(len(nodes), containerRegistryMaxQueuedDeletes))},
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "queue untagged manifests for deletion?", "", &options); selected == "queue" {
+			queued := queueManifestDeletes(nodes)
+			summary += fmt.Sprintf("\nAdded %d of %d manifests to the pending deletes, confirm the pending deletes to delete them", queued, len(nodes))
+			if queued < len(nodes) {
+				summary += " and then run this action again for the rest"
+			}
+			summary += "\n"
+		}
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     summary,
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        title,
+		},
+		Nodes:             nodes,
+		SourceDescription: "ContainerRegistryExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// listManifests lists the manifests in a repository, returning false if containerRegistryMaxManifestPages was
+// reached before the end of the list
+func (e *ContainerRegistryExpander) listManifests(ctx context.Context, loginServer string, repository string, accessToken string) ([]acrManifestAttributes, bool, error) {
+	manifests := []acrManifestAttributes{}
+	last := ""
+	for page := 0; page < containerRegistryMaxManifestPages; page++ {
+		listURL := fmt.Sprintf("https://%s/acr/v1/%s/_manifests?n=1000", loginServer, repository)
+		if last != "" {
+			listURL += "&last=" + url.QueryEscape(last)
+		}
+		buf, err := e.doRequest(ctx, "GET", listURL, accessToken)
+		if err != nil {
+			return nil, false, err
+		}
+		var list acrManifestListResponse
+		if err = json.Unmarshal(buf, &list); err != nil {
+			return nil, false, fmt.Errorf("Error unmarshalling manifests response: %s, %s", err, string(buf))
+		}
+		if len(list.Manifests) == 0 {
+			return manifests, true, nil
+		}
+		manifests = append(manifests, list.Manifests...)
+		last = list.Manifests[len(list.Manifests)-1].Digest
+	}
+	return manifests, false, nil
+}
+
+// findProtectedManifests returns the digests which shouldn't be deleted. Platform images are untagged when pushed
+// as part of an index and signatures/SBOMs are untagged artifacts attached to a subject, so the indexes which are
+// kept and the candidates are read to find them
+func (e *ContainerRegistryExpander) findProtectedManifests(ctx context.Context, loginServer string, repository string, accessToken string, manifests []acrManifestAttributes, candidates []acrManifestAttributes) (map[string]bool, error) {
+	protected := map[string]bool{}
+	isCandidate := map[string]bool{}
+	for _, candidate := range candidates {
+		isCandidate[candidate.Digest] = true
+	}
+	for _, m := range manifests {
+		// Any manifest can have a subject, e.g. signatures stored as artifact manifests, so read every candidate
+		checkIndex := !isCandidate[m.Digest] && containerRegistryIsIndexMediaType(m.MediaType)
+		checkSubject := isCandidate[m.Digest]
+		if !checkIndex && !checkSubject {
+			continue
+		}
+		buf, err := e.doRequestWithAccept(ctx, "GET", fmt.Sprintf("https://%s/v2/%s/manifests/%s", loginServer, repository, m.Digest), accessToken, containerRegistryManifestAccept)
+		if err != nil {
+			return nil, err
+		}
+		var manifest ociManifest
+		if err = json.Unmarshal(buf, &manifest); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling manifest: %s, %s", err, string(buf))
+		}
+		if checkIndex {
+			for _, child := range manifest.Manifests {
+				protected[child.Digest] = true
+			}
+		}
+		if checkSubject && manifest.Subject != nil {
+			protected[m.Digest] = true
+		}
+	}
+	return protected, nil
+}
+
+// queueManifestDeletes adds the first containerRegistryMaxQueuedDeletes manifests to the pending deletes,
+// returning how many were added
+func queueManifestDeletes(nodes []*TreeNode) int {
+	queued := nodes[:min(len(nodes), containerRegistryMaxQueuedDeletes)]
+	for _, node := range queued {
+		// The notifications widget shows the pending deletes and deletes them once confirmed
+		eventing.Publish("notifications.pendingdelete", node)
+	}
+	return len(queued)
+}
+
+func containerRegistryIsIndexMediaType(mediaType string) bool {
+	return mediaType == "application/vnd.oci.image.index.v1+json" ||
+		mediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
+}
+
+// selectUntaggedManifests returns the untagged manifests last updated before the cutoff, oldest first,
+// skipping any digests that are protected (e.g. referenced by an index that is being kept)
+func selectUntaggedManifests(manifests []acrManifestAttributes, cutoff time.Time, protected map[string]bool) []acrManifestAttributes {
+	result := []acrManifestAttributes{}
+	for _, m := range manifests {
+		if len(m.Tags) > 0 || protected[m.Digest] {
+			continue
+		}
+		updated, err := time.Parse(time.RFC3339Nano, m.LastUpdateTime)
+		if err != nil || !updated.Before(cutoff) {
+			continue
+		}
+		result = append(result, m)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastUpdateTime < result[j].LastUpdateTime
+	})
+	return result
+}
+
+func formatUntaggedManifests(candidates []acrManifestAttributes, scanned int) string {
+	if len(candidates) == 0 {
+		return fmt.Sprintf("No untagged manifests found (%d manifests checked)", scanned)
+	}
+	var b strings.Builder
+	var total int64
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LAST UPDATED\tSIZE\tPLATFORM\tDIGEST")
+	for _, m := range candidates {
+		total += m.ImageSize
+		platform := ""
+		if m.OS != "" {
+			platform = m.OS + "/" + m.Architecture
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.LastUpdateTime, formatBytes(m.ImageSize), platform, m.Digest)
+	}
+	w.Flush() //nolint: errcheck
+	fmt.Fprintf(&b, "\n%d of %d manifests untagged, %s in total\n", len(candidates), scanned, formatBytes(total))
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func Test_selectUntaggedManifests(t *testing.T) {
+	cutoff := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	manifests := []acrManifestAttributes{
+		{Digest: "sha256:tagged", LastUpdateTime: "2023-01-01T00:00:00Z", Tags: []string{"latest"}},
+		{Digest: "sha256:recent", LastUpdateTime: "2023-06-02T00:00:00Z"},
+		{Digest: "sha256:newer", LastUpdateTime: "2023-05-01T10:00:00.1234567Z"},
+		{Digest: "sha256:older", LastUpdateTime: "2023-02-01T00:00:00Z"},
+		{Digest: "sha256:platform", LastUpdateTime: "2023-01-01T00:00:00Z"},
+		{Digest: "sha256:bad-date", LastUpdateTime: "not a date"},
+	}
+
+	result := selectUntaggedManifests(manifests, cutoff, map[string]bool{"sha256:platform": true})
+
+	digests := []string{}
+	for _, m := range result {
+		digests = append(digests, m.Digest)
+	}
+	assert.Equal(t, []string{"sha256:older", "sha256:newer"}, digests)
+}
+
+func Test_formatContainerImageSummary_Image(t *testing.T) {
+	manifest := &ociManifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Layers: []ociDescriptor{
+			{Digest: "sha256:layer1", Size: 1024},
+			{Digest: "sha256:layer2", Size: 2048},
+		},
+	}
+	config := &ociImageConfig{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	config.Config.Entrypoint = []string{"/app", "--serve"}
+	config.Config.Labels = map[string]string{"b": "2", "a": "1"}
+
+	summary := formatContainerImageSummary("web", "sha256:abc", &acrManifestAttributes{}, manifest, config,
+		[]ociDescriptor{{Digest: "sha256:sig", ArtifactType: "application/vnd.cncf.notary.signature"}})
+
+	assert.Contains(t, summary, "<untagged>")
+	assert.Contains(t, summary, "Layers (2, 3.0 KiB compressed)")
+	assert.Contains(t, summary, "linux/arm64/v8")
+	assert.Contains(t, summary, `["/app", "--serve"]`)
+	assert.Less(t, strings.Index(summary, "a=1"), strings.Index(summary, "b=2"))
+	assert.Contains(t, summary, "application/vnd.cncf.notary.signature")
+}
+
+func Test_formatContainerImageSummary_Index(t *testing.T) {
+	manifest := &ociManifest{
+		MediaType: "application/vnd.oci.image.index.v1+json",
+		Manifests: []ociDescriptor{
+			{Digest: "sha256:amd", Size: 500, Platform: &ociPlatform{OS: "linux", Architecture: "amd64"}},
+			{Digest: "sha256:unknown", Size: 500},
+		},
+	}
+
+	summary := formatContainerImageSummary("web", "sha256:abc", &acrManifestAttributes{Tags: []string{"1.0", "latest"}}, manifest, nil, nil)
+
+	assert.Contains(t, summary, "1.0, latest")
+	assert.Contains(t, summary, "Platforms")
+	assert.Contains(t, summary, "linux/amd64")
+	assert.Contains(t, summary, "<unknown>")
+	assert.NotContains(t, summary, "Layers")
+}
+
+func Test_ContainerRegistry_findProtectedManifests(t *testing.T) {
+	manifests := []acrManifestAttributes{
+		{Digest: "sha256:index", MediaType: "application/vnd.oci.image.index.v1+json", Tags: []string{"v1"}},
+		{Digest: "sha256:platform", MediaType: "application/vnd.oci.image.manifest.v1+json"},
+		{Digest: "sha256:signature", MediaType: "application/vnd.oci.artifact.manifest.v1+json"},
+		{Digest: "sha256:orphan", MediaType: "application/vnd.docker.distribution.manifest.v2+json"},
+	}
+
+	defer gock.Off()
+	manifestURL := "/v2/app/manifests/"
+	gock.New("https://myreg.azurecr.io").
+		Get(manifestURL + "sha256:index").
+		Reply(200).
+		JSON(`{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": [{"digest": "sha256:platform"}]}`)
+	gock.New("https://myreg.azurecr.io").
+		Get(manifestURL + "sha256:platform").
+		Reply(200).
+		JSON(`{"mediaType": "application/vnd.oci.image.manifest.v1+json"}`)
+	gock.New("https://myreg.azurecr.io").
+		Get(manifestURL+"sha256:signature").
+		MatchHeader("Accept", "application/vnd.oci.artifact.manifest.v1\\+json").
+		Reply(200).
+		JSON(`{"mediaType": "application/vnd.oci.artifact.manifest.v1+json", "subject": {"digest": "sha256:index"}}`)
+	gock.New("https://myreg.azurecr.io").
+		Get(manifestURL + "sha256:orphan").
+		Reply(200).
+		JSON(`{"mediaType": "application/vnd.docker.distribution.manifest.v2+json"}`)
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+
+	e := &ContainerRegistryExpander{client: httpClient}
+	protected, err := e.findProtectedManifests(context.Background(), "myreg.azurecr.io", "app", "token", manifests, manifests[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"sha256:platform": true, "sha256:signature": true}, protected)
+	assert.True(t, gock.IsDone())
+}
+
+func Test_ContainerRegistry_listManifests(t *testing.T) {
+	defer gock.Off()
+	listURL := "/acr/v1/app/_manifests"
+	gock.New("https://myreg.azurecr.io").
+		Get(listURL).
+		MatchParam("n", "1000").
+		Reply(200).
+		JSON(`{"manifests": [{"digest": "sha256:one"}, {"digest": "sha256:two"}]}`)
+	gock.New("https://myreg.azurecr.io").
+		Get(listURL).
+		MatchParam("last", "sha256:two").
+		Reply(200).
+		JSON(`{"manifests": []}`)
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+
+	e := &ContainerRegistryExpander{client: httpClient}
+	manifests, complete, err := e.listManifests(context.Background(), "myreg.azurecr.io", "app", "token")
+	assert.NoError(t, err)
+	assert.True(t, complete)
+	assert.Len(t, manifests, 2)
+	assert.True(t, gock.IsDone())
+
+	// Reaching the page limit is reported so the manifests aren't treated as the whole repository
+	gock.New("https://myreg.azurecr.io").
+		Get(listURL).
+		Times(containerRegistryMaxManifestPages).
+		Reply(200).
+		JSON(`{"manifests": [{"digest": "sha256:more"}]}`)
+	manifests, complete, err = e.listManifests(context.Background(), "myreg.azurecr.io", "app", "token")
+	assert.NoError(t, err)
+	assert.False(t, complete)
+	assert.Len(t, manifests, containerRegistryMaxManifestPages)
+	assert.True(t, gock.IsDone())
+}
+
+func Test_queueManifestDeletes(t *testing.T) {
+	pendingDeletes := eventing.SubscribeToTopic("notifications.pendingdelete")
+	defer eventing.Unsubscribe(pendingDeletes)
+
+	nodes := []*TreeNode{}
+	for i := 0; i < containerRegistryMaxQueuedDeletes+5; i++ {
+		nodes = append(nodes, &TreeNode{ID: fmt.Sprintf("/repo/manifest%d", i)})
+	}
+	assert.Equal(t, containerRegistryMaxQueuedDeletes, queueManifestDeletes(nodes))
+
+	// The oldest manifests are queued first
+	for i := 0; i < containerRegistryMaxQueuedDeletes; i++ {
+		select {
+		case item := <-pendingDeletes:
+			assert.Equal(t, nodes[i], item)
+		case <-time.After(time.Second):
+			t.Fatalf("Manifest %d wasn't queued", i)
+		}
+	}
+	select {
+	case item := <-pendingDeletes:
+		t.Errorf("Unexpected pending delete %v", item)
+	default:
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/awesome-gocui/gocui"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
@@ -21,10 +22,12 @@ type containerRegistryResponse struct {
 }
 
 // NewContainerRegistryExpander creates a new instance of ContainerRegistryExpander
-func NewContainerRegistryExpander(armclient *armclient.Client) *ContainerRegistryExpander {
+func NewContainerRegistryExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *ContainerRegistryExpander {
 	return &ContainerRegistryExpander{
-		client:    &http.Client{},
-		armClient: armclient,
+		client:       &http.Client{},
+		armClient:    armclient,
+		gui:          gui,
+		commandPanel: commandPanel,
 	}
 }
 
@@ -38,8 +41,10 @@ func (e *ContainerRegistryExpander) setClient(c *armclient.Client) {
 // ContainerRegistryExpander expands Tthe data-plane aspects of a Container Registry
 type ContainerRegistryExpander struct {
 	ExpanderBase
-	client    *http.Client
-	armClient *armclient.Client
+	client       *http.Client
+	armClient    *armclient.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
 }
 
 // Name returns the name of the expander
@@ -105,6 +110,12 @@ func (e *ContainerRegistryExpander) Expand(ctx context.Context, currentItem *Tre
 		return e.expandRepositoryManifests(ctx, currentItem)
 	} else if currentItem.ItemType == "containerRegistry.repository.manifest" {
 		return e.expandRepositoryManifest(ctx, currentItem)
+	} else if currentItem.ItemType == containerRegistryManifestJSONType {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Response"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "ContainerRegistryExpander request",
+			IsPrimaryResponse: true,
+		}
 	}
 
 	return ExpanderResult{
@@ -323,21 +334,6 @@ func (e *ContainerRegistryExpander) expandRepositoryManifests(ctx context.Contex
 		e.getCreateManifestsNodeFunc(loginServer, repository, "more..."))
 }
 
-func (e *ContainerRegistryExpander) expandRepositoryManifest(ctx context.Context, currentItem *TreeNode) ExpanderResult {
-	loginServer := currentItem.Metadata["loginServer"]
-	repository := currentItem.Metadata["repository"]
-	digest := currentItem.Metadata["digest"]
-
-	return e.expandNode(
-		ctx,
-		currentItem,
-		fmt.Sprintf("https://%s/v2/%s/manifests/%s", loginServer, repository, digest),
-		fmt.Sprintf("repository:%s:metadata_read", repository),
-		"manifest.tags",
-		"",
-		e.getCreateTagNodeFunc(loginServer, repository),
-		nil)
-}
 func (e *ContainerRegistryExpander) deleteRepositoryManifest(ctx context.Context, currentItem *TreeNode) (bool, error) {
 
 	loginServer := currentItem.Metadata["loginServer"]
@@ -551,6 +547,10 @@ func (e *ContainerRegistryExpander) getItemsForURL(ctx context.Context, url stri
 }
 
 func (e *ContainerRegistryExpander) doRequest(ctx context.Context, verb string, url string, accessToken string) ([]byte, error) {
+	return e.doRequestWithAccept(ctx, verb, url, accessToken, "")
+}
+
+func (e *ContainerRegistryExpander) doRequestWithAccept(ctx context.Context, verb string, url string, accessToken string, accept string) ([]byte, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(containerregistry):"+url, tracing.SetTag("url", url))
 	defer span.Finish()
 
@@ -559,6 +559,9 @@ func (e *ContainerRegistryExpander) doRequest(ctx context.Context, verb string,
 		return []byte{}, fmt.Errorf("Failed to create request: %s", err)
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 
 	response, err := e.client.Do(req.WithContext(ctx))
 	if err != nil {
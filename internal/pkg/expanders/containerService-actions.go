@@ -0,0 +1,346 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+)
+
+const kubernetesPodTemplateURL = "/api/v1/namespaces/{namespace}/pods/{name}"
+
+const (
+	kubernetesActionPreviousLogs   = "aks-previous-logs"
+	kubernetesActionScale          = "aks-scale"
+	kubernetesActionRestartRollout = "aks-restart-rollout"
+)
+
+// kubernetesWorkloadTemplateRegex matches the template URLs for workloads that can be scaled or restarted
+var kubernetesWorkloadTemplateRegex = regexp.MustCompile(`^/apis/apps/v1/namespaces/\{namespace\}/(deployments|statefulsets|replicasets|daemonsets)/\{name\}$`)
+
+type kubernetesScaleResponse struct {
+	Spec struct {
+		Replicas int `yaml:"replicas"`
+	} `yaml:"spec"`
+}
+
+// IsKubernetesPod returns true for pod nodes, which the follow pod logs command is enabled for
+func IsKubernetesPod(item *TreeNode) bool {
+	return item.Namespace == "swagger" &&
+		item.SwaggerResourceType != nil &&
+		item.SwaggerResourceType.Endpoint.TemplateURL == kubernetesPodTemplateURL &&
+		getKubernetesAPISet(item) != nil
+}
+
+// kubernetesWorkloadKind returns the plural kind (e.g. deployments) for workload nodes, otherwise ""
+func kubernetesWorkloadKind(item *TreeNode) string {
+	if item.Namespace != "swagger" || item.SwaggerResourceType == nil || getKubernetesAPISet(item) == nil {
+		return ""
+	}
+	match := kubernetesWorkloadTemplateRegex.FindStringSubmatch(item.SwaggerResourceType.Endpoint.TemplateURL)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// HasActions returns true for pods and workloads
+func (e *AzureKubernetesServiceExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	return IsKubernetesPod(item) || kubernetesWorkloadKind(item) != "", nil
+}
+
+// ListActions returns the previous logs action for pods and the scale and restart actions for workloads
+func (e *AzureKubernetesServiceExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	actions := []struct{ id, name string }{}
+	if IsKubernetesPod(item) {
+		// Following the current logs is a command (see keybindings) so the stream can use the shared log view
+		actions = append(actions, struct{ id, name string }{kubernetesActionPreviousLogs, "Logs (previous instance)"})
+	}
+	switch kubernetesWorkloadKind(item) {
+	case "deployments", "statefulsets":
+		actions = append(actions,
+			struct{ id, name string }{kubernetesActionScale, "Scale"},
+			struct{ id, name string }{kubernetesActionRestartRollout, "Restart rollout"},
+		)
+	case "replicasets":
+		actions = append(actions, struct{ id, name string }{kubernetesActionScale, "Scale"})
+	case "daemonsets":
+		actions = append(actions, struct{ id, name string }{kubernetesActionRestartRollout, "Restart rollout"})
+	}
+
+	nodes := []*TreeNode{}
+	for _, action := range actions {
+		metadata := copyMetadata(item.Metadata)
+		metadata["ActionID"] = action.id
+		metadata["ObjectURL"] = item.ExpandURL
+		nodes = append(nodes, &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + action.id,
+			Namespace:              "AzureKubernetesService",
+			Name:                   action.name,
+			Display:                action.name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               metadata,
+		})
+	}
+
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "AzureKubernetesServiceExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the pod and workload actions
+func (e *AzureKubernetesServiceExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case kubernetesActionPreviousLogs:
+		return e.showPreviousLogs(ctx, item)
+	case kubernetesActionScale:
+		return e.scaleWorkload(ctx, item)
+	case kubernetesActionRestartRollout:
+		return e.restartRollout(ctx, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "AzureKubernetesServiceExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "AzureKubernetesServiceExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *AzureKubernetesServiceExpander) showPreviousLogs(ctx context.Context, item *TreeNode) ExpanderResult {
+	apiSet := getKubernetesAPISet(item)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cluster API not found for %s", item.ID),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	podURL := apiSet.serverURL + item.Metadata["ObjectURL"]
+	container, err := e.pickContainer(ctx, apiSet, podURL)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	query := url.Values{}
+	query.Set("container", container)
+	query.Set("tailLines", strconv.Itoa(maxTailLines))
+	query.Set("previous", "true")
+	data, err := apiSet.doRequest(ctx, "GET", podURL+"/log?"+query.Encode())
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get previous logs: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     data,
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        fmt.Sprintf("Logs: %s/%s (previous instance)", lastSegment(item.Metadata["ObjectURL"]), container),
+		},
+		SourceDescription: "AzureKubernetesServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// GetKubernetesPodContainers returns an option for each of the pod's containers, for picking the container
+// to follow the logs of
+func GetKubernetesPodContainers(ctx context.Context, item *TreeNode) ([]interfaces.CommandPanelListOption, error) {
+	apiSet := getKubernetesAPISet(item)
+	if apiSet == nil {
+		return nil, fmt.Errorf("Cluster API not found for %s", item.ID)
+	}
+	return getKubernetesContainerOptions(ctx, apiSet, apiSet.serverURL+item.ExpandURL)
+}
+
+// StreamKubernetesPodLogs follows the logs of a container in the pod, starting with the last maxTailLines lines.
+// The stream is closed when ctx is cancelled
+func StreamKubernetesPodLogs(ctx context.Context, item *TreeNode, container string) (io.ReadCloser, error) {
+	apiSet := getKubernetesAPISet(item)
+	if apiSet == nil {
+		return nil, fmt.Errorf("Cluster API not found for %s", item.ID)
+	}
+	query := url.Values{}
+	query.Set("container", container)
+	query.Set("tailLines", strconv.Itoa(maxTailLines))
+	query.Set("follow", "true")
+	return apiSet.stream(ctx, apiSet.serverURL+item.ExpandURL+"/log?"+query.Encode())
+}
+
+// pickContainer returns the pod's container, prompting for one when the pod has several
+func (e *AzureKubernetesServiceExpander) pickContainer(ctx context.Context, apiSet *SwaggerAPISetContainerService, podURL string) (string, error) {
+	options, err := getKubernetesContainerOptions(ctx, apiSet, podURL)
+	if err != nil {
+		return "", err
+	}
+	if len(options) == 1 {
+		return options[0].ID, nil
+	}
+	_, container := promptInCommandPanel(e.gui, e.commandPanel, "container:", "", &options)
+	if container == "" {
+		return "", fmt.Errorf("No container selected")
+	}
+	return container, nil
+}
+
+// getKubernetesContainerOptions returns an option for each container in the pod, with init containers last
+func getKubernetesContainerOptions(ctx context.Context, apiSet *SwaggerAPISetContainerService, podURL string) ([]interfaces.CommandPanelListOption, error) {
+	data, err := apiSet.doRequest(ctx, "GET", podURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get pod: %s", err)
+	}
+	var podInfo podResponse
+	if err = yaml.Unmarshal([]byte(data), &podInfo); err != nil {
+		return nil, fmt.Errorf("Error parsing YAML response: %s", err)
+	}
+
+	restarts := map[string]int{}
+	for _, status := range podInfo.Status.ContainerStatuses {
+		restarts[status.Name] = status.RestartCount
+	}
+	options := []interfaces.CommandPanelListOption{}
+	for _, container := range podInfo.Spec.Containers {
+		options = append(options, interfaces.CommandPanelListOption{
+			ID:          container.Name,
+			DisplayText: fmt.Sprintf("%s (%d restarts)", container.Name, restarts[container.Name]),
+		})
+	}
+	for _, container := range podInfo.Spec.InitContainers {
+		options = append(options, interfaces.CommandPanelListOption{
+			ID:          container.Name,
+			DisplayText: container.Name + " (init)",
+		})
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("No containers in pod")
+	}
+	return options, nil
+}
+
+func (e *AzureKubernetesServiceExpander) scaleWorkload(ctx context.Context, item *TreeNode) ExpanderResult {
+	apiSet := getKubernetesAPISet(item)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cluster API not found for %s", item.ID),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	scaleURL := apiSet.serverURL + item.Metadata["ObjectURL"] + "/scale"
+	data, err := apiSet.doRequest(ctx, "GET", scaleURL)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to get current scale: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	var scale kubernetesScaleResponse
+	if err = yaml.Unmarshal([]byte(data), &scale); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error parsing YAML response: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	replicasText, _ := promptInCommandPanel(e.gui, e.commandPanel, "replicas:", strconv.Itoa(scale.Spec.Replicas), nil)
+	replicas, err := strconv.Atoi(replicasText)
+	if err != nil || replicas < 0 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Replicas must be a number, 0 or more: %q", replicasText),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	body := fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)
+	data, err = apiSet.doRequestWithContentType(ctx, "PATCH", scaleURL, body, "application/merge-patch+json")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to scale: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     data,
+			ResponseType: interfaces.ResponseYAML,
+			Title:        fmt.Sprintf("Scaled %s from %d to %d replicas", lastSegment(item.Metadata["ObjectURL"]), scale.Spec.Replicas, replicas),
+		},
+		SourceDescription: "AzureKubernetesServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// restartRollout restarts the pods in the same way as 'kubectl rollout restart', by changing an annotation on the pod template
+func (e *AzureKubernetesServiceExpander) restartRollout(ctx context.Context, item *TreeNode) ExpanderResult {
+	apiSet := getKubernetesAPISet(item)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cluster API not found for %s", item.ID),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	name := lastSegment(item.Metadata["ObjectURL"])
+	options := []interfaces.CommandPanelListOption{
+		{ID: "restart", DisplayText: "Restart " + name},
+		{ID: "cancel", DisplayText: "Cancel"},
+	}
+	if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "restart rollout?", "", &options); selected != "restart" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Restart cancelled"),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	body := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`, time.Now().Format(time.RFC3339))
+	data, err := apiSet.doRequestWithContentType(ctx, "PATCH", apiSet.serverURL+item.Metadata["ObjectURL"], body, "application/strategic-merge-patch+json")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to restart rollout: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     data,
+			ResponseType: interfaces.ResponseYAML,
+			Title:        "Restarted rollout of " + name,
+		},
+		SourceDescription: "AzureKubernetesServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
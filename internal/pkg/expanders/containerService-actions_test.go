@@ -0,0 +1,39 @@
+package expanders
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getKubernetesContainerOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/default/pods/web", r.URL.Path)
+		_, _ = io.WriteString(w, `spec:
+  containers:
+  - name: app
+  - name: sidecar
+  initContainers:
+  - name: migrate
+status:
+  containerStatuses:
+  - name: app
+    restartCount: 3
+`)
+	}))
+	defer ts.Close()
+
+	c := NewSwaggerAPISetContainerService(nil, *ts.Client(), "cluster", ts.URL)
+	options, err := getKubernetesContainerOptions(context.Background(), &c, ts.URL+"/api/v1/namespaces/default/pods/web")
+	assert.NoError(t, err)
+	assert.Equal(t, []interfaces.CommandPanelListOption{
+		{ID: "app", DisplayText: "app (3 restarts)"},
+		{ID: "sidecar", DisplayText: "sidecar (0 restarts)"},
+		{ID: "migrate", DisplayText: "migrate (init)"},
+	}, options)
+}
@@ -0,0 +1,276 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const kubernetesNodeEvents = "aks-events"
+
+const kubernetesNamespaceTemplateURL = "/api/v1/namespaces/{name}"
+
+// kubernetesObjectTemplateRegex matches the template URLs for a single namespaced object, e.g. /api/v1/namespaces/{namespace}/pods/{name}
+var kubernetesObjectTemplateRegex = regexp.MustCompile(`^/apis?/.+/namespaces/\{namespace\}/([^/]+)/\{name\}$`)
+
+type kubernetesEventListResponse struct {
+	Items []kubernetesEvent `yaml:"items"`
+}
+
+type kubernetesEvent struct {
+	Metadata struct {
+		CreationTimestamp string `yaml:"creationTimestamp"`
+	} `yaml:"metadata"`
+	InvolvedObject struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"involvedObject"`
+	Reason         string `yaml:"reason"`
+	Message        string `yaml:"message"`
+	Type           string `yaml:"type"`
+	Count          int    `yaml:"count"`
+	LastTimestamp  string `yaml:"lastTimestamp"`
+	EventTime      string `yaml:"eventTime"`
+	FirstTimestamp string `yaml:"firstTimestamp"`
+}
+
+type kubernetesObjectMetadataResponse struct {
+	Metadata struct {
+		UID string `yaml:"uid"`
+	} `yaml:"metadata"`
+}
+
+// getKubernetesAPISet returns the cluster API set for a node browsed under the 'Kubernetes API' node
+func getKubernetesAPISet(node *TreeNode) *SwaggerAPISetContainerService {
+	if node.Metadata == nil || !strings.HasSuffix(node.Metadata["SwaggerAPISetID"], "/<k8sapi>") {
+		return nil
+	}
+	swaggerAPISet := GetSwaggerResourceExpander().GetAPISet(node.Metadata["SwaggerAPISetID"])
+	if swaggerAPISet == nil {
+		return nil
+	}
+	apiSet, ok := (*swaggerAPISet).(SwaggerAPISetContainerService)
+	if !ok {
+		return nil
+	}
+	return &apiSet
+}
+
+// kubernetesEventsTargetType returns "namespace" or "object" for cluster nodes that have events, otherwise ""
+func kubernetesEventsTargetType(node *TreeNode) string {
+	if node.Namespace != "swagger" || node.SwaggerResourceType == nil ||
+		node.Metadata == nil || !strings.HasSuffix(node.Metadata["SwaggerAPISetID"], "/<k8sapi>") {
+		return ""
+	}
+	templateURL := node.SwaggerResourceType.Endpoint.TemplateURL
+	if templateURL == kubernetesNamespaceTemplateURL {
+		return "namespace"
+	}
+	if match := kubernetesObjectTemplateRegex.FindStringSubmatch(templateURL); match != nil && match[1] != "events" {
+		return "object"
+	}
+	return ""
+}
+
+// newKubernetesEventsNode returns the Events node for a namespace or object, templateValues are from matching the node's ExpandURL
+func newKubernetesEventsNode(currentItem *TreeNode, templateValues map[string]string) *TreeNode {
+	targetType := kubernetesEventsTargetType(currentItem)
+	namespace := templateValues["namespace"]
+	if targetType == "namespace" {
+		namespace = templateValues["name"]
+	}
+
+	return &TreeNode{
+		Parentid:              currentItem.ID,
+		ID:                    currentItem.ID + "/<events>",
+		Namespace:             "AzureKubernetesService",
+		Name:                  "Events",
+		Display:               "Events",
+		ItemType:              kubernetesNodeEvents,
+		ExpandURL:             ExpandURLNotSupported,
+		SuppressSwaggerExpand: true,
+		SuppressGenericExpand: true,
+		Metadata: map[string]string{
+			"SwaggerAPISetID": currentItem.Metadata["SwaggerAPISetID"],
+			"EventsTarget":    targetType,
+			"EventsNamespace": namespace,
+			"ObjectURL":       currentItem.ExpandURL,
+		},
+	}
+}
+
+func (e *AzureKubernetesServiceExpander) expandEvents(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	apiSet := getKubernetesAPISet(currentItem)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cluster API not found for %s", currentItem.ID),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	eventsURL := apiSet.serverURL + "/api/v1/namespaces/" + currentItem.Metadata["EventsNamespace"] + "/events"
+	if currentItem.Metadata["EventsTarget"] == "object" {
+		// Filter by uid so that events for earlier objects with the same name aren't included
+		data, err := apiSet.doRequest(ctx, "GET", apiSet.serverURL+currentItem.Metadata["ObjectURL"])
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Failed to get object: %s", err),
+				SourceDescription: "AzureKubernetesServiceExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		var object kubernetesObjectMetadataResponse
+		if err = yaml.Unmarshal([]byte(data), &object); err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error parsing YAML response: %s", err),
+				SourceDescription: "AzureKubernetesServiceExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		eventsURL += "?fieldSelector=" + url.QueryEscape("involvedObject.uid="+object.Metadata.UID)
+	}
+
+	data, err := apiSet.doRequest(ctx, "GET", eventsURL)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to list events: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	var response kubernetesEventListResponse
+	if err = yaml.Unmarshal([]byte(data), &response); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error parsing YAML response: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	warnings := 0
+	for _, event := range response.Items {
+		if event.Type == "Warning" {
+			warnings++
+		}
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     formatKubernetesEvents(response.Items, time.Now()),
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        fmt.Sprintf("Events (%d, %d warnings)", len(response.Items), warnings),
+		},
+		SourceDescription: "AzureKubernetesServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// kubernetesEventTime returns the time the event was last seen
+func kubernetesEventTime(event kubernetesEvent) time.Time {
+	for _, value := range []string{event.LastTimestamp, event.EventTime, event.FirstTimestamp, event.Metadata.CreationTimestamp} {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// formatKubernetesEvents renders the events as a table with the most recent first and warnings highlighted
+func formatKubernetesEvents(events []kubernetesEvent, now time.Time) string {
+	if len(events) == 0 {
+		return "No events found"
+	}
+
+	sorted := append([]kubernetesEvent{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return kubernetesEventTime(sorted[i]).After(kubernetesEventTime(sorted[j]))
+	})
+
+	rows := [][]string{{"LAST SEEN", "TYPE", "REASON", "OBJECT", "COUNT", "MESSAGE"}}
+	for _, event := range sorted {
+		count := event.Count
+		if count == 0 {
+			count = 1
+		}
+		object := ""
+		if event.InvolvedObject.Name != "" {
+			object = strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name
+		}
+		rows = append(rows, []string{
+			kubernetesAge(kubernetesEventTime(event), now),
+			event.Type,
+			event.Reason,
+			object,
+			strconv.Itoa(count),
+			strings.ReplaceAll(strings.TrimSpace(event.Message), "\n", " "),
+		})
+	}
+
+	return formatKubernetesTable(rows, func(row []string, column int, cell string) string {
+		if column == 1 && row[1] == "Warning" {
+			return style.Warning(cell)
+		}
+		return cell
+	})
+}
+
+// formatKubernetesTable aligns the rows into columns, the highlight func can style a cell after it has been padded
+func formatKubernetesTable(rows [][]string, highlight func(row []string, column int, cell string) string) string {
+	widths := []int{}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	var buf strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(row)-1 {
+				cell = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+			if highlight != nil {
+				cell = highlight(row, i, cell)
+			}
+			buf.WriteString(cell)
+			if i < len(row)-1 {
+				buf.WriteString("  ")
+			}
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// kubernetesAge formats the time since t in the style of kubectl, e.g. 45s, 12m, 3h, 5d
+func kubernetesAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
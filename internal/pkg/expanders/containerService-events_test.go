@@ -0,0 +1,42 @@
+package expanders
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_formatKubernetesEvents_SortsMostRecentFirst(t *testing.T) {
+	now := time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)
+	events := []kubernetesEvent{
+		{Type: "Normal", Reason: "Pulled", Message: "Pulled image", LastTimestamp: "2023-01-02T11:00:00Z", Count: 1},
+		{Type: "Warning", Reason: "BackOff", Message: "Back-off restarting\nfailed container", LastTimestamp: "2023-01-02T11:59:30Z", Count: 12},
+		{Type: "Normal", Reason: "Scheduled", EventTime: "2023-01-01T10:00:00.123456Z"},
+	}
+	events[1].InvolvedObject.Kind = "Pod"
+	events[1].InvolvedObject.Name = "web-1"
+
+	lines := strings.Split(strings.TrimSpace(formatKubernetesEvents(events, now)), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "LAST SEEN"))
+	assert.Contains(t, lines[1], "BackOff")
+	assert.Contains(t, lines[1], "pod/web-1")
+	assert.Contains(t, lines[1], "Back-off restarting failed container")
+	assert.True(t, strings.HasPrefix(lines[1], "30s "))
+	assert.True(t, strings.HasPrefix(lines[2], "1h "))
+	assert.True(t, strings.HasPrefix(lines[3], "25h "))
+	assert.Equal(t, strings.Index(lines[0], "REASON"), strings.Index(lines[1], "BackOff"))
+
+	assert.Equal(t, "No events found", formatKubernetesEvents(nil, now))
+}
+
+func Test_kubernetesAge(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, "59s", kubernetesAge(now.Add(-59*time.Second), now))
+	assert.Equal(t, "5m", kubernetesAge(now.Add(-5*time.Minute), now))
+	assert.Equal(t, "47h", kubernetesAge(now.Add(-47*time.Hour), now))
+	assert.Equal(t, "3d", kubernetesAge(now.Add(-72*time.Hour), now))
+	assert.Equal(t, "<unknown>", kubernetesAge(time.Time{}, now))
+}
@@ -0,0 +1,443 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+)
+
+const (
+	kubernetesNodePodsOverview = "aks-pods-overview"
+	kubernetesNodeProblemPods  = "aks-problem-pods"
+)
+
+const kubernetesNodeTemplateURL = "/api/v1/nodes/{name}"
+
+// kubernetesProblemReasons are the pod statuses surfaced by the 'Problem Pods' view
+var kubernetesProblemReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"OOMKilled":                  true,
+	"Pending":                    true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+	"Error":                      true,
+	"Evicted":                    true,
+	"Failed":                     true,
+	"Unknown":                    true,
+}
+
+type kubernetesPodListResponse struct {
+	Items []kubernetesPod `yaml:"items"`
+}
+
+type kubernetesContainerState struct {
+	Waiting *struct {
+		Reason string `yaml:"reason"`
+	} `yaml:"waiting"`
+	Terminated *struct {
+		Reason string `yaml:"reason"`
+	} `yaml:"terminated"`
+}
+
+type kubernetesPod struct {
+	Metadata struct {
+		Name              string            `yaml:"name"`
+		Namespace         string            `yaml:"namespace"`
+		CreationTimestamp string            `yaml:"creationTimestamp"`
+		DeletionTimestamp string            `yaml:"deletionTimestamp"`
+		Labels            map[string]string `yaml:"labels"`
+		OwnerReferences   []struct {
+			Kind string `yaml:"kind"`
+			Name string `yaml:"name"`
+		} `yaml:"ownerReferences"`
+	} `yaml:"metadata"`
+	Spec struct {
+		NodeName   string `yaml:"nodeName"`
+		Containers []struct {
+			Name string `yaml:"name"`
+		} `yaml:"containers"`
+	} `yaml:"spec"`
+	Status struct {
+		Phase             string `yaml:"phase"`
+		Reason            string `yaml:"reason"`
+		ContainerStatuses []struct {
+			Name         string                   `yaml:"name"`
+			Ready        bool                     `yaml:"ready"`
+			RestartCount int                      `yaml:"restartCount"`
+			State        kubernetesContainerState `yaml:"state"`
+			LastState    kubernetesContainerState `yaml:"lastState"`
+		} `yaml:"containerStatuses"`
+	} `yaml:"status"`
+}
+
+type kubernetesPodMetricsListResponse struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Containers []struct {
+			Usage struct {
+				CPU    string `yaml:"cpu"`
+				Memory string `yaml:"memory"`
+			} `yaml:"usage"`
+		} `yaml:"containers"`
+	} `yaml:"items"`
+}
+
+// kubernetesPodUsage is the CPU (in cores) and memory (in bytes) used by a pod
+type kubernetesPodUsage struct {
+	CPU    float64
+	Memory float64
+}
+
+// kubernetesPodSummary is the health of a pod, as shown by 'kubectl get pods'
+type kubernetesPodSummary struct {
+	Status         string
+	ReadyCount     int
+	ContainerCount int
+	Restarts       int
+	Owner          string
+	// OOMKilled is set if a container was last killed for running out of memory
+	OOMKilled bool
+}
+
+// kubernetesPodsOverviewTargetType returns "namespace" or "node" for cluster nodes that have a pods overview, otherwise ""
+func kubernetesPodsOverviewTargetType(node *TreeNode) string {
+	if node.Namespace != "swagger" || node.SwaggerResourceType == nil ||
+		node.Metadata == nil || !strings.HasSuffix(node.Metadata["SwaggerAPISetID"], "/<k8sapi>") {
+		return ""
+	}
+	switch node.SwaggerResourceType.Endpoint.TemplateURL {
+	case kubernetesNamespaceTemplateURL:
+		return "namespace"
+	case kubernetesNodeTemplateURL:
+		return "node"
+	}
+	return ""
+}
+
+// newKubernetesPodsOverviewNode returns the Pods Overview node for a namespace or node, templateValues are from matching the node's ExpandURL
+func newKubernetesPodsOverviewNode(currentItem *TreeNode, templateValues map[string]string) *TreeNode {
+	metadata := map[string]string{
+		"SwaggerAPISetID": currentItem.Metadata["SwaggerAPISetID"],
+	}
+	if kubernetesPodsOverviewTargetType(currentItem) == "namespace" {
+		metadata["PodsNamespace"] = templateValues["name"]
+	} else {
+		metadata["PodsNodeName"] = templateValues["name"]
+	}
+
+	return &TreeNode{
+		Parentid:              currentItem.ID,
+		ID:                    currentItem.ID + "/<podsoverview>",
+		Namespace:             "AzureKubernetesService",
+		Name:                  "Pods Overview",
+		Display:               "Pods Overview",
+		ItemType:              kubernetesNodePodsOverview,
+		ExpandURL:             ExpandURLNotSupported,
+		SuppressSwaggerExpand: true,
+		SuppressGenericExpand: true,
+		Metadata:              metadata,
+	}
+}
+
+func (e *AzureKubernetesServiceExpander) expandPodsOverview(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	apiSet := getKubernetesAPISet(currentItem)
+	if apiSet == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cluster API not found for %s", currentItem.ID),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	namespace := currentItem.Metadata["PodsNamespace"]
+	nodeName := currentItem.Metadata["PodsNodeName"]
+	podsURL := "/api/v1/pods"
+	metricsURL := "/apis/metrics.k8s.io/v1beta1/pods"
+	title := "Problem pods"
+	if namespace != "" {
+		podsURL = "/api/v1/namespaces/" + namespace + "/pods"
+		metricsURL = "/apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods"
+		title = "Pods in " + namespace
+	} else if nodeName != "" {
+		podsURL += "?fieldSelector=" + url.QueryEscape("spec.nodeName="+nodeName)
+		title = "Pods on " + nodeName
+	}
+
+	data, err := apiSet.doRequest(ctx, "GET", apiSet.serverURL+podsURL)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to list pods: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	var podList kubernetesPodListResponse
+	if err = yaml.Unmarshal([]byte(data), &podList); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error parsing YAML response: %s", err),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Usage is only available when the metrics server is running so failures are ignored
+	usage := map[string]kubernetesPodUsage{}
+	if metricsData, err := apiSet.doRequest(ctx, "GET", apiSet.serverURL+metricsURL); err == nil {
+		usage = parseKubernetesPodMetrics(metricsData)
+	}
+
+	pods := []kubernetesPod{}
+	problems := 0
+	for _, pod := range podList.Items {
+		summary := summarizeKubernetesPod(pod)
+		isProblem := summary.isProblem()
+		if isProblem {
+			problems++
+		}
+		if currentItem.ItemType == kubernetesNodeProblemPods && !isProblem {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Metadata.Namespace != pods[j].Metadata.Namespace {
+			return pods[i].Metadata.Namespace < pods[j].Metadata.Namespace
+		}
+		return pods[i].Metadata.Name < pods[j].Metadata.Name
+	})
+
+	now := time.Now()
+	var podResourceType *swagger.ResourceType
+	newItems := []*TreeNode{}
+	for _, pod := range pods {
+		summary := summarizeKubernetesPod(pod)
+		podUsage, hasUsage := usage[pod.Metadata.Namespace+"/"+pod.Metadata.Name]
+		details := fmt.Sprintf("%s ready:%d/%d restarts:%d age:%s",
+			summary.Status, summary.ReadyCount, summary.ContainerCount, summary.Restarts,
+			kubernetesAge(parseKubernetesTime(pod.Metadata.CreationTimestamp), now))
+		if hasUsage {
+			details += fmt.Sprintf(" cpu:%s mem:%s", formatKubernetesCPU(podUsage.CPU), formatKubernetesMemory(podUsage.Memory))
+		}
+		if summary.Owner != "" {
+			details += " " + summary.Owner
+		}
+		name := pod.Metadata.Name
+		if namespace == "" {
+			name = pod.Metadata.Namespace + "/" + pod.Metadata.Name
+		}
+
+		podURL := "/api/v1/namespaces/" + pod.Metadata.Namespace + "/pods/" + pod.Metadata.Name
+		if podResourceType == nil {
+			podResourceType = swagger.GetResourceTypeForURL(ctx, podURL, apiSet.GetResourceTypes())
+		}
+		newItems = append(newItems, &TreeNode{
+			Parentid:            currentItem.ID,
+			ID:                  apiSet.clusterID + podURL,
+			Namespace:           "swagger",
+			Name:                pod.Metadata.Name,
+			Display:             name + "\n  " + style.Subtle(details),
+			ItemType:            SubResourceType,
+			ExpandURL:           podURL,
+			DeleteURL:           podURL,
+			StatusIndicator:     kubernetesPodStatusIndicator(summary),
+			SwaggerResourceType: podResourceType,
+			Metadata: map[string]string{
+				"SwaggerAPISetID": currentItem.Metadata["SwaggerAPISetID"],
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Nodes: newItems,
+		Response: ExpanderResponse{
+			Response:     formatKubernetesPods(pods, usage, namespace == "", now),
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        fmt.Sprintf("%s (%d pods, %d problems)", title, len(pods), problems),
+		},
+		SourceDescription: "AzureKubernetesServiceExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (s kubernetesPodSummary) isProblem() bool {
+	return kubernetesProblemReasons[s.Status] || s.OOMKilled
+}
+
+// summarizeKubernetesPod works out the pod's status in the same way as 'kubectl get pods'
+func summarizeKubernetesPod(pod kubernetesPod) kubernetesPodSummary {
+	summary := kubernetesPodSummary{
+		Status:         pod.Status.Phase,
+		ContainerCount: len(pod.Spec.Containers),
+	}
+	if pod.Status.Reason != "" {
+		summary.Status = pod.Status.Reason
+	}
+
+	for _, container := range pod.Status.ContainerStatuses {
+		summary.Restarts += container.RestartCount
+		if container.Ready {
+			summary.ReadyCount++
+		}
+		if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+			summary.Status = container.State.Waiting.Reason
+		} else if container.State.Terminated != nil && container.State.Terminated.Reason != "" {
+			summary.Status = container.State.Terminated.Reason
+		}
+		if (container.State.Terminated != nil && container.State.Terminated.Reason == "OOMKilled") ||
+			(container.LastState.Terminated != nil && container.LastState.Terminated.Reason == "OOMKilled") {
+			summary.OOMKilled = true
+		}
+	}
+	if pod.Metadata.DeletionTimestamp != "" {
+		summary.Status = "Terminating"
+	}
+
+	if len(pod.Metadata.OwnerReferences) > 0 {
+		owner := pod.Metadata.OwnerReferences[0]
+		kind := strings.ToLower(owner.Kind)
+		name := owner.Name
+		// Pods from a deployment are owned by a ReplicaSet named after the deployment and the pod template hash
+		if hash := pod.Metadata.Labels["pod-template-hash"]; kind == "replicaset" && hash != "" && strings.HasSuffix(name, "-"+hash) {
+			kind = "deployment"
+			name = strings.TrimSuffix(name, "-"+hash)
+		}
+		summary.Owner = kind + "/" + name
+	}
+	return summary
+}
+
+func kubernetesPodStatusIndicator(summary kubernetesPodSummary) string {
+	switch {
+	case summary.isProblem() && summary.Status != "Pending":
+		return "⛈"
+	case summary.Status == "Terminating":
+		return "☠"
+	case summary.Status == "Succeeded" || summary.Status == "Completed":
+		return "☼"
+	case summary.Status == "Running" && summary.ReadyCount == summary.ContainerCount:
+		return "☼"
+	case summary.Status == "Running":
+		return "⛅"
+	}
+	return "⌛"
+}
+
+// formatKubernetesPods renders the pods as a table with problems highlighted
+func formatKubernetesPods(pods []kubernetesPod, usage map[string]kubernetesPodUsage, includeNamespace bool, now time.Time) string {
+	if len(pods) == 0 {
+		return "No pods found"
+	}
+
+	rows := [][]string{{"NAME", "READY", "STATUS", "RESTARTS", "AGE", "CPU", "MEMORY", "OWNER", "NODE"}}
+	for _, pod := range pods {
+		summary := summarizeKubernetesPod(pod)
+		name := pod.Metadata.Name
+		if includeNamespace {
+			name = pod.Metadata.Namespace + "/" + name
+		}
+		status := summary.Status
+		if summary.OOMKilled && status != "OOMKilled" {
+			status += " (OOMKilled)"
+		}
+		cpu, memory := "-", "-"
+		if podUsage, ok := usage[pod.Metadata.Namespace+"/"+pod.Metadata.Name]; ok {
+			cpu, memory = formatKubernetesCPU(podUsage.CPU), formatKubernetesMemory(podUsage.Memory)
+		}
+		rows = append(rows, []string{
+			name,
+			fmt.Sprintf("%d/%d", summary.ReadyCount, summary.ContainerCount),
+			status,
+			strconv.Itoa(summary.Restarts),
+			kubernetesAge(parseKubernetesTime(pod.Metadata.CreationTimestamp), now),
+			cpu,
+			memory,
+			summary.Owner,
+			pod.Spec.NodeName,
+		})
+	}
+
+	return formatKubernetesTable(rows, func(row []string, column int, cell string) string {
+		if column == 2 && row[2] != "STATUS" && (kubernetesProblemReasons[strings.TrimSpace(cell)] || strings.Contains(cell, "OOMKilled")) {
+			return style.Warning(cell)
+		}
+		return cell
+	})
+}
+
+func parseKubernetesTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseKubernetesPodMetrics returns the total usage of each pod's containers keyed by namespace/name
+func parseKubernetesPodMetrics(data string) map[string]kubernetesPodUsage {
+	usage := map[string]kubernetesPodUsage{}
+	var metrics kubernetesPodMetricsListResponse
+	if err := yaml.Unmarshal([]byte(data), &metrics); err != nil {
+		return usage
+	}
+	for _, item := range metrics.Items {
+		podUsage := kubernetesPodUsage{}
+		for _, container := range item.Containers {
+			cpu, _ := parseKubernetesQuantity(container.Usage.CPU)
+			memory, _ := parseKubernetesQuantity(container.Usage.Memory)
+			podUsage.CPU += cpu
+			podUsage.Memory += memory
+		}
+		usage[item.Metadata.Namespace+"/"+item.Metadata.Name] = podUsage
+	}
+	return usage
+}
+
+// parseKubernetesQuantity parses resource quantities such as 250m, 12345n, 1.5 or 64Mi
+func parseKubernetesQuantity(value string) (float64, error) {
+	suffixes := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+		{"n", 1e-9}, {"u", 1e-6}, {"m", 1e-3}, {"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	}
+	for _, s := range suffixes {
+		if strings.HasSuffix(value, s.suffix) {
+			number, err := strconv.ParseFloat(strings.TrimSuffix(value, s.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid quantity %q: %s", value, err)
+			}
+			return number * s.multiplier, nil
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid quantity %q: %s", value, err)
+	}
+	return number, nil
+}
+
+// formatKubernetesCPU formats cores as millicores, e.g. 250m
+func formatKubernetesCPU(cores float64) string {
+	return fmt.Sprintf("%dm", int(math.Round(cores*1000)))
+}
+
+// formatKubernetesMemory formats bytes as Mi
+func formatKubernetesMemory(bytes float64) string {
+	return fmt.Sprintf("%dMi", int(math.Round(bytes/(1<<20))))
+}
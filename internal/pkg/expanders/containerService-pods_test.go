@@ -0,0 +1,86 @@
+package expanders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_summarizeKubernetesPod(t *testing.T) {
+	podYAML := `
+metadata:
+  name: web-5d8f7b9c6-abcde
+  labels:
+    pod-template-hash: 5d8f7b9c6
+  ownerReferences:
+  - kind: ReplicaSet
+    name: web-5d8f7b9c6
+spec:
+  containers:
+  - name: web
+  - name: sidecar
+status:
+  phase: Running
+  containerStatuses:
+  - name: web
+    ready: false
+    restartCount: 7
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+    lastState:
+      terminated:
+        reason: OOMKilled
+  - name: sidecar
+    ready: true
+    restartCount: 1
+    state:
+      running: {}
+`
+	var pod kubernetesPod
+	assert.NoError(t, yaml.Unmarshal([]byte(podYAML), &pod))
+
+	summary := summarizeKubernetesPod(pod)
+	assert.Equal(t, "CrashLoopBackOff", summary.Status)
+	assert.Equal(t, 1, summary.ReadyCount)
+	assert.Equal(t, 2, summary.ContainerCount)
+	assert.Equal(t, 8, summary.Restarts)
+	assert.Equal(t, "deployment/web", summary.Owner)
+	assert.True(t, summary.OOMKilled)
+	assert.True(t, summary.isProblem())
+	assert.Equal(t, "⛈", kubernetesPodStatusIndicator(summary))
+
+	healthy := kubernetesPodSummary{Status: "Running", ReadyCount: 1, ContainerCount: 1}
+	assert.False(t, healthy.isProblem())
+	assert.Equal(t, "☼", kubernetesPodStatusIndicator(healthy))
+
+	pending := kubernetesPodSummary{Status: "Pending", ContainerCount: 1}
+	assert.True(t, pending.isProblem())
+	assert.Equal(t, "⌛", kubernetesPodStatusIndicator(pending))
+}
+
+func Test_parseKubernetesQuantity(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"250m", 0.25},
+		{"1500000n", 0.0015},
+		{"2", 2},
+		{"64Mi", 64 * 1024 * 1024},
+		{"1024Ki", 1024 * 1024},
+		{"1G", 1e9},
+	}
+	for _, test := range tests {
+		actual, err := parseKubernetesQuantity(test.value)
+		assert.NoError(t, err)
+		assert.InDelta(t, test.expected, actual, 1e-9, test.value)
+	}
+
+	_, err := parseKubernetesQuantity("lots")
+	assert.Error(t, err)
+
+	assert.Equal(t, "2m", formatKubernetesCPU(0.0015))
+	assert.Equal(t, "64Mi", formatKubernetesMemory(64*1024*1024))
+}
@@ -3,14 +3,17 @@ package expanders
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/pkg/swagger"
 )
@@ -29,18 +32,56 @@ type podResponse struct {
 		Containers []struct {
 			Name string `yaml:"name"`
 		} `yaml:"containers"`
+		InitContainers []struct {
+			Name string `yaml:"name"`
+		} `yaml:"initContainers"`
 	} `yaml:"spec"`
+	Status struct {
+		ContainerStatuses []struct {
+			Name         string `yaml:"name"`
+			RestartCount int    `yaml:"restartCount"`
+		} `yaml:"containerStatuses"`
+	} `yaml:"status"`
 }
 
 var _ SwaggerAPISet = SwaggerAPISetContainerService{}
 var maxTailLines = 100
 
+// errKubernetesConflict is returned when the object has been changed since the content being saved was loaded
+var errKubernetesConflict = errors.New("the object has been modified since it was loaded, refresh it and reapply your changes")
+
+// errKubernetesFieldConflict is returned when server-side apply would change fields owned by another field manager
+var errKubernetesFieldConflict = errors.New("the changes conflict with fields managed by another field manager")
+
+// kubernetesFieldManager identifies azbrowse as the owner of fields set with server-side apply
+const kubernetesFieldManager = "azbrowse"
+
+type kubernetesObjectVersion struct {
+	Metadata struct {
+		Name            string `yaml:"name"`
+		ResourceVersion string `yaml:"resourceVersion"`
+	} `yaml:"metadata"`
+}
+
+// kubernetesStatus is the Status object returned by the API server when a request fails
+type kubernetesStatus struct {
+	Details struct {
+		Causes []struct {
+			Reason  string `yaml:"reason"`
+			Message string `yaml:"message"`
+		} `yaml:"causes"`
+	} `yaml:"details"`
+}
+
 // SwaggerAPISetContainerService holds the config for working with an AKS cluster API
 type SwaggerAPISetContainerService struct {
 	resourceTypes []swagger.ResourceType
 	httpClient    http.Client
 	clusterID     string
 	serverURL     string
+	// confirmForceApply asks whether to take ownership of the conflicting fields when server-side apply fails,
+	// if it isn't set the conflict is returned as an error
+	confirmForceApply func(name string, conflicts []string) bool
 }
 
 // NewSwaggerAPISetContainerService creates a new SwaggerAPISetContainerService
@@ -80,13 +121,17 @@ func (c SwaggerAPISetContainerService) doRequest(ctx context.Context, verb strin
 }
 
 func (c SwaggerAPISetContainerService) doRequestWithBody(ctx context.Context, verb string, url string, body string) (string, error) {
+	return c.doRequestWithContentType(ctx, verb, url, body, "application/yaml")
+}
+
+func (c SwaggerAPISetContainerService) doRequestWithContentType(ctx context.Context, verb string, url string, body string, contentType string) (string, error) {
 	request, err := http.NewRequest(verb, url, bytes.NewReader([]byte(body)))
 	if err != nil {
 		err = fmt.Errorf("Failed to create request" + err.Error() + url)
 		return "", err
 	}
 
-	request.Header.Set("Content-Type", "application/yaml")
+	request.Header.Set("Content-Type", contentType)
 	request.Header.Set("Accept", "application/yaml")
 	response, err := c.httpClient.Do(request.WithContext(ctx))
 	if err != nil {
@@ -103,9 +148,61 @@ func (c SwaggerAPISetContainerService) doRequestWithBody(ctx context.Context, ve
 	if 200 <= response.StatusCode && response.StatusCode < 300 {
 		return data, nil
 	}
+	if response.StatusCode == http.StatusConflict {
+		return "", kubernetesConflictError(url, data)
+	}
 	return "", fmt.Errorf("Response failed with %s (%s): %s", response.Status, url, data)
 }
 
+// kubernetesConflictError returns errKubernetesFieldConflict, listing the conflicting fields, if server-side apply
+// failed because another field manager owns the fields. Otherwise the object has changed and errKubernetesConflict is returned
+func kubernetesConflictError(url string, data string) error {
+	var status kubernetesStatus
+	if err := yaml.Unmarshal([]byte(data), &status); err == nil {
+		conflicts := []string{}
+		for _, cause := range status.Details.Causes {
+			if cause.Reason == "FieldManagerConflict" {
+				conflicts = append(conflicts, cause.Message)
+			}
+		}
+		if len(conflicts) > 0 {
+			return &kubernetesFieldConflict{conflicts: conflicts}
+		}
+	}
+	return fmt.Errorf("%w (%s): %s", errKubernetesConflict, url, data)
+}
+
+// kubernetesFieldConflict is an errKubernetesFieldConflict with the conflicts reported by the API server
+type kubernetesFieldConflict struct {
+	conflicts []string
+}
+
+func (e *kubernetesFieldConflict) Error() string {
+	return fmt.Sprintf("%s: %s", errKubernetesFieldConflict, strings.Join(e.conflicts, ", "))
+}
+
+func (e *kubernetesFieldConflict) Unwrap() error {
+	return errKubernetesFieldConflict
+}
+
+// stream makes a request and returns the response body for the caller to read, and close, as it arrives
+func (c SwaggerAPISetContainerService) stream(ctx context.Context, url string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed: %s (%s)", err, url)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		defer response.Body.Close() //nolint: errcheck
+		buf, _ := io.ReadAll(response.Body)
+		return nil, fmt.Errorf("Response failed with %s (%s): %s", response.Status, url, string(buf))
+	}
+	return response.Body, nil
+}
+
 // ExpandResource returns metadata about child resources of the specified resource node
 func (c SwaggerAPISetContainerService) ExpandResource(ctx context.Context, currentItem *TreeNode, resourceType swagger.ResourceType) (APISetExpandResponse, error) {
 
@@ -228,8 +325,16 @@ func (c SwaggerAPISetContainerService) Delete(ctx context.Context, item *TreeNod
 	return true, nil
 }
 
-// Update attempts to update the specified item with new content
+// Update attempts to update the specified item with new content.
+// Content with a resourceVersion replaces the object (PUT) and fails if the object has changed since it was loaded,
+// removing the resourceVersion updates the object with server-side apply instead. If the apply conflicts with fields
+// owned by another field manager the user is asked whether to force the change and take ownership of them
 func (c SwaggerAPISetContainerService) Update(ctx context.Context, item *TreeNode, content string) error {
+	var updated kubernetesObjectVersion
+	if err := yaml.Unmarshal([]byte(content), &updated); err != nil {
+		return fmt.Errorf("Error parsing YAML: %s", err)
+	}
+
 	matchResult := item.SwaggerResourceType.Endpoint.Match(item.ExpandURL)
 	if !matchResult.IsMatch {
 		return fmt.Errorf("item.ExpandURL didn't match current Endpoint")
@@ -239,10 +344,74 @@ func (c SwaggerAPISetContainerService) Update(ctx context.Context, item *TreeNod
 	if err != nil {
 		return fmt.Errorf("Failed to build PUT URL '%s': %s", item.SwaggerResourceType.PutEndpoint.TemplateURL, err)
 	}
+
+	if updated.Metadata.ResourceVersion == "" {
+		applyContent, err := removeManagedFields(content)
+		if err != nil {
+			return err
+		}
+		applyURL := putURL + "?fieldManager=" + kubernetesFieldManager
+		_, err = c.doRequestWithContentType(ctx, "PATCH", applyURL, applyContent, "application/apply-patch+yaml")
+		var fieldConflict *kubernetesFieldConflict
+		if errors.As(err, &fieldConflict) && c.confirmForceApply != nil && c.confirmForceApply(updated.Metadata.Name, fieldConflict.conflicts) {
+			_, err = c.doRequestWithContentType(ctx, "PATCH", applyURL+"&force=true", applyContent, "application/apply-patch+yaml")
+		}
+		if err != nil {
+			return fmt.Errorf("Error applying changes: %w", err)
+		}
+		return nil
+	}
+
+	// Check the version first to give a clear error rather than relying on the PUT failing
+	data, err := c.doRequest(ctx, "GET", c.serverURL+item.ExpandURL)
+	if err != nil {
+		return fmt.Errorf("Error getting current version: %s", err)
+	}
+	var current kubernetesObjectVersion
+	if err = yaml.Unmarshal([]byte(data), &current); err != nil {
+		return fmt.Errorf("Error parsing YAML response: %s", err)
+	}
+	if current.Metadata.ResourceVersion != updated.Metadata.ResourceVersion {
+		return fmt.Errorf("%w (resourceVersion %s, now %s)", errKubernetesConflict, updated.Metadata.ResourceVersion, current.Metadata.ResourceVersion)
+	}
+
 	_, err = c.doRequestWithBody(ctx, "PUT", putURL, content)
 	if err != nil {
 		return fmt.Errorf("Error making PUT request: %s", err)
 	}
 
+	eventing.SendStatusEvent(&eventing.StatusEvent{
+		Message: fmt.Sprintf("Updated %s, refresh to get the new resourceVersion before editing again", updated.Metadata.Name),
+		Timeout: time.Second * 5,
+	})
 	return nil
 }
+
+// removeManagedFields strips metadata.managedFields, which server-side apply refuses
+func removeManagedFields(content string) (string, error) {
+	var object yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(content), &object); err != nil {
+		return "", fmt.Errorf("Error parsing YAML: %s", err)
+	}
+	for i, item := range object {
+		if item.Key != "metadata" {
+			continue
+		}
+		metadata, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		filtered := yaml.MapSlice{}
+		for _, field := range metadata {
+			if field.Key != "managedFields" {
+				filtered = append(filtered, field)
+			}
+		}
+		object[i].Value = filtered
+	}
+	buf, err := yaml.Marshal(object)
+	if err != nil {
+		return "", fmt.Errorf("Error formatting YAML: %s", err)
+	}
+	return string(buf), nil
+}
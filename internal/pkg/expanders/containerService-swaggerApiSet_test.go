@@ -0,0 +1,95 @@
+package expanders
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/endpoints"
+	"github.com/lawrencegripper/azbrowse/pkg/swagger"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_removeManagedFields(t *testing.T) {
+	content := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  managedFields:
+  - manager: kubectl
+    operation: Update
+  namespace: default
+spec:
+  replicas: 2
+`
+	applyContent, err := removeManagedFields(content)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+`, applyContent)
+
+	_, err = removeManagedFields("metadata: [")
+	assert.Error(t, err)
+}
+
+func Test_ContainerService_UpdateForcesFieldConflictsWhenConfirmed(t *testing.T) {
+	fieldConflict := `kind: Status
+reason: Conflict
+details:
+  causes:
+  - reason: FieldManagerConflict
+    message: conflict with "kubectl" using apps/v1
+    field: .spec.replicas
+`
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		assert.Equal(t, "application/apply-patch+yaml", r.Header.Get("Content-Type"))
+		if r.URL.Query().Get("force") != "true" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = io.WriteString(w, fieldConflict)
+			return
+		}
+		_, _ = io.WriteString(w, "kind: Deployment")
+	}))
+	defer ts.Close()
+
+	endpoint := endpoints.MustGetEndpointInfoFromURL("/apis/apps/v1/namespaces/{namespace}/deployments/{name}", "")
+	item := &TreeNode{
+		ExpandURL:           "/apis/apps/v1/namespaces/default/deployments/web",
+		SwaggerResourceType: &swagger.ResourceType{Endpoint: endpoint, PutEndpoint: endpoint},
+	}
+	content := "metadata:\n  name: web\nspec:\n  replicas: 3\n"
+
+	c := NewSwaggerAPISetContainerService(nil, *ts.Client(), "cluster", ts.URL)
+	err := c.Update(context.Background(), item, content)
+	assert.ErrorIs(t, err, errKubernetesFieldConflict)
+	assert.NotErrorIs(t, err, errKubernetesConflict)
+	assert.Contains(t, err.Error(), `conflict with "kubectl" using apps/v1`)
+
+	confirmed := []string{}
+	c.confirmForceApply = func(name string, conflicts []string) bool {
+		confirmed = append(confirmed, name)
+		return true
+	}
+	requests = []string{}
+	assert.NoError(t, c.Update(context.Background(), item, content))
+	assert.Equal(t, []string{"web"}, confirmed)
+	assert.Equal(t, []string{
+		"PATCH /apis/apps/v1/namespaces/default/deployments/web?fieldManager=azbrowse",
+		"PATCH /apis/apps/v1/namespaces/default/deployments/web?fieldManager=azbrowse&force=true",
+	}, requests)
+}
+
+func Test_kubernetesConflictError(t *testing.T) {
+	err := kubernetesConflictError("/api/v1/namespaces/default/configmaps/c", "kind: Status\nreason: Conflict\nmessage: the object has been modified\n")
+	assert.ErrorIs(t, err, errKubernetesConflict)
+	assert.NotErrorIs(t, err, errKubernetesFieldConflict)
+}
@@ -10,12 +10,15 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/awesome-gocui/gocui"
 	"github.com/go-openapi/loads"
 
 	azbrowse_config "github.com/lawrencegripper/azbrowse/internal/pkg/config"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 	"github.com/lawrencegripper/azbrowse/pkg/swagger"
@@ -49,13 +52,24 @@ type kubeConfigResponse struct {
 	} `yaml:"users"`
 }
 
+// NewAzureKubernetesServiceExpander creates a new instance of AzureKubernetesServiceExpander
+func NewAzureKubernetesServiceExpander(client *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *AzureKubernetesServiceExpander {
+	return &AzureKubernetesServiceExpander{
+		client:       client,
+		gui:          gui,
+		commandPanel: commandPanel,
+	}
+}
+
 // Check interface
 var _ Expander = &AzureKubernetesServiceExpander{}
 
 // AzureKubernetesServiceExpander expands the kubernetes aspects of AKS
 type AzureKubernetesServiceExpander struct {
 	ExpanderBase
-	client *armclient.Client
+	client       *armclient.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
 }
 
 func (e *AzureKubernetesServiceExpander) setClient(c *armclient.Client) {
@@ -78,6 +92,9 @@ func (e *AzureKubernetesServiceExpander) DoesExpand(ctx context.Context, current
 	if currentItem.Namespace == "AzureKubernetesService" {
 		return true, nil
 	}
+	if kubernetesEventsTargetType(currentItem) != "" || kubernetesPodsOverviewTargetType(currentItem) != "" {
+		return true, nil
+	}
 	return false, nil
 }
 
@@ -113,8 +130,19 @@ func (e *AzureKubernetesServiceExpander) Expand(ctx context.Context, currentItem
 		}
 	}
 
-	if currentItem.Namespace == "AzureKubernetesService" && currentItem.ItemType == SubResourceType {
-		return e.expandKubernetesAPIRoot(ctx, currentItem)
+	if currentItem.Namespace == "AzureKubernetesService" {
+		switch currentItem.ItemType {
+		case SubResourceType:
+			return e.expandKubernetesAPIRoot(ctx, currentItem)
+		case kubernetesNodeEvents:
+			return e.expandEvents(ctx, currentItem)
+		case kubernetesNodePodsOverview, kubernetesNodeProblemPods:
+			return e.expandPodsOverview(ctx, currentItem)
+		}
+	}
+
+	if kubernetesEventsTargetType(currentItem) != "" || kubernetesPodsOverviewTargetType(currentItem) != "" {
+		return e.addKubernetesObjectNodes(currentItem)
 	}
 
 	return ExpanderResult{
@@ -124,6 +152,33 @@ func (e *AzureKubernetesServiceExpander) Expand(ctx context.Context, currentItem
 	}
 }
 
+// addKubernetesObjectNodes adds the Events and Pods Overview nodes to namespaces, nodes and other objects in the cluster
+func (e *AzureKubernetesServiceExpander) addKubernetesObjectNodes(currentItem *TreeNode) ExpanderResult {
+	matchResult := currentItem.SwaggerResourceType.Endpoint.Match(currentItem.ExpandURL)
+	if !matchResult.IsMatch {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error matching URL %q", currentItem.ExpandURL),
+			SourceDescription: "AzureKubernetesServiceExpander request",
+			IsPrimaryResponse: false,
+		}
+	}
+
+	newItems := []*TreeNode{}
+	if kubernetesPodsOverviewTargetType(currentItem) != "" {
+		newItems = append(newItems, newKubernetesPodsOverviewNode(currentItem, matchResult.Values))
+	}
+	if kubernetesEventsTargetType(currentItem) != "" {
+		newItems = append(newItems, newKubernetesEventsNode(currentItem, matchResult.Values))
+	}
+
+	return ExpanderResult{
+		Nodes:             newItems,
+		Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+		SourceDescription: "AzureKubernetesServiceExpander request",
+		IsPrimaryResponse: false,
+	}
+}
+
 func (e *AzureKubernetesServiceExpander) expandKubernetesAPIRoot(ctx context.Context, currentItem *TreeNode) ExpanderResult {
 
 	clusterID := currentItem.Metadata["ClusterID"]
@@ -146,7 +201,22 @@ func (e *AzureKubernetesServiceExpander) expandKubernetesAPIRoot(ctx context.Con
 	swaggerResourceTypes := apiSet.GetResourceTypes()
 
 	// TODO think about how to avoid re-registering - add something to the current node's metadata?
-	newItems := []*TreeNode{}
+	newItems := []*TreeNode{
+		{
+			Parentid:              currentItem.ID,
+			ID:                    currentItem.ID + "/<problempods>",
+			Namespace:             "AzureKubernetesService",
+			Name:                  "Problem Pods",
+			Display:               "Problem Pods",
+			ItemType:              kubernetesNodeProblemPods,
+			ExpandURL:             ExpandURLNotSupported,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata: map[string]string{
+				"SwaggerAPISetID": currentItem.ID,
+			},
+		},
+	}
 	for _, child := range swaggerResourceTypes {
 		resourceType := child
 		display := resourceType.Display
@@ -200,8 +270,19 @@ func (e *AzureKubernetesServiceExpander) createAPISetForCluster(ctx context.Cont
 
 	// Register the swagger config so that the swagger expander can take over
 	apiSet := NewSwaggerAPISetContainerService(swaggerResourceTypes, *httpClient, clusterID+"/<k8sapi>", serverURL)
+	apiSet.confirmForceApply = e.confirmForceApply
 	return &apiSet, nil
 }
+
+// confirmForceApply asks whether to force a server-side apply which conflicts with fields owned by another field manager
+func (e *AzureKubernetesServiceExpander) confirmForceApply(name string, conflicts []string) bool {
+	options := []interfaces.CommandPanelListOption{
+		{ID: "force", DisplayText: fmt.Sprintf("Force, taking ownership of %d field(s) from: %s", len(conflicts), strings.Join(conflicts, "; "))},
+		{ID: "cancel", DisplayText: "Cancel"},
+	}
+	_, selected := promptInCommandPanel(e.gui, e.commandPanel, "apply conflicts for "+name+", force?", "", &options)
+	return selected == "force"
+}
 func (e *AzureKubernetesServiceExpander) getAPISetForCluster(clusterID string) *SwaggerAPISetContainerService {
 
 	swaggerAPISet := GetSwaggerResourceExpander().GetAPISet(clusterID + "/<k8sapi>")
@@ -320,7 +401,8 @@ func (e *AzureKubernetesServiceExpander) getHTTPClientFromConfig(kubeConfig kube
 	}
 
 	httpClient := http.Client{
-		Transport: transport,
+		// Wrapped so read-only mode also applies to requests to the cluster
+		Transport: armclient.NewReadOnlyTransport(transport),
 	}
 
 	return &httpClient, nil
@@ -0,0 +1,370 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const cosmosdbGremlinGraphTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/gremlinDatabases/{databaseName}/graphs/{graphName}"
+
+const (
+	cosmosdbListGremlinVertices     = "gremlin-listvertices"
+	cosmosdbListGremlinEdges        = "gremlin-listedges"
+	cosmosdbListGremlinContinuation = "gremlin-list-continue"
+	cosmosdbGremlinVertex           = "gremlin-vertex"
+	cosmosdbGremlinEdge             = "gremlin-edge"
+)
+
+const cosmosdbActionGremlinQuery = "gremlin-query"
+
+// cosmosdbGremlinQueryTimeout limits how long a query from the command panel can run
+const cosmosdbGremlinQueryTimeout = 2 * time.Minute
+
+// The graph is stored as documents in the container, so vertices and edges are listed with SQL queries
+// which can be paged with continuation tokens
+const (
+	cosmosdbGremlinVerticesQuery = "SELECT * FROM c WHERE NOT IS_DEFINED(c._isEdge)"
+	cosmosdbGremlinEdgesQuery    = "SELECT * FROM c WHERE c._isEdge = true"
+)
+
+// gremlinGraphMetadata returns the metadata for connecting to the graph that item is (or is under)
+func gremlinGraphMetadata(item *TreeNode) (map[string]string, error) {
+	if item.Metadata["GraphID"] != "" {
+		return gremlinNodeMetadata(item), nil
+	}
+
+	tempItem := item
+	for tempItem.SwaggerResourceType == nil && tempItem.Parent != nil {
+		tempItem = tempItem.Parent
+	}
+	if tempItem.SwaggerResourceType == nil || tempItem.SwaggerResourceType.Endpoint.TemplateURL != cosmosdbGremlinGraphTemplateURL {
+		return nil, fmt.Errorf("Item isn't a Gremlin graph: %q", item.ID)
+	}
+	matchResult := tempItem.SwaggerResourceType.Endpoint.Match(tempItem.ID)
+	if !matchResult.IsMatch {
+		return nil, fmt.Errorf("Endpoint should match")
+	}
+	return map[string]string{
+		"AccountName":   matchResult.Values["accountName"],
+		"DatabaseName":  matchResult.Values["databaseName"],
+		"ContainerName": matchResult.Values["graphName"],
+		"GraphID":       tempItem.ID,
+	}, nil
+}
+
+// gremlinNodeMetadata returns the connection metadata from item without the node specific values
+func gremlinNodeMetadata(item *TreeNode) map[string]string {
+	metadata := copyMetadata(item.Metadata)
+	for _, key := range []string{"ActionID", "ContinuationToken", "ItemID", "PartitionKeyValue", "GremlinPartitionKeyValue"} {
+		delete(metadata, key)
+	}
+	return metadata
+}
+
+// ensureGremlinConnectionDetails saves the account key, Gremlin endpoint and the graph partition key in the item metadata.
+// In read-only mode the read-only key is used so the service refuses any query which changes the graph
+func (e *CosmosDbExpander) ensureGremlinConnectionDetails(ctx context.Context, item *TreeNode) error {
+	if item.Metadata["GremlinEndpoint"] != "" {
+		return nil
+	}
+
+	account, err := e.getAccount(ctx, item)
+	if err != nil {
+		return fmt.Errorf("Error getting account: %s", err)
+	}
+	if apiKind := account.apiKind(); apiKind != cosmosdbAPIGremlin {
+		return fmt.Errorf("Account uses the %s API, not Gremlin", apiKind)
+	}
+	endpoint, err := cosmosGremlinEndpoint(account)
+	if err != nil {
+		return err
+	}
+
+	getAccountKey := e.getAccountKey
+	if armclient.IsReadOnlyMode() {
+		getAccountKey = e.getReadOnlyAccountKey
+	}
+	accountKey, err := getAccountKey(ctx, item)
+	if err != nil {
+		return fmt.Errorf("Error getting account key: %s", err)
+	}
+	partitionKey, err := e.getCollectionPartitionKey(ctx, item.Metadata["AccountName"], item.Metadata["DatabaseName"], item.Metadata["ContainerName"], accountKey)
+	if err != nil {
+		return fmt.Errorf("Error getting partition key: %s", err)
+	}
+	item.Metadata["AccountKey"] = accountKey
+	item.Metadata["GremlinEndpoint"] = endpoint
+	item.Metadata["PartitionKey"] = strings.TrimPrefix(partitionKey, "/")
+	return nil
+}
+
+// cosmosGremlinEndpoint returns the Gremlin (WebSocket) endpoint for the account, e.g. wss://account.gremlin.cosmos.azure.com:443/
+func cosmosGremlinEndpoint(account *CosmosDbAccount) (string, error) {
+	documentEndpoint := account.Properties.DocumentEndpoint
+	if !strings.HasPrefix(documentEndpoint, "https://") || !strings.Contains(documentEndpoint, ".documents.") {
+		return "", fmt.Errorf("Unable to determine the Gremlin endpoint from document endpoint %q", documentEndpoint)
+	}
+	endpoint := "wss://" + strings.Replace(strings.TrimPrefix(documentEndpoint, "https://"), ".documents.", ".gremlin.cosmos.", 1)
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	return endpoint, nil
+}
+
+// connectGremlin connects to the Gremlin endpoint for the graph
+func (e *CosmosDbExpander) connectGremlin(ctx context.Context, item *TreeNode) (*gremlinClient, error) {
+	if err := e.ensureGremlinConnectionDetails(ctx, item); err != nil {
+		return nil, err
+	}
+	username := fmt.Sprintf("/dbs/%s/colls/%s", item.Metadata["DatabaseName"], item.Metadata["ContainerName"])
+	return dialGremlin(ctx, item.Metadata["GremlinEndpoint"], username, item.Metadata["AccountKey"])
+}
+
+func (e *CosmosDbExpander) expandGremlinElements(ctx context.Context, item *TreeNode) ExpanderResult {
+	if err := e.ensureGremlinConnectionDetails(ctx, item); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	elementType := cosmosdbGremlinVertex
+	query := cosmosdbGremlinVerticesQuery
+	if item.Metadata["ElementType"] == cosmosdbGremlinEdge {
+		elementType = cosmosdbGremlinEdge
+		query = cosmosdbGremlinEdgesQuery
+	}
+
+	response, err := e.queryDocuments(ctx, item.Metadata["AccountName"], item.Metadata["DatabaseName"], item.Metadata["ContainerName"], item.Metadata["AccountKey"], query, item.Metadata["ContinuationToken"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing graph: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	var list CosmosDbListDocumentResponse
+	if err = json.Unmarshal(response.Data, &list); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling response: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	partitionKey := item.Metadata["PartitionKey"]
+	nodes := []*TreeNode{}
+	for _, document := range list.Documents {
+		document, ok := document.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id := fmt.Sprintf("%v", document["id"])
+		label := fmt.Sprintf("%v", document["label"])
+
+		displayText := label + ": " + id
+		if elementType == cosmosdbGremlinEdge {
+			displayText = fmt.Sprintf("%s: %v -> %v", label, document["_vertexId"], document["_sink"])
+		}
+
+		metadata := gremlinNodeMetadata(item)
+		metadata["ItemID"] = id
+		metadata["PartitionKeyValue"] = ""
+		name := id
+		if partitionKey != "" {
+			partitionKeyValue, err := getJSONProperty(document, strings.Split(partitionKey, "/")...)
+			if err != nil {
+				return ExpanderResult{
+					Err:               fmt.Errorf("Error determining partition key value: %s", err),
+					IsPrimaryResponse: true,
+					SourceDescription: "CosmosDbExpander request",
+				}
+			}
+			partitionKeyJSON, err := json.Marshal([]interface{}{partitionKeyValue})
+			if err != nil {
+				return ExpanderResult{
+					Err:               fmt.Errorf("Error converting partition key value: %s", err),
+					IsPrimaryResponse: true,
+					SourceDescription: "CosmosDbExpander request",
+				}
+			}
+			metadata["PartitionKeyValue"] = string(partitionKeyJSON)
+			metadata["GremlinPartitionKeyValue"] = gremlinLiteral(partitionKeyValue)
+			name = fmt.Sprintf("%s %s", partitionKeyJSON, id)
+			if elementType == cosmosdbGremlinVertex {
+				displayText = style.Subtle(fmt.Sprintf("%v", partitionKeyValue)) + "\n  " + displayText
+			}
+		}
+
+		nodes = append(nodes, &TreeNode{
+			Parentid:              item.ID,
+			ID:                    item.ID + "/" + name,
+			Namespace:             "cosmosdb",
+			Name:                  name,
+			Display:               displayText,
+			ItemType:              elementType,
+			ExpandURL:             ExpandURLNotSupported,
+			DeleteURL:             item.ID + "/" + name,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata:              metadata,
+		})
+	}
+
+	if continuationToken := response.Headers.Get("x-ms-continuation"); continuationToken != "" {
+		metadata := gremlinNodeMetadata(item)
+		metadata["ContinuationToken"] = continuationToken
+		nodes = append(nodes, &TreeNode{
+			Parentid:      item.ID,
+			Namespace:     "cosmosdb",
+			ID:            item.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      cosmosdbListGremlinContinuation,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(response.Data), ResponseType: interfaces.ResponseJSON},
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// deleteGremlinElement drops the vertex or edge using Gremlin so that the edges of a vertex are dropped with it
+func (e *CosmosDbExpander) deleteGremlinElement(ctx context.Context, item *TreeNode) (bool, error) {
+	query := fmt.Sprintf("g.E(%s).drop()", gremlinString(item.Metadata["ItemID"]))
+	if item.ItemType == cosmosdbGremlinVertex {
+		query = fmt.Sprintf("g.V(%s)", gremlinString(item.Metadata["ItemID"]))
+		if partitionKey := item.Metadata["PartitionKey"]; partitionKey != "" {
+			query += fmt.Sprintf(".has(%s, %s)", gremlinString(partitionKey), item.Metadata["GremlinPartitionKeyValue"])
+		}
+		query += ".drop()"
+	}
+
+	client, err := e.connectGremlin(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close() //nolint: errcheck
+
+	if _, _, err = client.submit(query); err != nil {
+		return false, fmt.Errorf("Error dropping %s: %s", strings.TrimPrefix(item.ItemType, "gremlin-"), err)
+	}
+	return true, nil
+}
+
+// gremlinLiteral returns the Gremlin literal for a partition key value
+func gremlinLiteral(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return gremlinString(value)
+	case float64:
+		return fmt.Sprintf("%v", value)
+	case bool:
+		return fmt.Sprintf("%t", value)
+	default:
+		return gremlinString(fmt.Sprintf("%v", value))
+	}
+}
+
+// listGremlinActions returns the query action for a Gremlin graph
+func (e *CosmosDbExpander) listGremlinActions(item *TreeNode) []*TreeNode {
+	metadata, err := gremlinGraphMetadata(item)
+	if err != nil {
+		return []*TreeNode{}
+	}
+	metadata["ActionID"] = cosmosdbActionGremlinQuery
+
+	return []*TreeNode{
+		{
+			Parentid:              item.ID,
+			ID:                    item.ID + "?gremlin-query",
+			Namespace:             "cosmos-db",
+			Name:                  "Execute Gremlin Query",
+			Display:               "Execute Gremlin Query",
+			ItemType:              ActionType,
+			SuppressGenericExpand: true,
+			Metadata:              metadata,
+		},
+	}
+}
+
+func (e *CosmosDbExpander) cosmosdbActionGremlinQuery(ctx context.Context, item *TreeNode) ExpanderResult {
+	if err := e.ensureGremlinConnectionDetails(ctx, item); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	showResult := func(content string, contentType interfaces.ExpanderResponseType, title string) {
+		e.gui.Update(func(g *gocui.Gui) error {
+			e.contentPanel.SetContent(content, contentType, title)
+			return nil
+		})
+	}
+	commandPanelNotification := func(state interfaces.CommandPanelNotification) {
+		if !state.EnterPressed {
+			return
+		}
+		queryText := state.CurrentText
+		if armclient.IsReadOnlyMode() && isMutatingGremlinQuery(queryText) {
+			showResult("\n\n\n\nRead-only mode: queries which change the graph can't be run", interfaces.ResponsePlainText, "Error executing query")
+			return
+		}
+		// Run the query in the background so the UI stays responsive
+		go func() {
+			queryCtx, cancel := context.WithTimeout(context.Background(), cosmosdbGremlinQueryTimeout)
+			defer cancel()
+			result, requestCharge, err := e.executeGremlinQuery(queryCtx, item, queryText)
+			if err != nil {
+				showResult("\n\n\n\n"+err.Error(), interfaces.ResponsePlainText, "Error executing query")
+				return
+			}
+			showResult(result, interfaces.ResponseJSON, fmt.Sprintf("Results (%.2f RUs)", requestCharge))
+		}()
+	}
+	e.commandPanel.ShowWithText("gremlin:", "g.V().limit(10)", nil, commandPanelNotification)
+	// Force UI to re-render to pickup
+	e.gui.Update(func(g *gocui.Gui) error {
+		return nil
+	})
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "CosmosDbExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *CosmosDbExpander) executeGremlinQuery(ctx context.Context, item *TreeNode, queryText string) (string, float64, error) {
+	client, err := e.connectGremlin(ctx, item)
+	if err != nil {
+		return "", 0, err
+	}
+	defer client.Close() //nolint: errcheck
+
+	results, requestCharge, err := client.submit(queryText)
+	if err != nil {
+		return "", requestCharge, err
+	}
+	buf, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return "", requestCharge, fmt.Errorf("Error marshalling results: %s", err)
+	}
+	return string(buf), requestCharge, nil
+}
@@ -0,0 +1,533 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/editor"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const cosmosdbMongoCollectionTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/mongodbDatabases/{databaseName}/collections/{collectionName}"
+
+const (
+	cosmosdbListMongoDocuments             = "mongo-listdocs"
+	cosmosdbListMongoDocumentsContinuation = "mongo-listdocs-continue"
+	cosmosdbMongoDocument                  = "mongo-document"
+)
+
+const (
+	cosmosdbActionMongoFilter      = "mongo-filter"
+	cosmosdbActionMongoAddDocument = "mongo-add-document"
+)
+
+// cosmosdbMongoBatchSize is the number of documents listed per page
+const cosmosdbMongoBatchSize int64 = 50
+
+// CosmosDbMongoCollection is used to unmarshal the shard key from a MongoDB collection
+type CosmosDbMongoCollection struct {
+	Properties struct {
+		Resource struct {
+			ShardKey map[string]string `json:"shardKey"`
+		} `json:"resource"`
+	} `json:"properties"`
+}
+
+// mongoCollectionMetadata returns the metadata for connecting to the collection that item is (or is under)
+func mongoCollectionMetadata(item *TreeNode) (map[string]string, error) {
+	if item.Metadata["CollectionID"] != "" {
+		return mongoNodeMetadata(item), nil
+	}
+
+	tempItem := item
+	for tempItem.SwaggerResourceType == nil && tempItem.Parent != nil {
+		tempItem = tempItem.Parent
+	}
+	if tempItem.SwaggerResourceType == nil || tempItem.SwaggerResourceType.Endpoint.TemplateURL != cosmosdbMongoCollectionTemplateURL {
+		return nil, fmt.Errorf("Item isn't a MongoDB collection: %q", item.ID)
+	}
+	matchResult := tempItem.SwaggerResourceType.Endpoint.Match(tempItem.ID)
+	if !matchResult.IsMatch {
+		return nil, fmt.Errorf("Endpoint should match")
+	}
+	return map[string]string{
+		"AccountName":    matchResult.Values["accountName"],
+		"DatabaseName":   matchResult.Values["databaseName"],
+		"CollectionName": matchResult.Values["collectionName"],
+		"CollectionID":   tempItem.ID,
+		"Filter":         "{}",
+	}, nil
+}
+
+// mongoNodeMetadata returns the connection metadata from item without the node specific values
+func mongoNodeMetadata(item *TreeNode) map[string]string {
+	metadata := copyMetadata(item.Metadata)
+	for _, key := range []string{"ActionID", "Skip", "DocumentFilter"} {
+		delete(metadata, key)
+	}
+	return metadata
+}
+
+// connectMongo connects to the collection, saving the account's connection string in the item metadata
+func (e *CosmosDbExpander) connectMongo(ctx context.Context, item *TreeNode) (*mongoCollection, error) {
+	if item.Metadata["MongoConnectionString"] == "" {
+		account, err := e.getAccount(ctx, item)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting account: %s", err)
+		}
+		if apiKind := account.apiKind(); apiKind != cosmosdbAPIMongoDB {
+			return nil, fmt.Errorf("Account uses the %s API, not MongoDB", apiKind)
+		}
+		if account.Properties.APIProperties != nil && account.Properties.APIProperties.ServerVersion == "3.2" {
+			return nil, fmt.Errorf("MongoDB server version 3.2 isn't supported, upgrade the account to 3.6 or later")
+		}
+
+		// List Connection Strings docs: https://docs.microsoft.com/en-us/rest/api/cosmos-db-resource-provider/2021-04-15/database-accounts/list-connection-strings
+		data, err := e.armClient.DoRequest(ctx, "POST", cosmosDbAccountID(item.ID)+"/listConnectionStrings?api-version=2021-04-15")
+		if err != nil {
+			return nil, fmt.Errorf("Error getting connection strings: %s", err)
+		}
+		connectionString, err := mongoConnectionString(data, armclient.IsReadOnlyMode())
+		if err != nil {
+			return nil, err
+		}
+		item.Metadata["MongoConnectionString"] = connectionString
+	}
+
+	return dialMongo(ctx, item.Metadata["MongoConnectionString"], item.Metadata["DatabaseName"], item.Metadata["CollectionName"])
+}
+
+// ensureMongoShardKey saves the collection shard key (if any) in the item metadata
+func (e *CosmosDbExpander) ensureMongoShardKey(ctx context.Context, item *TreeNode) error {
+	if _, ok := item.Metadata["ShardKey"]; ok {
+		return nil
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "GET", item.Metadata["CollectionID"]+"?api-version=2021-04-15")
+	if err != nil {
+		return fmt.Errorf("Error getting collection: %s", err)
+	}
+	var collection CosmosDbMongoCollection
+	if err = json.Unmarshal([]byte(data), &collection); err != nil {
+		return fmt.Errorf("Error unmarshalling collection: %s", err)
+	}
+	item.Metadata["ShardKey"] = ""
+	for shardKey := range collection.Properties.Resource.ShardKey {
+		item.Metadata["ShardKey"] = shardKey
+	}
+	return nil
+}
+
+func (e *CosmosDbExpander) expandMongoDocuments(ctx context.Context, item *TreeNode) ExpanderResult {
+	var skip int64
+	if item.Metadata["Skip"] != "" {
+		var err error
+		if skip, err = strconv.ParseInt(item.Metadata["Skip"], 10, 64); err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Invalid page offset: %s", err),
+				IsPrimaryResponse: true,
+				SourceDescription: "CosmosDbExpander request",
+			}
+		}
+	}
+	filter, err := parseMongoDocument(item.Metadata["Filter"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Invalid filter: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	if err = e.ensureMongoShardKey(ctx, item); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	client, err := e.connectMongo(ctx, item)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	defer client.Close() //nolint: errcheck
+
+	documents, hasMore, err := client.findPage(ctx, filter, skip, cosmosdbMongoBatchSize)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting documents: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	content := []string{}
+	for _, document := range documents {
+		node, err := mongoDocumentNode(item, document)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				IsPrimaryResponse: true,
+				SourceDescription: "CosmosDbExpander request",
+			}
+		}
+		nodes = append(nodes, node)
+
+		documentJSON, err := formatMongoDocument(document)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error converting document to JSON: %s", err),
+				IsPrimaryResponse: true,
+				SourceDescription: "CosmosDbExpander request",
+			}
+		}
+		content = append(content, documentJSON)
+	}
+
+	if hasMore {
+		metadata := mongoNodeMetadata(item)
+		metadata["Skip"] = strconv.FormatInt(skip+int64(len(documents)), 10)
+		nodes = append(nodes, &TreeNode{
+			Parentid:      item.ID,
+			Namespace:     "cosmosdb",
+			ID:            item.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      cosmosdbListMongoDocumentsContinuation,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "[" + strings.Join(content, ",\n") + "]", ResponseType: interfaces.ResponseJSON},
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// mongoDocumentNode creates the node for a document, with the filter to find it again (by _id and shard key) in the metadata
+func mongoDocumentNode(parent *TreeNode, document bson.Raw) (*TreeNode, error) {
+	idValue, err := document.LookupErr("_id")
+	if err != nil {
+		return nil, fmt.Errorf("Document has no _id: %s", err)
+	}
+	filter := bson.D{{Key: "_id", Value: idValue}}
+	name := mongoDisplayValue(idValue)
+	displayText := name
+
+	if shardKey := parent.Metadata["ShardKey"]; shardKey != "" && shardKey != "_id" {
+		// Cosmos DB needs the shard key to target writes to a single document
+		if shardValue, err := document.LookupErr(strings.Split(shardKey, ".")...); err == nil {
+			filter = append(filter, bson.E{Key: shardKey, Value: shardValue})
+			name = mongoDisplayValue(shardValue) + " " + name
+			displayText = style.Subtle(mongoDisplayValue(shardValue)) + "\n  " + displayText
+		}
+	}
+	filterJSON, err := bson.MarshalExtJSON(filter, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("Error converting document ID to JSON: %s", err)
+	}
+
+	metadata := mongoNodeMetadata(parent)
+	metadata["DocumentFilter"] = string(filterJSON)
+	return &TreeNode{
+		Parentid:              parent.ID,
+		ID:                    parent.ID + "/" + name,
+		Namespace:             "cosmosdb",
+		Name:                  name,
+		Display:               displayText,
+		ItemType:              cosmosdbMongoDocument,
+		ExpandURL:             ExpandURLNotSupported,
+		DeleteURL:             parent.ID + "/" + name,
+		SuppressSwaggerExpand: true,
+		SuppressGenericExpand: true,
+		Metadata:              metadata,
+	}, nil
+}
+
+// mongoDisplayValue returns a short string for an _id or shard key value
+func mongoDisplayValue(value bson.RawValue) string {
+	switch value.Type {
+	case bson.TypeObjectID:
+		return value.ObjectID().Hex()
+	case bson.TypeString:
+		return value.StringValue()
+	case bson.TypeInt32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bson.TypeInt64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bson.TypeDouble:
+		return strconv.FormatFloat(value.Double(), 'g', -1, 64)
+	default:
+		return value.String()
+	}
+}
+
+// formatMongoDocument returns the document as canonical Extended JSON for display and editing. Relaxed Extended
+// JSON writes numbers as plain JSON, which parse back as the smallest type that fits (e.g. an Int64 of 5 would be
+// saved as an Int32), so every number is written with its type, e.g. {"$numberLong": "5"}
+func formatMongoDocument(document interface{}) (string, error) {
+	documentJSON, err := bson.MarshalExtJSONIndent(document, true, false, "", "  ")
+	return string(documentJSON), err
+}
+
+// parseMongoDocument parses a document (or filter) from canonical Extended JSON, e.g. {"_id": {"$oid": "..."}}.
+// Plain JSON numbers are still accepted, e.g. in filters
+func parseMongoDocument(content string) (bson.D, error) {
+	document := bson.D{}
+	if strings.TrimSpace(content) == "" {
+		return document, nil
+	}
+	if err := bson.UnmarshalExtJSON([]byte(content), true, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+func (e *CosmosDbExpander) expandMongoDocument(ctx context.Context, item *TreeNode) ExpanderResult {
+	filter, err := parseMongoDocument(item.Metadata["DocumentFilter"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Invalid document filter: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	client, err := e.connectMongo(ctx, item)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	defer client.Close() //nolint: errcheck
+
+	document, err := client.findOne(ctx, filter)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting document: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	if document == nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Document not found, it may have been deleted"),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	documentJSON, err := formatMongoDocument(document)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error converting document to JSON: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: documentJSON, ResponseType: interfaces.ResponseJSON},
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *CosmosDbExpander) updateMongoDocument(ctx context.Context, item *TreeNode, updatedContent string) error {
+	filter, err := parseMongoDocument(item.Metadata["DocumentFilter"])
+	if err != nil {
+		return fmt.Errorf("Invalid document filter: %s", err)
+	}
+	document, err := parseMongoDocument(updatedContent)
+	if err != nil {
+		return fmt.Errorf("Invalid document: %s", err)
+	}
+
+	client, err := e.connectMongo(ctx, item)
+	if err != nil {
+		return err
+	}
+	defer client.Close() //nolint: errcheck
+
+	result, err := client.ReplaceOne(ctx, filter, document)
+	if err != nil {
+		return fmt.Errorf("Error updating document: %s", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("Document not found, it may have been deleted")
+	}
+	return nil
+}
+
+func (e *CosmosDbExpander) deleteMongoDocument(ctx context.Context, item *TreeNode) (bool, error) {
+	filter, err := parseMongoDocument(item.Metadata["DocumentFilter"])
+	if err != nil {
+		return false, fmt.Errorf("Invalid document filter: %s", err)
+	}
+
+	client, err := e.connectMongo(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close() //nolint: errcheck
+
+	result, err := client.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, fmt.Errorf("Error deleting document: %s", err)
+	}
+	if result.DeletedCount == 0 {
+		return false, fmt.Errorf("Document not found, it may have already been deleted")
+	}
+	return true, nil
+}
+
+// listMongoActions returns the filter and add document actions for a MongoDB collection
+func (e *CosmosDbExpander) listMongoActions(item *TreeNode) []*TreeNode {
+	metadata, err := mongoCollectionMetadata(item)
+	if err != nil {
+		return []*TreeNode{}
+	}
+
+	filterMetadata := copyMetadata(metadata)
+	filterMetadata["ActionID"] = cosmosdbActionMongoFilter
+	addMetadata := copyMetadata(metadata)
+	addMetadata["ActionID"] = cosmosdbActionMongoAddDocument
+
+	return []*TreeNode{
+		{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?mongo-filter",
+			Namespace:              "cosmos-db",
+			Name:                   "Filter Documents",
+			Display:                "Filter Documents",
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               filterMetadata,
+		},
+		{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?mongo-add-document",
+			Namespace:              "cosmos-db",
+			Name:                   "Add New Document",
+			Display:                "Add New Document",
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               addMetadata,
+		},
+	}
+}
+
+func (e *CosmosDbExpander) cosmosdbActionMongoFilter(ctx context.Context, item *TreeNode) ExpanderResult {
+	filterText, _ := promptInCommandPanel(e.gui, e.commandPanel, "filter (e.g. {\"status\": \"active\"}, {} for all documents):", item.Metadata["Filter"], nil)
+	if filterText == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if _, err := parseMongoDocument(filterText); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Invalid filter: %s", err),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	item.Metadata["Filter"] = filterText
+	return e.expandMongoDocuments(ctx, item)
+}
+
+func (e *CosmosDbExpander) cosmosdbActionMongoAddDocument(ctx context.Context, item *TreeNode) ExpanderResult {
+	if armclient.IsReadOnlyMode() {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Read-only mode: documents can't be added"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	if err := e.ensureMongoShardKey(ctx, item); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	initialDocument := "{\n}"
+	if shardKey := item.Metadata["ShardKey"]; shardKey != "" && shardKey != "_id" {
+		initialDocument = fmt.Sprintf("{\n\t%q: \"\"\n}", shardKey)
+	}
+	initialContent := "// Fill out this document (Extended JSON) and save and exit to insert a new document. To cancel, leave the document as-is or delete the content\n" + initialDocument
+
+	content, err := editor.OpenForContent(initialContent, ".json")
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if content == initialContent || strings.TrimSpace(content) == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if strings.HasPrefix(content, "//") {
+		// remove the comment line we added!
+		newLineIndex := strings.Index(content, "\n")
+		content = content[newLineIndex+1:]
+	}
+
+	document, err := parseMongoDocument(content)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Invalid document: %s", err),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	client, err := e.connectMongo(ctx, item)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	defer client.Close() //nolint: errcheck
+
+	if _, err = client.InsertOne(ctx, document); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error inserting: %s", err),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		SourceDescription: "CosmosDbExpander request",
+		Response:          ExpanderResponse{ResponseType: interfaces.ResponsePlainText, Response: "Document added."},
+		IsPrimaryResponse: true,
+	}
+}
@@ -0,0 +1,174 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const cosmosdbSQLQueryContinuation = "sql-query-continue"
+
+const cosmosdbActionSQLQueryHistory = "sql-query-history"
+
+// sqlDocumentNode creates the node for a document. Returns nil if the document has no id, e.g. a query projection
+func sqlDocumentNode(parentID string, document interface{}, connectionDetails CosmosDbSqlConnectionDetails) (*TreeNode, error) {
+	documentMap, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	id, ok := documentMap["id"].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	idWithPartitionKey := id
+	displayText := id
+	partitionKeyValue := ""
+	if partitionKey := strings.TrimPrefix(connectionDetails.PartitionKey, "/"); partitionKey != "" {
+		// get the partitionKey value for the current document
+		v, err := getJSONProperty(documentMap, strings.Split(partitionKey, "/")...)
+		if err != nil {
+			return nil, fmt.Errorf("Error determining partition key value: %s", err)
+		}
+		vString := fmt.Sprintf("%v", v)
+		partitionKeyValue = fmt.Sprintf("[\"%s\"]", vString)
+		idWithPartitionKey = fmt.Sprintf("%s %s", partitionKeyValue, id)
+		displayText = style.Subtle(vString) + "\n  " + id
+	}
+
+	return &TreeNode{
+		Parentid:              parentID,
+		ID:                    parentID + "/" + idWithPartitionKey,
+		Namespace:             "cosmosdb",
+		Name:                  idWithPartitionKey,
+		Display:               displayText,
+		ItemType:              cosmosdbSQLDocument,
+		ExpandURL:             ExpandURLNotSupported,
+		DeleteURL:             parentID + "/" + idWithPartitionKey,
+		SuppressSwaggerExpand: true,
+		SuppressGenericExpand: true,
+		Metadata: map[string]string{
+			"AccountName":       connectionDetails.AccountName,
+			"DatabaseName":      connectionDetails.DatabaseName,
+			"ContainerName":     connectionDetails.ContainerName,
+			"AccountKey":        connectionDetails.AccountKey,
+			"PartitionKeyValue": partitionKeyValue,
+			"ItemID":            id,
+		},
+	}, nil
+}
+
+// expandSQLQueryResults runs the query and returns the results as document nodes, with a "more..." node to continue the query
+func (e *CosmosDbExpander) expandSQLQueryResults(ctx context.Context, item *TreeNode, connectionDetails CosmosDbSqlConnectionDetails, queryText string, continuationToken string) ExpanderResult {
+	response, err := e.queryDocuments(ctx, connectionDetails.AccountName, connectionDetails.DatabaseName, connectionDetails.ContainerName, connectionDetails.AccountKey, queryText, continuationToken)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error executing query: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	var list CosmosDbListDocumentResponse
+	if err = json.Unmarshal(response.Data, &list); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling response: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, document := range list.Documents {
+		// Results without an id or partition key (e.g. projections or aggregates) are only shown in the response
+		if node, err := sqlDocumentNode(item.ID, document, connectionDetails); err == nil && node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if continuationToken := response.Headers.Get("x-ms-continuation"); continuationToken != "" {
+		nodes = append(nodes, &TreeNode{
+			Parentid:      item.ID,
+			Namespace:     "cosmosdb",
+			ID:            item.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      cosmosdbSQLQueryContinuation,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata: map[string]string{
+				"AccountName":       connectionDetails.AccountName,
+				"DatabaseName":      connectionDetails.DatabaseName,
+				"ContainerName":     connectionDetails.ContainerName,
+				"AccountKey":        connectionDetails.AccountKey,
+				"PartitionKey":      connectionDetails.PartitionKey,
+				"Query":             queryText,
+				"ContinuationToken": continuationToken,
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     string(response.Data),
+			ResponseType: interfaces.ResponseJSON,
+			Title:        cosmosDbQueryTitle(response.Headers),
+		},
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+		SourceDescription: "CosmosDbExpander request",
+	}
+}
+
+func (e *CosmosDbExpander) expandSQLQueryContinuation(ctx context.Context, item *TreeNode) ExpanderResult {
+	connectionDetails := CosmosDbSqlConnectionDetails{
+		AccountName:   item.Metadata["AccountName"],
+		DatabaseName:  item.Metadata["DatabaseName"],
+		ContainerName: item.Metadata["ContainerName"],
+		AccountKey:    item.Metadata["AccountKey"],
+		PartitionKey:  item.Metadata["PartitionKey"],
+	}
+	return e.expandSQLQueryResults(ctx, item, connectionDetails, item.Metadata["Query"], item.Metadata["ContinuationToken"])
+}
+
+// cosmosDbQueryTitle returns a title showing the request charge and index utilization of a query response
+func cosmosDbQueryTitle(headers http.Header) string {
+	details := []string{}
+	if requestCharge := headers.Get("x-ms-request-charge"); requestCharge != "" {
+		details = append(details, requestCharge+" RUs")
+	}
+	if indexUtilization, ok := cosmosDbIndexUtilization(headers.Get("x-ms-documentdb-query-metrics")); ok {
+		details = append(details, fmt.Sprintf("index utilization %.0f%%", indexUtilization*100))
+	}
+
+	if len(details) == 0 {
+		return "Query results"
+	}
+	return "Query results (" + strings.Join(details, ", ") + ")"
+}
+
+// cosmosDbIndexUtilization gets the indexUtilizationRatio from query metrics, e.g. totalExecutionTimeInMs=0.51;indexUtilizationRatio=1.00;...
+func cosmosDbIndexUtilization(queryMetrics string) (float64, bool) {
+	for _, metric := range strings.Split(queryMetrics, ";") {
+		name, value, found := strings.Cut(metric, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "indexUtilizationRatio") {
+			continue
+		}
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0, false
+		}
+		return ratio, true
+	}
+	return 0, false
+}
+
+func cosmosDbQueryHistoryKey(connectionDetails CosmosDbSqlConnectionDetails) string {
+	return resourceCacheKey("CosmosDbQueryHistory", connectionDetails.AccountName+"/"+connectionDetails.DatabaseName+"/"+connectionDetails.ContainerName)
+}
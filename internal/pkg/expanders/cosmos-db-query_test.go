@@ -0,0 +1,32 @@
+package expanders
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cosmosDbQueryTitle(t *testing.T) {
+	headers := http.Header{}
+	assert.Equal(t, "Query results", cosmosDbQueryTitle(headers))
+
+	headers.Set("x-ms-request-charge", "2.89")
+	headers.Set("x-ms-documentdb-query-metrics", "totalExecutionTimeInMs=0.51;queryCompileTimeInMs=0.05;indexUtilizationRatio=0.25;retrievedDocumentCount=4")
+	assert.Equal(t, "Query results (2.89 RUs, index utilization 25%)", cosmosDbQueryTitle(headers))
+}
+
+func Test_sqlDocumentNode(t *testing.T) {
+	connectionDetails := CosmosDbSqlConnectionDetails{AccountName: "account", DatabaseName: "db", ContainerName: "orders", PartitionKey: "/customer/id"}
+
+	node, err := sqlDocumentNode("parent", map[string]interface{}{"id": "order-1", "customer": map[string]interface{}{"id": "c1"}}, connectionDetails)
+	assert.NoError(t, err)
+	assert.Equal(t, `["c1"] order-1`, node.Name)
+	assert.Equal(t, `["c1"]`, node.Metadata["PartitionKeyValue"])
+	assert.Equal(t, "order-1", node.Metadata["ItemID"])
+
+	// projections without an id can't be navigated to
+	node, err = sqlDocumentNode("parent", map[string]interface{}{"total": 42}, connectionDetails)
+	assert.NoError(t, err)
+	assert.Nil(t, node)
+}
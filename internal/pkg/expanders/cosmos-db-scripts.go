@@ -0,0 +1,354 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+const (
+	cosmosdbListSQLScripts = "sql-listscripts"
+	cosmosdbSQLScript      = "sql-script"
+)
+
+const cosmosdbActionExecuteStoredProcedure = "execute-sproc"
+
+// cosmosdbStoredProcedureDefaultParameters is the parameters value for a stored procedure that takes no parameters
+const cosmosdbStoredProcedureDefaultParameters = "[]"
+
+// Script types, which are also the REST resource types
+const (
+	cosmosdbScriptStoredProcedures     = "sprocs"
+	cosmosdbScriptTriggers             = "triggers"
+	cosmosdbScriptUserDefinedFunctions = "udfs"
+)
+
+// cosmosdbScriptTypeNames are the display names for the script types, in the order they are shown
+var cosmosdbScriptTypeNames = []struct {
+	ScriptType string
+	Name       string
+}{
+	{cosmosdbScriptStoredProcedures, "Stored Procedures"},
+	{cosmosdbScriptTriggers, "Triggers"},
+	{cosmosdbScriptUserDefinedFunctions, "User Defined Functions"},
+}
+
+// CosmosDbScript is used to unmarshal a stored procedure, trigger or user defined function
+type CosmosDbScript struct {
+	ID               string `json:"id"`
+	Body             string `json:"body"`
+	TriggerType      string `json:"triggerType,omitempty"`
+	TriggerOperation string `json:"triggerOperation,omitempty"`
+}
+
+// CosmosDbListScriptsResponse is used to unmarshal a list of scripts
+type CosmosDbListScriptsResponse struct {
+	StoredProcedures     []CosmosDbScript `json:"StoredProcedures"`
+	Triggers             []CosmosDbScript `json:"Triggers"`
+	UserDefinedFunctions []CosmosDbScript `json:"UserDefinedFunctions"`
+}
+
+// sqlScriptListNodes returns the Stored Procedures, Triggers and User Defined Functions nodes for a container
+func sqlScriptListNodes(parentID string, accountName string, databaseName string, containerName string) []*TreeNode {
+	nodes := []*TreeNode{}
+	for _, scriptType := range cosmosdbScriptTypeNames {
+		nodes = append(nodes, &TreeNode{
+			Parentid:              parentID,
+			ID:                    parentID + "/<" + scriptType.ScriptType + ">",
+			Namespace:             "cosmosdb",
+			Name:                  scriptType.Name,
+			Display:               scriptType.Name,
+			ItemType:              cosmosdbListSQLScripts,
+			ExpandURL:             ExpandURLNotSupported,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata: map[string]string{
+				"AccountName":   accountName,
+				"DatabaseName":  databaseName,
+				"ContainerName": containerName,
+				"ScriptType":    scriptType.ScriptType,
+			},
+		})
+	}
+	return nodes
+}
+
+func (e *CosmosDbExpander) expandSQLScripts(ctx context.Context, item *TreeNode) ExpanderResult {
+	accountKey, err := e.getAccountKey(ctx, item)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting account key: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	scriptType := item.Metadata["ScriptType"]
+	requestURL := fmt.Sprintf("/dbs/%s/colls/%s/%s", item.Metadata["DatabaseName"], item.Metadata["ContainerName"], scriptType)
+	response, err := e.doRequest(ctx, "GET", item.Metadata["AccountName"], requestURL, accountKey)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing %s: %s", item.Name, err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	if !e.isSuccessCode(response.StatusCode) {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing %s. StatusCode=%d, Response=%s", item.Name, response.StatusCode, string(response.Data)),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	var list CosmosDbListScriptsResponse
+	if err = json.Unmarshal(response.Data, &list); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling response: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	scripts := list.StoredProcedures
+	switch scriptType {
+	case cosmosdbScriptTriggers:
+		scripts = list.Triggers
+	case cosmosdbScriptUserDefinedFunctions:
+		scripts = list.UserDefinedFunctions
+	}
+
+	nodes := []*TreeNode{}
+	for _, script := range scripts {
+		display := script.ID
+		if script.TriggerType != "" {
+			display += " " + style.Subtle("("+script.TriggerType+" "+script.TriggerOperation+")")
+		}
+		nodes = append(nodes, &TreeNode{
+			Parentid:              item.ID,
+			ID:                    item.ID + "/" + script.ID,
+			Namespace:             "cosmosdb",
+			Name:                  script.ID,
+			Display:               display,
+			ItemType:              cosmosdbSQLScript,
+			ExpandURL:             ExpandURLNotSupported,
+			DeleteURL:             item.ID + "/" + script.ID,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata: map[string]string{
+				"AccountName":      item.Metadata["AccountName"],
+				"DatabaseName":     item.Metadata["DatabaseName"],
+				"ContainerName":    item.Metadata["ContainerName"],
+				"AccountKey":       accountKey,
+				"ScriptType":       scriptType,
+				"ScriptID":         script.ID,
+				"TriggerType":      script.TriggerType,
+				"TriggerOperation": script.TriggerOperation,
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(response.Data), ResponseType: interfaces.ResponseJSON},
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+		SourceDescription: "CosmosDbExpander request",
+	}
+}
+
+func sqlScriptURL(item *TreeNode) string {
+	return fmt.Sprintf("/dbs/%s/colls/%s/%s/%s", item.Metadata["DatabaseName"], item.Metadata["ContainerName"], item.Metadata["ScriptType"], item.Metadata["ScriptID"])
+}
+
+// expandSQLScript shows the JavaScript body of the script so that it can be edited
+func (e *CosmosDbExpander) expandSQLScript(ctx context.Context, item *TreeNode) ExpanderResult {
+	response, err := e.doRequest(ctx, "GET", item.Metadata["AccountName"], sqlScriptURL(item), item.Metadata["AccountKey"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting script: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	if !e.isSuccessCode(response.StatusCode) {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting script. StatusCode=%d, Response=%s", response.StatusCode, string(response.Data)),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+
+	var script CosmosDbScript
+	if err = json.Unmarshal(response.Data, &script); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling response: %s", err),
+			IsPrimaryResponse: true,
+			SourceDescription: "CosmosDbExpander request",
+		}
+	}
+	item.Metadata["TriggerType"] = script.TriggerType
+	item.Metadata["TriggerOperation"] = script.TriggerOperation
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: script.Body, ResponseType: interfaces.ResponsePlainText},
+		IsPrimaryResponse: true,
+		SourceDescription: "CosmosDbExpander request",
+	}
+}
+
+// updateSQLScript replaces the script body, keeping the trigger type and operation for triggers
+func (e *CosmosDbExpander) updateSQLScript(ctx context.Context, item *TreeNode, updatedContent string) error {
+	script := CosmosDbScript{
+		ID:   item.Metadata["ScriptID"],
+		Body: updatedContent,
+	}
+	if item.Metadata["ScriptType"] == cosmosdbScriptTriggers {
+		script.TriggerType = item.Metadata["TriggerType"]
+		script.TriggerOperation = item.Metadata["TriggerOperation"]
+	}
+	buf, err := json.Marshal(script)
+	if err != nil {
+		return fmt.Errorf("Error marshalling script: %s", err)
+	}
+
+	response, err := e.doRequestWithHeadersAndBody(ctx, "PUT", item.Metadata["AccountName"], sqlScriptURL(item), item.Metadata["AccountKey"], map[string]string{}, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("Error updating script: %s", err)
+	}
+	if !e.isSuccessCode(response.StatusCode) {
+		return fmt.Errorf("Error updating script. StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	return nil
+}
+
+func (e *CosmosDbExpander) deleteSQLScript(ctx context.Context, item *TreeNode) (bool, error) {
+	response, err := e.doRequest(ctx, "DELETE", item.Metadata["AccountName"], sqlScriptURL(item), item.Metadata["AccountKey"])
+	if err != nil {
+		return false, fmt.Errorf("Error deleting script: %s", err)
+	}
+	if !e.isSuccessCode(response.StatusCode) {
+		return false, fmt.Errorf("Error deleting script. StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	return true, nil
+}
+
+// listSQLScriptActions returns the execute action for a stored procedure
+func (e *CosmosDbExpander) listSQLScriptActions(item *TreeNode) []*TreeNode {
+	if item.ItemType != cosmosdbSQLScript || item.Metadata["ScriptType"] != cosmosdbScriptStoredProcedures {
+		return []*TreeNode{}
+	}
+	// Executing waits on the command panel so allow extra time for the user to respond
+	metadata := copyMetadata(item.Metadata)
+	metadata["ActionID"] = cosmosdbActionExecuteStoredProcedure
+
+	return []*TreeNode{
+		{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?execute-sproc",
+			Namespace:              "cosmos-db",
+			Name:                   "Execute Stored Procedure",
+			Display:                "Execute Stored Procedure",
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               metadata,
+		},
+	}
+}
+
+func (e *CosmosDbExpander) cosmosdbActionExecuteStoredProcedure(ctx context.Context, item *TreeNode) ExpanderResult {
+	partitionKey, err := e.getCollectionPartitionKey(ctx, item.Metadata["AccountName"], item.Metadata["DatabaseName"], item.Metadata["ContainerName"], item.Metadata["AccountKey"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting partition key: %s", err),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	headers := map[string]string{
+		"Content-Type":                          "application/json",
+		"x-ms-documentdb-script-enable-logging": "true",
+	}
+	if partitionKey != "" {
+		// Stored procedures run in the scope of a single partition. Entering nothing (or closing the prompt)
+		// cancels, an empty string partition key value can be given as ""
+		partitionKeyValue, _ := promptInCommandPanel(e.gui, e.commandPanel, "partition key ("+partitionKey+`, "" for an empty string):`, "", nil)
+		if partitionKeyValue == "" {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Cancelled"),
+				SourceDescription: "CosmosDbExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		headers["x-ms-documentdb-partitionkey"] = cosmosDbPartitionKeyHeader(partitionKeyValue)
+	}
+	// Closing the prompt cancels, stored procedures without parameters are run with []
+	parameters, _ := promptInCommandPanel(e.gui, e.commandPanel, "parameters (JSON array, [] for none):", cosmosdbStoredProcedureDefaultParameters, nil)
+	if parameters == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cancelled"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if !json.Valid([]byte(parameters)) || !strings.HasPrefix(parameters, "[") {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Parameters must be a JSON array, e.g. [\"value\", 42]"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	response, err := e.doRequestWithHeadersAndBody(ctx, "POST", item.Metadata["AccountName"], sqlScriptURL(item), item.Metadata["AccountKey"], headers, strings.NewReader(parameters))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error executing stored procedure: %s", err),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if !e.isSuccessCode(response.StatusCode) {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error executing stored procedure. StatusCode=%d, Response=%s", response.StatusCode, string(response.Data)),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	title := "Stored procedure result"
+	if requestCharge := response.Headers.Get("x-ms-request-charge"); requestCharge != "" {
+		title += " (" + requestCharge + " RUs)"
+	}
+	result := ExpanderResponse{Response: string(response.Data), ResponseType: interfaces.ResponseJSON, Title: title}
+
+	// Include anything the stored procedure wrote with console.log
+	if scriptLog := response.Headers.Get("x-ms-documentdb-script-log-results"); scriptLog != "" {
+		if decodedLog, err := url.QueryUnescape(scriptLog); err == nil {
+			scriptLog = decodedLog
+		}
+		result.Response = string(response.Data) + "\n\nScript log:\n" + scriptLog
+		result.ResponseType = interfaces.ResponsePlainText
+	}
+
+	return ExpanderResult{
+		Response:          result,
+		SourceDescription: "CosmosDbExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// cosmosDbPartitionKeyHeader returns the partition key header value for a value entered by the user.
+// JSON numbers, booleans and quoted strings are used as-is, anything else is treated as a string
+func cosmosDbPartitionKeyHeader(value string) string {
+	if json.Valid([]byte(value)) && !strings.HasPrefix(value, "[") && !strings.HasPrefix(value, "{") {
+		return "[" + value + "]"
+	}
+	buf, _ := json.Marshal([]string{value})
+	return string(buf)
+}
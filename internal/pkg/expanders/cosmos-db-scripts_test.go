@@ -0,0 +1,61 @@
+package expanders
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func Test_cosmosDbPartitionKeyHeader(t *testing.T) {
+	assert.Equal(t, `["customer-1"]`, cosmosDbPartitionKeyHeader("customer-1"))
+	assert.Equal(t, `["42"]`, cosmosDbPartitionKeyHeader(`"42"`))
+	assert.Equal(t, `[42]`, cosmosDbPartitionKeyHeader("42"))
+	assert.Equal(t, `[true]`, cosmosDbPartitionKeyHeader("true"))
+	assert.Equal(t, `["{\"a\":1}"]`, cosmosDbPartitionKeyHeader(`{"a":1}`))
+	assert.Equal(t, `[""]`, cosmosDbPartitionKeyHeader(`""`))
+}
+
+func Test_CosmosDb_CancellingStoredProcedurePromptsSendsNoRequest(t *testing.T) {
+	defer gock.Off()
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	mockPartitionKey := func() {
+		gock.New("https://acct.documents.azure.com").
+			Get("/dbs/db/colls/orders").
+			Reply(200).
+			JSON(`{"id": "orders", "partitionKey": {"paths": ["/customerId"]}}`)
+	}
+	item := &TreeNode{
+		ItemType: ActionType,
+		Metadata: map[string]string{
+			"AccountName":   "acct",
+			"AccountKey":    "a2V5",
+			"DatabaseName":  "db",
+			"ContainerName": "orders",
+			"ScriptType":    "sprocs",
+			"ScriptID":      "bulkImport",
+		},
+	}
+
+	// Closing the partition key prompt
+	mockPartitionKey()
+	g, commandPanel := newTestPrompt(t)
+	e := &CosmosDbExpander{client: httpClient, gui: g, commandPanel: commandPanel}
+	result := e.cosmosdbActionExecuteStoredProcedure(context.Background(), item)
+	assert.EqualError(t, result.Err, "Cancelled")
+
+	// Closing the parameters prompt
+	mockPartitionKey()
+	g, commandPanel = newTestPrompt(t, "customer-1")
+	e = &CosmosDbExpander{client: httpClient, gui: g, commandPanel: commandPanel}
+	result = e.cosmosdbActionExecuteStoredProcedure(context.Background(), item)
+	assert.EqualError(t, result.Err, "Cancelled")
+	assert.Len(t, commandPanel.titles, 2)
+
+	// Only the partition key was looked up, the stored procedure wasn't run
+	assert.True(t, gock.IsDone())
+	assert.False(t, gock.HasUnmatchedRequest())
+}
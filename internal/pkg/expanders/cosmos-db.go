@@ -19,8 +19,8 @@ import (
 
 	"github.com/awesome-gocui/gocui"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/editor"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
-	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 )
@@ -41,10 +41,54 @@ var _ Expander = &CosmosDbExpander{}
 
 // CosmosDbListKeyResponse is used to unmarshal a call to listKeys on a storage account
 type CosmosDbListKeyResponse struct {
-	PrimaryMasterKey string `json:"primaryMasterKey"`
+	PrimaryMasterKey         string `json:"primaryMasterKey"`
+	PrimaryReadonlyMasterKey string `json:"primaryReadonlyMasterKey"`
 	// other properties not needed
 }
 
+// CosmosDbAccount is used to unmarshal the API details of a Cosmos DB account
+type CosmosDbAccount struct {
+	Kind       string `json:"kind"`
+	Properties struct {
+		DocumentEndpoint string `json:"documentEndpoint"`
+		TableEndpoint    string `json:"tableEndpoint"`
+		APIProperties    *struct {
+			ServerVersion string `json:"serverVersion"`
+		} `json:"apiProperties"`
+		Capabilities []struct {
+			Name string `json:"name"`
+		} `json:"capabilities"`
+	} `json:"properties"`
+}
+
+const (
+	cosmosdbAPISQL       = "SQL"
+	cosmosdbAPIMongoDB   = "MongoDB"
+	cosmosdbAPIGremlin   = "Gremlin"
+	cosmosdbAPITable     = "Table"
+	cosmosdbAPICassandra = "Cassandra"
+)
+
+// apiKind returns the API the account was created for
+func (a *CosmosDbAccount) apiKind() string {
+	if strings.EqualFold(a.Kind, "MongoDB") {
+		return cosmosdbAPIMongoDB
+	}
+	for _, capability := range a.Properties.Capabilities {
+		switch strings.ToLower(capability.Name) {
+		case "enablegremlin":
+			return cosmosdbAPIGremlin
+		case "enabletable":
+			return cosmosdbAPITable
+		case "enablecassandra":
+			return cosmosdbAPICassandra
+		case "enablemongo":
+			return cosmosdbAPIMongoDB
+		}
+	}
+	return cosmosdbAPISQL
+}
+
 // CosmosDbListDocumentResponse is used to unmarshal a ListDocuments response
 type CosmosDbListDocumentResponse struct {
 	Documents []interface{} `json:"Documents"`
@@ -101,11 +145,14 @@ func (e *CosmosDbExpander) Name() string {
 	return "CosmosDbExpander"
 }
 
-// DoesExpand checks if this is a Cosmos DB account
+// DoesExpand checks if this is a Cosmos DB SQL container, MongoDB collection or Gremlin graph
 func (e *CosmosDbExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
 	swaggerResourceType := currentItem.SwaggerResourceType
 	if currentItem.ItemType == SubResourceType && swaggerResourceType != nil {
-		if swaggerResourceType.Endpoint.TemplateURL == "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/sqlDatabases/{databaseName}/containers/{containerName}" {
+		switch swaggerResourceType.Endpoint.TemplateURL {
+		case "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/sqlDatabases/{databaseName}/containers/{containerName}",
+			cosmosdbMongoCollectionTemplateURL,
+			cosmosdbGremlinGraphTemplateURL:
 			return true, nil
 		}
 	}
@@ -142,6 +189,78 @@ func (e *CosmosDbExpander) Expand(ctx context.Context, currentItem *TreeNode) Ex
 					"ContainerName": matchResult.Values["containerName"],
 				},
 			})
+			newItems = append(newItems, sqlScriptListNodes(currentItem.ID, matchResult.Values["accountName"], matchResult.Values["databaseName"], matchResult.Values["containerName"])...)
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "CosmosDbExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	if currentItem.Namespace != "cosmosdb" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == cosmosdbMongoCollectionTemplateURL {
+		if metadata, err := mongoCollectionMetadata(currentItem); err == nil {
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<documents>",
+				Namespace:             "cosmosdb",
+				Name:                  "Documents",
+				Display:               "Documents",
+				ItemType:              cosmosdbListMongoDocuments,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata:              metadata,
+			})
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "CosmosDbExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	if currentItem.Namespace != "cosmosdb" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == cosmosdbGremlinGraphTemplateURL {
+		if metadata, err := gremlinGraphMetadata(currentItem); err == nil {
+			verticesMetadata := copyMetadata(metadata)
+			verticesMetadata["ElementType"] = cosmosdbGremlinVertex
+			edgesMetadata := copyMetadata(metadata)
+			edgesMetadata["ElementType"] = cosmosdbGremlinEdge
+			newItems = append(newItems,
+				&TreeNode{
+					Parentid:              currentItem.ID,
+					ID:                    currentItem.ID + "/<vertices>",
+					Namespace:             "cosmosdb",
+					Name:                  "Vertices",
+					Display:               "Vertices",
+					ItemType:              cosmosdbListGremlinVertices,
+					ExpandURL:             ExpandURLNotSupported,
+					SuppressSwaggerExpand: true,
+					SuppressGenericExpand: true,
+					Metadata:              verticesMetadata,
+				},
+				&TreeNode{
+					Parentid:              currentItem.ID,
+					ID:                    currentItem.ID + "/<edges>",
+					Namespace:             "cosmosdb",
+					Name:                  "Edges",
+					Display:               "Edges",
+					ItemType:              cosmosdbListGremlinEdges,
+					ExpandURL:             ExpandURLNotSupported,
+					SuppressSwaggerExpand: true,
+					SuppressGenericExpand: true,
+					Metadata:              edgesMetadata,
+				})
 		}
 
 		return ExpanderResult{
@@ -158,8 +277,20 @@ func (e *CosmosDbExpander) Expand(ctx context.Context, currentItem *TreeNode) Ex
 		return e.expandSQLDocuments(ctx, currentItem)
 	case cosmosdbListSQLDocumentsContinuation:
 		return e.expandSQLDocumentsContinuation(ctx, currentItem)
-	case cosmosdbSQLDocument:
+	case cosmosdbSQLDocument, cosmosdbGremlinVertex, cosmosdbGremlinEdge:
 		return e.expandSQLDocumentNode(ctx, currentItem)
+	case cosmosdbSQLQueryContinuation:
+		return e.expandSQLQueryContinuation(ctx, currentItem)
+	case cosmosdbListSQLScripts:
+		return e.expandSQLScripts(ctx, currentItem)
+	case cosmosdbSQLScript:
+		return e.expandSQLScript(ctx, currentItem)
+	case cosmosdbListMongoDocuments, cosmosdbListMongoDocumentsContinuation:
+		return e.expandMongoDocuments(ctx, currentItem)
+	case cosmosdbMongoDocument:
+		return e.expandMongoDocument(ctx, currentItem)
+	case cosmosdbListGremlinVertices, cosmosdbListGremlinEdges, cosmosdbListGremlinContinuation:
+		return e.expandGremlinElements(ctx, currentItem)
 	}
 
 	return ExpanderResult{
@@ -172,7 +303,7 @@ func (e *CosmosDbExpander) Expand(ctx context.Context, currentItem *TreeNode) Ex
 // CanUpdate indicates if the item can be updated
 func (e CosmosDbExpander) CanUpdate(ctx context.Context, item *TreeNode) (bool, error) {
 	switch item.ItemType {
-	case cosmosdbSQLDocument:
+	case cosmosdbSQLDocument, cosmosdbSQLScript, cosmosdbMongoDocument, cosmosdbGremlinVertex, cosmosdbGremlinEdge:
 		return true, nil
 	default:
 		return false, nil
@@ -182,8 +313,13 @@ func (e CosmosDbExpander) CanUpdate(ctx context.Context, item *TreeNode) (bool,
 // Update updates the item
 func (e *CosmosDbExpander) Update(ctx context.Context, item *TreeNode, updatedContent string) error {
 	switch item.ItemType {
-	case cosmosdbSQLDocument:
+	case cosmosdbSQLDocument, cosmosdbGremlinVertex, cosmosdbGremlinEdge:
+		// Graph elements are updated as the documents they are stored as
 		return e.updateSQLDocument(ctx, item, updatedContent)
+	case cosmosdbSQLScript:
+		return e.updateSQLScript(ctx, item, updatedContent)
+	case cosmosdbMongoDocument:
+		return e.updateMongoDocument(ctx, item, updatedContent)
 	default:
 		return fmt.Errorf("Unsupported item type: %s", item.ItemType)
 	}
@@ -194,6 +330,12 @@ func (e *CosmosDbExpander) Delete(ctx context.Context, item *TreeNode) (bool, er
 	switch item.ItemType {
 	case cosmosdbSQLDocument:
 		return e.deleteSQLDocument(ctx, item)
+	case cosmosdbSQLScript:
+		return e.deleteSQLScript(ctx, item)
+	case cosmosdbMongoDocument:
+		return e.deleteMongoDocument(ctx, item)
+	case cosmosdbGremlinVertex, cosmosdbGremlinEdge:
+		return e.deleteGremlinElement(ctx, item)
 	}
 	return false, nil
 }
@@ -213,6 +355,10 @@ func (e *CosmosDbExpander) HasActions(context context.Context, item *TreeNode) (
 		if strings.HasPrefix(swaggerResourceType.Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/sqlDatabases/{databaseName}/containers/{containerName}") {
 			return true, nil
 		}
+		if swaggerResourceType.Endpoint.TemplateURL == cosmosdbMongoCollectionTemplateURL ||
+			swaggerResourceType.Endpoint.TemplateURL == cosmosdbGremlinGraphTemplateURL {
+			return true, nil
+		}
 	}
 	return false, nil
 }
@@ -244,6 +390,7 @@ func (e *CosmosDbExpander) ListActions(context context.Context, item *TreeNode)
 				})
 		}
 		if strings.HasPrefix(swaggerResourceType.Endpoint.TemplateURL, "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/sqlDatabases/{databaseName}/containers/{containerName}") {
+			// Queries wait on the command panel so allow extra time for the user to respond
 			nodes = append(nodes,
 				&TreeNode{
 					Parentid:              item.ID,
@@ -258,17 +405,31 @@ func (e *CosmosDbExpander) ListActions(context context.Context, item *TreeNode)
 					},
 				},
 				&TreeNode{
-					Parentid:              item.ID,
-					ID:                    item.ID + "?sql-query",
-					Namespace:             "cosmos-db",
-					Name:                  "Execute Query",
-					Display:               "Execute Query",
-					ItemType:              ActionType,
-					SuppressGenericExpand: true,
+					Parentid:               item.ID,
+					ID:                     item.ID + "?sql-query",
+					Namespace:              "cosmos-db",
+					Name:                   "Execute Query",
+					Display:                "Execute Query",
+					ItemType:               ActionType,
+					SuppressGenericExpand:  true,
+					TimeoutOverrideSeconds: promptTimeout(),
 					Metadata: map[string]string{
 						"ActionID": cosmosdbActionSQLQuery,
 					},
 				},
+				&TreeNode{
+					Parentid:               item.ID,
+					ID:                     item.ID + "?sql-query-history",
+					Namespace:              "cosmos-db",
+					Name:                   "Query History",
+					Display:                "Query History",
+					ItemType:               ActionType,
+					SuppressGenericExpand:  true,
+					TimeoutOverrideSeconds: promptTimeout(),
+					Metadata: map[string]string{
+						"ActionID": cosmosdbActionSQLQueryHistory,
+					},
+				},
 				&TreeNode{
 					Parentid:              item.ID,
 					ID:                    item.ID + "?sql-query",
@@ -281,6 +442,13 @@ func (e *CosmosDbExpander) ListActions(context context.Context, item *TreeNode)
 						"ActionID": cosmosdbActionAddDocument,
 					},
 				})
+			nodes = append(nodes, e.listSQLScriptActions(item)...)
+		}
+		switch swaggerResourceType.Endpoint.TemplateURL {
+		case cosmosdbMongoCollectionTemplateURL:
+			nodes = append(nodes, e.listMongoActions(item)...)
+		case cosmosdbGremlinGraphTemplateURL:
+			nodes = append(nodes, e.listGremlinActions(item)...)
 		}
 	}
 
@@ -304,6 +472,16 @@ func (e *CosmosDbExpander) ExecuteAction(context context.Context, item *TreeNode
 		return e.cosmosdbActionAddDocument(context, item)
 	case cosmosdbActionSQLQuery:
 		return e.cosmosdbActionExecuteQuery(context, item)
+	case cosmosdbActionSQLQueryHistory:
+		return e.cosmosdbActionQueryHistory(context, item)
+	case cosmosdbActionExecuteStoredProcedure:
+		return e.cosmosdbActionExecuteStoredProcedure(context, item)
+	case cosmosdbActionMongoFilter:
+		return e.cosmosdbActionMongoFilter(context, item)
+	case cosmosdbActionMongoAddDocument:
+		return e.cosmosdbActionMongoAddDocument(context, item)
+	case cosmosdbActionGremlinQuery:
+		return e.cosmosdbActionGremlinQuery(context, item)
 	case "":
 		return ExpanderResult{
 			SourceDescription: "CosmosDbExpander",
@@ -359,8 +537,6 @@ func (e *CosmosDbExpander) expandSQLDocumentsContinuation(ctx context.Context, i
 }
 
 func (e *CosmosDbExpander) expandSQLDocumentsCommon(ctx context.Context, item *TreeNode, accountName string, databaseName string, containerName string, accountKey string, partitionKey string, continuationToken string) ExpanderResult {
-	partitionKeySegments := strings.Split(partitionKey, "/")
-
 	requestURL := fmt.Sprintf("/dbs/%s/colls/%s/docs", databaseName, containerName)
 	headers := map[string]string{}
 	if continuationToken != "" {
@@ -396,49 +572,26 @@ func (e *CosmosDbExpander) expandSQLDocumentsCommon(ctx context.Context, item *T
 		}
 	}
 
+	connectionDetails := CosmosDbSqlConnectionDetails{
+		AccountName:   accountName,
+		DatabaseName:  databaseName,
+		ContainerName: containerName,
+		PartitionKey:  partitionKey,
+		AccountKey:    accountKey,
+	}
 	nodes := []*TreeNode{}
 	for _, document := range list.Documents {
-		document := document.(map[string]interface{})
-		id := document["id"].(string)
-		idWithPartitionKey := id
-		displayText := id
-		partitionKeyValue := ""
-		if partitionKey != "" {
-			// get the partitionKey value for the current document
-			v, err := getJSONProperty(document, partitionKeySegments...)
-			if err != nil {
-				return ExpanderResult{
-					Err:               fmt.Errorf("Error determining partition key value: %s", err),
-					IsPrimaryResponse: true,
-					SourceDescription: "CosmosDbExpander request",
-				}
+		node, err := sqlDocumentNode(item.ID, document, connectionDetails)
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				IsPrimaryResponse: true,
+				SourceDescription: "CosmosDbExpander request",
 			}
-			vString := fmt.Sprintf("%v", v)
-			partitionKeyValue = fmt.Sprintf("[\"%s\"]", vString)
-			idWithPartitionKey = fmt.Sprintf("%s %s", partitionKeyValue, id)
-			displayText = style.Subtle(vString) + "\n  " + id
 		}
-		node := TreeNode{
-			Parentid:              item.ID,
-			ID:                    item.ID + "/" + idWithPartitionKey,
-			Namespace:             "cosmosdb",
-			Name:                  idWithPartitionKey,
-			Display:               displayText,
-			ItemType:              cosmosdbSQLDocument,
-			ExpandURL:             ExpandURLNotSupported,
-			DeleteURL:             item.ID + "/" + idWithPartitionKey,
-			SuppressSwaggerExpand: true,
-			SuppressGenericExpand: true,
-			Metadata: map[string]string{
-				"AccountName":       accountName,
-				"DatabaseName":      databaseName,
-				"ContainerName":     containerName,
-				"AccountKey":        accountKey,
-				"PartitionKeyValue": partitionKeyValue,
-				"ItemID":            id,
-			},
+		if node != nil {
+			nodes = append(nodes, node)
 		}
-		nodes = append(nodes, &node)
 	}
 
 	if continuationToken := response.Headers.Get("x-ms-continuation"); continuationToken != "" {
@@ -712,25 +865,19 @@ func (e *CosmosDbExpander) cosmosdbActionGetDocument(ctx context.Context, item *
 
 	}
 
-	commandChannel := make(chan string, 1)
-	commandPanelNotification := func(state interfaces.CommandPanelNotification) {
-		if state.EnterPressed {
-			commandChannel <- state.CurrentText
-			e.commandPanel.Hide()
-		}
-	}
 	partitionKeyValue := ""
 	if connectionDetails.PartitionKey != "" {
-		e.commandPanel.ShowWithText("partition key:", "", nil, commandPanelNotification)
-		// Force UI to re-render to pickup
-		e.gui.Update(func(g *gocui.Gui) error {
-			return nil
-		})
-		partitionKeyValue = <-commandChannel
+		partitionKeyValue, _ = promptInCommandPanel(e.gui, e.commandPanel, "partition key:", "", nil)
 		partitionKeyValue = fmt.Sprintf("[\"%s\"]", partitionKeyValue)
 	}
-	e.commandPanel.ShowWithText("id:", "", nil, commandPanelNotification)
-	id := <-commandChannel
+	id, _ := promptInCommandPanel(e.gui, e.commandPanel, "id:", "", nil)
+	if id == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Cancelled"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
 	_, _ = e.gui.SetCurrentView("listWidget")
 	// Force UI to re-render to pickup
 	e.gui.Update(func(g *gocui.Gui) error {
@@ -874,70 +1021,137 @@ func (e *CosmosDbExpander) cosmosdbActionExecuteQuery(ctx context.Context, item
 
 	}
 
-	commandPanelNotification := func(state interfaces.CommandPanelNotification) {
-		if state.EnterPressed {
-			queryText := state.CurrentText
-			result, err := e.executeQuery(ctx, connectionDetails.AccountName, connectionDetails.DatabaseName, connectionDetails.ContainerName, connectionDetails.AccountKey, queryText)
-			if err != nil {
-				e.contentPanel.SetContent("\n\n\n\n"+err.Error(), interfaces.ResponsePlainText, "Error executing query")
-			} else {
-				e.contentPanel.SetContent(result, interfaces.ResponseJSON, "Results")
-			}
+	initialQuery := "SELECT * FROM c"
+	if history := loadQueryHistory(cosmosDbQueryHistoryKey(connectionDetails)); len(history) > 0 {
+		initialQuery = history[0]
+	}
+	queryText, _ := promptInCommandPanel(e.gui, e.commandPanel, "query:", initialQuery, nil)
+	if queryText == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No query entered"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
 		}
 	}
-	e.commandPanel.ShowWithText("query:", "SELECT * FROM c", nil, commandPanelNotification)
-	// Force UI to re-render to pickup
-	e.gui.Update(func(g *gocui.Gui) error {
-		return nil
-	})
 
-	return ExpanderResult{
-		Response:          ExpanderResponse{Response: "", ResponseType: interfaces.ResponsePlainText},
-		SourceDescription: "CosmosDbExpander request",
-		IsPrimaryResponse: true,
+	return e.executeQueryAndSaveHistory(ctx, item, connectionDetails, queryText)
+}
+
+func (e *CosmosDbExpander) cosmosdbActionQueryHistory(ctx context.Context, item *TreeNode) ExpanderResult {
+
+	connectionDetails, err := e.walkParentsToGetConnectionDetails(ctx, item)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	history := loadQueryHistory(cosmosDbQueryHistoryKey(connectionDetails))
+	if len(history) == 0 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No queries have been run against this container yet"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	options := []interfaces.CommandPanelListOption{}
+	for _, query := range history {
+		options = append(options, interfaces.CommandPanelListOption{ID: query, DisplayText: query})
+	}
+	queryText, selectedQuery := promptInCommandPanel(e.gui, e.commandPanel, "query history:", "", &options)
+	if selectedQuery != "" {
+		queryText = selectedQuery
+	}
+	if queryText == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No query selected"),
+			SourceDescription: "CosmosDbExpander request",
+			IsPrimaryResponse: true,
+		}
 	}
 
+	return e.executeQueryAndSaveHistory(ctx, item, connectionDetails, queryText)
+}
+
+// executeQueryAndSaveHistory runs the query and adds it to the container's query history if it succeeds
+func (e *CosmosDbExpander) executeQueryAndSaveHistory(ctx context.Context, item *TreeNode, connectionDetails CosmosDbSqlConnectionDetails, queryText string) ExpanderResult {
+	result := e.expandSQLQueryResults(ctx, item, connectionDetails, queryText, "")
+	if result.Err == nil {
+		if err := saveQueryToHistory(cosmosDbQueryHistoryKey(connectionDetails), queryText); err != nil {
+			eventing.SendStatusEvent(&eventing.StatusEvent{
+				Failure: true,
+				Message: fmt.Sprintf("Failed to save query history: %s", err),
+				Timeout: time.Second * 5,
+			})
+		}
+	}
+	return result
 }
 
-func (e *CosmosDbExpander) executeQuery(ctx context.Context, accountName string, databaseName string, containerName string, accountKey string, queryText string) (string, error) {
+// queryDocuments runs a SQL query against the container, continuing a previous query if continuationToken is set
+func (e *CosmosDbExpander) queryDocuments(ctx context.Context, accountName string, databaseName string, containerName string, accountKey string, queryText string, continuationToken string) (*doRequestResponse, error) {
 
 	headers := map[string]string{}
 	headers["x-ms-documentdb-isquery"] = "true"
 	headers["Content-Type"] = "application/query+json"
 	headers["x-ms-documentdb-query-enablecrosspartition"] = "true" // enable cross-parition queries - can be restricted to single-partition via WHERE clause
+	headers["x-ms-documentdb-populatequerymetrics"] = "true"       // return the index utilization with the results
+	if continuationToken != "" {
+		headers["x-ms-continuation"] = continuationToken
+	}
 
 	query := CosmosDbQuery{
 		Query: queryText,
 	}
 	buf, err := json.Marshal(query)
 	if err != nil {
-		return "", fmt.Errorf("Error marshaling query as JSON: %s", err)
+		return nil, fmt.Errorf("Error marshaling query as JSON: %s", err)
 	}
 
 	requestURL := fmt.Sprintf("/dbs/%s/colls/%s/docs", databaseName, containerName)
 	response, err := e.doRequestWithHeadersAndBody(ctx, "POST", accountName, requestURL, accountKey, headers, bytes.NewBuffer(buf))
 	if err != nil {
-		return "", fmt.Errorf("Error getting documents: %s", err)
+		return nil, fmt.Errorf("Error getting documents: %s", err)
 	}
 	if !e.isSuccessCode(response.StatusCode) {
 		data := ""
 		if response != nil {
 			data = string(response.Data)
 		}
-		return "", fmt.Errorf("Error getting document. StatusCode=%d, Response=%s", response.StatusCode, data)
+		return nil, fmt.Errorf("Error getting document. StatusCode=%d, Response=%s", response.StatusCode, data)
 	}
 
-	return string(response.Data), nil
+	return response, nil
 }
 
-func (e *CosmosDbExpander) getAccountKey(ctx context.Context, item *TreeNode) (string, error) {
-	parts := strings.Split(item.ID, "/")
+// cosmosDbAccountID returns the ID of the account that the item is under
+func cosmosDbAccountID(itemID string) string {
+	parts := strings.Split(itemID, "/")
 	if len(parts) > 9 {
 		// Want "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}"
 		parts = parts[:9]
 	}
-	baseURL := strings.Join(parts, "/")
-	listKeysURL := baseURL + "/listKeys?api-version=2020-04-01"
+	return strings.Join(parts, "/")
+}
+
+// getAccount gets the account that the item is under, which is used to detect the API kind
+func (e *CosmosDbExpander) getAccount(ctx context.Context, item *TreeNode) (*CosmosDbAccount, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", cosmosDbAccountID(item.ID)+"?api-version=2021-04-15")
+	if err != nil {
+		return nil, err
+	}
+
+	var account CosmosDbAccount
+	if err = json.Unmarshal([]byte(data), &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (e *CosmosDbExpander) getAccountKey(ctx context.Context, item *TreeNode) (string, error) {
+	listKeysURL := cosmosDbAccountID(item.ID) + "/listKeys?api-version=2020-04-01"
 	data, err := e.armClient.DoRequest(ctx, "POST", listKeysURL)
 	if err != nil {
 		return "", err
@@ -951,6 +1165,21 @@ func (e *CosmosDbExpander) getAccountKey(ctx context.Context, item *TreeNode) (s
 	return result.PrimaryMasterKey, nil
 }
 
+// getReadOnlyAccountKey returns the primary read-only key, which the service won't accept for writes
+func (e *CosmosDbExpander) getReadOnlyAccountKey(ctx context.Context, item *TreeNode) (string, error) {
+	data, err := e.armClient.DoRequest(ctx, "POST", cosmosDbAccountID(item.ID)+"/readonlykeys?api-version=2020-04-01")
+	if err != nil {
+		return "", err
+	}
+
+	var result CosmosDbListKeyResponse
+	if err = json.Unmarshal([]byte(data), &result); err != nil {
+		return "", err
+	}
+
+	return result.PrimaryReadonlyMasterKey, nil
+}
+
 func (e *CosmosDbExpander) isSuccessCode(statusCode int) bool {
 	return statusCode >= 200 && statusCode <= 299
 }
@@ -0,0 +1,916 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const eventHubTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.EventHub/namespaces/{namespaceName}/eventhubs/{eventHubName}"
+
+const eventHubAPIVersion = "2017-04-01"
+
+const (
+	eventHubNodePartitions     = "eventhub-partitions"
+	eventHubNodePartition      = "eventhub-partition"
+	eventHubNodeConsumerGroups = "eventhub-consumergroups"
+	eventHubNodeConsumerGroup  = "eventhub-consumergroup"
+)
+
+const (
+	eventHubActionSetCheckpointStore = "eventhub-set-checkpoint-store"
+	eventHubActionSampleEvents       = "eventhub-sample-events"
+)
+
+const (
+	// eventHubMaxSampleEvents is the most events that can be sampled from a partition at once
+	eventHubMaxSampleEvents = 100
+	// eventHubSampleTimeout is how long to wait for each event when sampling, events can have
+	// expired from the partition since its end sequence number was read
+	eventHubSampleTimeout = 10 * time.Second
+)
+
+type eventHubResponse struct {
+	Properties struct {
+		PartitionIds []string `json:"partitionIds"`
+	} `json:"properties"`
+}
+
+type eventHubConsumerGroupListResponse struct {
+	Value []struct {
+		Name string `json:"name"`
+	} `json:"value"`
+}
+
+// eventHubPartitionDescription is the runtime information for a partition
+type eventHubPartitionDescription struct {
+	PartitionID            string `xml:"-" json:"partitionId"`
+	SizeInBytes            int64  `xml:"SizeInBytes" json:"sizeInBytes"`
+	BeginSequenceNumber    int64  `xml:"BeginSequenceNumber" json:"beginSequenceNumber"`
+	EndSequenceNumber      int64  `xml:"EndSequenceNumber" json:"endSequenceNumber"`
+	IncomingBytesPerSecond int64  `xml:"IncomingBytesPerSecond" json:"incomingBytesPerSecond"`
+	OutgoingBytesPerSecond int64  `xml:"OutgoingBytesPerSecond" json:"outgoingBytesPerSecond"`
+	LastEnqueuedOffset     string `xml:"LastEnqueuedOffset" json:"lastEnqueuedOffset"`
+	LastEnqueuedTimeUtc    string `xml:"LastEnqueuedTimeUtc" json:"lastEnqueuedTimeUtc"`
+}
+
+// eventHubPartitionEntry is the Atom entry returned for a partition
+type eventHubPartitionEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Content struct {
+		Description eventHubPartitionDescription `xml:"PartitionDescription"`
+	} `xml:"content"`
+}
+
+// eventHubCheckpointListResponse is the blob listing for checkpoints written by the Event Hubs SDKs,
+// which store the position in the blob metadata
+type eventHubCheckpointListResponse struct {
+	Blobs []struct {
+		Name     string `xml:"Name"`
+		Metadata struct {
+			SequenceNumber string `xml:"sequencenumber"`
+		} `xml:"Metadata"`
+	} `xml:"Blobs>Blob"`
+}
+
+// eventHubLegacyCheckpoint is the blob content for checkpoints written by the (legacy) EventProcessorHost
+type eventHubLegacyCheckpoint struct {
+	PartitionID    string `json:"PartitionId"`
+	SequenceNumber int64  `json:"SequenceNumber"`
+}
+
+// eventHubCheckpointStore is the blob container the consumers write their checkpoints to
+type eventHubCheckpointStore struct {
+	Name          string
+	AccountID     string
+	AccountName   string
+	AccountKey    string
+	BlobEndpoint  string
+	ContainerName string
+}
+
+// eventHubSavedCheckpointStore is the checkpoint store saved for an Event Hub. The account key isn't saved,
+// it's looked up when the store is loaded
+type eventHubSavedCheckpointStore struct {
+	AccountID     string `json:"accountId"`
+	ContainerName string `json:"containerName"`
+}
+
+// NewEventHubExpander creates a new instance of EventHubExpander
+func NewEventHubExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *EventHubExpander {
+	return &EventHubExpander{
+		sharedAccessClient: newSharedAccessClient(armclient, "Microsoft.EventHub"),
+		storage:            newStorageSharedKeyClient(armclient),
+		gui:                gui,
+		commandPanel:       commandPanel,
+		checkpointStores:   map[string]eventHubCheckpointStore{},
+	}
+}
+
+// Check interface
+var _ Expander = &EventHubExpander{}
+
+// EventHubExpander expands the partitions and consumer groups of an Event Hub
+type EventHubExpander struct {
+	ExpanderBase
+	sharedAccessClient
+	storage      storageSharedKeyClient
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+
+	// checkpointStores are the checkpoint stores loaded for the session, keyed by Event Hub ID
+	checkpointStores      map[string]eventHubCheckpointStore
+	checkpointStoresMutex sync.Mutex
+}
+
+func (e *EventHubExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+	e.storage.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *EventHubExpander) Name() string {
+	return "EventHubExpander"
+}
+
+// DoesExpand checks if this is an Event Hub
+func (e *EventHubExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == SubResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == eventHubTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "eventhub" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the partitions and consumer groups of the Event Hub
+func (e *EventHubExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "eventhub" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == eventHubTemplateURL {
+		i := strings.Index(strings.ToLower(currentItem.ID), "/eventhubs/")
+		newItems := []*TreeNode{}
+		for _, child := range []struct{ name, itemType, path string }{
+			{"Partitions", eventHubNodePartitions, "partitions"},
+			{"Consumer groups (checkpoint lag)", eventHubNodeConsumerGroups, "consumergroups"},
+		} {
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<" + child.path + ">",
+				Namespace:             "eventhub",
+				Name:                  child.name,
+				Display:               child.name,
+				ItemType:              child.itemType,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"NamespaceID":  currentItem.ID[:i],
+					"EventHubID":   currentItem.ID,
+					"EventHubName": lastSegment(currentItem.ID),
+				},
+			})
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "EventHubExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case eventHubNodePartitions:
+		return e.expandPartitions(ctx, currentItem)
+	case eventHubNodePartition:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	case eventHubNodeConsumerGroups:
+		return e.expandConsumerGroups(ctx, currentItem)
+	case eventHubNodeConsumerGroup:
+		return e.expandConsumerGroup(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "EventHubExpander request",
+	}
+}
+
+func (e *EventHubExpander) expandPartitions(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	partitions, err := e.getPartitions(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, partition := range partitions {
+		content, err := json.MarshalIndent(partition, "", "  ")
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error marshaling partition: %s", err),
+				SourceDescription: "EventHubExpander request",
+			}
+		}
+		name := "Partition " + partition.PartitionID
+		metadata := copyMetadata(currentItem.Metadata)
+		metadata["Content"] = string(content)
+		metadata["PartitionID"] = partition.PartitionID
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + partition.PartitionID,
+			Namespace: "eventhub",
+			Name:      name,
+			Display:   name + "\n  " + style.Subtle(fmt.Sprintf("seq %d-%d, last enqueued %s", partition.BeginSequenceNumber, partition.EndSequenceNumber, partition.LastEnqueuedTimeUtc)),
+			ItemType:  eventHubNodePartition,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: formatEventHubPartitions(partitions), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "EventHubExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *EventHubExpander) expandConsumerGroups(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.Metadata["EventHubID"]+"/consumergroups?api-version="+eventHubAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing consumer groups: %s", err),
+			SourceDescription: "EventHubExpander request",
+		}
+	}
+	var response eventHubConsumerGroupListResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling consumer groups: %s", err),
+			SourceDescription: "EventHubExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, consumerGroup := range response.Value {
+		metadata := copyMetadata(currentItem.Metadata)
+		metadata["ConsumerGroup"] = consumerGroup.Name
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + consumerGroup.Name,
+			Namespace: "eventhub",
+			Name:      consumerGroup.Name,
+			Display:   consumerGroup.Name,
+			ItemType:  eventHubNodeConsumerGroup,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "EventHubExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandConsumerGroup shows the checkpoint lag for each partition
+func (e *EventHubExpander) expandConsumerGroup(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	store, ok, err := e.getCheckpointStore(ctx, currentItem.Metadata["EventHubID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+		}
+	}
+	if !ok {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "Use the 'Set checkpoint store' action to choose the blob container the consumers checkpoint to", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	partitions, err := e.getPartitions(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+		}
+	}
+	checkpoints, err := e.getCheckpoints(ctx, store, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting checkpoints: %s", err),
+			SourceDescription: "EventHubExpander request",
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: formatCheckpointLag(currentItem.Metadata["ConsumerGroup"], store.Name, partitions, checkpoints), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "EventHubExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// formatEventHubPartitions returns a table of the partitions' sequence numbers
+func formatEventHubPartitions(partitions []eventHubPartitionDescription) string {
+	var table strings.Builder
+	fmt.Fprintf(&table, "%-10s %15s %15s %12s  %s\n", "Partition", "Begin seq", "End seq", "Size", "Last enqueued")
+	for _, partition := range partitions {
+		fmt.Fprintf(&table, "%-10s %15d %15d %12s  %s\n", partition.PartitionID, partition.BeginSequenceNumber, partition.EndSequenceNumber, formatBytes(partition.SizeInBytes), partition.LastEnqueuedTimeUtc)
+	}
+	fmt.Fprintf(&table, "\nUse the 'Sample last events' action on a partition to see its latest events\n")
+	return table.String()
+}
+
+// formatCheckpointLag returns a table of how far the consumer group's checkpoints are behind each partition
+func formatCheckpointLag(consumerGroup string, storeName string, partitions []eventHubPartitionDescription, checkpoints map[string]int64) string {
+	var table strings.Builder
+	fmt.Fprintf(&table, "Consumer group %q, checkpoints from %s\n\n", consumerGroup, storeName)
+	fmt.Fprintf(&table, "%-10s %15s %15s %12s  %s\n", "Partition", "End seq", "Checkpoint", "Lag", "Last enqueued")
+	for _, partition := range partitions {
+		checkpoint, found := checkpoints[partition.PartitionID]
+		if !found {
+			fmt.Fprintf(&table, "%-10s %15d %15s %12s  %s\n", partition.PartitionID, partition.EndSequenceNumber, "-", "-", partition.LastEnqueuedTimeUtc)
+			continue
+		}
+		lag := partition.EndSequenceNumber - checkpoint
+		lagText := strconv.FormatInt(lag, 10)
+		if lag > 0 {
+			lagText = style.Warning(fmt.Sprintf("%12d", lag))
+		}
+		fmt.Fprintf(&table, "%-10s %15d %15d %12s  %s\n", partition.PartitionID, partition.EndSequenceNumber, checkpoint, lagText, partition.LastEnqueuedTimeUtc)
+	}
+	return table.String()
+}
+
+// HasActions returns true for consumer groups and partitions
+func (e *EventHubExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	switch item.ItemType {
+	case eventHubNodeConsumerGroups, eventHubNodeConsumerGroup, eventHubNodePartition:
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the actions for consumer groups and partitions
+func (e *EventHubExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	actionID, name := eventHubActionSetCheckpointStore, "Set checkpoint store"
+	if item.ItemType == eventHubNodePartition {
+		actionID, name = eventHubActionSampleEvents, "Sample last events"
+	}
+	metadata := copyMetadata(item.Metadata)
+	metadata["ActionID"] = actionID
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?" + actionID,
+				Namespace:              "eventhub",
+				Name:                   name,
+				Display:                name,
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata:               metadata,
+			},
+		},
+		SourceDescription: "EventHubExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the consumer group or partition action
+func (e *EventHubExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case eventHubActionSetCheckpointStore:
+		return e.setCheckpointStore(ctx, item)
+	case eventHubActionSampleEvents:
+		return e.sampleEvents(ctx, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "EventHubExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "EventHubExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+// setCheckpointStore prompts for the storage account and container the consumers checkpoint to and saves them for the Event Hub
+func (e *EventHubExpander) setCheckpointStore(ctx context.Context, item *TreeNode) ExpanderResult {
+	eventHubID := item.Metadata["EventHubID"]
+	accounts, err := e.listStorageAccounts(ctx, armclient.GetSubscriptionIDFromResourceID(eventHubID))
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	_, accountID := promptInCommandPanel(e.gui, e.commandPanel, "Checkpoint store storage account:", "", &accounts)
+	if accountID == "" {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "No storage account selected, the checkpoint store wasn't changed", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	containers, err := e.listContainers(ctx, accountID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	_, containerName := promptInCommandPanel(e.gui, e.commandPanel, "Checkpoint store container:", "", &containers)
+	if containerName == "" {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "No container selected, the checkpoint store wasn't changed", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	saved := eventHubSavedCheckpointStore{AccountID: accountID, ContainerName: containerName}
+	store, err := e.loadCheckpointStore(ctx, saved)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	buf, err := json.Marshal(saved)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error marshaling checkpoint store: %s", err),
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if err = storage.PutCache(checkpointStoreKey(eventHubID), string(buf)); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error saving checkpoint store: %s", err),
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	e.checkpointStoresMutex.Lock()
+	e.checkpointStores[eventHubID] = store
+	e.checkpointStoresMutex.Unlock()
+
+	if item.Metadata["ConsumerGroup"] != "" {
+		return e.expandConsumerGroup(ctx, item)
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Checkpoint store set to " + store.Name + ", expand a consumer group to see the lag", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "EventHubExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// sampleEvents prompts for a number of events and shows that many of the latest events in the partition
+func (e *EventHubExpander) sampleEvents(ctx context.Context, item *TreeNode) ExpanderResult {
+	countText, _ := promptInCommandPanel(e.gui, e.commandPanel, fmt.Sprintf("Number of events to sample (1-%d):", eventHubMaxSampleEvents), "10", nil)
+	if countText == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil || count < 1 || count > eventHubMaxSampleEvents {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Enter a number of events between 1 and %d", eventHubMaxSampleEvents),
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Consumers using EventProcessorClient (or the legacy EventProcessorHost) hold their partitions with an owner
+	// level, which stops other receivers in the same consumer group, so let the user pick a group to read from
+	consumerGroups, err := e.listConsumerGroups(ctx, item.Metadata["EventHubID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	_, consumerGroup := promptInCommandPanel(e.gui, e.commandPanel, "Consumer group to read from (ideally one no processor uses):", "", &consumerGroups)
+	if consumerGroup == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Get the current sequence numbers, the ones shown when the partitions were listed may be out of date
+	if err = e.ensureConnectionDetails(ctx, item); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	partition, err := e.getPartition(ctx, item, item.Metadata["PartitionID"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	afterSequenceNumber, expected := eventHubSampleRange(partition, count)
+	if expected == 0 {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "Partition " + partition.PartitionID + " has no events", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	events, err := e.receiveEvents(ctx, item, partition.PartitionID, consumerGroup, afterSequenceNumber, expected)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "EventHubExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: formatEventHubEvents(partition.PartitionID, events), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "EventHubExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// eventHubSampleRange returns the sequence number to read the last count events of the partition after,
+// and how many events that should return
+func eventHubSampleRange(partition eventHubPartitionDescription, count int) (int64, int64) {
+	afterSequenceNumber := partition.EndSequenceNumber - int64(count)
+	if afterSequenceNumber < partition.BeginSequenceNumber-1 {
+		afterSequenceNumber = partition.BeginSequenceNumber - 1
+	}
+	expected := partition.EndSequenceNumber - afterSequenceNumber
+	if expected < 0 {
+		expected = 0
+	}
+	return afterSequenceNumber, expected
+}
+
+// receiveEvents reads the events after the sequence number from the partition in the consumer group over AMQP.
+// It stops after the expected number of events, or sooner if no more arrive within eventHubSampleTimeout
+func (e *EventHubExpander) receiveEvents(ctx context.Context, item *TreeNode, partitionID string, consumerGroup string, afterSequenceNumber int64, expected int64) ([]*amqp.Message, error) {
+	client, err := dialAMQP(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close() //nolint: errcheck
+
+	eventHubName := item.Metadata["EventHubName"]
+	if err = client.authorize(ctx, eventHubName); err != nil {
+		return nil, err
+	}
+	// The receiver has no owner level (epoch) so it doesn't disconnect any consumers, but the service refuses it
+	// if a consumer in the group holds the partition with an owner level
+	// See https://docs.microsoft.com/en-us/azure/event-hubs/event-hubs-event-processor-host#epoch
+	address := eventHubName + "/ConsumerGroups/" + consumerGroup + "/Partitions/" + partitionID
+	receiver, err := client.session.NewReceiver(ctx, address, &amqp.ReceiverOptions{
+		Credit:  int32(expected),
+		Filters: []amqp.LinkFilter{amqp.NewSelectorFilter(fmt.Sprintf("amqp.annotation.x-opt-sequence-number > '%d'", afterSequenceNumber))},
+	})
+	if err != nil {
+		return nil, eventHubReceiveError(partitionID, consumerGroup, err)
+	}
+
+	events := []*amqp.Message{}
+	for int64(len(events)) < expected {
+		receiveCtx, cancel := context.WithTimeout(ctx, eventHubSampleTimeout)
+		event, err := receiver.Receive(receiveCtx, nil)
+		cancel()
+		if err != nil {
+			if receiveCtx.Err() != nil && ctx.Err() == nil {
+				break // no more events
+			}
+			return nil, eventHubReceiveError(partitionID, consumerGroup, err)
+		}
+		_ = receiver.AcceptMessage(ctx, event)
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// eventHubReceiveError describes an error receiving events, explaining when the service refused the receiver
+// because a consumer holds the partition with an owner level
+func eventHubReceiveError(partitionID string, consumerGroup string, err error) error {
+	var remoteErr *amqp.Error
+	var linkErr *amqp.LinkError
+	if errors.As(err, &linkErr) {
+		remoteErr = linkErr.RemoteErr
+	} else if !errors.As(err, &remoteErr) {
+		remoteErr = nil
+	}
+	if remoteErr != nil && (remoteErr.Condition == amqp.ErrCondStolen || strings.Contains(strings.ToLower(remoteErr.Description), "epoch")) {
+		return fmt.Errorf("Partition %s is held by a consumer with an owner level (e.g. an EventProcessorClient) in consumer group %q, "+
+			"which stops other receivers. Sample from a consumer group that no processor uses, e.g. one created for azbrowse", partitionID, consumerGroup)
+	}
+	return fmt.Errorf("Error receiving events from partition %s: %s", partitionID, err)
+}
+
+// formatEventHubEvents returns the events with their sequence number, offset, enqueued time and properties
+func formatEventHubEvents(partitionID string, events []*amqp.Message) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d event(s) from partition %s\n", len(events), partitionID)
+	for _, event := range events {
+		text.WriteString("\n" + strings.Repeat("-", 80) + "\n")
+		for _, annotation := range []struct{ name, label string }{
+			{"x-opt-sequence-number", "Sequence number"},
+			{"x-opt-offset", "Offset"},
+			{"x-opt-enqueued-time", "Enqueued"},
+			{"x-opt-partition-key", "Partition key"},
+		} {
+			value, ok := amqpAnnotation(event, annotation.name)
+			if !ok {
+				continue
+			}
+			if timestamp, ok := value.(time.Time); ok {
+				value = timestamp.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(&text, "%s: %v\n", annotation.label, value)
+		}
+		names := []string{}
+		for name := range event.ApplicationProperties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&text, "%s: %v\n", name, event.ApplicationProperties[name])
+		}
+		fmt.Fprintf(&text, "\n%s\n", amqpBody(event))
+	}
+	return text.String()
+}
+
+func checkpointStoreKey(eventHubID string) string {
+	return resourceCacheKey("EventHubCheckpointStore", strings.ToLower(eventHubID))
+}
+
+// getCheckpointStore returns the checkpoint store for the Event Hub, loading the saved store on first use
+func (e *EventHubExpander) getCheckpointStore(ctx context.Context, eventHubID string) (eventHubCheckpointStore, bool, error) {
+	e.checkpointStoresMutex.Lock()
+	store, ok := e.checkpointStores[eventHubID]
+	e.checkpointStoresMutex.Unlock()
+	if ok {
+		return store, true, nil
+	}
+
+	value, err := storage.GetCache(checkpointStoreKey(eventHubID))
+	if err != nil || value == "" {
+		return eventHubCheckpointStore{}, false, nil
+	}
+	var saved eventHubSavedCheckpointStore
+	if err = json.Unmarshal([]byte(value), &saved); err != nil {
+		return eventHubCheckpointStore{}, false, nil // ignore a corrupt entry, the store can be set again
+	}
+	store, err = e.loadCheckpointStore(ctx, saved)
+	if err != nil {
+		return eventHubCheckpointStore{}, false, fmt.Errorf("Error loading the saved checkpoint store (use 'Set checkpoint store' to change it): %s", err)
+	}
+
+	e.checkpointStoresMutex.Lock()
+	e.checkpointStores[eventHubID] = store
+	e.checkpointStoresMutex.Unlock()
+	return store, true, nil
+}
+
+// loadCheckpointStore looks up the key and blob endpoint for a saved checkpoint store
+func (e *EventHubExpander) loadCheckpointStore(ctx context.Context, saved eventHubSavedCheckpointStore) (eventHubCheckpointStore, error) {
+	accountKey, err := e.storage.getStorageAccountKey(ctx, saved.AccountID)
+	if err != nil {
+		return eventHubCheckpointStore{}, fmt.Errorf("Error getting account key: %s", err)
+	}
+	account, err := e.storage.getStorageAccount(ctx, saved.AccountID)
+	if err != nil {
+		return eventHubCheckpointStore{}, fmt.Errorf("Error getting blob endpoint: %s", err)
+	}
+	accountName := lastSegment(saved.AccountID)
+	return eventHubCheckpointStore{
+		Name:          accountName + "/" + saved.ContainerName,
+		AccountID:     saved.AccountID,
+		AccountName:   accountName,
+		AccountKey:    accountKey,
+		BlobEndpoint:  account.Properties.PrimaryEndpoints.Blob,
+		ContainerName: saved.ContainerName,
+	}, nil
+}
+
+// listStorageAccounts returns the storage accounts in the subscription as options keyed by resource ID
+func (e *EventHubExpander) listStorageAccounts(ctx context.Context, subscriptionID string) ([]interfaces.CommandPanelListOption, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", "/subscriptions/"+subscriptionID+"/providers/Microsoft.Storage/storageAccounts?api-version=2019-06-01")
+	if err != nil {
+		return nil, fmt.Errorf("Error listing storage accounts: %s", err)
+	}
+	return parseEventHubResourceOptions(data, "storage accounts", func(id, name string) string { return id })
+}
+
+// listContainers returns the blob containers in the storage account as options keyed by name
+func (e *EventHubExpander) listContainers(ctx context.Context, accountID string) ([]interfaces.CommandPanelListOption, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", accountID+"/blobServices/default/containers?api-version=2019-06-01")
+	if err != nil {
+		return nil, fmt.Errorf("Error listing containers: %s", err)
+	}
+	return parseEventHubResourceOptions(data, "containers", func(id, name string) string { return name })
+}
+
+// listConsumerGroups returns the consumer groups of the Event Hub as options
+func (e *EventHubExpander) listConsumerGroups(ctx context.Context, eventHubID string) ([]interfaces.CommandPanelListOption, error) {
+	data, err := e.armClient.DoRequest(ctx, "GET", eventHubID+"/consumergroups?api-version="+eventHubAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing consumer groups: %s", err)
+	}
+	return parseEventHubResourceOptions(data, "consumer groups", func(id, name string) string { return name })
+}
+
+// parseEventHubResourceOptions returns the resources in an ARM list response as options sorted by name
+func parseEventHubResourceOptions(data string, description string, optionID func(id, name string) string) ([]interfaces.CommandPanelListOption, error) {
+	var response struct {
+		Value []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling %s: %s", description, err)
+	}
+	if len(response.Value) == 0 {
+		return nil, fmt.Errorf("No %s found", description)
+	}
+	sort.Slice(response.Value, func(i, j int) bool {
+		return strings.ToLower(response.Value[i].Name) < strings.ToLower(response.Value[j].Name)
+	})
+	options := []interfaces.CommandPanelListOption{}
+	for _, resource := range response.Value {
+		options = append(options, interfaces.CommandPanelListOption{ID: optionID(resource.ID, resource.Name), DisplayText: resource.Name})
+	}
+	return options, nil
+}
+
+// getPartitions gets the runtime information for each partition
+func (e *EventHubExpander) getPartitions(ctx context.Context, item *TreeNode) ([]eventHubPartitionDescription, error) {
+	if err := e.ensureConnectionDetails(ctx, item); err != nil {
+		return nil, err
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "GET", item.Metadata["EventHubID"]+"?api-version="+eventHubAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting Event Hub: %s", err)
+	}
+	var eventHub eventHubResponse
+	if err = json.Unmarshal([]byte(data), &eventHub); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling Event Hub: %s", err)
+	}
+
+	partitions := make([]eventHubPartitionDescription, len(eventHub.Properties.PartitionIds))
+	errs := make([]error, len(eventHub.Properties.PartitionIds))
+	var wg sync.WaitGroup
+	for i, partitionID := range eventHub.Properties.PartitionIds {
+		wg.Add(1)
+		go func(i int, partitionID string) {
+			defer wg.Done()
+			partitions[i], errs[i] = e.getPartition(ctx, item, partitionID)
+		}(i, partitionID)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return partitions, nil
+}
+
+// getPartition gets the runtime information for the partition
+func (e *EventHubExpander) getPartition(ctx context.Context, item *TreeNode, partitionID string) (eventHubPartitionDescription, error) {
+	// Get Partition docs: https://docs.microsoft.com/en-us/rest/api/eventhub/get-partition
+	response, err := e.doRequest(ctx, item, "GET", item.Metadata["EventHubName"]+"/consumergroups/$Default/partitions/"+partitionID+"?api-version=2014-01", map[string]string{}, nil)
+	if err != nil {
+		return eventHubPartitionDescription{}, fmt.Errorf("Error getting partition %s: %s", partitionID, err)
+	}
+	var entry eventHubPartitionEntry
+	if err = xml.Unmarshal(response.Body, &entry); err != nil {
+		return eventHubPartitionDescription{}, fmt.Errorf("Error unmarshalling partition %s: %s", partitionID, err)
+	}
+	partition := entry.Content.Description
+	partition.PartitionID = partitionID
+	return partition, nil
+}
+
+// getCheckpoints returns the checkpointed sequence number for each partition, keyed by partition ID
+func (e *EventHubExpander) getCheckpoints(ctx context.Context, store eventHubCheckpointStore, item *TreeNode) (map[string]int64, error) {
+	fullyQualifiedNamespace := strings.TrimSuffix(strings.TrimPrefix(item.Metadata["Endpoint"], "https://"), "/")
+	consumerGroup := item.Metadata["ConsumerGroup"]
+
+	// The current SDKs store checkpoints as blob metadata under <namespace>/<event hub>/<consumer group>/checkpoint/<partition>
+	prefix := strings.ToLower(fullyQualifiedNamespace+"/"+item.Metadata["EventHubName"]+"/"+consumerGroup) + "/checkpoint/"
+	names, buf, err := e.listCheckpointBlobs(ctx, store, prefix, "&include=metadata")
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := map[string]int64{}
+	if len(names) > 0 {
+		var response eventHubCheckpointListResponse
+		if err = xml.Unmarshal(buf, &response); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling checkpoint list: %s", err)
+		}
+		for _, blob := range response.Blobs {
+			if sequenceNumber, err := strconv.ParseInt(blob.Metadata.SequenceNumber, 10, 64); err == nil {
+				checkpoints[lastSegment(blob.Name)] = sequenceNumber
+			}
+		}
+		return checkpoints, nil
+	}
+
+	// The legacy EventProcessorHost stores a JSON lease blob per partition under <consumer group>/<partition>
+	names, _, err = e.listCheckpointBlobs(ctx, store, consumerGroup+"/", "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		blobURL := getBlobURL(store.BlobEndpoint, store.ContainerName, name)
+		buf, err := e.storage.doRequest(ctx, "GET", blobURL, store.AccountName, store.AccountKey, "")
+		if err != nil {
+			return nil, fmt.Errorf("Error getting checkpoint %s: %s", name, err)
+		}
+		var checkpoint eventHubLegacyCheckpoint
+		if err = json.Unmarshal(buf, &checkpoint); err != nil {
+			continue // not a checkpoint
+		}
+		checkpoints[checkpoint.PartitionID] = checkpoint.SequenceNumber
+	}
+	return checkpoints, nil
+}
+
+// listCheckpointBlobs lists the names of the blobs in the checkpoint store with the prefix
+func (e *EventHubExpander) listCheckpointBlobs(ctx context.Context, store eventHubCheckpointStore, prefix string, include string) ([]string, []byte, error) {
+	// ListBlob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/list-blobs
+	listURL := store.BlobEndpoint + store.ContainerName + "?restype=container&comp=list&prefix=" + url.QueryEscape(prefix) + include
+	buf, err := e.storage.doRequest(ctx, "GET", listURL, store.AccountName, store.AccountKey, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error listing checkpoints in %s: %s", store.Name, err)
+	}
+	response := &ContainerListResponse{}
+	if err = xml.Unmarshal(buf, response); err != nil {
+		return nil, nil, fmt.Errorf("Error unmarshalling checkpoint list: %s", err)
+	}
+	names := []string{}
+	for _, blob := range response.Blobs {
+		names = append(names, blob.Name)
+	}
+	return names, buf, nil
+}
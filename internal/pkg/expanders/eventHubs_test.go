@@ -0,0 +1,238 @@
+package expanders
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func newEventHubTestConsumerGroup() *TreeNode {
+	return &TreeNode{
+		ItemType: eventHubNodeConsumerGroup,
+		Metadata: map[string]string{
+			"Endpoint":      "https://ns.servicebus.windows.net/",
+			"EventHubName":  "Orders",
+			"ConsumerGroup": "$Default",
+		},
+	}
+}
+
+func Test_EventHub_getCheckpoints(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/checkpoints", r.URL.Path)
+		assert.Equal(t, "ns.servicebus.windows.net/orders/$default/checkpoint/", r.URL.Query().Get("prefix"))
+		assert.Equal(t, "metadata", r.URL.Query().Get("include"))
+		_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>
+			<Blob><Name>ns.servicebus.windows.net/orders/$default/checkpoint/0</Name><Metadata><sequencenumber>41</sequencenumber><offset>100</offset></Metadata></Blob>
+			<Blob><Name>ns.servicebus.windows.net/orders/$default/checkpoint/1</Name><Metadata><sequencenumber>7</sequencenumber></Metadata></Blob>
+		</Blobs></EnumerationResults>`)
+	}))
+	defer ts.Close()
+
+	e := &EventHubExpander{storage: storageSharedKeyClient{client: ts.Client()}}
+	store := eventHubCheckpointStore{Name: "acct/checkpoints", AccountName: "acct", AccountKey: "a2V5", BlobEndpoint: ts.URL + "/", ContainerName: "checkpoints"}
+
+	checkpoints, err := e.getCheckpoints(context.Background(), store, newEventHubTestConsumerGroup())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"0": 41, "1": 7}, checkpoints)
+}
+
+func Test_EventHub_getLegacyCheckpoints(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("comp") != "list" {
+			switch r.URL.Path {
+			case "/checkpoints/$Default/0":
+				_, _ = io.WriteString(w, `{"PartitionId": "0", "SequenceNumber": 12, "Offset": "300"}`)
+			default:
+				_, _ = io.WriteString(w, `not a checkpoint`)
+			}
+			return
+		}
+		if r.URL.Query().Get("prefix") != "$Default/" {
+			// No checkpoints written by the current SDKs
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs></Blobs></EnumerationResults>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>
+			<Blob><Name>$Default/0</Name></Blob>
+			<Blob><Name>$Default/owner</Name></Blob>
+		</Blobs></EnumerationResults>`)
+	}))
+	defer ts.Close()
+
+	e := &EventHubExpander{storage: storageSharedKeyClient{client: ts.Client()}}
+	store := eventHubCheckpointStore{Name: "acct/checkpoints", AccountName: "acct", AccountKey: "a2V5", BlobEndpoint: ts.URL + "/", ContainerName: "checkpoints"}
+
+	checkpoints, err := e.getCheckpoints(context.Background(), store, newEventHubTestConsumerGroup())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"0": 12}, checkpoints)
+}
+
+func Test_formatCheckpointLag(t *testing.T) {
+	partitions := []eventHubPartitionDescription{
+		{PartitionID: "0", EndSequenceNumber: 50, LastEnqueuedTimeUtc: "2023-01-02T10:00:00Z"},
+		{PartitionID: "1", EndSequenceNumber: 7, LastEnqueuedTimeUtc: "2023-01-02T09:00:00Z"},
+		{PartitionID: "2", EndSequenceNumber: 3, LastEnqueuedTimeUtc: "2023-01-02T08:00:00Z"},
+	}
+	table := formatCheckpointLag("$Default", "acct/checkpoints", partitions, map[string]int64{"0": 41, "1": 7})
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	assert.Len(t, lines, 6)
+	assert.Equal(t, `Consumer group "$Default", checkpoints from acct/checkpoints`, lines[0])
+	assert.Equal(t, []string{"0", "50", "41"}, strings.Fields(lines[3])[:3])
+	assert.Contains(t, lines[3], "9") // the lag is highlighted
+	assert.Equal(t, []string{"1", "7", "7", "0", "2023-01-02T09:00:00Z"}, strings.Fields(lines[4]))
+	assert.Equal(t, []string{"2", "3", "-", "-", "2023-01-02T08:00:00Z"}, strings.Fields(lines[5]))
+}
+
+func Test_eventHubSampleRange(t *testing.T) {
+	after, expected := eventHubSampleRange(eventHubPartitionDescription{BeginSequenceNumber: 5, EndSequenceNumber: 50}, 10)
+	assert.Equal(t, int64(40), after)
+	assert.Equal(t, int64(10), expected)
+
+	// Only the events still in the partition are read
+	after, expected = eventHubSampleRange(eventHubPartitionDescription{BeginSequenceNumber: 45, EndSequenceNumber: 50}, 10)
+	assert.Equal(t, int64(44), after)
+	assert.Equal(t, int64(6), expected)
+
+	// Empty partitions report an end sequence number of -1
+	_, expected = eventHubSampleRange(eventHubPartitionDescription{BeginSequenceNumber: 0, EndSequenceNumber: -1}, 10)
+	assert.Equal(t, int64(0), expected)
+}
+
+func Test_formatEventHubEvents(t *testing.T) {
+	enqueued := time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)
+	events := []*amqp.Message{
+		{
+			Annotations: amqp.Annotations{
+				"x-opt-sequence-number": int64(49),
+				"x-opt-offset":          "4096",
+				"x-opt-enqueued-time":   enqueued,
+			},
+			ApplicationProperties: map[string]any{"source": "orders-api"},
+			Data:                  [][]byte{[]byte(`{"orderId": 1}`)},
+		},
+		{
+			Annotations: amqp.Annotations{"x-opt-sequence-number": int64(50), "x-opt-partition-key": "customer-1"},
+			Value:       "plain",
+		},
+	}
+	text := formatEventHubEvents("0", events)
+	assert.True(t, strings.HasPrefix(text, "2 event(s) from partition 0\n"))
+	assert.Contains(t, text, "Sequence number: 49\nOffset: 4096\nEnqueued: 2023-01-02T10:00:00Z\nsource: orders-api\n\n{\"orderId\": 1}\n")
+	assert.Contains(t, text, "Sequence number: 50\nPartition key: customer-1\n\nplain\n")
+}
+
+func Test_EventHub_SampleEvents(t *testing.T) {
+	e := &EventHubExpander{}
+	partition := &TreeNode{ID: "/partitions/0", ItemType: eventHubNodePartition, Metadata: map[string]string{"PartitionID": "0"}}
+	actions := e.ListActions(context.Background(), partition)
+	assert.Len(t, actions.Nodes, 1)
+	assert.Equal(t, eventHubActionSampleEvents, actions.Nodes[0].Metadata["ActionID"])
+
+	// Closing the prompt or entering an invalid count doesn't connect to the Event Hub
+	g, commandPanel := newTestPrompt(t, "0")
+	e = &EventHubExpander{gui: g, commandPanel: commandPanel}
+	result := e.ExecuteAction(context.Background(), actions.Nodes[0])
+	assert.EqualError(t, result.Err, "Enter a number of events between 1 and 100")
+	result = e.ExecuteAction(context.Background(), actions.Nodes[0])
+	assert.EqualError(t, result.Err, "User canceled")
+	assert.Len(t, commandPanel.titles, 2)
+}
+
+func Test_EventHub_SampleEventsAsksForConsumerGroup(t *testing.T) {
+	eventHubID := "/subscriptions/1/resourceGroups/rg1/providers/Microsoft.EventHub/namespaces/ns/eventhubs/orders"
+
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Get(eventHubID + "/consumergroups").
+		Reply(200).
+		JSON(`{"value": [{"id": "` + eventHubID + `/consumergroups/$Default", "name": "$Default"}, {"id": "` + eventHubID + `/consumergroups/azbrowse", "name": "azbrowse"}]}`)
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+
+	// Closing the consumer group prompt doesn't connect to the Event Hub
+	g, commandPanel := newTestPrompt(t, "5")
+	e := &EventHubExpander{gui: g, commandPanel: commandPanel}
+	e.setClient(armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000))
+	action := &TreeNode{ItemType: ActionType, Metadata: map[string]string{"ActionID": eventHubActionSampleEvents, "EventHubID": eventHubID, "PartitionID": "0"}}
+	result := e.ExecuteAction(context.Background(), action)
+	assert.EqualError(t, result.Err, "User canceled")
+	assert.Equal(t, "Consumer group to read from (ideally one no processor uses):", commandPanel.titles[1])
+	assert.True(t, gock.IsDone())
+	assert.False(t, gock.HasUnmatchedRequest())
+}
+
+func Test_eventHubReceiveError(t *testing.T) {
+	// The service refuses receivers without an owner level while a processor holds the partition
+	err := eventHubReceiveError("0", "$Default", &amqp.LinkError{RemoteErr: &amqp.Error{
+		Condition:   amqp.ErrCondStolen,
+		Description: "At least one receiver for the endpoint is created with epoch of '0', and so non-epoch receiver is not allowed.",
+	}})
+	assert.Contains(t, err.Error(), `Partition 0 is held by a consumer with an owner level (e.g. an EventProcessorClient) in consumer group "$Default"`)
+
+	err = eventHubReceiveError("0", "$Default", &amqp.Error{Condition: amqp.ErrCondNotFound, Description: "not found"})
+	assert.True(t, strings.HasPrefix(err.Error(), "Error receiving events from partition 0: "))
+}
+
+func Test_EventHub_CheckpointStorePickers(t *testing.T) {
+	accountID := "/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/acct"
+
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Get("/subscriptions/1/providers/Microsoft.Storage/storageAccounts").
+		Reply(200).
+		JSON(`{"value": [
+			{"id": "/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/zeta", "name": "zeta"},
+			{"id": "` + accountID + `", "name": "acct"}
+		]}`)
+	gock.New("https://management.azure.com").
+		Get(accountID + "/blobServices/default/containers").
+		Reply(200).
+		JSON(`{"value": [{"id": "` + accountID + `/blobServices/default/containers/checkpoints", "name": "checkpoints"}]}`)
+	gock.New("https://management.azure.com").
+		Post(accountID + "/listKeys").
+		Reply(200).
+		JSON(`{"keys": [{"value": "a2V5"}]}`)
+	gock.New("https://management.azure.com").
+		Get(accountID).
+		Reply(200).
+		JSON(`{"properties": {"primaryEndpoints": {"blob": "https://acct.blob.core.windows.net/"}}}`)
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+
+	e := &EventHubExpander{}
+	e.setClient(armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000))
+	ctx := context.Background()
+
+	accounts, err := e.listStorageAccounts(ctx, "1")
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, "acct", accounts[0].DisplayText)
+	assert.Equal(t, accountID, accounts[0].ID)
+
+	containers, err := e.listContainers(ctx, accountID)
+	assert.NoError(t, err)
+	assert.Len(t, containers, 1)
+	assert.Equal(t, "checkpoints", containers[0].ID)
+
+	store, err := e.loadCheckpointStore(ctx, eventHubSavedCheckpointStore{AccountID: accountID, ContainerName: "checkpoints"})
+	assert.NoError(t, err)
+	assert.Equal(t, eventHubCheckpointStore{
+		Name:          "acct/checkpoints",
+		AccountID:     accountID,
+		AccountName:   "acct",
+		AccountKey:    "a2V5",
+		BlobEndpoint:  "https://acct.blob.core.windows.net/",
+		ContainerName: "checkpoints",
+	}, store)
+	assert.True(t, gock.IsDone())
+}
@@ -368,18 +368,14 @@ func (e *GraphExpander) listFilteredApps(ctx context.Context, currentItem *TreeN
 }
 
 func (e *GraphExpander) getAppByID(ctx context.Context, currentItem *TreeNode) ExpanderResult {
-	commandChannel := make(chan string, 1)
-	commandPanelNotification := func(state interfaces.CommandPanelNotification) {
-		if state.EnterPressed {
-			commandChannel <- state.CurrentText
-			e.commandPanel.Hide()
+	appID, _ := promptInCommandPanel(e.gui, e.commandPanel, "App ID:", "", nil)
+	if appID == "" {
+		return ExpanderResult{
+			SourceDescription: "GraphExpander",
+			Err:               fmt.Errorf("Cancelled"),
 		}
 	}
 
-	e.commandPanel.ShowWithText("App ID:", "", nil, commandPanelNotification)
-
-	appID := <-commandChannel
-
 	// get the list of apps
 	expandURLRoot := currentItem.Parent.ID
 
@@ -562,18 +558,14 @@ func removeString(content string, start string, end string) string {
 }
 
 func (e *GraphExpander) searchAppsOrSps(ctx context.Context, currentItem *TreeNode) ExpanderResult {
-	commandChannel := make(chan string, 1)
-	commandPanelNotification := func(state interfaces.CommandPanelNotification) {
-		if state.EnterPressed {
-			commandChannel <- state.CurrentText
-			e.commandPanel.Hide()
+	queryText, _ := promptInCommandPanel(e.gui, e.commandPanel, "App Name:", "", nil)
+	if queryText == "" {
+		return ExpanderResult{
+			SourceDescription: "GraphExpander",
+			Err:               fmt.Errorf("Cancelled"),
 		}
 	}
 
-	e.commandPanel.ShowWithText("App Name:", "", nil, commandPanelNotification)
-
-	queryText := <-commandChannel
-
 	return e.listAppsOrSps(ctx, currentItem, queryText)
 }
 
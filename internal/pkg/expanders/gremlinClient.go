@@ -0,0 +1,155 @@
+package expanders
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	gremlinMimeType = "application/vnd.gremlin-v2.0+json"
+	// gremlinMaxMessageSize is the largest WebSocket message accepted from the server
+	gremlinMaxMessageSize = 64 * 1024 * 1024
+)
+
+// gremlinMutatingSteps matches the Gremlin steps which change the graph
+var gremlinMutatingSteps = regexp.MustCompile(`\b(addV|addE|property|drop|mergeV|mergeE)\s*\(`)
+
+// gremlinClient sends Gremlin queries to a Cosmos DB graph using the Gremlin server protocol
+// See https://tinkerpop.apache.org/docs/current/dev/provider/#_graph_driver_provider_requirements
+type gremlinClient struct {
+	conn     *websocket.Conn
+	username string
+	password string
+}
+
+type gremlinRequest struct {
+	RequestID string                 `json:"requestId"`
+	Op        string                 `json:"op"`
+	Processor string                 `json:"processor"`
+	Args      map[string]interface{} `json:"args"`
+}
+
+type gremlinResponse struct {
+	RequestID string `json:"requestId"`
+	Status    struct {
+		Code       int                    `json:"code"`
+		Message    string                 `json:"message"`
+		Attributes map[string]interface{} `json:"attributes"`
+	} `json:"status"`
+	Result struct {
+		Data []interface{} `json:"data"`
+	} `json:"result"`
+}
+
+// dialGremlin opens a WebSocket connection to the Gremlin endpoint (wss://host:port/), authenticating
+// as the graph (/dbs/{database}/colls/{graph}) when the server asks
+func dialGremlin(ctx context.Context, endpoint string, username string, password string) (*gremlinClient, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, strings.TrimSuffix(endpoint, "/")+"/gremlin", nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to %s: %s", endpoint, err)
+	}
+	conn.SetReadLimit(gremlinMaxMessageSize)
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	return &gremlinClient{
+		conn:     conn,
+		username: username,
+		password: password,
+	}, nil
+}
+
+// Close closes the connection
+func (c *gremlinClient) Close() error {
+	return c.conn.Close()
+}
+
+// submit runs the query and returns the results (combined across partial responses) and the
+// total request charge
+func (c *gremlinClient) submit(query string) ([]interface{}, float64, error) {
+	requestID := uuid.NewV4().String()
+	err := c.send(gremlinRequest{
+		RequestID: requestID,
+		Op:        "eval",
+		Args: map[string]interface{}{
+			"gremlin":  query,
+			"language": "gremlin-groovy",
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := []interface{}{}
+	requestCharge := 0.0
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return nil, 0, fmt.Errorf("Error reading response: %s", err)
+		}
+		var response gremlinResponse
+		if err = json.Unmarshal(message, &response); err != nil {
+			return nil, 0, fmt.Errorf("Error unmarshalling response: %s", err)
+		}
+		if charge, ok := response.Status.Attributes["x-ms-total-request-charge"].(float64); ok {
+			requestCharge = charge
+		}
+
+		switch response.Status.Code {
+		case 407: // authentication required
+			credentials := "\x00" + c.username + "\x00" + c.password
+			err = c.send(gremlinRequest{
+				RequestID: requestID,
+				Op:        "authentication",
+				Args: map[string]interface{}{
+					"sasl": base64.StdEncoding.EncodeToString([]byte(credentials)),
+				},
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+		case 206: // partial content, more responses follow
+			results = append(results, response.Result.Data...)
+		case 200, 204:
+			results = append(results, response.Result.Data...)
+			return results, requestCharge, nil
+		default:
+			return nil, requestCharge, fmt.Errorf("Query failed (%d): %s", response.Status.Code, response.Status.Message)
+		}
+	}
+}
+
+func (c *gremlinClient) send(request gremlinRequest) error {
+	buf, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("Error marshalling request: %s", err)
+	}
+	// Requests are prefixed with the length and value of the mime type
+	payload := append([]byte{byte(len(gremlinMimeType))}, gremlinMimeType...)
+	payload = append(payload, buf...)
+	if err = c.conn.WriteMessage(websocket.BinaryMessage, payload); err != nil {
+		return fmt.Errorf("Error sending request: %s", err)
+	}
+	return nil
+}
+
+// gremlinString returns value as a single-quoted Gremlin string literal
+func gremlinString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// isMutatingGremlinQuery returns true if the query contains steps which change the graph. This is a
+// text match to give a clear error up front, it isn't what keeps read-only mode read-only: the
+// connection uses the account's read-only key then, so the service rejects any write the match misses
+func isMutatingGremlinQuery(query string) bool {
+	return gremlinMutatingSteps.MatchString(query)
+}
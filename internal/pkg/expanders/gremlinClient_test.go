@@ -0,0 +1,81 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_gremlinHelpers(t *testing.T) {
+	assert.Equal(t, `'it\'s a \\ test'`, gremlinString(`it's a \ test`))
+	assert.Equal(t, "42", gremlinLiteral(float64(42)))
+	assert.Equal(t, "'pk1'", gremlinLiteral("pk1"))
+
+	assert.False(t, isMutatingGremlinQuery("g.V().hasLabel('person').out('knows').limit(10)"))
+	assert.True(t, isMutatingGremlinQuery("g.addV('person').property('name', 'bob')"))
+	assert.True(t, isMutatingGremlinQuery("g.V('1').drop ()"))
+}
+
+func Test_cosmosGremlinEndpoint(t *testing.T) {
+	account := &CosmosDbAccount{}
+	account.Properties.DocumentEndpoint = "https://acct.documents.azure.us:443/"
+	endpoint, err := cosmosGremlinEndpoint(account)
+	assert.NoError(t, err)
+	assert.Equal(t, "wss://acct.gremlin.cosmos.azure.us:443/", endpoint)
+
+	account.Properties.DocumentEndpoint = ""
+	_, err = cosmosGremlinEndpoint(account)
+	assert.Error(t, err)
+}
+
+func Test_gremlinClient_submit(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/gremlin", r.URL.Path)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close() //nolint: errcheck
+
+		readRequest := func() gremlinRequest {
+			_, message, err := conn.ReadMessage()
+			assert.NoError(t, err)
+			// Requests are prefixed with the mime type
+			assert.True(t, bytes.HasPrefix(message[1:], []byte(gremlinMimeType)))
+			var request gremlinRequest
+			assert.NoError(t, json.Unmarshal(message[1+len(gremlinMimeType):], &request))
+			return request
+		}
+
+		request := readRequest()
+		assert.Equal(t, "eval", request.Op)
+		assert.Equal(t, "g.V().limit(2)", request.Args["gremlin"])
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"requestId": "`+request.RequestID+`", "status": {"code": 407}}`))
+
+		request = readRequest()
+		assert.Equal(t, "authentication", request.Op)
+		sasl, _ := base64.StdEncoding.DecodeString(request.Args["sasl"].(string))
+		assert.Equal(t, "\x00/dbs/db/colls/graph\x00a2V5", string(sasl))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"requestId": "`+request.RequestID+`", "status": {"code": 206, "attributes": {"x-ms-total-request-charge": 1.5}}, "result": {"data": [{"id": "1"}]}}`))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"requestId": "`+request.RequestID+`", "status": {"code": 200, "attributes": {"x-ms-total-request-charge": 2.5}}, "result": {"data": [{"id": "2"}]}}`))
+	}))
+	defer ts.Close()
+
+	client, err := dialGremlin(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http")+"/", "/dbs/db/colls/graph", "a2V5")
+	assert.NoError(t, err)
+	defer client.Close() //nolint: errcheck
+
+	results, requestCharge, err := client.submit("g.V().limit(2)")
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 2.5, requestCharge)
+}
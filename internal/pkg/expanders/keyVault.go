@@ -0,0 +1,559 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const keyVaultTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.KeyVault/vaults/{vaultName}"
+
+const (
+	keyVaultAPIVersion = "7.4"
+	keyVaultResource   = "https://vault.azure.net"
+	// keyVaultHiddenValue is shown in place of secret values until they are revealed
+	keyVaultHiddenValue = "<hidden - use the 'Reveal value' action to show or set a new value here to update>"
+)
+
+const (
+	keyVaultNodeSecrets       = "keyvault-secrets"
+	keyVaultNodeSecret        = "keyvault-secret"
+	keyVaultNodeSecretVersion = "keyvault-secret-version"
+	keyVaultNodeKeys          = "keyvault-keys"
+	keyVaultNodeKey           = "keyvault-key"
+	keyVaultNodeKeyVersion    = "keyvault-key-version"
+	keyVaultNodeCertificates  = "keyvault-certificates"
+	keyVaultNodeCertificate   = "keyvault-certificate"
+	keyVaultNodeCertVersion   = "keyvault-certificate-version"
+)
+
+const keyVaultActionRevealSecret = "reveal-secret"
+
+// keyVaultAttributes are the attributes common to secrets, keys and certificates
+type keyVaultAttributes struct {
+	Enabled bool   `json:"enabled"`
+	Expires *int64 `json:"exp,omitempty"`
+	Created int64  `json:"created,omitempty"`
+	Updated int64  `json:"updated,omitempty"`
+}
+
+// keyVaultItem is an item returned when listing secrets, keys or certificates (or their versions)
+type keyVaultItem struct {
+	ID          string             `json:"id"`
+	Kid         string             `json:"kid"`
+	ContentType string             `json:"contentType,omitempty"`
+	Attributes  keyVaultAttributes `json:"attributes"`
+	Tags        map[string]string  `json:"tags,omitempty"`
+}
+
+type keyVaultListResponse struct {
+	Value    []keyVaultItem `json:"value"`
+	NextLink string         `json:"nextLink"`
+}
+
+type keyVaultResponse struct {
+	Properties struct {
+		VaultURI string `json:"vaultUri"`
+	} `json:"properties"`
+}
+
+// NewKeyVaultExpander creates a new instance of KeyVaultExpander
+func NewKeyVaultExpander(armClient *armclient.Client) *KeyVaultExpander {
+	return &KeyVaultExpander{
+		client:    &http.Client{},
+		armClient: armClient,
+		getToken:  armclient.AcquireTokenForResourceFromAzCLI,
+	}
+}
+
+// Check interface
+var _ Expander = &KeyVaultExpander{}
+
+// KeyVaultExpander expands the data-plane aspects of a Key Vault
+type KeyVaultExpander struct {
+	ExpanderBase
+	client    *http.Client
+	armClient *armclient.Client
+	getToken  func(subscription string, resource string) (armclient.AzCLIToken, error)
+}
+
+func (e *KeyVaultExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *KeyVaultExpander) Name() string {
+	return "KeyVaultExpander"
+}
+
+// DoesExpand checks if this is a Key Vault
+func (e *KeyVaultExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == keyVaultTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "keyvault" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the secrets, keys and certificates in the Key Vault
+func (e *KeyVaultExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "keyvault" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == keyVaultTemplateURL {
+		newItems := []*TreeNode{}
+		for _, child := range []struct{ name, itemType, path string }{
+			{"Secrets", keyVaultNodeSecrets, "secrets"},
+			{"Keys", keyVaultNodeKeys, "keys"},
+			{"Certificates", keyVaultNodeCertificates, "certificates"},
+		} {
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<" + child.path + ">",
+				Namespace:             "keyvault",
+				Name:                  child.name,
+				Display:               child.name,
+				ItemType:              child.itemType,
+				ExpandURL:             ExpandURLNotSupported,
+				SubscriptionID:        currentItem.SubscriptionID,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"VaultID":        currentItem.ID,
+					"SubscriptionID": armclient.GetSubscriptionIDFromResourceID(currentItem.ID),
+					"ListPath":       child.path,
+				},
+			})
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "KeyVaultExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case keyVaultNodeSecrets:
+		return e.expandList(ctx, currentItem, keyVaultNodeSecret)
+	case keyVaultNodeKeys:
+		return e.expandList(ctx, currentItem, keyVaultNodeKey)
+	case keyVaultNodeCertificates:
+		return e.expandList(ctx, currentItem, keyVaultNodeCertificate)
+	case keyVaultNodeSecret:
+		return e.expandItem(ctx, currentItem, keyVaultNodeSecretVersion)
+	case keyVaultNodeKey:
+		return e.expandItem(ctx, currentItem, keyVaultNodeKeyVersion)
+	case keyVaultNodeCertificate:
+		return e.expandItem(ctx, currentItem, keyVaultNodeCertVersion)
+	case keyVaultNodeSecretVersion:
+		return e.expandSecretVersion(currentItem)
+	case keyVaultNodeKeyVersion, keyVaultNodeCertVersion:
+		return e.expandVersion(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "KeyVaultExpander request",
+	}
+}
+
+// expandList lists the secrets, keys or certificates in the vault
+func (e *KeyVaultExpander) expandList(ctx context.Context, currentItem *TreeNode, childItemType string) ExpanderResult {
+	vaultURI, err := e.getVaultURI(ctx, currentItem)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "KeyVaultExpander request",
+		}
+	}
+
+	listURL := currentItem.Metadata["NextLink"]
+	if listURL == "" {
+		listURL = vaultURI + currentItem.Metadata["ListPath"] + "?api-version=" + keyVaultAPIVersion
+	}
+
+	return e.expandItemList(ctx, currentItem, listURL, vaultURI, func(item keyVaultItem) *TreeNode {
+		name := lastSegment(item.ID)
+		return &TreeNode{
+			ID:       currentItem.Metadata["VaultID"] + "/<" + currentItem.Metadata["ListPath"] + ">/" + name,
+			Name:     name,
+			Display:  name + "\n  " + style.Subtle(keyVaultStatus(item.Attributes)),
+			ItemType: childItemType,
+		}
+	})
+}
+
+// expandItem lists the versions of a secret, key or certificate
+func (e *KeyVaultExpander) expandItem(ctx context.Context, currentItem *TreeNode, childItemType string) ExpanderResult {
+	listURL := currentItem.Metadata["NextLink"]
+	if listURL == "" {
+		listURL = currentItem.Metadata["VaultURI"] + currentItem.Metadata["ListPath"] + "/" + currentItem.Metadata["ItemName"] + "/versions?api-version=" + keyVaultAPIVersion
+	}
+
+	result := e.expandItemList(ctx, currentItem, listURL, currentItem.Metadata["VaultURI"], func(item keyVaultItem) *TreeNode {
+		version := lastSegment(item.ID)
+		return &TreeNode{
+			ID:       currentItem.ID + "/" + version,
+			Name:     version,
+			Display:  version + "\n  " + style.Subtle(keyVaultStatus(item.Attributes)),
+			ItemType: childItemType,
+		}
+	})
+
+	// Show the current metadata for secrets (without the value) so they can be updated via the editor
+	if result.Err == nil && currentItem.ItemType == keyVaultNodeSecret && currentItem.Metadata["NextLink"] == "" {
+		result.Response = ExpanderResponse{Response: hideKeyVaultSecretValue(currentItem.Metadata["Content"]), ResponseType: interfaces.ResponseJSON}
+	}
+	return result
+}
+
+func (e *KeyVaultExpander) expandItemList(ctx context.Context, currentItem *TreeNode, listURL string, vaultURI string, createNode func(item keyVaultItem) *TreeNode) ExpanderResult {
+	data, err := e.doRequest(ctx, "GET", listURL, currentItem.Metadata["SubscriptionID"], "")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing Key Vault items: %s", err),
+			SourceDescription: "KeyVaultExpander request",
+		}
+	}
+
+	var response keyVaultListResponse
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling Key Vault list response: %s", err),
+			SourceDescription: "KeyVaultExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, item := range response.Value {
+		if item.ID == "" {
+			// Keys are listed with a 'kid' rather than an 'id'
+			item.ID = item.Kid
+		}
+		content, err := json.MarshalIndent(item, "", "  ")
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error marshalling Key Vault item: %s", err),
+				SourceDescription: "KeyVaultExpander request",
+			}
+		}
+
+		node := createNode(item)
+		node.Parentid = currentItem.ID
+		node.Namespace = "keyvault"
+		node.ExpandURL = ExpandURLNotSupported
+		node.SubscriptionID = currentItem.SubscriptionID
+		node.SuppressSwaggerExpand = true
+		node.SuppressGenericExpand = true
+		node.StatusIndicator = keyVaultStatusIndicator(item.Attributes)
+		node.Metadata = map[string]string{
+			"VaultID":        currentItem.Metadata["VaultID"],
+			"VaultURI":       vaultURI,
+			"SubscriptionID": currentItem.Metadata["SubscriptionID"],
+			"ListPath":       currentItem.Metadata["ListPath"],
+			"ItemName":       currentItem.Metadata["ItemName"],
+			"ItemID":         item.ID,
+			"Content":        string(content),
+		}
+		if node.Metadata["ItemName"] == "" {
+			node.Metadata["ItemName"] = node.Name
+		}
+		nodes = append(nodes, node)
+	}
+
+	if response.NextLink != "" {
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "keyvault",
+			ID:            currentItem.ID + "/...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      currentItem.ItemType,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata: map[string]string{
+				"VaultID":        currentItem.Metadata["VaultID"],
+				"VaultURI":       vaultURI,
+				"SubscriptionID": currentItem.Metadata["SubscriptionID"],
+				"ListPath":       currentItem.Metadata["ListPath"],
+				"ItemName":       currentItem.Metadata["ItemName"],
+				"NextLink":       response.NextLink,
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(data), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "KeyVaultExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandSecretVersion shows the attributes of the secret version, the value is only shown via the reveal action
+func (e *KeyVaultExpander) expandSecretVersion(currentItem *TreeNode) ExpanderResult {
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "KeyVaultExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandVersion gets the key or certificate version (these contain public material only)
+func (e *KeyVaultExpander) expandVersion(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.doRequest(ctx, "GET", currentItem.Metadata["ItemID"]+"?api-version="+keyVaultAPIVersion, currentItem.Metadata["SubscriptionID"], "")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting Key Vault item: %s", err),
+			SourceDescription: "KeyVaultExpander request",
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(data), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "KeyVaultExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// HasActions returns true for secrets so their value can be revealed
+func (e *KeyVaultExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	if isKeyVaultMoreNode(item) {
+		return false, nil
+	}
+	switch item.ItemType {
+	case keyVaultNodeSecret, keyVaultNodeSecretVersion:
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the reveal action for secrets
+func (e *KeyVaultExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	switch item.ItemType {
+	case keyVaultNodeSecret, keyVaultNodeSecretVersion:
+		return ListActionsResult{
+			Nodes: []*TreeNode{
+				{
+					Parentid:              item.ID,
+					ID:                    item.ID + "?" + keyVaultActionRevealSecret,
+					Namespace:             "keyvault",
+					Name:                  "Reveal value",
+					Display:               "Reveal value",
+					ItemType:              ActionType,
+					SuppressGenericExpand: true,
+					Metadata: map[string]string{
+						"ActionID": keyVaultActionRevealSecret,
+					},
+				},
+			},
+			SourceDescription: "KeyVaultExpander",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ListActionsResult{
+		SourceDescription: "KeyVaultExpander",
+		Err:               fmt.Errorf("ListActions not supported for ItemType %q", item.ItemType),
+	}
+}
+
+// ExecuteAction reveals the secret value
+func (e *KeyVaultExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+	if actionID != keyVaultActionRevealSecret {
+		return ExpanderResult{
+			SourceDescription: "KeyVaultExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+
+	secretItem := item.Parent
+	// The secret node's ItemID has no version so gets the current value
+	data, err := e.doRequest(ctx, "GET", secretItem.Metadata["ItemID"]+"?api-version="+keyVaultAPIVersion, secretItem.Metadata["SubscriptionID"], "")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting secret: %s", err),
+			SourceDescription: "KeyVaultExpander",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(data), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "KeyVaultExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// CanUpdate returns true for secrets as a new version can be set
+func (e *KeyVaultExpander) CanUpdate(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == keyVaultNodeSecret && !isKeyVaultMoreNode(item), nil
+}
+
+// Update sets a new version of the secret using the value from the edited content
+func (e *KeyVaultExpander) Update(ctx context.Context, item *TreeNode, updatedContent string) error {
+	if item.ItemType != keyVaultNodeSecret || isKeyVaultMoreNode(item) {
+		return fmt.Errorf("Unsupported item type: %s", item.ItemType)
+	}
+
+	var secret struct {
+		Value       string              `json:"value"`
+		ContentType string              `json:"contentType,omitempty"`
+		Attributes  *keyVaultAttributes `json:"attributes"`
+		Tags        map[string]string   `json:"tags,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(updatedContent), &secret); err != nil {
+		return fmt.Errorf("Error parsing secret: %s", err)
+	}
+	if secret.Value == "" || secret.Value == keyVaultHiddenValue {
+		return fmt.Errorf("Set 'value' to the new secret value to update the secret")
+	}
+
+	request := map[string]interface{}{
+		"value":       secret.Value,
+		"contentType": secret.ContentType,
+		"tags":        secret.Tags,
+	}
+	// Leave out attributes when they weren't edited so Key Vault applies its defaults (enabled)
+	if secret.Attributes != nil {
+		request["attributes"] = map[string]interface{}{
+			"enabled": secret.Attributes.Enabled,
+			"exp":     secret.Attributes.Expires,
+		}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// Set Secret docs: https://learn.microsoft.com/en-us/rest/api/keyvault/secrets/set-secret/set-secret
+	setURL := item.Metadata["VaultURI"] + "secrets/" + url.PathEscape(item.Name) + "?api-version=" + keyVaultAPIVersion
+	_, err = e.doRequest(ctx, "PUT", setURL, item.Metadata["SubscriptionID"], string(body))
+	if err != nil {
+		return fmt.Errorf("Error setting secret: %s", err)
+	}
+	return nil
+}
+
+func (e *KeyVaultExpander) getVaultURI(ctx context.Context, currentItem *TreeNode) (string, error) {
+	if vaultURI := currentItem.Metadata["VaultURI"]; vaultURI != "" {
+		return vaultURI, nil
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.Metadata["VaultID"]+"?api-version=2019-09-01")
+	if err != nil {
+		return "", fmt.Errorf("Error getting vault: %s", err)
+	}
+	var response keyVaultResponse
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling vault response: %s", err)
+	}
+	if response.Properties.VaultURI == "" {
+		return "", fmt.Errorf("Vault URI not found for %s", currentItem.Metadata["VaultID"])
+	}
+	vaultURI := response.Properties.VaultURI
+	if !strings.HasSuffix(vaultURI, "/") {
+		vaultURI += "/"
+	}
+	currentItem.Metadata["VaultURI"] = vaultURI
+	return vaultURI, nil
+}
+
+func (e *KeyVaultExpander) doRequest(ctx context.Context, verb string, url string, subscriptionID string, body string) ([]byte, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(keyvault):"+url, tracing.SetTag("url", url))
+	defer span.Finish()
+
+	token, err := e.getToken(subscriptionID, keyVaultResource)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting token: %s", err)
+	}
+
+	req, err := http.NewRequest(verb, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("Request failed %v for '%s': %s", response.Status, url, string(buf))
+	}
+	return buf, nil
+}
+
+// isKeyVaultMoreNode returns true for the "more..." paging nodes, these share the ItemType
+// of the node being paged so they expand the same way but aren't secrets themselves
+func isKeyVaultMoreNode(item *TreeNode) bool {
+	return item.Metadata["NextLink"] != ""
+}
+
+// keyVaultStatus describes whether an item is enabled and when it expires
+func keyVaultStatus(attributes keyVaultAttributes) string {
+	status := "enabled"
+	if !attributes.Enabled {
+		status = "disabled"
+	}
+	if attributes.Expires != nil {
+		expires := time.Unix(*attributes.Expires, 0).UTC()
+		if expires.Before(time.Now()) {
+			status += ", expired " + expires.Format("2006-01-02")
+		} else {
+			status += ", expires " + expires.Format("2006-01-02")
+		}
+	}
+	return status
+}
+
+func keyVaultStatusIndicator(attributes keyVaultAttributes) string {
+	if !attributes.Enabled {
+		return DrawStatus("Suspended")
+	}
+	if attributes.Expires != nil && time.Unix(*attributes.Expires, 0).Before(time.Now()) {
+		return DrawStatus("Failed")
+	}
+	return ""
+}
+
+// hideKeyVaultSecretValue adds a placeholder value to the secret metadata so it can be edited to set a new value
+func hideKeyVaultSecretValue(content string) string {
+	var secret map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &secret); err != nil {
+		return content
+	}
+	secret["value"] = keyVaultHiddenValue
+	buf, err := json.MarshalIndent(secret, "", "  ")
+	if err != nil {
+		return content
+	}
+	return string(buf)
+}
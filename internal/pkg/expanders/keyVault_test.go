@@ -0,0 +1,162 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/stretchr/testify/assert"
+)
+
+const keyVaultTestVaultID = "/subscriptions/1/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1"
+
+func newKeyVaultTestExpander(ts *httptest.Server) *KeyVaultExpander {
+	return &KeyVaultExpander{
+		client: ts.Client(),
+		getToken: func(subscription string, resource string) (armclient.AzCLIToken, error) {
+			return armclient.AzCLIToken{AccessToken: "bob"}, nil
+		},
+	}
+}
+
+func Test_KeyVault_ExpandSecrets(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/secrets", r.URL.Path)
+		assert.Equal(t, "Bearer bob", r.Header.Get("Authorization"))
+		_, _ = io.WriteString(w, `{
+			"value": [
+				{"id": "`+ts.URL+`/secrets/db-password", "attributes": {"enabled": true}},
+				{"id": "`+ts.URL+`/secrets/old", "attributes": {"enabled": false}}
+			],
+			"nextLink": "`+ts.URL+`/secrets?page=2"
+		}`)
+	}))
+	defer ts.Close()
+
+	e := newKeyVaultTestExpander(ts)
+	secrets := &TreeNode{
+		ID:        keyVaultTestVaultID + "/<secrets>",
+		Namespace: "keyvault",
+		ItemType:  keyVaultNodeSecrets,
+		Metadata: map[string]string{
+			"VaultID":  keyVaultTestVaultID,
+			"VaultURI": ts.URL + "/",
+			"ListPath": "secrets",
+		},
+	}
+
+	result := e.Expand(context.Background(), secrets)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Nodes, 3)
+
+	secret := result.Nodes[0]
+	assert.Equal(t, keyVaultTestVaultID+"/<secrets>/db-password", secret.ID)
+	assert.Equal(t, secrets.ID, secret.Parentid)
+	assert.Equal(t, keyVaultNodeSecret, secret.ItemType)
+	assert.Equal(t, "db-password", secret.Metadata["ItemName"])
+	assert.Equal(t, ts.URL+"/secrets/db-password", secret.Metadata["ItemID"])
+	assert.Equal(t, "", secret.StatusIndicator)
+	assert.NotEqual(t, "", result.Nodes[1].StatusIndicator)
+
+	more := result.Nodes[2]
+	assert.Equal(t, "more...", more.Name)
+	assert.Equal(t, keyVaultNodeSecrets, more.ItemType)
+	assert.Equal(t, ts.URL+"/secrets?page=2", more.Metadata["NextLink"])
+}
+
+func Test_KeyVault_ExpandSecretVersions(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/secrets/db-password/versions", r.URL.Path)
+		_, _ = io.WriteString(w, `{
+			"value": [{"id": "`+ts.URL+`/secrets/db-password/v1", "attributes": {"enabled": true}}],
+			"nextLink": "`+ts.URL+`/secrets/db-password/versions?page=2"
+		}`)
+	}))
+	defer ts.Close()
+
+	e := newKeyVaultTestExpander(ts)
+	secret := &TreeNode{
+		ID:        keyVaultTestVaultID + "/<secrets>/db-password",
+		Name:      "db-password",
+		Namespace: "keyvault",
+		ItemType:  keyVaultNodeSecret,
+		Metadata: map[string]string{
+			"VaultID":  keyVaultTestVaultID,
+			"VaultURI": ts.URL + "/",
+			"ListPath": "secrets",
+			"ItemName": "db-password",
+			"Content":  `{"id": "` + ts.URL + `/secrets/db-password", "attributes": {"enabled": true}}`,
+		},
+	}
+
+	result := e.Expand(context.Background(), secret)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Nodes, 2)
+
+	version := result.Nodes[0]
+	assert.Equal(t, secret.ID+"/v1", version.ID)
+	assert.Equal(t, keyVaultNodeSecretVersion, version.ItemType)
+	assert.Equal(t, "db-password", version.Metadata["ItemName"])
+
+	// The secret is shown with a placeholder value so it can be edited
+	var content map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(result.Response.Response), &content))
+	assert.Equal(t, keyVaultHiddenValue, content["value"])
+
+	ctx := context.Background()
+	canUpdate, _ := e.CanUpdate(ctx, secret)
+	assert.True(t, canUpdate)
+	hasActions, _ := e.HasActions(ctx, version)
+	assert.True(t, hasActions)
+
+	// The paging node shares the secret's ItemType but isn't a secret
+	more := result.Nodes[1]
+	assert.Equal(t, keyVaultNodeSecret, more.ItemType)
+	canUpdate, _ = e.CanUpdate(ctx, more)
+	assert.False(t, canUpdate)
+	hasActions, _ = e.HasActions(ctx, more)
+	assert.False(t, hasActions)
+	assert.Error(t, e.Update(ctx, more, `{"value": "new"}`))
+}
+
+func Test_KeyVault_UpdateSecret(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "/secrets/db-password", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	defer ts.Close()
+
+	e := newKeyVaultTestExpander(ts)
+	secret := &TreeNode{
+		Name:     "db-password",
+		ItemType: keyVaultNodeSecret,
+		Metadata: map[string]string{"VaultURI": ts.URL + "/"},
+	}
+
+	ctx := context.Background()
+	err := e.Update(ctx, secret, `{"value": "`+keyVaultHiddenValue+`"}`)
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "Set 'value'"))
+
+	assert.NoError(t, e.Update(ctx, secret, `{"value": "new-value", "contentType": "text/plain", "attributes": {"enabled": true}}`))
+	assert.Equal(t, "new-value", body["value"])
+	assert.Equal(t, "text/plain", body["contentType"])
+	assert.Equal(t, map[string]interface{}{"enabled": true, "exp": nil}, body["attributes"])
+
+	// Without attributes the new version is left to Key Vault's defaults rather than being disabled
+	body = nil
+	assert.NoError(t, e.Update(ctx, secret, `{"value": "another-value"}`))
+	assert.Equal(t, "another-value", body["value"])
+	_, hasAttributes := body["attributes"]
+	assert.False(t, hasAttributes)
+}
@@ -0,0 +1,301 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// queryDefaultTimeRange is used until a time range is set for the resource
+const queryDefaultTimeRange = "24h"
+
+// queryTimeRanges are the time range presets offered for queries, with their ISO8601 durations
+var queryTimeRanges = []struct {
+	Name     string
+	Timespan string
+}{
+	{"30m", "PT30M"},
+	{"1h", "PT1H"},
+	{"4h", "PT4H"},
+	{"12h", "PT12H"},
+	{"24h", "P1D"},
+	{"48h", "P2D"},
+	{"7d", "P7D"},
+	{"30d", "P30D"},
+}
+
+// kustoMaxColumnWidth and kustoMaxRows limit the size of the tables rendered for query results
+const (
+	kustoMaxColumnWidth = 50
+	kustoMaxRows        = 500
+)
+
+type kustoQueryRequest struct {
+	Query    string `json:"query"`
+	Timespan string `json:"timespan,omitempty"`
+}
+
+// kustoQueryResponse is the response from the Log Analytics and App Insights query APIs
+type kustoQueryResponse struct {
+	Tables []kustoTable `json:"tables"`
+}
+
+type kustoTable struct {
+	Name    string `json:"name"`
+	Columns []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"columns"`
+	Rows [][]interface{} `json:"rows"`
+}
+
+func queryTimeRangeKey(resourceID string) string {
+	return resourceCacheKey("QueryTimeRange", strings.ToLower(resourceID))
+}
+
+// getQueryTimeRange returns the name and ISO8601 duration of the time range set for the resource
+func getQueryTimeRange(resourceID string) (string, string) {
+	selected, _ := storage.GetCache(queryTimeRangeKey(resourceID))
+	defaultTimespan := ""
+	for _, timeRange := range queryTimeRanges {
+		if timeRange.Name == selected {
+			return timeRange.Name, timeRange.Timespan
+		}
+		if timeRange.Name == queryDefaultTimeRange {
+			defaultTimespan = timeRange.Timespan
+		}
+	}
+	return queryDefaultTimeRange, defaultTimespan
+}
+
+// promptForQueryTimeRange prompts for the time range queries against the resource cover and saves it
+func promptForQueryTimeRange(gui *gocui.Gui, commandPanel interfaces.CommandPanel, resourceID string) (string, error) {
+	options := []interfaces.CommandPanelListOption{}
+	for _, timeRange := range queryTimeRanges {
+		options = append(options, interfaces.CommandPanelListOption{ID: timeRange.Name, DisplayText: timeRange.Name})
+	}
+	_, selected := promptInCommandPanel(gui, commandPanel, "time range:", "", &options)
+	if selected == "" {
+		return "", fmt.Errorf("No time range selected")
+	}
+	if err := storage.PutCache(queryTimeRangeKey(resourceID), selected); err != nil {
+		return "", fmt.Errorf("Error saving time range: %s", err)
+	}
+	return selected, nil
+}
+
+// doKustoQuery runs a KQL query using the Log Analytics or App Insights query API
+func doKustoQuery(ctx context.Context, client *http.Client, queryURL string, resource string, subscriptionID string, queryText string, timespan string) ([]byte, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(kusto):"+queryURL, tracing.SetTag("url", queryURL))
+	defer span.Finish()
+
+	token, err := armclient.AcquireTokenForResourceFromAzCLI(subscriptionID, resource)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting token: %s", err)
+	}
+
+	body, err := json.Marshal(kustoQueryRequest{Query: queryText, Timespan: timespan})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal query: %s", err)
+	}
+	req, err := http.NewRequest("POST", queryURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("Query failed %v: %s", response.Status, string(buf))
+	}
+	return buf, nil
+}
+
+// kustoQueryResult shows query results as a table, with a child node to view the raw JSON response
+func kustoQueryResult(currentItem *TreeNode, data []byte, title string, timeRange string, namespace string, jsonItemType string, sourceDescription string) ExpanderResult {
+	var response kustoQueryResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling query response: %s", err),
+			SourceDescription: sourceDescription,
+			IsPrimaryResponse: true,
+		}
+	}
+
+	rowCount := 0
+	for _, table := range response.Tables {
+		rowCount += len(table.Rows)
+	}
+
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     formatKustoTables(response.Tables),
+			ResponseType: interfaces.ResponsePlainText,
+			Title:        fmt.Sprintf("%s (%d rows, last %s)", title, rowCount, timeRange),
+		},
+		Nodes: []*TreeNode{
+			{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<json>",
+				Namespace:             namespace,
+				Name:                  "View as JSON",
+				Display:               "View as JSON",
+				ItemType:              jsonItemType,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"Response": string(data),
+				},
+			},
+		},
+		SourceDescription: sourceDescription,
+		IsPrimaryResponse: true,
+	}
+}
+
+// formatKustoTables renders query results as aligned text tables
+func formatKustoTables(tables []kustoTable) string {
+	var buf strings.Builder
+	for _, table := range tables {
+		if len(tables) > 1 {
+			fmt.Fprintf(&buf, "%s\n\n", table.Name)
+		}
+
+		rows := table.Rows
+		if len(rows) > kustoMaxRows {
+			rows = rows[:kustoMaxRows]
+		}
+
+		cells := make([][]string, 0, len(rows)+1)
+		header := []string{}
+		for _, column := range table.Columns {
+			header = append(header, column.Name)
+		}
+		cells = append(cells, header)
+		for _, row := range rows {
+			rowCells := []string{}
+			for _, value := range row {
+				rowCells = append(rowCells, kustoCellText(value))
+			}
+			cells = append(cells, rowCells)
+		}
+
+		widths := make([]int, len(header))
+		for _, rowCells := range cells {
+			for i, cell := range rowCells {
+				if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+					widths[i] = utf8.RuneCountInString(cell)
+				}
+			}
+		}
+
+		for rowIndex, rowCells := range cells {
+			line := []string{}
+			for i, cell := range rowCells {
+				if i < len(widths) {
+					line = append(line, cell+strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+				}
+			}
+			buf.WriteString(strings.TrimRight(strings.Join(line, "  "), " ") + "\n")
+			if rowIndex == 0 {
+				separators := []string{}
+				for _, width := range widths {
+					separators = append(separators, strings.Repeat("-", width))
+				}
+				buf.WriteString(strings.Join(separators, "  ") + "\n")
+			}
+		}
+
+		if len(rows) < len(table.Rows) {
+			fmt.Fprintf(&buf, "\n(showing the first %d of %d rows, view as JSON to see them all)\n", len(rows), len(table.Rows))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// kustoCellText formats a value from a query result as a single line
+func kustoCellText(value interface{}) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		text = ""
+	case string:
+		text = v
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		text = strconv.FormatBool(v)
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			text = fmt.Sprintf("%v", v)
+		} else {
+			text = string(buf)
+		}
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) > kustoMaxColumnWidth {
+		text = string([]rune(text)[:kustoMaxColumnWidth-1]) + "…"
+	}
+	return text
+}
+
+// kustoColumnValues returns the distinct, non-empty values of a column in the first table of the results
+func kustoColumnValues(tables []kustoTable, columnName string) []string {
+	values := []string{}
+	if len(tables) == 0 {
+		return values
+	}
+	columnIndex := -1
+	for i, column := range tables[0].Columns {
+		if column.Name == columnName {
+			columnIndex = i
+		}
+	}
+	if columnIndex < 0 {
+		return values
+	}
+
+	seen := map[string]bool{}
+	for _, row := range tables[0].Rows {
+		if columnIndex >= len(row) {
+			continue
+		}
+		value, ok := row[columnIndex].(string)
+		if ok && value != "" && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// kqlString quotes a value as a KQL string literal
+func kqlString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,40 @@
+package expanders
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_formatKustoTables(t *testing.T) {
+	var response kustoQueryResponse
+	err := json.Unmarshal([]byte(`{"tables":[{"name":"PrimaryResult","columns":[{"name":"TimeGenerated","type":"datetime"},{"name":"Computer","type":"string"},{"name":"Count","type":"long"}],
+		"rows":[["2024-01-02T03:04:05Z","web-1",42],["2024-01-02T03:05:00Z",null,7.5]]}]}`), &response)
+	assert.NoError(t, err)
+
+	expected := "TimeGenerated         Computer  Count\n" +
+		"--------------------  --------  -----\n" +
+		"2024-01-02T03:04:05Z  web-1     42\n" +
+		"2024-01-02T03:05:00Z            7.5\n\n"
+	assert.Equal(t, expected, formatKustoTables(response.Tables))
+}
+
+func Test_kustoCellText(t *testing.T) {
+	assert.Equal(t, "", kustoCellText(nil))
+	assert.Equal(t, "true", kustoCellText(true))
+	assert.Equal(t, "line one line two", kustoCellText("line one\n  line two"))
+	assert.Equal(t, `{"a":1}`, kustoCellText(map[string]interface{}{"a": 1}))
+	assert.Len(t, []rune(kustoCellText(string(make([]byte, 100)))), kustoMaxColumnWidth)
+}
+
+func Test_kustoColumnValues(t *testing.T) {
+	var response kustoQueryResponse
+	err := json.Unmarshal([]byte(`{"tables":[{"name":"PrimaryResult","columns":[{"name":"name","type":"string"},{"name":"operation_Id","type":"string"}],
+		"rows":[["GET /","op1"],["GET /a","op2"],["GET /b","op1"],["GET /c",null]]}]}`), &response)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"op1", "op2"}, kustoColumnValues(response.Tables, "operation_Id"))
+	assert.Empty(t, kustoColumnValues(response.Tables, "missing"))
+	assert.Equal(t, `'it\'s'`, kqlString("it's"))
+}
@@ -0,0 +1,457 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/editor"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const logAnalyticsWorkspaceTemplateURL = "/subscriptions/{subscriptionId}/resourcegroups/{resourceGroupName}/providers/Microsoft.OperationalInsights/workspaces/{workspaceName}"
+
+const (
+	logAnalyticsAPIVersion = "2020-08-01"
+	logAnalyticsResource   = "https://api.loganalytics.io"
+	logAnalyticsQueryURL   = "https://api.loganalytics.io/v1/workspaces/%s/query"
+)
+
+const (
+	logAnalyticsNodeSavedSearches = "loganalytics-savedsearches"
+	logAnalyticsNodeSavedSearch   = "loganalytics-savedsearch"
+	logAnalyticsNodeHistory       = "loganalytics-history"
+	logAnalyticsNodeHistoryQuery  = "loganalytics-history-query"
+	logAnalyticsNodeResultsJSON   = "loganalytics-results-json"
+)
+
+const (
+	logAnalyticsActionQuery         = "loganalytics-query"
+	logAnalyticsActionQueryInEditor = "loganalytics-query-editor"
+	logAnalyticsActionTimeRange     = "loganalytics-timerange"
+)
+
+const (
+	logAnalyticsDefaultQuery = "search * | take 10"
+	// logAnalyticsQueryTimeoutSeconds allows for queries which take longer than the default expand timeout
+	logAnalyticsQueryTimeoutSeconds = 180
+)
+
+type logAnalyticsWorkspaceResponse struct {
+	Properties struct {
+		CustomerID string `json:"customerId"`
+	} `json:"properties"`
+}
+
+type logAnalyticsSavedSearchListResponse struct {
+	Value []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Properties struct {
+			Category      string `json:"category"`
+			DisplayName   string `json:"displayName"`
+			Query         string `json:"query"`
+			FunctionAlias string `json:"functionAlias"`
+		} `json:"properties"`
+	} `json:"value"`
+}
+
+// NewLogAnalyticsExpander creates a new instance of LogAnalyticsExpander
+func NewLogAnalyticsExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *LogAnalyticsExpander {
+	return &LogAnalyticsExpander{
+		client:       &http.Client{},
+		armClient:    armclient,
+		gui:          gui,
+		commandPanel: commandPanel,
+		customerIDs:  map[string]string{},
+	}
+}
+
+// Check interface
+var _ Expander = &LogAnalyticsExpander{}
+
+// LogAnalyticsExpander runs KQL queries against a Log Analytics workspace
+type LogAnalyticsExpander struct {
+	ExpanderBase
+	client       *http.Client
+	armClient    *armclient.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+
+	// customerIDs are the workspace IDs used by the query API, keyed by resource ID
+	customerIDs      map[string]string
+	customerIDsMutex sync.Mutex
+}
+
+func (e *LogAnalyticsExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *LogAnalyticsExpander) Name() string {
+	return "LogAnalyticsExpander"
+}
+
+func isLogAnalyticsWorkspace(item *TreeNode) bool {
+	return item.ItemType == ResourceType &&
+		item.SwaggerResourceType != nil &&
+		item.SwaggerResourceType.Endpoint.TemplateURL == logAnalyticsWorkspaceTemplateURL
+}
+
+// DoesExpand checks if this is a Log Analytics workspace
+func (e *LogAnalyticsExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if isLogAnalyticsWorkspace(currentItem) {
+		return true, nil
+	}
+	if currentItem.Namespace == "loganalytics" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the saved searches and query history of the workspace, or runs the selected query
+func (e *LogAnalyticsExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	if currentItem.Namespace != "loganalytics" && isLogAnalyticsWorkspace(currentItem) {
+		newItems := []*TreeNode{}
+		for _, child := range []struct{ name, itemType, path string }{
+			{"Saved Searches", logAnalyticsNodeSavedSearches, "savedSearches"},
+			{"Query History", logAnalyticsNodeHistory, "history"},
+		} {
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<" + child.path + ">",
+				Namespace:             "loganalytics",
+				Name:                  child.name,
+				Display:               child.name,
+				ItemType:              child.itemType,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"WorkspaceID": currentItem.ID,
+				},
+			})
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "LogAnalyticsExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case logAnalyticsNodeSavedSearches:
+		return e.expandSavedSearches(ctx, currentItem)
+	case logAnalyticsNodeHistory:
+		return e.expandHistory(currentItem)
+	case logAnalyticsNodeSavedSearch, logAnalyticsNodeHistoryQuery:
+		return e.runQuery(ctx, currentItem, currentItem.Metadata["WorkspaceID"], currentItem.Metadata["Query"])
+	case logAnalyticsNodeResultsJSON:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Response"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "LogAnalyticsExpander request",
+	}
+}
+
+func (e *LogAnalyticsExpander) expandSavedSearches(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	workspaceID := currentItem.Metadata["WorkspaceID"]
+	data, err := e.armClient.DoRequest(ctx, "GET", workspaceID+"/savedSearches?api-version="+logAnalyticsAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing saved searches: %s", err),
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var response logAnalyticsSavedSearchListResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling saved searches response: %s", err),
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	searches := response.Value
+	sort.Slice(searches, func(i, j int) bool {
+		if searches[i].Properties.Category != searches[j].Properties.Category {
+			return searches[i].Properties.Category < searches[j].Properties.Category
+		}
+		return searches[i].Properties.DisplayName < searches[j].Properties.DisplayName
+	})
+
+	timeoutSeconds := logAnalyticsQueryTimeoutSeconds
+	newItems := []*TreeNode{}
+	for _, search := range searches {
+		category := search.Properties.Category
+		if search.Properties.FunctionAlias != "" {
+			category += " - function " + search.Properties.FunctionAlias
+		}
+		newItems = append(newItems, &TreeNode{
+			Parentid:               currentItem.ID,
+			ID:                     currentItem.ID + "/" + search.Name,
+			Namespace:              "loganalytics",
+			Name:                   search.Properties.DisplayName,
+			Display:                style.Subtle("["+category+"]") + "\n  " + search.Properties.DisplayName,
+			ItemType:               logAnalyticsNodeSavedSearch,
+			ExpandURL:              ExpandURLNotSupported,
+			SuppressSwaggerExpand:  true,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: &timeoutSeconds,
+			Metadata: map[string]string{
+				"WorkspaceID": workspaceID,
+				"Query":       search.Properties.Query,
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: interfaces.ResponseJSON},
+		Nodes:             newItems,
+		SourceDescription: "LogAnalyticsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *LogAnalyticsExpander) expandHistory(currentItem *TreeNode) ExpanderResult {
+	workspaceID := currentItem.Metadata["WorkspaceID"]
+	history := loadQueryHistory(logAnalyticsQueryHistoryKey(workspaceID))
+
+	timeoutSeconds := logAnalyticsQueryTimeoutSeconds
+	newItems := []*TreeNode{}
+	for i, query := range history {
+		newItems = append(newItems, &TreeNode{
+			Parentid:               currentItem.ID,
+			ID:                     currentItem.ID + "/" + strconv.Itoa(i),
+			Namespace:              "loganalytics",
+			Name:                   query,
+			Display:                strings.Join(strings.Fields(query), " "),
+			ItemType:               logAnalyticsNodeHistoryQuery,
+			ExpandURL:              ExpandURLNotSupported,
+			SuppressSwaggerExpand:  true,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: &timeoutSeconds,
+			Metadata: map[string]string{
+				"WorkspaceID": workspaceID,
+				"Query":       query,
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: strings.Join(history, "\n\n"), ResponseType: interfaces.ResponsePlainText},
+		Nodes:             newItems,
+		SourceDescription: "LogAnalyticsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// runQuery runs the query over the workspace's current time range and shows the results as a table,
+// with a child node to view the raw JSON response
+func (e *LogAnalyticsExpander) runQuery(ctx context.Context, currentItem *TreeNode, workspaceID string, queryText string) ExpanderResult {
+	customerID, err := e.getCustomerID(ctx, workspaceID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	timeRange, timespan := getQueryTimeRange(workspaceID)
+	data, err := doKustoQuery(ctx, e.client, fmt.Sprintf(logAnalyticsQueryURL, customerID), logAnalyticsResource, armclient.GetSubscriptionIDFromResourceID(workspaceID), queryText, timespan)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	if err = saveQueryToHistory(logAnalyticsQueryHistoryKey(workspaceID), queryText); err != nil {
+		eventing.SendStatusEvent(&eventing.StatusEvent{
+			Failure: true,
+			Message: fmt.Sprintf("Failed to save query history: %s", err),
+			Timeout: time.Second * 5,
+		})
+	}
+
+	return kustoQueryResult(currentItem, data, "Query results", timeRange, "loganalytics", logAnalyticsNodeResultsJSON, "LogAnalyticsExpander request")
+}
+
+// getCustomerID returns the workspace ID used by the query API
+func (e *LogAnalyticsExpander) getCustomerID(ctx context.Context, workspaceID string) (string, error) {
+	e.customerIDsMutex.Lock()
+	customerID, ok := e.customerIDs[workspaceID]
+	e.customerIDsMutex.Unlock()
+	if ok {
+		return customerID, nil
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "GET", workspaceID+"?api-version="+logAnalyticsAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("Error getting workspace: %s", err)
+	}
+	var response logAnalyticsWorkspaceResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return "", fmt.Errorf("Error unmarshalling workspace response: %s", err)
+	}
+	if response.Properties.CustomerID == "" {
+		return "", fmt.Errorf("Workspace ID not found for %s", workspaceID)
+	}
+
+	e.customerIDsMutex.Lock()
+	e.customerIDs[workspaceID] = response.Properties.CustomerID
+	e.customerIDsMutex.Unlock()
+	return response.Properties.CustomerID, nil
+}
+
+// HasActions returns true for workspaces and the queries under them
+func (e *LogAnalyticsExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.Namespace != "loganalytics" && isLogAnalyticsWorkspace(item) {
+		return true, nil
+	}
+	switch item.ItemType {
+	case logAnalyticsNodeSavedSearch, logAnalyticsNodeHistoryQuery:
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the actions for running queries against the workspace
+func (e *LogAnalyticsExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	metadata := copyMetadata(item.Metadata)
+	if metadata["WorkspaceID"] == "" {
+		metadata["WorkspaceID"] = item.ID
+	}
+	timeRange, _ := getQueryTimeRange(metadata["WorkspaceID"])
+
+	newAction := func(name string, actionID string) *TreeNode {
+		actionMetadata := copyMetadata(metadata)
+		actionMetadata["ActionID"] = actionID
+		return &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + actionID,
+			Namespace:              "loganalytics",
+			Name:                   name,
+			Display:                name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               actionMetadata,
+		}
+	}
+
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			newAction("Run Query", logAnalyticsActionQuery),
+			newAction("Run Query in Editor", logAnalyticsActionQueryInEditor),
+			newAction("Set time range (currently "+timeRange+")", logAnalyticsActionTimeRange),
+		},
+		SourceDescription: "LogAnalyticsExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the workspace action
+func (e *LogAnalyticsExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case logAnalyticsActionQuery:
+		queryText, _ := promptInCommandPanel(e.gui, e.commandPanel, "KQL query:", e.initialQuery(item), nil)
+		return e.runQueryFromAction(ctx, item, queryText)
+	case logAnalyticsActionQueryInEditor:
+		queryText, err := editor.OpenForContent(e.initialQuery(item), ".kql")
+		if err != nil {
+			return ExpanderResult{
+				Err:               err,
+				SourceDescription: "LogAnalyticsExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+		return e.runQueryFromAction(ctx, item, strings.TrimSpace(queryText))
+	case logAnalyticsActionTimeRange:
+		return e.setTimeRange(ctx, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "LogAnalyticsExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "LogAnalyticsExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+// initialQuery returns the query of the selected node, or the last query run against the workspace
+func (e *LogAnalyticsExpander) initialQuery(item *TreeNode) string {
+	if query := item.Metadata["Query"]; query != "" {
+		return query
+	}
+	if history := loadQueryHistory(logAnalyticsQueryHistoryKey(item.Metadata["WorkspaceID"])); len(history) > 0 {
+		return history[0]
+	}
+	return logAnalyticsDefaultQuery
+}
+
+func (e *LogAnalyticsExpander) runQueryFromAction(ctx context.Context, item *TreeNode, queryText string) ExpanderResult {
+	if queryText == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No query entered"),
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return e.runQuery(ctx, item, item.Metadata["WorkspaceID"], queryText)
+}
+
+func (e *LogAnalyticsExpander) setTimeRange(ctx context.Context, item *TreeNode) ExpanderResult {
+	workspaceID := item.Metadata["WorkspaceID"]
+	selected, err := promptForQueryTimeRange(e.gui, e.commandPanel, workspaceID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "LogAnalyticsExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Re-run the selected query so the change is visible straight away
+	if query := item.Metadata["Query"]; query != "" {
+		return e.runQuery(ctx, item, workspaceID, query)
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Queries will cover the last " + selected, ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "LogAnalyticsExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func logAnalyticsQueryHistoryKey(workspaceID string) string {
+	return resourceCacheKey("LogAnalyticsQueryHistory", strings.ToLower(workspaceID))
+}
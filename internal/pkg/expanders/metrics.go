@@ -1,12 +1,18 @@
 package expanders
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/awesome-gocui/gocui"
 	"github.com/guptarohit/asciigraph"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
@@ -28,10 +34,79 @@ var ItemWidgetWidth int
 // Check interface
 var _ Expander = &MetricsExpander{}
 
+// NewMetricsExpander creates a new instance of MetricsExpander
+func NewMetricsExpander(client *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *MetricsExpander {
+	return &MetricsExpander{
+		client:       client,
+		gui:          gui,
+		commandPanel: commandPanel,
+	}
+}
+
 // MetricsExpander expands the data-plane aspects of the Microsoft.Insights RP
 type MetricsExpander struct {
 	ExpanderBase
-	client *armclient.Client
+	client       *armclient.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+}
+
+const (
+	metricsActionTimeRange   = "metrics-timerange"
+	metricsActionInterval    = "metrics-interval"
+	metricsActionAggregation = "metrics-aggregation"
+	metricsActionSplit       = "metrics-split"
+	metricsActionFilter      = "metrics-filter"
+	metricsActionAddSeries   = "metrics-addseries"
+	metricsActionClearSeries = "metrics-clearseries"
+	metricsActionExportCSV   = "metrics-exportcsv"
+)
+
+const (
+	metricsDefaultTimeRange = "4h"
+	metricsDefaultInterval  = "PT1M"
+)
+
+// metricsTimeRanges are the time ranges offered by the metrics explorer
+var metricsTimeRanges = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"4h", 4 * time.Hour},
+	{"12h", 12 * time.Hour},
+	{"24h", 24 * time.Hour},
+	{"48h", 48 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// metricsIntervals are the ISO8601 time grains offered by the metrics explorer
+var metricsIntervals = []string{"PT1M", "PT5M", "PT15M", "PT30M", "PT1H", "PT6H", "PT12H", "P1D"}
+
+// metricsSeriesColors are used in order for each series drawn on a graph
+var metricsSeriesColors = []asciigraph.AnsiColor{
+	asciigraph.Blue,
+	asciigraph.Green,
+	asciigraph.Red,
+	asciigraph.Yellow,
+	asciigraph.Magenta,
+	asciigraph.Cyan,
+}
+
+// metricSeries identifies a metric on a resource to plot
+type metricSeries struct {
+	ResourceID string `json:"resourceId"`
+	Namespace  string `json:"namespace"`
+	MetricName string `json:"metricName"`
+}
+
+// metricPlotSeries holds the datapoints for a single line on the graph
+type metricPlotSeries struct {
+	Legend     string
+	Timestamps []string
+	Values     []float64
 }
 
 func (e *MetricsExpander) setClient(c *armclient.Client) {
@@ -137,25 +212,30 @@ func (e *MetricsExpander) expandMetricDefinition(ctx context.Context, currentIte
 	newItems := []*TreeNode{}
 
 	for _, metric := range metricsListResponse.Value {
+		dimensions := []string{}
+		for _, dimension := range metric.Dimensions {
+			dimensions = append(dimensions, dimension.Value)
+		}
 		newItems = append(newItems, &TreeNode{
-			Name:     metric.Name.Value,
-			Display:  metric.Name.Value + "\n  " + style.Subtle("Unit: "+metric.Unit),
-			ID:       currentItem.Metadata["ResourceID"] + "/providers/microsoft.Insights/metrics",
-			Parentid: currentItem.ID,
-			ExpandURL: currentItem.Metadata["ResourceID"] + "/providers/microsoft.Insights/metrics?timespan=" +
-				time.Now().UTC().Add(-4*time.Hour).Format("2006-01-02T15:04:05.000Z") + "/" +
-				time.Now().UTC().Format("2006-01-02T15:04:05.000Z") + "&interval=PT1M&metricnames=" +
-				url.QueryEscape(metric.Name.Value) + "&aggregation=" +
-				url.QueryEscape(metric.PrimaryAggregationType) +
-				"&metricNamespace=" + url.QueryEscape(metric.Namespace) +
-				"&autoadjusttimegrain=true&validatedimensions=false&api-version=2018-01-01",
+			Name:                  metric.Name.Value,
+			Display:               metric.Name.Value + "\n  " + style.Subtle("Unit: "+metric.Unit),
+			ID:                    currentItem.Metadata["ResourceID"] + "/providers/microsoft.Insights/metrics/" + metric.Name.Value,
+			Parentid:              currentItem.ID,
+			ExpandURL:             ExpandURLNotSupported,
 			ItemType:              "metrics.graph",
 			SubscriptionID:        currentItem.SubscriptionID,
 			SuppressSwaggerExpand: true,
 			SuppressGenericExpand: true,
 			Metadata: map[string]string{
-				"AggregationType": strings.ToLower(metric.PrimaryAggregationType),
-				"Units":           strings.ToLower(metric.Unit),
+				"ResourceID":            currentItem.Metadata["ResourceID"],
+				"MetricName":            metric.Name.Value,
+				"MetricNamespace":       metric.Namespace,
+				"AggregationType":       strings.ToLower(metric.PrimaryAggregationType),
+				"SupportedAggregations": strings.ToLower(strings.Join(metric.SupportedAggregationTypes, ",")),
+				"Dimensions":            strings.Join(dimensions, ","),
+				"Units":                 strings.ToLower(metric.Unit),
+				"TimeRange":             metricsDefaultTimeRange,
+				"Interval":              metricsDefaultInterval,
 			},
 		})
 	}
@@ -169,54 +249,413 @@ func (e *MetricsExpander) expandMetricDefinition(ctx context.Context, currentIte
 }
 
 func (e *MetricsExpander) expandGraph(ctx context.Context, currentItem *TreeNode) ExpanderResult {
-	data, err := e.client.DoRequest(ctx, "GET", currentItem.ExpandURL)
+	plotSeries, err := e.getGraphData(ctx, currentItem)
 	if err != nil {
 		return ExpanderResult{
 			Err:               err,
-			SourceDescription: "MetricsExpander request metricDefinitions",
+			SourceDescription: "MetricsExpander request metrics",
+		}
+	}
+
+	// handle empty response
+	if len(plotSeries) < 1 {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No datapoints returned for metric %s", currentItem.Name),
+			SourceDescription: "MetricsExpander graphdata failed to deserialise",
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: drawMetricsGraph(currentItem, plotSeries)},
+		IsPrimaryResponse: true,
+		SourceDescription: "MetricsExpander build graph",
+	}
+}
+
+// getGraphSeries returns the metrics plotted on a graph node, starting with the node's own metric
+func getGraphSeries(item *TreeNode) []metricSeries {
+	series := []metricSeries{
+		{
+			ResourceID: item.Metadata["ResourceID"],
+			Namespace:  item.Metadata["MetricNamespace"],
+			MetricName: item.Metadata["MetricName"],
+		},
+	}
+	if additional := item.Metadata["AdditionalSeries"]; additional != "" {
+		var additionalSeries []metricSeries
+		if err := json.Unmarshal([]byte(additional), &additionalSeries); err == nil {
+			series = append(series, additionalSeries...)
+		}
+	}
+	return series
+}
+
+func getMetricsTimeRange(item *TreeNode) (string, time.Duration) {
+	for _, timeRange := range metricsTimeRanges {
+		if timeRange.Name == item.Metadata["TimeRange"] {
+			return timeRange.Name, timeRange.Duration
+		}
+	}
+	return metricsDefaultTimeRange, 4 * time.Hour
+}
+
+func getMetricsURL(item *TreeNode, series metricSeries, now time.Time) string {
+	_, duration := getMetricsTimeRange(item)
+	interval := item.Metadata["Interval"]
+	if interval == "" {
+		interval = metricsDefaultInterval
+	}
+
+	filters := []string{}
+	if filter := item.Metadata["Filter"]; filter != "" {
+		filters = append(filters, filter)
+	}
+	if splitBy := item.Metadata["SplitBy"]; splitBy != "" {
+		filters = append(filters, splitBy+" eq '*'")
+	}
+
+	metricsURL := series.ResourceID + "/providers/microsoft.Insights/metrics?timespan=" +
+		now.UTC().Add(-duration).Format("2006-01-02T15:04:05.000Z") + "/" +
+		now.UTC().Format("2006-01-02T15:04:05.000Z") + "&interval=" + url.QueryEscape(interval) +
+		"&metricnames=" + url.QueryEscape(series.MetricName) +
+		"&aggregation=" + url.QueryEscape(item.Metadata["AggregationType"]) +
+		"&metricNamespace=" + url.QueryEscape(series.Namespace)
+	if len(filters) > 0 {
+		metricsURL += "&$filter=" + url.QueryEscape(strings.Join(filters, " and "))
+	}
+	return metricsURL + "&autoadjusttimegrain=true&validatedimensions=false&api-version=2018-01-01"
+}
+
+func (e *MetricsExpander) getGraphData(ctx context.Context, item *TreeNode) ([]metricPlotSeries, error) {
+	aggregationType := item.Metadata["AggregationType"]
+	baseResourceID := item.Metadata["ResourceID"]
+	now := time.Now()
+
+	plotSeries := []metricPlotSeries{}
+	for _, series := range getGraphSeries(item) {
+		data, err := e.client.DoRequest(ctx, "GET", getMetricsURL(item, series, now))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get metric %s: %s", series.MetricName, err)
+		}
+
+		var metricResponse armclient.MetricResponse
+		err = json.Unmarshal([]byte(data), &metricResponse)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to deserialise metric %s: %s", series.MetricName, err)
+		}
+
+		legend := series.MetricName
+		if !strings.EqualFold(series.ResourceID, baseResourceID) {
+			legend += " (" + lastSegment(series.ResourceID) + ")"
 		}
+
+		for _, value := range metricResponse.Value {
+			for _, timeseries := range value.Timeseries {
+				seriesLegend := legend
+				for _, metadataValue := range timeseries.Metadatavalues {
+					seriesLegend += " [" + metadataValue.Name.Value + "=" + metadataValue.Value + "]"
+				}
+				plot := metricPlotSeries{Legend: seriesLegend}
+				for _, datapoint := range timeseries.Data {
+					value, success := datapoint[aggregationType].(float64)
+					if !success {
+						value = 0
+					}
+					timestamp, _ := datapoint["timeStamp"].(string)
+					plot.Values = append(plot.Values, value)
+					plot.Timestamps = append(plot.Timestamps, timestamp)
+				}
+				if len(plot.Values) > 0 {
+					plotSeries = append(plotSeries, plot)
+				}
+			}
+		}
+	}
+	return plotSeries, nil
+}
+
+func lastSegment(resourceID string) string {
+	parts := strings.Split(strings.TrimSuffix(resourceID, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+func drawMetricsGraph(item *TreeNode, plotSeries []metricPlotSeries) string {
+	timeRange, _ := getMetricsTimeRange(item)
+	caption := style.Title(item.Name) +
+		style.Subtle(" (Aggregate: '"+item.Metadata["AggregationType"]+"' Unit: '"+
+			item.Metadata["Units"]+"' Interval: '"+item.Metadata["Interval"]+"')")
+	if filter := item.Metadata["Filter"]; filter != "" {
+		caption += "\n" + style.Subtle("Filter: "+filter)
+	}
+	if splitBy := item.Metadata["SplitBy"]; splitBy != "" {
+		caption += "\n" + style.Subtle("Split by: "+splitBy)
+	}
+
+	data := [][]float64{}
+	legends := []string{}
+	colors := []asciigraph.AnsiColor{}
+	stats := strings.Builder{}
+	for i, series := range plotSeries {
+		data = append(data, series.Values)
+		legends = append(legends, series.Legend)
+		colors = append(colors, metricsSeriesColors[i%len(metricsSeriesColors)])
+
+		min, max, avg := getMetricsStats(series.Values)
+		stats.WriteString(fmt.Sprintf("%s\n  min: %.2f  max: %.2f  avg: %.2f\n", series.Legend, min, max, avg))
+	}
+
+	// Leave space for the caption, legend and stats beneath the graph
+	height := ItemWidgetHeight - 8 - 2*len(plotSeries)
+	if height < 5 {
+		height = 5
+	}
+
+	graph := asciigraph.PlotMany(data,
+		asciigraph.Height(height),
+		asciigraph.Width(ItemWidgetWidth-15),
+		asciigraph.SeriesColors(colors...),
+		asciigraph.SeriesLegends(legends...),
+		asciigraph.Caption("time: "+timeRange+" ago ----> now"))
+
+	return "\n\n" + caption + "\n\n" + graph + "\n\n" + stats.String()
+}
+
+func getMetricsStats(values []float64) (float64, float64, float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	min, max, total := math.Inf(1), math.Inf(-1), float64(0)
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+		total += value
+	}
+	return min, max, total / float64(len(values))
+}
+
+// HasActions returns true for metric graphs so they can be customised
+func (e *MetricsExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == "metrics.graph", nil
+}
+
+// ListActions returns the options for customising a metric graph
+func (e *MetricsExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	newAction := func(name string, actionID string) *TreeNode {
+		return &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + actionID,
+			Name:                   name,
+			Display:                name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata: map[string]string{
+				"ActionID": actionID,
+			},
+		}
+	}
+
+	timeRange, _ := getMetricsTimeRange(item)
+	nodes := []*TreeNode{
+		newAction("Set time range (currently "+timeRange+")", metricsActionTimeRange),
+		newAction("Set interval (currently "+item.Metadata["Interval"]+")", metricsActionInterval),
+		newAction("Set aggregation (currently "+item.Metadata["AggregationType"]+")", metricsActionAggregation),
+	}
+	if item.Metadata["Dimensions"] != "" {
+		nodes = append(nodes,
+			newAction("Split by dimension", metricsActionSplit),
+			newAction("Filter by dimension", metricsActionFilter))
+	}
+	nodes = append(nodes, newAction("Add metric to graph", metricsActionAddSeries))
+	if item.Metadata["AdditionalSeries"] != "" {
+		nodes = append(nodes, newAction("Remove added metrics", metricsActionClearSeries))
+	}
+	nodes = append(nodes, newAction("Export datapoints as CSV", metricsActionExportCSV))
+
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "MetricsExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction updates the graph settings and redraws the graph
+func (e *MetricsExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	graphItem := item.Parent
+	actionID := item.Metadata["ActionID"]
+
+	var err error
+	switch actionID {
+	case metricsActionTimeRange:
+		options := []interfaces.CommandPanelListOption{}
+		for _, timeRange := range metricsTimeRanges {
+			options = append(options, interfaces.CommandPanelListOption{ID: timeRange.Name, DisplayText: timeRange.Name})
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "time range:", "", &options); selected != "" {
+			graphItem.Metadata["TimeRange"] = selected
+		}
+	case metricsActionInterval:
+		options := []interfaces.CommandPanelListOption{}
+		for _, interval := range metricsIntervals {
+			options = append(options, interfaces.CommandPanelListOption{ID: interval, DisplayText: interval})
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "interval:", "", &options); selected != "" {
+			graphItem.Metadata["Interval"] = selected
+		}
+	case metricsActionAggregation:
+		options := []interfaces.CommandPanelListOption{}
+		for _, aggregation := range strings.Split(graphItem.Metadata["SupportedAggregations"], ",") {
+			if aggregation != "" {
+				options = append(options, interfaces.CommandPanelListOption{ID: aggregation, DisplayText: aggregation})
+			}
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "aggregation:", "", &options); selected != "" {
+			graphItem.Metadata["AggregationType"] = selected
+		}
+	case metricsActionSplit:
+		options := []interfaces.CommandPanelListOption{{ID: "-", DisplayText: "(none)"}}
+		for _, dimension := range strings.Split(graphItem.Metadata["Dimensions"], ",") {
+			options = append(options, interfaces.CommandPanelListOption{ID: dimension, DisplayText: dimension})
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "split by:", "", &options); selected == "-" {
+			delete(graphItem.Metadata, "SplitBy")
+		} else if selected != "" {
+			graphItem.Metadata["SplitBy"] = selected
+		}
+	case metricsActionFilter:
+		err = e.promptForFilter(graphItem)
+	case metricsActionAddSeries:
+		err = e.promptForAdditionalSeries(ctx, graphItem)
+	case metricsActionClearSeries:
+		delete(graphItem.Metadata, "AdditionalSeries")
+	case metricsActionExportCSV:
+		return e.exportCSV(ctx, graphItem)
+	default:
+		err = fmt.Errorf("Unhandled ActionID: %q", actionID)
 	}
 
-	var metricResponse armclient.MetricResponse
-	err = json.Unmarshal([]byte(data), &metricResponse)
 	if err != nil {
 		return ExpanderResult{
 			Err:               err,
-			SourceDescription: "MetricsExpander graphdata failed to deserialise",
+			SourceDescription: "MetricsExpander",
+			IsPrimaryResponse: true,
 		}
 	}
 
-	caption := style.Title(currentItem.Name) +
-		style.Subtle(" (Aggregate: '"+currentItem.Metadata["AggregationType"]+"' Unit: '"+
-			currentItem.Metadata["Units"]+"')")
+	return e.expandGraph(ctx, graphItem)
+}
 
-	graphData := []float64{}
+func (e *MetricsExpander) promptForFilter(graphItem *TreeNode) error {
+	options := []interfaces.CommandPanelListOption{{ID: "-", DisplayText: "(clear filter)"}}
+	for _, dimension := range strings.Split(graphItem.Metadata["Dimensions"], ",") {
+		options = append(options, interfaces.CommandPanelListOption{ID: dimension, DisplayText: dimension})
+	}
+	_, dimension := promptInCommandPanel(e.gui, e.commandPanel, "filter dimension:", "", &options)
+	switch dimension {
+	case "":
+		return nil
+	case "-":
+		delete(graphItem.Metadata, "Filter")
+		return nil
+	}
 
-	// handle empty response
-	if len(metricResponse.Value) < 1 || len(metricResponse.Value[0].Timeseries) < 1 {
+	value, _ := promptInCommandPanel(e.gui, e.commandPanel, dimension+" eq:", "", nil)
+	if value == "" {
+		return fmt.Errorf("No value entered for dimension %s", dimension)
+	}
+	graphItem.Metadata["Filter"] = dimension + " eq '" + strings.ReplaceAll(value, "'", "''") + "'"
+	return nil
+}
+
+func (e *MetricsExpander) promptForAdditionalSeries(ctx context.Context, graphItem *TreeNode) error {
+	resourceID, _ := promptInCommandPanel(e.gui, e.commandPanel, "resource id (leave empty for this resource):", "", nil)
+	if resourceID == "" {
+		resourceID = graphItem.Metadata["ResourceID"]
+	}
+
+	definitionsURL := resourceID + "/providers/microsoft.insights/metricdefinitions?api-version=2018-01-01"
+	if strings.EqualFold(resourceID, graphItem.Metadata["ResourceID"]) {
+		definitionsURL += "&metricNamespace=" + url.QueryEscape(graphItem.Metadata["MetricNamespace"])
+	}
+	data, err := e.client.DoRequest(ctx, "GET", definitionsURL)
+	if err != nil {
+		return fmt.Errorf("Failed to get metric definitions for %s: %s", resourceID, err)
+	}
+	var metricsListResponse armclient.MetricsListResponse
+	if err = json.Unmarshal([]byte(data), &metricsListResponse); err != nil {
+		return fmt.Errorf("Failed to deserialise metric definitions: %s", err)
+	}
+
+	options := []interfaces.CommandPanelListOption{}
+	namespaces := map[string]string{}
+	for _, metric := range metricsListResponse.Value {
+		options = append(options, interfaces.CommandPanelListOption{ID: metric.Name.Value, DisplayText: metric.Name.Value})
+		namespaces[metric.Name.Value] = metric.Namespace
+	}
+	_, metricName := promptInCommandPanel(e.gui, e.commandPanel, "metric:", "", &options)
+	if metricName == "" {
+		return nil
+	}
+
+	series := getGraphSeries(graphItem)[1:]
+	series = append(series, metricSeries{
+		ResourceID: resourceID,
+		Namespace:  namespaces[metricName],
+		MetricName: metricName,
+	})
+	buf, err := json.Marshal(series)
+	if err != nil {
+		return err
+	}
+	graphItem.Metadata["AdditionalSeries"] = string(buf)
+	return nil
+}
+
+func (e *MetricsExpander) exportCSV(ctx context.Context, graphItem *TreeNode) ExpanderResult {
+	plotSeries, err := e.getGraphData(ctx, graphItem)
+	if err != nil {
 		return ExpanderResult{
 			Err:               err,
-			SourceDescription: "MetricsExpander graphdata failed to deserialise",
+			SourceDescription: "MetricsExpander",
+			IsPrimaryResponse: true,
 		}
 	}
 
-	for _, datapoint := range metricResponse.Value[0].Timeseries[0].Data {
-		value, success := datapoint[currentItem.Metadata["AggregationType"]].(float64)
-		if success {
-			graphData = append(graphData, value)
-		} else {
-			graphData = append(graphData, float64(0))
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"series", "timestamp", "value"})
+	rowCount := 0
+	for _, series := range plotSeries {
+		for i, value := range series.Values {
+			_ = writer.Write([]string{series.Legend, series.Timestamps[i], fmt.Sprintf("%v", value)})
+			rowCount++
 		}
 	}
+	writer.Flush()
 
-	graph := asciigraph.Plot(graphData,
-		asciigraph.Height(ItemWidgetHeight-6),
-		asciigraph.Width(ItemWidgetWidth-15),
-		asciigraph.Caption("time: 4hrs ago ----> now"))
+	defaultPath := fmt.Sprintf("%s-%s.csv", strings.ReplaceAll(graphItem.Name, "/", "_"), time.Now().Format("20060102-150405"))
+	path, _ := promptInCommandPanel(e.gui, e.commandPanel, "save CSV to:", defaultPath, nil)
+	if path == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "MetricsExpander",
+			IsPrimaryResponse: true,
+		}
+	}
+	if err = os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to write CSV: %s", err),
+			SourceDescription: "MetricsExpander",
+			IsPrimaryResponse: true,
+		}
+	}
 
 	return ExpanderResult{
-		Response:          ExpanderResponse{Response: "\n\n" + caption + "\n\n" + style.Graph(graph)},
+		Response: ExpanderResponse{
+			Response:     fmt.Sprintf("Exported %d datapoints to %s\n\n%s", rowCount, path, buf.String()),
+			ResponseType: interfaces.ResponsePlainText,
+		},
+		SourceDescription: "MetricsExpander",
 		IsPrimaryResponse: true,
-		SourceDescription: "MetricsExpander build graph",
 	}
 }
@@ -0,0 +1,147 @@
+package expanders
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getMetricsURL(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	series := metricSeries{ResourceID: "/subscriptions/1/resourceGroups/rg/providers/Microsoft.Web/sites/app", Namespace: "Microsoft.Web/sites", MetricName: "Requests"}
+	base := series.ResourceID + "/providers/microsoft.Insights/metrics?timespan="
+	suffix := "&autoadjusttimegrain=true&validatedimensions=false&api-version=2018-01-01"
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		expected string
+	}{
+		{
+			name:     "defaults",
+			metadata: map[string]string{"AggregationType": "Total"},
+			expected: base + "2020-01-02T08:00:00.000Z/2020-01-02T12:00:00.000Z&interval=PT1M&metricnames=Requests&aggregation=Total&metricNamespace=Microsoft.Web%2Fsites" + suffix,
+		},
+		{
+			name:     "time range and interval",
+			metadata: map[string]string{"AggregationType": "Average", "TimeRange": "7d", "Interval": "PT1H"},
+			expected: base + "2019-12-26T12:00:00.000Z/2020-01-02T12:00:00.000Z&interval=PT1H&metricnames=Requests&aggregation=Average&metricNamespace=Microsoft.Web%2Fsites" + suffix,
+		},
+		{
+			name:     "unknown time range uses the default",
+			metadata: map[string]string{"AggregationType": "Total", "TimeRange": "1y"},
+			expected: base + "2020-01-02T08:00:00.000Z/2020-01-02T12:00:00.000Z&interval=PT1M&metricnames=Requests&aggregation=Total&metricNamespace=Microsoft.Web%2Fsites" + suffix,
+		},
+		{
+			name:     "filter",
+			metadata: map[string]string{"AggregationType": "Total", "Filter": "Instance eq 'a'"},
+			expected: base + "2020-01-02T08:00:00.000Z/2020-01-02T12:00:00.000Z&interval=PT1M&metricnames=Requests&aggregation=Total&metricNamespace=Microsoft.Web%2Fsites" +
+				"&$filter=Instance+eq+%27a%27" + suffix,
+		},
+		{
+			name:     "split by",
+			metadata: map[string]string{"AggregationType": "Total", "SplitBy": "Instance"},
+			expected: base + "2020-01-02T08:00:00.000Z/2020-01-02T12:00:00.000Z&interval=PT1M&metricnames=Requests&aggregation=Total&metricNamespace=Microsoft.Web%2Fsites" +
+				"&$filter=Instance+eq+%27%2A%27" + suffix,
+		},
+		{
+			name:     "filter and split by",
+			metadata: map[string]string{"AggregationType": "Total", "Filter": "Instance eq 'a'", "SplitBy": "StatusCode"},
+			expected: base + "2020-01-02T08:00:00.000Z/2020-01-02T12:00:00.000Z&interval=PT1M&metricnames=Requests&aggregation=Total&metricNamespace=Microsoft.Web%2Fsites" +
+				"&$filter=Instance+eq+%27a%27+and+StatusCode+eq+%27%2A%27" + suffix,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getMetricsURL(&TreeNode{Metadata: test.metadata}, series, now))
+		})
+	}
+}
+
+func Test_getMetricsURL_MultipleSeries(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	item := &TreeNode{
+		Metadata: map[string]string{
+			"ResourceID":       "/sites/app",
+			"MetricNamespace":  "Microsoft.Web/sites",
+			"MetricName":       "Requests",
+			"AggregationType":  "Total",
+			"AdditionalSeries": `[{"resourceId": "/sites/other", "namespace": "Microsoft.Web/sites", "metricName": "Http 5xx"}]`,
+		},
+	}
+
+	// Each series is requested separately, with its own resource and metric name
+	urls := []string{}
+	for _, series := range getGraphSeries(item) {
+		urls = append(urls, getMetricsURL(item, series, now))
+	}
+	assert.Len(t, urls, 2)
+	assert.True(t, strings.HasPrefix(urls[0], "/sites/app/providers/microsoft.Insights/metrics?"))
+	assert.Contains(t, urls[0], "&metricnames=Requests&")
+	assert.True(t, strings.HasPrefix(urls[1], "/sites/other/providers/microsoft.Insights/metrics?"))
+	assert.Contains(t, urls[1], "&metricnames=Http+5xx&")
+}
+
+func Test_getMetricsStats(t *testing.T) {
+	tests := []struct {
+		name              string
+		values            []float64
+		min, max, average float64
+	}{
+		{"empty", nil, 0, 0, 0},
+		{"single", []float64{3}, 3, 3, 3},
+		{"several", []float64{2, -1, 5, 2}, -1, 5, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			min, max, average := getMetricsStats(test.values)
+			assert.Equal(t, test.min, min)
+			assert.Equal(t, test.max, max)
+			assert.Equal(t, test.average, average)
+		})
+	}
+}
+
+func Test_drawMetricsGraph(t *testing.T) {
+	height, width := ItemWidgetHeight, ItemWidgetWidth
+	defer func() { ItemWidgetHeight, ItemWidgetWidth = height, width }()
+	ItemWidgetHeight = 30
+	ItemWidgetWidth = 80
+	item := &TreeNode{
+		Name: "Requests",
+		Metadata: map[string]string{
+			"AggregationType": "Total",
+			"Units":           "Count",
+			"Interval":        "PT5M",
+			"TimeRange":       "1h",
+			"Filter":          "Instance eq 'a'",
+			"SplitBy":         "StatusCode",
+		},
+	}
+	plotSeries := []metricPlotSeries{
+		{Legend: "Requests [StatusCode=200]", Values: []float64{1, 2, 3}},
+		{Legend: "Requests [StatusCode=500]", Values: []float64{0, 4}},
+	}
+
+	graph := drawMetricsGraph(item, plotSeries)
+	assert.Contains(t, graph, "Requests")
+	assert.Contains(t, graph, "Aggregate: 'Total' Unit: 'Count' Interval: 'PT5M'")
+	assert.Contains(t, graph, "Filter: Instance eq 'a'")
+	assert.Contains(t, graph, "Split by: StatusCode")
+	assert.Contains(t, graph, "time: 1h ago ----> now")
+
+	// Each series has its stats listed beneath the graph in order
+	assert.True(t, strings.HasSuffix(graph,
+		"Requests [StatusCode=200]\n  min: 1.00  max: 3.00  avg: 2.00\n"+
+			"Requests [StatusCode=500]\n  min: 0.00  max: 4.00  avg: 2.00\n"))
+
+	// Without a filter or split the captions aren't shown
+	delete(item.Metadata, "Filter")
+	delete(item.Metadata, "SplitBy")
+	graph = drawMetricsGraph(item, plotSeries[:1])
+	assert.NotContains(t, graph, "Filter:")
+	assert.NotContains(t, graph, "Split by:")
+	assert.NotContains(t, graph, "StatusCode=500")
+}
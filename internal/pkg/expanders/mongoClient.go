@@ -0,0 +1,102 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CosmosDbConnectionStringsResponse is used to unmarshal the response from listConnectionStrings
+type CosmosDbConnectionStringsResponse struct {
+	ConnectionStrings []struct {
+		ConnectionString string `json:"connectionString"`
+		Description      string `json:"description"`
+		KeyKind          string `json:"keyKind"`
+		Type             string `json:"type"`
+	} `json:"connectionStrings"`
+}
+
+// mongoConnectionString returns the primary MongoDB connection string from a listConnectionStrings response,
+// or the primary read-only one when readOnly is set so the service rejects any writes
+func mongoConnectionString(data string, readOnly bool) (string, error) {
+	var response CosmosDbConnectionStringsResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return "", fmt.Errorf("Error unmarshalling connection strings: %s", err)
+	}
+	keyKind, description := "Primary", "Primary MongoDB"
+	if readOnly {
+		keyKind, description = "PrimaryReadonly", "Primary Read-Only MongoDB"
+	}
+	for _, connectionString := range response.ConnectionStrings {
+		if !strings.HasPrefix(connectionString.ConnectionString, "mongodb://") {
+			continue
+		}
+		// Older API versions don't return the key kind so fall back to the description
+		if strings.EqualFold(connectionString.KeyKind, keyKind) ||
+			(connectionString.KeyKind == "" && strings.HasPrefix(connectionString.Description, description)) {
+			return connectionString.ConnectionString, nil
+		}
+	}
+	if readOnly {
+		return "", fmt.Errorf("No primary read-only MongoDB connection string found for the account")
+	}
+	return "", fmt.Errorf("No primary MongoDB connection string found for the account")
+}
+
+// mongoCollection is a connection to a collection, Close disconnects the client
+type mongoCollection struct {
+	*mongo.Collection
+	client *mongo.Client
+}
+
+// dialMongo connects to the collection using the connection string for the account
+func dialMongo(ctx context.Context, connectionString string, database string, collection string) (*mongoCollection, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connectionString))
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting: %s", err)
+	}
+	return &mongoCollection{
+		Collection: client.Database(database).Collection(collection),
+		client:     client,
+	}, nil
+}
+
+// Close disconnects the client
+func (c *mongoCollection) Close() error {
+	return c.client.Disconnect(context.Background())
+}
+
+// findPage returns up to pageSize documents matching the filter after skipping the first skip,
+// and whether there are more documents
+func (c *mongoCollection) findPage(ctx context.Context, filter bson.D, skip int64, pageSize int64) ([]bson.Raw, bool, error) {
+	// Ask for one more document than is shown to know if there is another page
+	cursor, err := c.Find(ctx, filter, options.Find().SetSkip(skip).SetLimit(pageSize+1).SetBatchSize(int32(pageSize+1)))
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx) //nolint: errcheck
+
+	documents := []bson.Raw{}
+	for cursor.Next(ctx) {
+		if int64(len(documents)) == pageSize {
+			return documents, true, nil
+		}
+		// Current is reused by the cursor so take a copy
+		documents = append(documents, append(bson.Raw{}, cursor.Current...))
+	}
+	return documents, false, cursor.Err()
+}
+
+// findOne returns the first document matching the filter, or nil if there isn't one
+func (c *mongoCollection) findOne(ctx context.Context, filter bson.D) (bson.Raw, error) {
+	document, err := c.FindOne(ctx, filter).Raw()
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return document, err
+}
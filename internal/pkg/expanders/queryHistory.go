@@ -0,0 +1,52 @@
+package expanders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/storage"
+)
+
+// queryHistorySize is the number of queries saved for each resource
+const queryHistorySize = 20
+
+// resourceCacheKey returns a cache key for state saved against a resource, e.g. its query history
+func resourceCacheKey(prefix string, resourceID string) string {
+	hash := sha256.Sum256([]byte(resourceID))
+	return prefix + "-" + hex.EncodeToString(hash[:8])
+}
+
+// loadQueryHistory returns the queries saved under the key, most recent first
+func loadQueryHistory(key string) []string {
+	value, err := storage.GetCache(key)
+	if err != nil || value == "" {
+		return []string{}
+	}
+	history := []string{}
+	if err = json.Unmarshal([]byte(value), &history); err != nil {
+		return []string{}
+	}
+	return history
+}
+
+// saveQueryToHistory adds the query to the history saved under the key
+func saveQueryToHistory(key string, queryText string) error {
+	history := addToQueryHistory(loadQueryHistory(key), queryText)
+	buf, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return storage.PutCache(key, string(buf))
+}
+
+// addToQueryHistory moves (or adds) the query to the front of the history
+func addToQueryHistory(history []string, queryText string) []string {
+	newHistory := []string{queryText}
+	for _, query := range history {
+		if query != queryText && len(newHistory) < queryHistorySize {
+			newHistory = append(newHistory, query)
+		}
+	}
+	return newHistory
+}
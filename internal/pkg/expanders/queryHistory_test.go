@@ -0,0 +1,19 @@
+package expanders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_addToQueryHistory(t *testing.T) {
+	history := addToQueryHistory([]string{}, "SELECT * FROM c")
+	history = addToQueryHistory(history, "SELECT c.id FROM c")
+	history = addToQueryHistory(history, "SELECT * FROM c")
+	assert.Equal(t, []string{"SELECT * FROM c", "SELECT c.id FROM c"}, history)
+
+	for i := 0; i < queryHistorySize+5; i++ {
+		history = addToQueryHistory(history, string(rune('a'+i)))
+	}
+	assert.Len(t, history, queryHistorySize)
+}
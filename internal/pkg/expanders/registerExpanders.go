@@ -52,9 +52,7 @@ func InitializeExpanders(client *armclient.Client, graphClient *armclient.Client
 			client: client,
 			gui:    gui,
 		},
-		&MetricsExpander{
-			client: client,
-		},
+		NewMetricsExpander(client, gui, commandPanel),
 		swaggerResourceExpander,
 		&DeploymentsExpander{
 			client: client,
@@ -64,18 +62,21 @@ func InitializeExpanders(client *armclient.Client, graphClient *armclient.Client
 		},
 		&JSONExpander{},
 		&StorageManagementPoliciesExpander{},                         // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
-		NewContainerRegistryExpander(client),                         // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
-		NewStorageBlobExpander(client),                               // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewContainerRegistryExpander(client, gui, commandPanel),      // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewStorageBlobExpander(client, gui, commandPanel),            // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewStorageQueueExpander(client, gui, commandPanel),           // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewStorageTableExpander(client, gui, commandPanel),           // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewStorageFilesExpander(client, gui, commandPanel),           // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
 		NewCosmosDbExpander(client, gui, commandPanel, contentPanel), // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
-		&ContainerInstanceExpander{
-			client: client,
-		},
-		&AppInsightsExpander{
-			client: client,
-		},
-		&AzureKubernetesServiceExpander{
-			client: client,
-		},
+		NewKeyVaultExpander(client),                                  // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewServiceBusExpander(client, gui, commandPanel),             // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewEventHubExpander(client, gui, commandPanel),               // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewAppConfigurationExpander(client, gui, commandPanel),       // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewLogAnalyticsExpander(client, gui, commandPanel),           // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewWebAppExpander(client, gui, commandPanel, contentPanel),   // Needs to be registered after SwaggerResourceExpander as it depends on SwaggerResourceType being set
+		NewContainerInstanceExpander(client, gui, commandPanel),
+		NewAppInsightsExpander(client, gui, commandPanel),
+		NewAzureKubernetesServiceExpander(client, gui, commandPanel),
 		&AzureSearchServiceExpander{
 			client: client,
 		},
@@ -0,0 +1,655 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const (
+	serviceBusQueueTemplateURL        = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ServiceBus/namespaces/{namespaceName}/queues/{queueName}"
+	serviceBusSubscriptionTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ServiceBus/namespaces/{namespaceName}/topics/{topicName}/subscriptions/{subscriptionName}"
+)
+
+const (
+	serviceBusAPIVersion = "2017-04-01"
+	// serviceBusPeekCount is the number of messages shown when peeking
+	serviceBusPeekCount = 20
+)
+
+const (
+	serviceBusNodeActive     = "servicebus-active"
+	serviceBusNodeDeadLetter = "servicebus-deadletter"
+	serviceBusNodeMessage    = "servicebus-message"
+)
+
+const (
+	serviceBusActionResubmit = "servicebus-resubmit"
+)
+
+// serviceBusResendProperties are the broker properties which are kept when resubmitting a message
+var serviceBusResendProperties = []string{"ContentType", "CorrelationId", "Label", "MessageId", "PartitionKey", "ReplyTo", "ReplyToSessionId", "SessionId", "TimeToLive", "To", "ViaPartitionKey"}
+
+// serviceBusDeadLetterProperties are the custom properties added when a message is dead-lettered (in canonical header form)
+var serviceBusDeadLetterProperties = map[string]bool{
+	http.CanonicalHeaderKey("DeadLetterReason"):           true,
+	http.CanonicalHeaderKey("DeadLetterErrorDescription"): true,
+}
+
+type serviceBusEntityResponse struct {
+	Properties struct {
+		CountDetails struct {
+			ActiveMessageCount             int64 `json:"activeMessageCount"`
+			DeadLetterMessageCount         int64 `json:"deadLetterMessageCount"`
+			ScheduledMessageCount          int64 `json:"scheduledMessageCount"`
+			TransferMessageCount           int64 `json:"transferMessageCount"`
+			TransferDeadLetterMessageCount int64 `json:"transferDeadLetterMessageCount"`
+		} `json:"countDetails"`
+	} `json:"properties"`
+}
+
+// serviceBusMessage is a message read from a queue or subscription
+type serviceBusMessage struct {
+	BrokerProperties map[string]interface{} `json:"brokerProperties"`
+	Properties       map[string]string      `json:"properties,omitempty"`
+	ContentType      string                 `json:"contentType,omitempty"`
+	Body             string                 `json:"body"`
+	lockLocation     string
+}
+
+// NewServiceBusExpander creates a new instance of ServiceBusExpander
+func NewServiceBusExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *ServiceBusExpander {
+	return &ServiceBusExpander{
+		sharedAccessClient: newSharedAccessClient(armclient, "Microsoft.ServiceBus"),
+		gui:                gui,
+		commandPanel:       commandPanel,
+	}
+}
+
+// Check interface
+var _ Expander = &ServiceBusExpander{}
+
+// ServiceBusExpander expands the messages in Service Bus queues and topic subscriptions
+type ServiceBusExpander struct {
+	ExpanderBase
+	sharedAccessClient
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+}
+
+func (e *ServiceBusExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *ServiceBusExpander) Name() string {
+	return "ServiceBusExpander"
+}
+
+// DoesExpand checks if this is a Service Bus queue or topic subscription
+func (e *ServiceBusExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == SubResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == serviceBusQueueTemplateURL ||
+			swaggerResourceType.Endpoint.TemplateURL == serviceBusSubscriptionTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "servicebus" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the active and dead-letter messages for the queue or subscription
+func (e *ServiceBusExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "servicebus" && swaggerResourceType != nil {
+		switch swaggerResourceType.Endpoint.TemplateURL {
+		case serviceBusQueueTemplateURL, serviceBusSubscriptionTemplateURL:
+			return e.expandEntity(ctx, currentItem)
+		}
+	}
+
+	switch currentItem.ItemType {
+	case serviceBusNodeActive:
+		return e.expandMessages(ctx, currentItem, currentItem.Metadata["EntityPath"])
+	case serviceBusNodeDeadLetter:
+		return e.expandMessages(ctx, currentItem, currentItem.Metadata["EntityPath"]+"/$DeadLetterQueue")
+	case serviceBusNodeMessage:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "ServiceBusExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "ServiceBusExpander request",
+	}
+}
+
+func (e *ServiceBusExpander) expandEntity(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	namespaceID, entityPath, sendPath := serviceBusEntityPaths(currentItem.ID)
+	metadata := map[string]string{
+		"NamespaceID": namespaceID,
+		"EntityPath":  entityPath,
+		"SendPath":    sendPath,
+	}
+
+	// The counts are only used for display so the nodes are still shown if they can't be fetched
+	activeDisplay := "Active messages"
+	deadLetterDisplay := "Dead-letter queue"
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.ID+"?api-version="+serviceBusAPIVersion)
+	if err == nil {
+		var entity serviceBusEntityResponse
+		if err = json.Unmarshal([]byte(data), &entity); err == nil {
+			counts := entity.Properties.CountDetails
+			activeDisplay += "\n  " + style.Subtle(fmt.Sprintf("%d active, %d scheduled", counts.ActiveMessageCount, counts.ScheduledMessageCount))
+			deadLetterDisplay += "\n  " + style.Subtle(fmt.Sprintf("%d dead-lettered", counts.DeadLetterMessageCount))
+			metadata["DeadLetterCount"] = fmt.Sprint(counts.DeadLetterMessageCount)
+		}
+	}
+
+	newItems := []*TreeNode{
+		{
+			Parentid:              currentItem.ID,
+			ID:                    currentItem.ID + "/<active>",
+			Namespace:             "servicebus",
+			Name:                  "Active messages",
+			Display:               activeDisplay,
+			ItemType:              serviceBusNodeActive,
+			ExpandURL:             ExpandURLNotSupported,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata:              copyMetadata(metadata),
+		},
+		{
+			Parentid:              currentItem.ID,
+			ID:                    currentItem.ID + "/<deadletter>",
+			Namespace:             "servicebus",
+			Name:                  "Dead-letter queue (" + lastSegment(entityPath) + ")",
+			Display:               deadLetterDisplay,
+			ItemType:              serviceBusNodeDeadLetter,
+			ExpandURL:             ExpandURLNotSupported,
+			DeleteURL:             currentItem.ID + "/<deadletter>",
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata:              copyMetadata(metadata),
+		},
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: false,
+	}
+}
+
+// expandMessages peeks the messages at the front of the queue or subscription at path
+func (e *ServiceBusExpander) expandMessages(ctx context.Context, currentItem *TreeNode, path string) ExpanderResult {
+	if err := e.ensureConnectionDetails(ctx, currentItem); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ServiceBusExpander request",
+		}
+	}
+
+	messages, err := e.peekMessages(ctx, currentItem, path, serviceBusPeekCount)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error peeking messages: %s", err),
+			SourceDescription: "ServiceBusExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, message := range messages {
+		content, err := json.MarshalIndent(message, "", "  ")
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error marshaling message: %s", err),
+				SourceDescription: "ServiceBusExpander request",
+			}
+		}
+		name, status := serviceBusMessageDisplay(message)
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			ID:        currentItem.ID + "/" + fmt.Sprint(message.BrokerProperties["SequenceNumber"]),
+			Namespace: "servicebus",
+			Name:      name,
+			Display:   name + "\n  " + style.Subtle(status),
+			ItemType:  serviceBusNodeMessage,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"Content": string(content),
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: fmt.Sprintf("Peeked %d message(s) from %s", len(messages), path), ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "ServiceBusExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// Delete purges the dead-letter queue
+func (e *ServiceBusExpander) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.ItemType != serviceBusNodeDeadLetter {
+		return false, nil
+	}
+	if err := e.ensureConnectionDetails(ctx, item); err != nil {
+		return false, err
+	}
+
+	path := item.Metadata["EntityPath"] + "/$DeadLetterQueue"
+	for {
+		// Receive and Delete docs: https://docs.microsoft.com/en-us/rest/api/servicebus/receive-and-delete-message-destructive-read
+		response, err := e.doRequest(ctx, item, "DELETE", path+"/messages/head?timeout=1", map[string]string{}, nil)
+		if err != nil {
+			return false, fmt.Errorf("Error purging dead-letter queue: %s", err)
+		}
+		if response.StatusCode == http.StatusNoContent {
+			return true, nil
+		}
+	}
+}
+
+// HasActions returns true for the dead-letter queue
+func (e *ServiceBusExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == serviceBusNodeDeadLetter, nil
+}
+
+// ListActions returns the resubmit action for the dead-letter queue
+func (e *ServiceBusExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	if item.ItemType != serviceBusNodeDeadLetter {
+		return ListActionsResult{
+			SourceDescription: "ServiceBusExpander",
+			Err:               fmt.Errorf("ListActions not supported for ItemType %q", item.ItemType),
+		}
+	}
+
+	metadata := copyMetadata(item.Metadata)
+	metadata["ActionID"] = serviceBusActionResubmit
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?resubmit",
+				Namespace:              "servicebus",
+				Name:                   "Resubmit dead-lettered messages",
+				Display:                "Resubmit dead-lettered messages",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata:               metadata,
+			},
+		},
+		SourceDescription: "ServiceBusExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the resubmit action
+func (e *ServiceBusExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case serviceBusActionResubmit:
+		return e.resubmitDeadLetters(ctx, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "ServiceBusExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "ServiceBusExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+// resubmitDeadLetters sends each dead-lettered message back to the queue (or topic for a subscription) and then
+// removes it from the dead-letter queue. Only the messages present when the action starts are resubmitted so that
+// messages which are dead-lettered again can't cause a loop
+func (e *ServiceBusExpander) resubmitDeadLetters(ctx context.Context, item *TreeNode) ExpanderResult {
+	if armclient.IsReadOnlyMode() {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Read-only mode: dead-lettered messages can't be resubmitted"),
+			SourceDescription: "ServiceBusExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if err := e.ensureConnectionDetails(ctx, item); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "ServiceBusExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var count int64
+	if _, err := fmt.Sscan(item.Metadata["DeadLetterCount"], &count); err != nil || count == 0 {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "No dead-lettered messages to resubmit (refresh the parent node to update the count)", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "ServiceBusExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	entityName := lastSegment(item.Metadata["EntityPath"])
+	confirmation, _ := promptInCommandPanel(e.gui, e.commandPanel, serviceBusResubmitPrompt(item, count), "", nil)
+	if confirmation != entityName {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Resubmit cancelled as the name wasn't confirmed"),
+			SourceDescription: "ServiceBusExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+			InProgress: true,
+			Message:    "Resubmitting dead-lettered messages",
+			Timeout:    time.Hour,
+		})
+		resubmitted, err := e.resubmitMessages(item, count, event)
+		event.InProgress = false
+		event.SetTimeout(time.Second * 15)
+		if err != nil {
+			event.Failure = true
+			event.Message = fmt.Sprintf("Failed after resubmitting %d message(s): %s", resubmitted, err)
+		} else {
+			event.Message = fmt.Sprintf("Resubmitted %d message(s) to %s", resubmitted, item.Metadata["SendPath"])
+		}
+		event.Update()
+	}()
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Resubmitting dead-lettered messages (progress is shown in the status bar)", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "ServiceBusExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// serviceBusResubmitPrompt returns the confirmation prompt for resubmitting dead-lettered messages. A subscription's
+// messages are sent to its topic, so every subscription on the topic gets them again and not just this one
+func serviceBusResubmitPrompt(item *TreeNode, count int64) string {
+	entityName := lastSegment(item.Metadata["EntityPath"])
+	sendPath := item.Metadata["SendPath"]
+	if sendPath != item.Metadata["EntityPath"] {
+		return fmt.Sprintf("Type %q to resubmit %d message(s) to topic %s, ALL its subscriptions will receive them again:", entityName, count, sendPath)
+	}
+	return fmt.Sprintf("Type %q to resubmit %d message(s) to %s:", entityName, count, sendPath)
+}
+
+func (e *ServiceBusExpander) resubmitMessages(item *TreeNode, count int64, event *eventing.StatusEvent) (int64, error) {
+	ctx := context.Background()
+	path := item.Metadata["EntityPath"] + "/$DeadLetterQueue"
+	var resubmitted int64
+	for resubmitted < count {
+		message, err := e.peekLock(ctx, item, path)
+		if err != nil {
+			return resubmitted, err
+		}
+		if message == nil {
+			return resubmitted, nil
+		}
+
+		err = e.sendMessage(ctx, item, item.Metadata["SendPath"], message)
+		if err != nil {
+			e.unlock(item, message)
+			return resubmitted, err
+		}
+		// Complete Message docs: https://docs.microsoft.com/en-us/rest/api/servicebus/delete-message
+		_, err = e.doRequest(ctx, item, "DELETE", message.lockLocation, map[string]string{}, nil)
+		if err != nil {
+			return resubmitted, fmt.Errorf("Message was resubmitted but not removed from the dead-letter queue: %s", err)
+		}
+
+		resubmitted++
+		event.Message = fmt.Sprintf("Resubmitting dead-lettered messages (%d of %d)", resubmitted, count)
+		event.Update()
+	}
+	return resubmitted, nil
+}
+
+// peekMessages returns up to count messages from the front of the queue or subscription at path without locking them.
+// The REST API can only peek by locking messages, which increases their delivery count, so this uses the AMQP peek operation
+func (e *ServiceBusExpander) peekMessages(ctx context.Context, item *TreeNode, path string, count int) ([]*serviceBusMessage, error) {
+	client, err := dialAMQP(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close() //nolint: errcheck
+
+	if err = client.authorize(ctx, path); err != nil {
+		return nil, err
+	}
+	// Peek docs: https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-amqp-request-response#message-operations
+	response, err := client.managementRequest(ctx, path, "com.microsoft:peek-message", map[string]any{
+		"from-sequence-number": int64(0),
+		"message-count":        int32(count),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseServiceBusPeekResponse(response)
+}
+
+// peekLock locks the message at the front of the queue, returning nil if there are no messages
+func (e *ServiceBusExpander) peekLock(ctx context.Context, item *TreeNode, path string) (*serviceBusMessage, error) {
+	// Peek-Lock docs: https://docs.microsoft.com/en-us/rest/api/servicebus/peek-lock-message-non-destructive-read
+	response, err := e.doRequest(ctx, item, "POST", path+"/messages/head?timeout=1", map[string]string{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	return parseServiceBusMessage(response.Header, response.Body)
+}
+
+// parseServiceBusMessage reads a message from a peek-lock response
+func parseServiceBusMessage(headers http.Header, body []byte) (*serviceBusMessage, error) {
+	message := &serviceBusMessage{
+		BrokerProperties: map[string]interface{}{},
+		Properties:       map[string]string{},
+		ContentType:      headers.Get("Content-Type"),
+		Body:             string(body),
+		lockLocation:     headers.Get("Location"),
+	}
+	// Use json.Number so sequence numbers aren't shown in exponent form
+	decoder := json.NewDecoder(strings.NewReader(headers.Get("BrokerProperties")))
+	decoder.UseNumber()
+	if err := decoder.Decode(&message.BrokerProperties); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling BrokerProperties: %s", err)
+	}
+	// Custom properties are returned as headers so pick out everything that isn't a standard header.
+	// Note that the property names are in canonical header form as the original case isn't preserved
+	for header := range headers {
+		switch http.CanonicalHeaderKey(header) {
+		case "Brokerproperties", "Content-Type", "Content-Length", "Date", "Location", "Server", "Strict-Transport-Security", "Transfer-Encoding":
+			continue
+		}
+		message.Properties[http.CanonicalHeaderKey(header)] = headers.Get(header)
+	}
+	return message, nil
+}
+
+// serviceBusMessageDisplay returns the name and status line shown for a peeked message
+func serviceBusMessageDisplay(message *serviceBusMessage) (string, string) {
+	name := fmt.Sprintf("#%v %v", message.BrokerProperties["SequenceNumber"], message.BrokerProperties["MessageId"])
+	status := fmt.Sprintf("enqueued %v, delivery count %v", message.BrokerProperties["EnqueuedTimeUtc"], message.BrokerProperties["DeliveryCount"])
+	if reason := message.Properties["DeadLetterReason"]; reason != "" {
+		status += ", " + strings.Trim(reason, `"`)
+	}
+	return name, status
+}
+
+// parseServiceBusPeekResponse reads the messages from a peek response, which holds each message in its encoded form.
+// There are no messages in a response with status code 204
+func parseServiceBusPeekResponse(response *amqp.Message) ([]*serviceBusMessage, error) {
+	messages := []*serviceBusMessage{}
+	body, ok := response.Value.(map[string]any)
+	if !ok {
+		return messages, nil
+	}
+	entries, ok := body["messages"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected peek response: %v", body)
+	}
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("Unexpected entry in peek response: %v", entry)
+		}
+		encoded, ok := fields["message"].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("Unexpected message in peek response: %v", entry)
+		}
+		var message amqp.Message
+		if err := message.UnmarshalBinary(encoded); err != nil {
+			return nil, fmt.Errorf("Error decoding message: %s", err)
+		}
+		messages = append(messages, serviceBusMessageFromAMQP(&message))
+	}
+	return messages, nil
+}
+
+// serviceBusMessageFromAMQP converts an AMQP message to the form used by the REST API, mapping the
+// AMQP properties and annotations to the names of the broker properties
+// See https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-amqp-protocol-guide#message-annotations
+func serviceBusMessageFromAMQP(message *amqp.Message) *serviceBusMessage {
+	result := &serviceBusMessage{
+		BrokerProperties: map[string]interface{}{},
+		Properties:       map[string]string{},
+		Body:             amqpBody(message),
+	}
+	if message.Header != nil {
+		result.BrokerProperties["DeliveryCount"] = message.Header.DeliveryCount
+		if message.Header.TTL > 0 {
+			result.BrokerProperties["TimeToLive"] = message.Header.TTL.Seconds()
+		}
+	}
+	if properties := message.Properties; properties != nil {
+		setIfPresent := func(name string, value *string) {
+			if value != nil {
+				result.BrokerProperties[name] = *value
+			}
+		}
+		if properties.MessageID != nil {
+			result.BrokerProperties["MessageId"] = fmt.Sprint(properties.MessageID)
+		}
+		if properties.CorrelationID != nil {
+			result.BrokerProperties["CorrelationId"] = fmt.Sprint(properties.CorrelationID)
+		}
+		setIfPresent("Label", properties.Subject)
+		setIfPresent("To", properties.To)
+		setIfPresent("ReplyTo", properties.ReplyTo)
+		setIfPresent("SessionId", properties.GroupID)
+		setIfPresent("ReplyToSessionId", properties.ReplyToGroupID)
+		if properties.ContentType != nil {
+			result.ContentType = *properties.ContentType
+		}
+	}
+	for annotation, name := range map[string]string{
+		"x-opt-sequence-number":        "SequenceNumber",
+		"x-opt-enqueued-time":          "EnqueuedTimeUtc",
+		"x-opt-scheduled-enqueue-time": "ScheduledEnqueueTimeUtc",
+		"x-opt-partition-key":          "PartitionKey",
+		"x-opt-deadletter-source":      "DeadLetterSource",
+	} {
+		if value, ok := amqpAnnotation(message, annotation); ok {
+			if timestamp, isTime := value.(time.Time); isTime {
+				value = timestamp.UTC().Format(http.TimeFormat)
+			}
+			result.BrokerProperties[name] = value
+		}
+	}
+	for name, value := range message.ApplicationProperties {
+		result.Properties[name] = fmt.Sprint(value)
+	}
+	return result
+}
+
+// unlock releases the lock on the message so that it can be received again
+func (e *ServiceBusExpander) unlock(item *TreeNode, message *serviceBusMessage) {
+	// Unlock docs: https://docs.microsoft.com/en-us/rest/api/servicebus/unlock-message
+	// Locks expire anyway so this is best effort and uses a new context in case the original has been cancelled
+	_, _ = e.doRequest(context.Background(), item, "PUT", message.lockLocation, map[string]string{}, nil)
+}
+
+// sendMessage sends a copy of the message (without its system properties) to path
+func (e *ServiceBusExpander) sendMessage(ctx context.Context, item *TreeNode, path string, message *serviceBusMessage) error {
+	brokerProperties := map[string]interface{}{}
+	for _, name := range serviceBusResendProperties {
+		if value, ok := message.BrokerProperties[name]; ok {
+			brokerProperties[name] = value
+		}
+	}
+	brokerPropertiesJSON, err := json.Marshal(brokerProperties)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"BrokerProperties": string(brokerPropertiesJSON),
+		"Content-Type":     message.ContentType,
+	}
+	for name, value := range message.Properties {
+		if !serviceBusDeadLetterProperties[name] {
+			headers[name] = value
+		}
+	}
+
+	// Send Message docs: https://docs.microsoft.com/en-us/rest/api/servicebus/send-message-to-queue
+	_, err = e.doRequest(ctx, item, "POST", path+"/messages", headers, []byte(message.Body))
+	return err
+}
+
+// serviceBusEntityPaths splits the resource ID of a queue or subscription into the namespace ID, the path
+// of the entity to receive from and the path of the entity to send to
+func serviceBusEntityPaths(resourceID string) (namespaceID string, entityPath string, sendPath string) {
+	if i := strings.Index(strings.ToLower(resourceID), "/queues/"); i >= 0 {
+		queueName := lastSegment(resourceID)
+		return resourceID[:i], queueName, queueName
+	}
+
+	// <namespace>/topics/<topic>/subscriptions/<subscription>
+	i := strings.Index(strings.ToLower(resourceID), "/topics/")
+	segments := strings.Split(resourceID[i+1:], "/")
+	topicName := segments[1]
+	return resourceID[:i], topicName + "/subscriptions/" + segments[3], topicName
+}
+
+// copyMetadata returns a copy of the metadata so that nodes don't share a map
+func copyMetadata(metadata map[string]string) map[string]string {
+	result := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
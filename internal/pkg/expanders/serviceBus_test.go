@@ -0,0 +1,114 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseServiceBusMessage(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("BrokerProperties", `{"SequenceNumber": 12345678, "MessageId": "m1", "DeliveryCount": 2, "EnqueuedTimeUtc": "Mon, 02 Jan 2023 10:00:00 GMT"}`)
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Location", "https://ns.servicebus.windows.net/q/messages/12345678/lock")
+	headers.Set("Date", "Mon, 02 Jan 2023 10:00:01 GMT")
+	headers.Set("DeadLetterReason", `"MaxDeliveryCountExceeded"`)
+	headers.Set("Customer", `"contoso"`)
+
+	message, err := parseServiceBusMessage(headers, []byte(`{"order": 1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", message.ContentType)
+	assert.Equal(t, `{"order": 1}`, message.Body)
+	assert.Equal(t, "https://ns.servicebus.windows.net/q/messages/12345678/lock", message.lockLocation)
+	assert.Equal(t, map[string]string{
+		"Deadletterreason": `"MaxDeliveryCountExceeded"`,
+		"Customer":         `"contoso"`,
+	}, message.Properties)
+
+	name, status := serviceBusMessageDisplay(message)
+	assert.Equal(t, "#12345678 m1", name)
+	assert.Equal(t, "enqueued Mon, 02 Jan 2023 10:00:00 GMT, delivery count 2", status)
+
+	// Numbers are kept as they were sent when the message is shown or resubmitted
+	content, err := json.Marshal(message.BrokerProperties)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"SequenceNumber":12345678`)
+
+	_, err = parseServiceBusMessage(http.Header{}, nil)
+	assert.Error(t, err)
+}
+
+func Test_parseServiceBusPeekResponse(t *testing.T) {
+	subject := "order"
+	contentType := "application/json"
+	encoded, err := (&amqp.Message{
+		Header: &amqp.MessageHeader{DeliveryCount: 3},
+		Annotations: amqp.Annotations{
+			"x-opt-sequence-number": int64(12345678),
+			"x-opt-enqueued-time":   time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC),
+		},
+		Properties:            &amqp.MessageProperties{MessageID: "m1", Subject: &subject, ContentType: &contentType},
+		ApplicationProperties: map[string]any{"DeadLetterReason": "MaxDeliveryCountExceeded", "Customer": "contoso"},
+		Data:                  [][]byte{[]byte(`{"order": `), []byte(`1}`)},
+	}).MarshalBinary()
+	assert.NoError(t, err)
+
+	messages, err := parseServiceBusPeekResponse(&amqp.Message{
+		Value: map[string]any{"messages": []any{map[string]any{"message": encoded}}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	message := messages[0]
+	assert.Equal(t, `{"order": 1}`, message.Body)
+	assert.Equal(t, "application/json", message.ContentType)
+	assert.Equal(t, "order", message.BrokerProperties["Label"])
+	assert.Equal(t, map[string]string{"DeadLetterReason": "MaxDeliveryCountExceeded", "Customer": "contoso"}, message.Properties)
+
+	name, status := serviceBusMessageDisplay(message)
+	assert.Equal(t, "#12345678 m1", name)
+	assert.Equal(t, "enqueued Mon, 02 Jan 2023 10:00:00 GMT, delivery count 3, MaxDeliveryCountExceeded", status)
+
+	// No messages (status code 204) has no body
+	messages, err = parseServiceBusPeekResponse(&amqp.Message{})
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+
+	_, err = parseServiceBusPeekResponse(&amqp.Message{
+		Value: map[string]any{"messages": []any{"not a map"}},
+	})
+	assert.Error(t, err)
+}
+
+func Test_serviceBusResubmitPrompt(t *testing.T) {
+	queue := &TreeNode{Metadata: map[string]string{"EntityPath": "orders", "SendPath": "orders"}}
+	assert.Equal(t, `Type "orders" to resubmit 3 message(s) to orders:`, serviceBusResubmitPrompt(queue, 3))
+
+	// Messages from a subscription go back through the topic to every subscription
+	subscription := &TreeNode{Metadata: map[string]string{"EntityPath": "events/subscriptions/audit", "SendPath": "events"}}
+	assert.Equal(t, `Type "audit" to resubmit 3 message(s) to topic events, ALL its subscriptions will receive them again:`, serviceBusResubmitPrompt(subscription, 3))
+}
+
+func Test_ServiceBus_OnlyDeadLetterQueueHasActions(t *testing.T) {
+	e := &ServiceBusExpander{}
+	ctx := context.Background()
+	metadata := map[string]string{"EntityPath": "events/subscriptions/audit"}
+
+	active := &TreeNode{ID: "/sb/<active>", Namespace: "servicebus", ItemType: serviceBusNodeActive, Metadata: metadata}
+	hasActions, err := e.HasActions(ctx, active)
+	assert.NoError(t, err)
+	assert.False(t, hasActions)
+
+	deadLetter := &TreeNode{ID: "/sb/<deadletter>", Namespace: "servicebus", ItemType: serviceBusNodeDeadLetter, Metadata: metadata}
+	hasActions, err = e.HasActions(ctx, deadLetter)
+	assert.NoError(t, err)
+	assert.True(t, hasActions)
+	actions := e.ListActions(ctx, deadLetter)
+	assert.NoError(t, actions.Err)
+	assert.Len(t, actions.Nodes, 1)
+	assert.Equal(t, serviceBusActionResubmit, actions.Nodes[0].Metadata["ActionID"])
+}
@@ -0,0 +1,150 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// sharedAccessKeyName is the authorization rule created with every Service Bus and Event Hubs namespace
+const sharedAccessKeyName = "RootManageSharedAccessKey"
+
+// sharedAccessClient makes data-plane requests to Service Bus and Event Hubs namespaces,
+// authenticating with a Shared Access Signature created from the namespace keys
+type sharedAccessClient struct {
+	client    *http.Client
+	armClient *armclient.Client
+	provider  string
+}
+
+type sharedAccessListKeysResponse struct {
+	PrimaryKey string `json:"primaryKey"`
+	KeyName    string `json:"keyName"`
+}
+
+type sharedAccessNamespaceResponse struct {
+	Properties struct {
+		ServiceBusEndpoint string `json:"serviceBusEndpoint"`
+	} `json:"properties"`
+}
+
+// sharedAccessResponse is the response from a data-plane request
+type sharedAccessResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func newSharedAccessClient(armclient *armclient.Client, provider string) sharedAccessClient {
+	return sharedAccessClient{
+		client:    &http.Client{},
+		armClient: armclient,
+		provider:  provider,
+	}
+}
+
+// ensureConnectionDetails looks up the endpoint and key for the namespace in the NamespaceID metadata
+// if they haven't been saved in the item metadata
+func (c *sharedAccessClient) ensureConnectionDetails(ctx context.Context, item *TreeNode) error {
+	if item.Metadata["Key"] != "" {
+		return nil
+	}
+
+	namespaceID := item.Metadata["NamespaceID"]
+	data, err := c.armClient.DoRequest(ctx, "POST", namespaceID+"/AuthorizationRules/"+sharedAccessKeyName+"/listKeys?api-version=2017-04-01")
+	if err != nil {
+		return fmt.Errorf("Error getting %s keys: %s", c.provider, err)
+	}
+	var keys sharedAccessListKeysResponse
+	if err = json.Unmarshal([]byte(data), &keys); err != nil {
+		return fmt.Errorf("Error unmarshalling keys response: %s", err)
+	}
+
+	data, err = c.armClient.DoRequest(ctx, "GET", namespaceID+"?api-version=2017-04-01")
+	if err != nil {
+		return fmt.Errorf("Error getting %s namespace: %s", c.provider, err)
+	}
+	var namespace sharedAccessNamespaceResponse
+	if err = json.Unmarshal([]byte(data), &namespace); err != nil {
+		return fmt.Errorf("Error unmarshalling namespace response: %s", err)
+	}
+	if namespace.Properties.ServiceBusEndpoint == "" {
+		return fmt.Errorf("Endpoint not found for %s", namespaceID)
+	}
+
+	// The endpoint is returned with the port, e.g. https://myns.servicebus.windows.net:443/
+	endpoint := strings.Replace(namespace.Properties.ServiceBusEndpoint, ":443", "", 1)
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	item.Metadata["Endpoint"] = endpoint
+	item.Metadata["KeyName"] = keys.KeyName
+	item.Metadata["Key"] = keys.PrimaryKey
+	return nil
+}
+
+// doRequest makes a request to path (relative to the namespace endpoint, or absolute) and returns an error
+// for unsuccessful status codes
+func (c *sharedAccessClient) doRequest(ctx context.Context, item *TreeNode, verb string, path string, headers map[string]string, body []byte) (*sharedAccessResponse, error) {
+	requestURL := path
+	if !strings.HasPrefix(path, "https://") {
+		requestURL = item.Metadata["Endpoint"] + path
+	}
+
+	span, _ := tracing.StartSpanFromContext(ctx, "doRequest("+c.provider+"):"+requestURL, tracing.SetTag("url", requestURL))
+	defer span.Finish()
+
+	req, err := http.NewRequest(verb, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("Authorization", sharedAccessSignature(item.Metadata["Endpoint"], item.Metadata["KeyName"], item.Metadata["Key"], time.Now().Add(time.Hour)))
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+
+	response, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("Request failed %v for '%s': %s", response.Status, requestURL, string(buf))
+	}
+	return &sharedAccessResponse{
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Body:       buf,
+	}, nil
+}
+
+// sharedAccessSignature creates a SAS token for the resource URI
+// See https://docs.microsoft.com/en-us/rest/api/eventhub/generate-sas-token
+func sharedAccessSignature(resourceURI string, keyName string, key string, expiry time.Time) string {
+	encodedURI := url.QueryEscape(strings.ToLower(resourceURI))
+	expires := strconv.FormatInt(expiry.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(encodedURI + "\n" + expires))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s&skn=%s", encodedURI, url.QueryEscape(signature), expires, keyName)
+}
@@ -0,0 +1,25 @@
+package expanders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sharedAccessSignature(t *testing.T) {
+	token := sharedAccessSignature("https://MyNS.servicebus.windows.net/", "RootManageSharedAccessKey", "a2V5", time.Unix(1600000000, 0))
+	assert.Equal(t, "SharedAccessSignature sr=https%3A%2F%2Fmyns.servicebus.windows.net%2F&sig=TxzXVJpIbFDpNfD01mw%2F7bbvKviywaO2IX9nnuRX3RU%3D&se=1600000000&skn=RootManageSharedAccessKey", token)
+}
+
+func Test_serviceBusEntityPaths(t *testing.T) {
+	namespaceID, entityPath, sendPath := serviceBusEntityPaths("/subscriptions/s/resourceGroups/rg/providers/Microsoft.ServiceBus/namespaces/ns/queues/orders")
+	assert.Equal(t, "/subscriptions/s/resourceGroups/rg/providers/Microsoft.ServiceBus/namespaces/ns", namespaceID)
+	assert.Equal(t, "orders", entityPath)
+	assert.Equal(t, "orders", sendPath)
+
+	namespaceID, entityPath, sendPath = serviceBusEntityPaths("/subscriptions/s/resourceGroups/rg/providers/Microsoft.ServiceBus/namespaces/ns/topics/events/subscriptions/audit")
+	assert.Equal(t, "/subscriptions/s/resourceGroups/rg/providers/Microsoft.ServiceBus/namespaces/ns", namespaceID)
+	assert.Equal(t, "events/subscriptions/audit", entityPath)
+	assert.Equal(t, "events", sendPath)
+}
@@ -1,62 +1,53 @@
 package expanders
 
 import (
-	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
-	"sort"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
-	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 )
 
 // NewStorageBlobExpander creates a new instance of StorageBlobExpander
-func NewStorageBlobExpander(armclient *armclient.Client) *StorageBlobExpander {
+func NewStorageBlobExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *StorageBlobExpander {
 	return &StorageBlobExpander{
-		client:    &http.Client{},
-		armClient: armclient,
+		storageSharedKeyClient: newStorageSharedKeyClient(armclient),
+		gui:                    gui,
+		commandPanel:           commandPanel,
 	}
 }
 
 // Check interface
 var _ Expander = &StorageBlobExpander{}
 
-// StorageListKeyResponse is used to unmarshal a call to listKeys on a storage account
-type StorageListKeyResponse struct {
-	Keys []struct {
-		KeyName     string `json:"keyName"`
-		Value       string `json:"value"`
-		Permissions string `json:"permissions"`
-	} `json:"keys"`
-}
-
-// StorageAccountResponse is a partial representation of the storage account response
-type StorageAccountResponse struct {
-	Properties struct {
-		PrimaryEndpoints struct {
-			Blob string `json:"blob"`
-			Dfs  string `json:"dfs"`
-		} `json:"primaryEndpoints"`
-	} `json:"properties"`
-}
-
 // ContainerListResponse is a partial representation of the List container response
 type ContainerListResponse struct {
-	XMLName    xml.Name `xml:"EnumerationResults"`
-	Blobs      []Blob   `xml:"Blobs>Blob"`
-	NextMarker string   `xml:"NextMarker"`
+	XMLName xml.Name `xml:"EnumerationResults"`
+	Blobs   []Blob   `xml:"Blobs>Blob"`
+	// BlobPrefixes are the virtual directories returned when listing with a delimiter
+	BlobPrefixes []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>BlobPrefix"`
+	NextMarker string `xml:"NextMarker"`
 }
 
 type Blob struct {
@@ -85,11 +76,29 @@ const (
 	storageBlobNodeListBlobMetadata = "blob-metadata-list"
 	storageBlobNodeBlob             = "blob"
 	storageBlobNodeListBlob         = "blob-list"
+	storageBlobNodeDirectory        = "blob-directory"
 )
 
 const (
 	storageBlobActionLeaseAcquire = "lease-acquire"
 	storageBlobActionLeaseBreak   = "lease-break"
+	storageBlobActionDownload     = "blob-download"
+	storageBlobActionUpload       = "blob-upload"
+	storageBlobActionGoToPrefix   = "blob-go-to-prefix"
+	storageBlobActionSummarise    = "blob-summarise-directories"
+)
+
+const (
+	// storageBlobMaxDisplaySize is the largest blob that will be shown (and can be edited) in the content panel
+	storageBlobMaxDisplaySize = 1024 * 1024
+	// storageBlobBlockSize is the size of each block when uploading, files up to this size are uploaded in a single request
+	storageBlobBlockSize = 4 * 1024 * 1024
+	// storageBlobMaxDirectorySummaries is the most directories in a page of results that blob counts and sizes will be fetched for
+	storageBlobMaxDirectorySummaries = 20
+	// storageBlobDirectorySummaryLimit is the most blobs that will be counted for a directory (a single list request)
+	storageBlobDirectorySummaryLimit = 1000
+	// storageBlobDirectorySummaryConcurrency is the number of directories summarised at once
+	storageBlobDirectorySummaryConcurrency = 5
 )
 
 func (e *StorageBlobExpander) setClient(c *armclient.Client) {
@@ -99,8 +108,9 @@ func (e *StorageBlobExpander) setClient(c *armclient.Client) {
 // StorageBlobExpander expands the blob  data-plane aspects of a Storage Account
 type StorageBlobExpander struct {
 	ExpanderBase
-	client    *http.Client
-	armClient *armclient.Client
+	storageSharedKeyClient
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
 }
 
 // Name returns the name of the expander
@@ -142,6 +152,7 @@ func (e *StorageBlobExpander) Expand(ctx context.Context, currentItem *TreeNode)
 				SuppressGenericExpand: true,
 				Metadata: map[string]string{
 					"ContainerID": currentItem.ExpandURL, // save resourceID of blob
+					"ListID":      currentItem.ID + "/<blobs>",
 				},
 			},
 			{
@@ -156,6 +167,7 @@ func (e *StorageBlobExpander) Expand(ctx context.Context, currentItem *TreeNode)
 				SuppressGenericExpand: true,
 				Metadata: map[string]string{
 					"ContainerID": currentItem.ExpandURL, // save resourceID of blob
+					"ListID":      currentItem.ID + "/<blobs>",
 				},
 			},
 		}
@@ -174,7 +186,8 @@ func (e *StorageBlobExpander) Expand(ctx context.Context, currentItem *TreeNode)
 		return e.expandMetadataList(ctx, currentItem)
 	case storageBlobNodeBlobMetadata:
 		return e.expandMetadata(ctx, currentItem)
-	case storageBlobNodeListBlob:
+	case storageBlobNodeListBlob,
+		storageBlobNodeDirectory:
 		return e.expandBlobList(ctx, currentItem)
 	case storageBlobNodeBlob:
 		return e.expandBlob(ctx, currentItem)
@@ -196,11 +209,73 @@ func (e *StorageBlobExpander) Delete(ctx context.Context, currentItem *TreeNode)
 	return false, nil
 }
 
+// CanUpdate returns true for blobs that have been loaded as text into the content panel
+func (e *StorageBlobExpander) CanUpdate(context context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == storageBlobNodeBlob && item.Metadata["ETag"] != "", nil
+}
+
+// Update writes the edited content back to the blob, failing if the blob has changed since it was loaded.
+// Put Blob replaces the blob's metadata and properties so the current ones are read and sent with the content
+func (e *StorageBlobExpander) Update(ctx context.Context, item *TreeNode, updatedContent string) error {
+	accountName := item.Metadata["AccountName"]
+	accountKey := item.Metadata["AccountKey"]
+	url := getBlobURL(item.Metadata["BlobEndpoint"], item.Metadata["ContainerName"], item.Metadata["BlobName"])
+
+	// Blob Properties: https://docs.microsoft.com/en-us/rest/api/storageservices/get-blob-properties
+	_, currentHeaders, err := e.doRequestWithBody(ctx, "HEAD", url, accountName, accountKey, map[string]string{"If-Match": item.Metadata["ETag"]}, nil)
+	if err != nil {
+		if isStorageRequestStatus(err, http.StatusPreconditionFailed) {
+			return fmt.Errorf("Blob has been changed since it was loaded - refresh and try again")
+		}
+		return fmt.Errorf("Error getting blob properties: %s", err)
+	}
+
+	headers := blobPropertyHeaders(currentHeaders)
+	headers["x-ms-blob-type"] = "BlockBlob"
+	headers["If-Match"] = item.Metadata["ETag"]
+
+	// PutBlob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/put-blob
+	_, responseHeaders, err := e.doRequestWithBody(ctx, "PUT", url, accountName, accountKey, headers, []byte(updatedContent))
+	if err != nil {
+		if isStorageRequestStatus(err, http.StatusPreconditionFailed) {
+			return fmt.Errorf("Blob has been changed since it was loaded - refresh and try again")
+		}
+		return fmt.Errorf("Error updating blob: %s", err)
+	}
+	item.Metadata["ETag"] = responseHeaders.Get("ETag")
+	return nil
+}
+
+// blobPropertyHeaders returns the Put Blob request headers which keep the metadata and content
+// properties from a Get Blob Properties response (Content-MD5 is dropped as the content changes)
+func blobPropertyHeaders(properties http.Header) map[string]string {
+	headers := map[string]string{}
+	for header, blobHeader := range map[string]string{
+		"Content-Type":        "x-ms-blob-content-type",
+		"Content-Encoding":    "x-ms-blob-content-encoding",
+		"Content-Language":    "x-ms-blob-content-language",
+		"Content-Disposition": "x-ms-blob-content-disposition",
+		"Cache-Control":       "x-ms-blob-cache-control",
+	} {
+		if value := properties.Get(header); value != "" {
+			headers[blobHeader] = value
+		}
+	}
+	for header := range properties {
+		if strings.HasPrefix(strings.ToLower(header), "x-ms-meta-") {
+			headers[header] = properties.Get(header)
+		}
+	}
+	return headers
+}
+
 // HasActions is a default implementation returning false to indicate no actions available
 func (e *StorageBlobExpander) HasActions(context context.Context, item *TreeNode) (bool, error) {
 	switch item.ItemType {
 	case storageBlobNodeBlob,
-		storageBlobNodeBlobMetadata:
+		storageBlobNodeBlobMetadata,
+		storageBlobNodeListBlob,
+		storageBlobNodeDirectory:
 		return true, nil
 	}
 	return false, nil
@@ -250,6 +325,75 @@ func (e *StorageBlobExpander) ListActions(context context.Context, item *TreeNod
 					"BlobEndpoint":  item.Metadata["BlobEndpoint"],
 				},
 			},
+			&TreeNode{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?download",
+				Namespace:              "storageBlob",
+				Name:                   "Download",
+				Display:                "Download",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata: map[string]string{
+					"ActionID":      storageBlobActionDownload,
+					"BlobName":      item.Metadata["BlobName"],
+					"ContainerID":   item.Metadata["ContainerID"],
+					"ContainerName": item.Metadata["ContainerName"],
+					"AccountName":   item.Metadata["AccountName"],
+					"AccountKey":    item.Metadata["AccountKey"],
+					"BlobEndpoint":  item.Metadata["BlobEndpoint"],
+				},
+			},
+		)
+	case storageBlobNodeListBlob,
+		storageBlobNodeDirectory:
+		nodes = append(nodes,
+			&TreeNode{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?upload",
+				Namespace:              "storageBlob",
+				Name:                   "Upload file",
+				Display:                "Upload file",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata: map[string]string{
+					"ActionID":    storageBlobActionUpload,
+					"ContainerID": item.Metadata["ContainerID"],
+					"Prefix":      item.Metadata["Prefix"],
+				},
+			},
+			&TreeNode{
+				Parentid:              item.ID,
+				ID:                    item.ID + "?summarise-directories",
+				Namespace:             "storageBlob",
+				Name:                  "Show directory sizes",
+				Display:               "Show directory sizes",
+				ItemType:              ActionType,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"ActionID":    storageBlobActionSummarise,
+					"ContainerID": item.Metadata["ContainerID"],
+					"ListID":      item.Metadata["ListID"],
+					"Prefix":      item.Metadata["Prefix"],
+				},
+			},
+			&TreeNode{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?go-to-prefix",
+				Namespace:              "storageBlob",
+				Name:                   "Go to prefix",
+				Display:                "Go to prefix",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata: map[string]string{
+					"ActionID":    storageBlobActionGoToPrefix,
+					"ContainerID": item.Metadata["ContainerID"],
+					"ListID":      item.Metadata["ListID"],
+					"Prefix":      item.Metadata["Prefix"],
+				},
+			},
 		)
 	default:
 		return ListActionsResult{
@@ -273,6 +417,15 @@ func (e *StorageBlobExpander) ExecuteAction(context context.Context, item *TreeN
 		return e.storageBlobLeaseAcquire(context, item)
 	case storageBlobActionLeaseBreak:
 		return e.storageBlobLeaseBreak(context, item)
+	case storageBlobActionDownload:
+		return e.storageBlobDownload(item)
+	case storageBlobActionUpload:
+		return e.storageBlobUpload(context, item)
+	case storageBlobActionGoToPrefix:
+		prefix, _ := promptInCommandPanel(e.gui, e.commandPanel, "Go to prefix (e.g. logs/2020/), leave empty for the container root:", item.Metadata["Prefix"], nil)
+		return e.expandBlobList(context, blobListNodeForAction(item, prefix, false))
+	case storageBlobActionSummarise:
+		return e.expandBlobList(context, blobListNodeForAction(item, item.Metadata["Prefix"], true))
 	case "":
 		return ExpanderResult{
 			SourceDescription: "StorageBlobExpander",
@@ -294,7 +447,7 @@ func (e *StorageBlobExpander) storageBlobLeaseBreak(ctx context.Context, current
 	blobEndpoint := currentItem.Metadata["BlobEndpoint"]
 
 	// Lease Blob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/lease-blob
-	url := blobEndpoint + containerName + "/" + blobName + "?comp=lease"
+	url := getBlobURL(blobEndpoint, containerName, blobName) + "?comp=lease"
 	headers := map[string]string{
 		"x-ms-lease-action":       "break",
 		"x-ms-lease-break-period": "0",
@@ -329,7 +482,7 @@ func (e *StorageBlobExpander) storageBlobLeaseAcquire(ctx context.Context, curre
 	blobEndpoint := currentItem.Metadata["BlobEndpoint"]
 
 	// Lease Blob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/lease-blob
-	url := blobEndpoint + containerName + "/" + blobName + "?comp=lease"
+	url := getBlobURL(blobEndpoint, containerName, blobName) + "?comp=lease"
 	headers := map[string]string{
 		"x-ms-lease-action":   "acquire",
 		"x-ms-lease-duration": "-1",
@@ -374,12 +527,12 @@ func (e *StorageBlobExpander) expandMetadataList(ctx context.Context, currentIte
 			node := TreeNode{
 				Parentid:  currentItem.ID,
 				Namespace: "storageBlob",
-				ID:        currentItem.ID + "/" + blob.Name,
+				ID:        blobNodeID(currentItem, blob.Name),
 				Name:      blob.Name,
 				Display:   blob.Name,
 				ItemType:  storageBlobNodeBlobMetadata,
 				ExpandURL: ExpandURLNotSupported,
-				DeleteURL: currentItem.ID + "/" + blob.Name,
+				DeleteURL: blobNodeID(currentItem, blob.Name),
 				Metadata: map[string]string{
 					"BlobName": blob.Name,
 					"Content":  string(content),
@@ -388,7 +541,8 @@ func (e *StorageBlobExpander) expandMetadataList(ctx context.Context, currentIte
 
 			return &node, nil
 		},
-		storageBlobNodeListBlobMetadata)
+		storageBlobNodeListBlobMetadata,
+		"")
 }
 
 func (e *StorageBlobExpander) expandBlobList(ctx context.Context, currentItem *TreeNode) ExpanderResult {
@@ -397,26 +551,32 @@ func (e *StorageBlobExpander) expandBlobList(ctx context.Context, currentItem *T
 		ctx,
 		currentItem,
 		func(currentItem *TreeNode, blob Blob) (*TreeNode, error) {
+			// Show the name relative to the virtual directory being listed
+			displayName := strings.TrimPrefix(blob.Name, currentItem.Metadata["Prefix"])
 			node := TreeNode{
 				Parentid:  currentItem.ID,
 				Namespace: "storageBlob",
-				ID:        currentItem.ID + "/" + blob.Name,
-				Name:      blob.Name,
-				Display:   blob.Name,
+				ID:        blobNodeID(currentItem, blob.Name),
+				Name:      displayName,
+				Display:   displayName,
 				ItemType:  storageBlobNodeBlob,
 				ExpandURL: ExpandURLNotSupported,
-				DeleteURL: currentItem.ID + "/" + blob.Name,
+				DeleteURL: blobNodeID(currentItem, blob.Name),
 				Metadata: map[string]string{
-					"BlobName": blob.Name,
+					"BlobName":      blob.Name,
+					"ContentLength": strconv.Itoa(blob.Properties.ContentLength),
 				},
 			}
 
 			return &node, nil
 		},
-		storageBlobNodeListBlob)
+		storageBlobNodeListBlob,
+		"/")
 }
 
-func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeNode, createNodeFunc func(currentItem *TreeNode, blob Blob) (*TreeNode, error), continuationItemType string) ExpanderResult {
+// expandList lists the blobs in the container under the Prefix in the item metadata. When delimiter is set
+// the blobs are listed hierarchically, with virtual directories returned as expandable nodes
+func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeNode, createNodeFunc func(currentItem *TreeNode, blob Blob) (*TreeNode, error), continuationItemType string, delimiter string) ExpanderResult {
 
 	// https://docs.microsoft.com/en-us/rest/api/storageservices/enumerating-blob-resources#Subheading5
 
@@ -425,6 +585,7 @@ func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeN
 	accountName := e.getAccountName(containerID)
 	accountKey, err := e.getAccountKey(ctx, containerID)
 	marker := currentItem.Metadata["Marker"]
+	prefix := currentItem.Metadata["Prefix"]
 	if err != nil {
 		err = fmt.Errorf("Error getting account key: %s", err)
 		return ExpanderResult{
@@ -442,11 +603,17 @@ func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeN
 	}
 
 	// ListBlob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/list-blobs
-	url := blobEndpoint + containerName + "?restype=container&comp=list&maxresults=50"
+	listURL := blobEndpoint + containerName + "?restype=container&comp=list&maxresults=50"
 	if marker != "" {
-		url += "&marker=" + marker
+		listURL += "&marker=" + url.QueryEscape(marker)
+	}
+	if prefix != "" {
+		listURL += "&prefix=" + url.QueryEscape(prefix)
 	}
-	buf, err := e.doRequest(ctx, "GET", url, accountName, accountKey, "/"+accountName+"/"+containerName)
+	if delimiter != "" {
+		listURL += "&delimiter=" + url.QueryEscape(delimiter)
+	}
+	buf, err := e.doRequest(ctx, "GET", listURL, accountName, accountKey, "/"+accountName+"/"+containerName)
 
 	if err != nil {
 		return ExpanderResult{
@@ -465,6 +632,41 @@ func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeN
 	}
 	nodes := []*TreeNode{}
 
+	directoryNodes := []*TreeNode{}
+	for _, blobPrefix := range response.BlobPrefixes {
+		name := strings.TrimPrefix(blobPrefix.Name, prefix)
+		directoryNodes = append(directoryNodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageBlob",
+			ID:        blobNodeID(currentItem, blobPrefix.Name),
+			Name:      name,
+			Display:   name,
+			ItemType:  storageBlobNodeDirectory,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"ContainerID":   containerID,
+				"ContainerName": containerName,
+				"AccountName":   accountName,
+				"AccountKey":    accountKey,
+				"BlobEndpoint":  blobEndpoint,
+				"ListID":        blobListID(currentItem),
+				"Prefix":        blobPrefix.Name,
+			},
+		})
+	}
+	if currentItem.Metadata["SummariseDirectories"] == "true" {
+		if len(directoryNodes) > storageBlobMaxDirectorySummaries {
+			eventing.SendStatusEvent(&eventing.StatusEvent{
+				Message: fmt.Sprintf("Only the first %d directories are summarised", storageBlobMaxDirectorySummaries),
+				Timeout: time.Second * 5,
+			})
+			e.addDirectorySummaries(ctx, directoryNodes[:storageBlobMaxDirectorySummaries])
+		} else {
+			e.addDirectorySummaries(ctx, directoryNodes)
+		}
+	}
+	nodes = append(nodes, directoryNodes...)
+
 	for _, blob := range response.Blobs {
 		node, err := createNodeFunc(currentItem, blob)
 
@@ -479,6 +681,7 @@ func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeN
 		node.Metadata["AccountName"] = accountName
 		node.Metadata["AccountKey"] = accountKey
 		node.Metadata["BlobEndpoint"] = blobEndpoint
+		node.Metadata["ListID"] = blobListID(currentItem)
 
 		nodes = append(nodes, node)
 	}
@@ -499,6 +702,10 @@ func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeN
 				"AccountKey":    accountKey,
 				"BlobEndpoint":  blobEndpoint,
 				"Marker":        response.NextMarker,
+				"ListID":        blobListID(currentItem),
+				"Prefix":        prefix,
+				// Keep summarising directories on the following pages if requested
+				"SummariseDirectories": currentItem.Metadata["SummariseDirectories"],
 			},
 		}
 
@@ -514,6 +721,47 @@ func (e *StorageBlobExpander) expandList(ctx context.Context, currentItem *TreeN
 	}
 }
 
+// addDirectorySummaries adds the blob count and total size to the display of each directory node. Each directory
+// is summarised with a single (flat) list request so directories with more blobs than that are shown as a lower bound.
+// This is only done when requested with the 'Show directory sizes' action as it lists every directory
+func (e *StorageBlobExpander) addDirectorySummaries(ctx context.Context, directoryNodes []*TreeNode) {
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, storageBlobDirectorySummaryConcurrency)
+	for _, node := range directoryNodes {
+		wg.Add(1)
+		go func(node *TreeNode) {
+			defer wg.Done()
+			// recover from panic, if one occurrs, and leave terminal usable
+			defer errorhandling.RecoveryWithCleanup()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			accountName := node.Metadata["AccountName"]
+			containerName := node.Metadata["ContainerName"]
+			listURL := fmt.Sprintf("%s%s?restype=container&comp=list&maxresults=%d&prefix=%s", node.Metadata["BlobEndpoint"], containerName, storageBlobDirectorySummaryLimit, url.QueryEscape(node.Metadata["Prefix"]))
+			buf, err := e.doRequest(ctx, "GET", listURL, accountName, node.Metadata["AccountKey"], "/"+accountName+"/"+containerName)
+			if err != nil {
+				return // the summary is optional so just show the directory without it
+			}
+			response := &ContainerListResponse{}
+			if err = xml.Unmarshal(buf, response); err != nil {
+				return
+			}
+
+			var size int64
+			for _, blob := range response.Blobs {
+				size += int64(blob.Properties.ContentLength)
+			}
+			count := strconv.Itoa(len(response.Blobs))
+			if response.NextMarker != "" {
+				count += "+"
+			}
+			node.Display += "\n  " + style.Subtle(fmt.Sprintf("%s blobs, %s", count, formatBytes(size)))
+		}(node)
+	}
+	wg.Wait()
+}
+
 func (e *StorageBlobExpander) expandMetadata(ctx context.Context, currentItem *TreeNode) ExpanderResult {
 
 	containerName := currentItem.Metadata["ContainerName"]
@@ -523,7 +771,7 @@ func (e *StorageBlobExpander) expandMetadata(ctx context.Context, currentItem *T
 	blobEndpoint := currentItem.Metadata["BlobEndpoint"]
 
 	// Blob Properties: https://docs.microsoft.com/en-us/rest/api/storageservices/get-blob-properties
-	url := blobEndpoint + containerName + "/" + blobName
+	url := getBlobURL(blobEndpoint, containerName, blobName)
 	_, headers, err := e.doRequestWithHeadersIncludeResponseHeaders(ctx, "HEAD", url, accountName, accountKey, "/"+accountName+"/"+containerName, map[string]string{})
 
 	if err != nil {
@@ -572,9 +820,14 @@ func (e *StorageBlobExpander) expandBlob(ctx context.Context, currentItem *TreeN
 		}
 	}
 
+	if size, err := strconv.Atoi(currentItem.Metadata["ContentLength"]); err == nil && size > storageBlobMaxDisplaySize {
+		// Too large to show, so show the properties instead (the blob can be fetched with the Download action)
+		return e.expandMetadata(ctx, currentItem)
+	}
+
 	// GetBlob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-blob
-	url := blobEndpoint + containerName + "/" + blobName
-	buf, err := e.doRequest(ctx, "GET", url, accountName, accountKey, "/"+accountName+"/"+containerName+"/"+blobName)
+	url := getBlobURL(blobEndpoint, containerName, blobName)
+	buf, headers, err := e.doRequestWithHeadersIncludeResponseHeaders(ctx, "GET", url, accountName, accountKey, "/"+accountName+"/"+containerName+"/"+blobName, map[string]string{})
 
 	if err != nil {
 		return ExpanderResult{
@@ -583,6 +836,13 @@ func (e *StorageBlobExpander) expandBlob(ctx context.Context, currentItem *TreeN
 		}
 	}
 
+	if utf8.Valid(buf) {
+		// Save the ETag so that edits can be written back without overwriting changes made by others
+		currentItem.Metadata["ETag"] = headers.Get("ETag")
+	} else {
+		delete(currentItem.Metadata, "ETag")
+	}
+
 	result := string(buf)
 	return ExpanderResult{
 		Response:          ExpanderResponse{Response: result, ResponseType: interfaces.ResponsePlainText},
@@ -593,6 +853,219 @@ func (e *StorageBlobExpander) expandBlob(ctx context.Context, currentItem *TreeN
 
 }
 
+func (e *StorageBlobExpander) storageBlobDownload(item *TreeNode) ExpanderResult {
+	workingDir, _ := os.Getwd()
+	defaultPath := filepath.Join(workingDir, path.Base(item.Metadata["BlobName"]))
+	localPath, _ := promptInCommandPanel(e.gui, e.commandPanel, "Download to:", defaultPath, nil)
+	if localPath == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	url := getBlobURL(item.Metadata["BlobEndpoint"], item.Metadata["ContainerName"], item.Metadata["BlobName"])
+	e.downloadToFile(url, item.Metadata["AccountName"], item.Metadata["AccountKey"], localPath)
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Downloading to " + localPath + " (progress is shown in the notification panel)", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageBlobExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageBlobExpander) storageBlobUpload(ctx context.Context, item *TreeNode) ExpanderResult {
+	localPath, _ := promptInCommandPanel(e.gui, e.commandPanel, "Local file to upload:", "", nil)
+	if localPath == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if fileInfo.IsDir() {
+		return ExpanderResult{
+			Err:               fmt.Errorf("%s is a directory", localPath),
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	containerID := item.Metadata["ContainerID"]
+	containerName := e.getContainerName(containerID)
+	accountName := e.getAccountName(containerID)
+	accountKey, err := e.getAccountKey(ctx, containerID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting account key: %s", err),
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	blobEndpoint, err := e.getStorageBlobEndpoint(ctx, containerID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting blob endpoint: %s", err),
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	blobName := item.Metadata["Prefix"] + fileInfo.Name()
+	blobURL := getBlobURL(blobEndpoint, containerName, blobName)
+	overwrite, err := e.confirmBlobOverwrite(ctx, blobURL, accountName, accountKey, blobName)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageBlobExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		message := "Uploading " + localPath
+		event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+			InProgress: true,
+			IsToast:    true,
+			Message:    message,
+			Timeout:    time.Hour,
+		})
+		err := e.uploadBlob(localPath, fileInfo.Size(), blobURL, accountName, accountKey, overwrite, &transferProgress{event: event, message: message, total: fileInfo.Size()})
+		event.InProgress = false
+		event.SetTimeout(time.Second * 15)
+		if err != nil {
+			event.Failure = true
+			event.Message = "Failed to upload " + localPath + ": " + err.Error()
+			if isStorageRequestStatus(err, http.StatusConflict) || isStorageRequestStatus(err, http.StatusPreconditionFailed) {
+				event.Message = fmt.Sprintf("Failed to upload %s: %s was created while uploading and wasn't overwritten", localPath, blobName)
+			}
+		} else {
+			event.Message = fmt.Sprintf("Uploaded %s to %s (%s)", localPath, blobName, formatBytes(fileInfo.Size()))
+		}
+		event.Update()
+	}()
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Uploading " + localPath + " to " + blobName + " (progress is shown in the notification panel)", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageBlobExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// confirmBlobOverwrite asks the user before an upload overwrites an existing blob, returning whether the blob
+// exists and the user chose to overwrite it
+func (e *StorageBlobExpander) confirmBlobOverwrite(ctx context.Context, blobURL string, accountName string, accountKey string, blobName string) (bool, error) {
+	// Get Blob Properties docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-blob-properties
+	_, _, err := e.doRequestWithHeadersIncludeResponseHeaders(ctx, "HEAD", blobURL, accountName, accountKey, "/"+accountName, map[string]string{})
+	switch {
+	case err == nil:
+		options := []interfaces.CommandPanelListOption{
+			{ID: "cancel", DisplayText: "Cancel"},
+			{ID: "overwrite", DisplayText: "Overwrite " + blobName},
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, blobName+" already exists, overwrite it?", "", &options); selected != "overwrite" {
+			return false, fmt.Errorf("User canceled")
+		}
+		return true, nil
+	case isStorageRequestStatus(err, http.StatusNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("Error checking for an existing blob: %s", err)
+	}
+}
+
+// uploadBlob writes the local file to a block blob. Small files are written with a single request,
+// larger files are written as a series of blocks which are then committed. Unless overwrite is set
+// the blob is only written if it doesn't exist, so a blob created since the user was asked is kept
+func (e *StorageBlobExpander) uploadBlob(localPath string, size int64, blobURL string, accountName string, accountKey string, overwrite bool, progress *transferProgress) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint: errcheck
+
+	contentType := mime.TypeByExtension(filepath.Ext(localPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	conditionHeaders := map[string]string{}
+	if !overwrite {
+		conditionHeaders[headerIfNoneMatch] = "*"
+	}
+
+	if size <= storageBlobBlockSize {
+		buf, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		// PutBlob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/put-blob
+		headers := map[string]string{
+			"x-ms-blob-type":         "BlockBlob",
+			"x-ms-blob-content-type": contentType,
+		}
+		for header, value := range conditionHeaders {
+			headers[header] = value
+		}
+		_, _, err = e.doRequestWithBody(context.Background(), "PUT", blobURL, accountName, accountKey, headers, buf)
+		if err != nil {
+			return err
+		}
+		progress.add(int64(len(buf)))
+		return nil
+	}
+
+	buf := make([]byte, storageBlobBlockSize)
+	blockIDs := []string{}
+	for {
+		count, err := io.ReadFull(file, buf)
+		if count > 0 {
+			// Block IDs must all be the same length within a blob
+			blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(blockIDs))))
+			// PutBlock docs: https://docs.microsoft.com/en-us/rest/api/storageservices/put-block
+			_, _, putErr := e.doRequestWithBody(context.Background(), "PUT", blobURL+"?comp=block&blockid="+url.QueryEscape(blockID), accountName, accountKey, map[string]string{}, buf[:count])
+			if putErr != nil {
+				return putErr
+			}
+			blockIDs = append(blockIDs, blockID)
+			progress.add(int64(count))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// PutBlockList docs: https://docs.microsoft.com/en-us/rest/api/storageservices/put-block-list
+	blockList := struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: blockIDs}
+	body, err := xml.Marshal(blockList)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"x-ms-blob-content-type": contentType,
+	}
+	for header, value := range conditionHeaders {
+		headers[header] = value
+	}
+	_, _, err = e.doRequestWithBody(context.Background(), "PUT", blobURL+"?comp=blocklist", accountName, accountKey, headers, append([]byte(xml.Header), body...))
+	return err
+}
+
 func (e *StorageBlobExpander) deleteBlob(ctx context.Context, currentItem *TreeNode) (bool, error) {
 
 	containerID := currentItem.Metadata["ContainerID"]
@@ -608,7 +1081,7 @@ func (e *StorageBlobExpander) deleteBlob(ctx context.Context, currentItem *TreeN
 	}
 
 	// DeleteBlob docs: https://docs.microsoft.com/en-us/rest/api/storageservices/delete-blob
-	url := blobEndpoint + containerName + "/" + blobName
+	url := getBlobURL(blobEndpoint, containerName, blobName)
 	_, err = e.doRequest(ctx, "DELETE", url, accountName, accountKey, "/"+accountName+"/"+containerName+"/"+blobName)
 
 	if err != nil {
@@ -636,258 +1109,55 @@ func (e *StorageBlobExpander) getContainerName(containerID string) string {
 }
 
 func (e *StorageBlobExpander) getAccountKey(ctx context.Context, containerID string) (string, error) {
-
 	i := strings.Index(containerID, "/blobServices")
-	rootURL := containerID[0:i]
-	listKeysURL := rootURL + "/listKeys?api-version=2019-06-01"
-
-	data, err := e.armClient.DoRequest(ctx, "POST", listKeysURL)
-	if err != nil {
-		return "", fmt.Errorf("Error calling listKeys: %s", err)
-	}
-	response := StorageListKeyResponse{}
-	err = json.Unmarshal([]byte(data), &response)
-	if err != nil {
-		err = fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, listKeysURL)
-		return "", err
-	}
-	if len(response.Keys) == 0 {
-		err = fmt.Errorf("No keys in response: %s", err)
-		return "", err
-	}
-
-	return response.Keys[0].Value, nil
+	return e.getStorageAccountKey(ctx, containerID[0:i])
 }
-func (e *StorageBlobExpander) getStorageBlobEndpoint(ctx context.Context, containerID string) (string, error) {
 
+func (e *StorageBlobExpander) getStorageBlobEndpoint(ctx context.Context, containerID string) (string, error) {
 	i := strings.Index(containerID, "/blobServices")
-	rootURL := containerID[0:i]
-	storageAccountURL := rootURL + "?api-version=2019-06-01"
-
-	data, err := e.armClient.DoRequest(ctx, "GET", storageAccountURL)
+	account, err := e.getStorageAccount(ctx, containerID[0:i])
 	if err != nil {
-		return "", fmt.Errorf("Error getting storage account: %s", err)
-	}
-	response := StorageAccountResponse{}
-	err = json.Unmarshal([]byte(data), &response)
-	if err != nil {
-		err = fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, storageAccountURL)
 		return "", err
 	}
-
-	return response.Properties.PrimaryEndpoints.Blob, nil
-}
-
-func (e *StorageBlobExpander) doRequest(ctx context.Context, verb string, url string, accountName string, accountKey string, accountAndPath string) ([]byte, error) {
-	return e.doRequestWithHeaders(ctx, verb, url, accountName, accountKey, accountAndPath, map[string]string{})
-}
-func (e *StorageBlobExpander) doRequestWithHeaders(ctx context.Context, verb string, url string, accountName string, accountKey string, accountAndPath string, headers map[string]string) ([]byte, error) {
-	buf, _, err := e.doRequestWithHeadersIncludeResponseHeaders(ctx, verb, url, accountName, accountKey, accountAndPath, headers)
-	return buf, err
-}
-func (e *StorageBlobExpander) doRequestWithHeadersIncludeResponseHeaders(ctx context.Context, verb string, url string, accountName string, accountKey string, accountAndPath string, headers map[string]string) ([]byte, http.Header, error) {
-
-	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(blobexpnder):"+url, tracing.SetTag("url", url))
-	defer span.Finish()
-
-	req, err := http.NewRequest(verb, url, nil)
-	if err != nil {
-		return []byte{}, nil, fmt.Errorf("Failed to create request: %s", err)
-	}
-	if req.Header.Get("x-ms-version") == "" {
-		req.Header.Set("x-ms-version", "2018-03-28")
-	}
-	dateString := time.Now().UTC().Format(http.TimeFormat)
-	req.Header.Set("x-ms-date", dateString)
-
-	for header, value := range headers {
-		req.Header.Set(header, value)
-	}
-
-	err = e.addAuthHeader(req, accountName, accountKey)
-	if err != nil {
-		return []byte{}, nil, fmt.Errorf("Failed to add auth header: %s", err)
-	}
-
-	response, err := e.client.Do(req.WithContext(ctx))
-	if err != nil {
-		return []byte{}, nil, fmt.Errorf("Request failed: %s", err)
-	}
-
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		defer response.Body.Close() //nolint: errcheck
-		return []byte{}, nil, fmt.Errorf("DoRequest failed %v for '%s'", response.Status, url)
-	}
-
-	defer response.Body.Close() //nolint: errcheck
-	buf, err := io.ReadAll(response.Body)
-	if err != nil {
-		return []byte{}, nil, fmt.Errorf("Failed to read body: %s", err)
-	}
-
-	buf = e.stripBOM(buf)
-
-	return buf, response.Header, nil
+	return account.Properties.PrimaryEndpoints.Blob, nil
 }
 
-func (e *StorageBlobExpander) stripBOM(buf []byte) []byte {
-	if len(buf) < 3 {
-		return buf
-	}
-	if buf[0] == 0xEF && buf[1] == 0xBB && buf[2] == 0xBF {
-		return buf[3:]
+// blobListID returns the ID of the Blobs node the item was listed under, blob and directory node IDs
+// are built from this and the full blob name so they are the same however the blob was reached
+func blobListID(item *TreeNode) string {
+	if listID := item.Metadata["ListID"]; listID != "" {
+		return listID
 	}
-	return buf
+	return item.ID
 }
 
-// Auth helper code based on https://github.com/Azure/azure-storage-blob-go
-// (https://github.com/Azure/azure-storage-blob-go/blob/3efca72bd11c050222deab57e25ea90df03b9692/azblob/zc_credential_shared_key.go#L55)
-func (e *StorageBlobExpander) addAuthHeader(request *http.Request, accountName string, accountKey string) error {
-
-	// Add a x-ms-date header if it doesn't already exist
-	if d := request.Header.Get(headerXmsDate); d == "" {
-		request.Header[headerXmsDate] = []string{time.Now().UTC().Format(http.TimeFormat)}
-	}
-	stringToSign, err := e.buildStringToSign(request, accountName)
-	if err != nil {
-		return fmt.Errorf("Failed to build string to sign: %s", err)
-	}
-	signature, err := e.ComputeHMACSHA256(stringToSign, accountKey)
-	if err != nil {
-		return fmt.Errorf("Failed to compute signature: %s", err)
-	}
-	authHeader := strings.Join([]string{"SharedKey ", accountName, ":", signature}, "")
-	request.Header[headerAuthorization] = []string{authHeader}
-	return nil
+// blobNodeID returns the node ID for a blob or virtual directory (which has a trailing delimiter)
+func blobNodeID(item *TreeNode, name string) string {
+	return blobListID(item) + "/" + strings.TrimSuffix(name, "/")
 }
 
-// Constants ensuring that header names are correctly spelled and consistently cased.
-const (
-	headerAuthorization     = "Authorization"
-	headerContentEncoding   = "Content-Encoding"
-	headerContentLanguage   = "Content-Language"
-	headerContentLength     = "Content-Length"
-	headerContentMD5        = "Content-MD5"
-	headerContentType       = "Content-Type"
-	headerIfMatch           = "If-Match"
-	headerIfModifiedSince   = "If-Modified-Since"
-	headerIfNoneMatch       = "If-None-Match"
-	headerIfUnmodifiedSince = "If-Unmodified-Since"
-	headerRange             = "Range"
-	headerXmsDate           = "x-ms-date"
-)
-
-// ComputeHMACSHA256 generates a hash signature for an HTTP request or for a SAS.
-func (e *StorageBlobExpander) ComputeHMACSHA256(message string, accountKey string) (string, error) {
-	bytes, err := base64.StdEncoding.DecodeString(accountKey)
-	if err != nil {
-		return "", fmt.Errorf("Failed to decode storage account key: %s", err)
+// blobListNodeForAction returns a node for listing the blobs from an action, the listed nodes
+// are parented to the node the action was run on rather than the action
+func blobListNodeForAction(action *TreeNode, prefix string, summariseDirectories bool) *TreeNode {
+	metadata := copyMetadata(action.Metadata)
+	delete(metadata, "ActionID")
+	metadata["Prefix"] = prefix
+	if summariseDirectories {
+		metadata["SummariseDirectories"] = "true"
 	}
-	h := hmac.New(sha256.New, bytes)
-	_, err = h.Write([]byte(message))
-	if err != nil {
-		return "", fmt.Errorf("Failed to write bytes: %s", err)
+	return &TreeNode{
+		ID:        action.Parentid,
+		Namespace: "storageBlob",
+		ItemType:  storageBlobNodeDirectory,
+		Metadata:  metadata,
 	}
-	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
 }
 
-func (e *StorageBlobExpander) buildStringToSign(request *http.Request, accountName string) (string, error) {
-	// https://docs.microsoft.com/en-us/rest/api/storageservices/authentication-for-the-azure-storage-services
-	headers := request.Header
-	contentLength := headers.Get(headerContentLength)
-	if contentLength == "0" {
-		contentLength = ""
-	}
-
-	canonicalizedResource, err := e.buildCanonicalizedResource(request.URL, accountName)
-	if err != nil {
-		return "", err
-	}
-
-	stringToSign := strings.Join([]string{
-		request.Method,
-		headers.Get(headerContentEncoding),
-		headers.Get(headerContentLanguage),
-		contentLength,
-		headers.Get(headerContentMD5),
-		headers.Get(headerContentType),
-		"", // Empty date because x-ms-date is expected (as per web page above)
-		headers.Get(headerIfModifiedSince),
-		headers.Get(headerIfMatch),
-		headers.Get(headerIfNoneMatch),
-		headers.Get(headerIfUnmodifiedSince),
-		headers.Get(headerRange),
-		buildCanonicalizedHeader(headers),
-		canonicalizedResource,
-	}, "\n")
-	return stringToSign, nil
-}
-
-func buildCanonicalizedHeader(headers http.Header) string {
-	cm := map[string][]string{}
-	for k, v := range headers {
-		headerName := strings.TrimSpace(strings.ToLower(k))
-		if strings.HasPrefix(headerName, "x-ms-") {
-			cm[headerName] = v // NOTE: the value must not have any whitespace around it.
-		}
-	}
-	if len(cm) == 0 {
-		return ""
-	}
-
-	keys := make([]string, 0, len(cm))
-	for key := range cm {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	ch := bytes.NewBufferString("")
-	for i, key := range keys {
-		if i > 0 {
-			ch.WriteRune('\n')
-		}
-		ch.WriteString(key)
-		ch.WriteRune(':')
-		ch.WriteString(strings.Join(cm[key], ","))
-	}
-	return ch.String()
-}
-
-func (e *StorageBlobExpander) buildCanonicalizedResource(u *url.URL, accountName string) (string, error) {
-	// https://docs.microsoft.com/en-us/rest/api/storageservices/authentication-for-the-azure-storage-services
-	cr := bytes.NewBufferString("/")
-	cr.WriteString(accountName)
-
-	if len(u.Path) > 0 {
-		// Any portion of the CanonicalizedResource string that is derived from
-		// the resource's URI should be encoded exactly as it is in the URI.
-		// -- https://msdn.microsoft.com/en-gb/library/azure/dd179428.aspx
-		cr.WriteString(u.EscapedPath())
-	} else {
-		// a slash is required to indicate the root path
-		cr.WriteString("/")
-	}
-
-	// params is a map[string][]string; param name is key; params values is []string
-	params, err := url.ParseQuery(u.RawQuery) // Returns URL decoded values
-	if err != nil {
-		return "", errors.New("parsing query parameters must succeed, otherwise there might be serious problems in the SDK/generated code")
-	}
-
-	if len(params) > 0 { // There is at least 1 query parameter
-		paramNames := []string{} // We use this to sort the parameter key names
-		for paramName := range params {
-			paramNames = append(paramNames, paramName) // paramNames must be lowercase
-		}
-		sort.Strings(paramNames)
-
-		for _, paramName := range paramNames {
-			paramValues := params[paramName]
-			sort.Strings(paramValues)
-
-			// Join the sorted key values separated by ','
-			// Then prepend "keyName:"; then add this string to the buffer
-			cr.WriteString("\n" + paramName + ":" + strings.Join(paramValues, ","))
-		}
+// getBlobURL returns the URL for the blob, escaping each segment of the blob name
+func getBlobURL(blobEndpoint string, containerName string, blobName string) string {
+	segments := strings.Split(blobName, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
 	}
-	return cr.String(), nil
+	return blobEndpoint + containerName + "/" + strings.Join(segments, "/")
 }
@@ -0,0 +1,219 @@
+package expanders
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func Test_getBlobURL(t *testing.T) {
+	assert.Equal(t, "https://acct.blob.core.windows.net/container/dir/file%20name%231.txt",
+		getBlobURL("https://acct.blob.core.windows.net/", "container", "dir/file name#1.txt"))
+	assert.Equal(t, "https://acct.blob.core.windows.net/container/a%3Fb/%25",
+		getBlobURL("https://acct.blob.core.windows.net/", "container", "a?b/%"))
+}
+
+func Test_blobPropertyHeaders(t *testing.T) {
+	properties := http.Header{}
+	properties.Set("Content-Type", "application/json")
+	properties.Set("Cache-Control", "no-cache")
+	properties.Set("Content-MD5", "abc=")
+	properties.Set("x-ms-meta-owner", "team-a")
+	properties.Set("ETag", "0x1")
+
+	assert.Equal(t, map[string]string{
+		"x-ms-blob-content-type":  "application/json",
+		"x-ms-blob-cache-control": "no-cache",
+		"X-Ms-Meta-Owner":         "team-a",
+	}, blobPropertyHeaders(properties))
+}
+
+func Test_StorageBlob_UpdateKeepsMetadataAndProperties(t *testing.T) {
+	var putHeaders http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/container/dir/my%20file.json", r.URL.EscapedPath())
+		assert.Equal(t, "0x1", r.Header.Get("If-Match"))
+		switch r.Method {
+		case "HEAD":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("x-ms-meta-owner", "team-a")
+		case "PUT":
+			putHeaders = r.Header
+			w.Header().Set("ETag", "0x2")
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	e := &StorageBlobExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}}
+	item := &TreeNode{
+		ItemType: storageBlobNodeBlob,
+		Metadata: map[string]string{
+			"AccountName":   "acct",
+			"AccountKey":    "a2V5",
+			"BlobEndpoint":  ts.URL + "/",
+			"ContainerName": "container",
+			"BlobName":      "dir/my file.json",
+			"ETag":          "0x1",
+		},
+	}
+
+	assert.NoError(t, e.Update(context.Background(), item, `{"updated": true}`))
+	assert.Equal(t, "BlockBlob", putHeaders.Get("x-ms-blob-type"))
+	assert.Equal(t, "application/json", putHeaders.Get("x-ms-blob-content-type"))
+	assert.Equal(t, "team-a", putHeaders.Get("x-ms-meta-owner"))
+	assert.Equal(t, "0x2", item.Metadata["ETag"])
+}
+
+func Test_StorageBlob_UploadAsksBeforeOverwriting(t *testing.T) {
+	exists := true
+	var putHeaders http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "HEAD":
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case "PUT":
+			putHeaders = r.Header
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+	blobURL := ts.URL + "/container/report.txt"
+
+	// Closing the prompt leaves the existing blob untouched
+	g, commandPanel := newTestPrompt(t)
+	e := &StorageBlobExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+	_, err := e.confirmBlobOverwrite(context.Background(), blobURL, "acct", "a2V5", "report.txt")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"report.txt already exists, overwrite it?"}, commandPanel.titles)
+
+	commandPanel.responses = append(commandPanel.responses, interfaces.CommandPanelNotification{SelectedID: "overwrite", EnterPressed: true})
+	overwrite, err := e.confirmBlobOverwrite(context.Background(), blobURL, "acct", "a2V5", "report.txt")
+	assert.NoError(t, err)
+	assert.True(t, overwrite)
+
+	// A new blob is uploaded without asking, but only if it still doesn't exist
+	exists = false
+	commandPanel.titles = nil
+	overwrite, err = e.confirmBlobOverwrite(context.Background(), blobURL, "acct", "a2V5", "report.txt")
+	assert.NoError(t, err)
+	assert.False(t, overwrite)
+	assert.Empty(t, commandPanel.titles)
+
+	localPath := filepath.Join(t.TempDir(), "report.txt")
+	assert.NoError(t, os.WriteFile(localPath, []byte("new content"), 0600))
+	progress := &transferProgress{event: &eventing.StatusEvent{}}
+	assert.NoError(t, e.uploadBlob(localPath, 11, blobURL, "acct", "a2V5", false, progress))
+	assert.Equal(t, "*", putHeaders.Get("If-None-Match"))
+	assert.NoError(t, e.uploadBlob(localPath, 11, blobURL, "acct", "a2V5", true, progress))
+	assert.Empty(t, putHeaders.Get("If-None-Match"))
+}
+
+func Test_blobNodeID(t *testing.T) {
+	blobs := &TreeNode{ID: "/container/<blobs>"}
+	assert.Equal(t, "/container/<blobs>/logs", blobNodeID(blobs, "logs/"))
+	assert.Equal(t, "/container/<blobs>/logs/2020/app.log", blobNodeID(blobs, "logs/2020/app.log"))
+
+	// Nested directories and paging nodes build IDs from the Blobs node
+	directory := &TreeNode{ID: "/container/<blobs>/logs", Metadata: map[string]string{"ListID": "/container/<blobs>"}}
+	assert.Equal(t, "/container/<blobs>/logs/2020", blobNodeID(directory, "logs/2020/"))
+}
+
+func Test_StorageBlob_ExpandDirectory(t *testing.T) {
+	const accountID = "/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/acct"
+	listRequests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listRequests = append(listRequests, r.URL.Query().Get("prefix")+"|"+r.URL.Query().Get("delimiter"))
+		if r.URL.Query().Get("delimiter") == "" {
+			// Directory summary
+			_, _ = io.WriteString(w, `<EnumerationResults><Blobs>
+				<Blob><Name>logs/2020/a.log</Name><Properties><Content-Length>1024</Content-Length></Properties></Blob>
+				<Blob><Name>logs/2020/b.log</Name><Properties><Content-Length>1024</Content-Length></Properties></Blob>
+			</Blobs></EnumerationResults>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<EnumerationResults><Blobs>
+			<BlobPrefix><Name>logs/2020/</Name></BlobPrefix>
+			<Blob><Name>logs/readme.txt</Name><Properties><Content-Length>10</Content-Length></Properties></Blob>
+		</Blobs><NextMarker>m1</NextMarker></EnumerationResults>`)
+	}))
+	defer ts.Close()
+
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Post(accountID + "/listKeys").
+		Times(2).
+		Reply(200).
+		JSON(`{"keys": [{"value": "a2V5"}]}`)
+	gock.New("https://management.azure.com").
+		Get(accountID).
+		Times(2).
+		Reply(200).
+		JSON(`{"properties": {"primaryEndpoints": {"blob": "` + ts.URL + `/"}}}`)
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+
+	e := &StorageBlobExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}}
+	e.setClient(armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000))
+
+	directory := &TreeNode{
+		ID:        "/container/<blobs>/logs",
+		Namespace: "storageBlob",
+		ItemType:  storageBlobNodeDirectory,
+		Metadata: map[string]string{
+			"ContainerID": accountID + "/blobServices/default/containers/c1",
+			"ListID":      "/container/<blobs>",
+			"Prefix":      "logs/",
+		},
+	}
+	result := e.Expand(context.Background(), directory)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Nodes, 3)
+
+	subDirectory := result.Nodes[0]
+	assert.Equal(t, "/container/<blobs>/logs/2020", subDirectory.ID)
+	assert.Equal(t, directory.ID, subDirectory.Parentid)
+	assert.Equal(t, "2020/", subDirectory.Display)
+	assert.Equal(t, "logs/2020/", subDirectory.Metadata["Prefix"])
+
+	blob := result.Nodes[1]
+	assert.Equal(t, "/container/<blobs>/logs/readme.txt", blob.ID)
+	assert.Equal(t, blob.ID, blob.DeleteURL)
+	assert.Equal(t, "readme.txt", blob.Name)
+
+	more := result.Nodes[2]
+	assert.Equal(t, "m1", more.Metadata["Marker"])
+	assert.Equal(t, "/container/<blobs>", more.Metadata["ListID"])
+
+	// Directories are only summarised when requested, and the nodes are parented to the node the action was run on
+	assert.Equal(t, []string{"logs/|/"}, listRequests)
+	listRequests = []string{}
+	action := &TreeNode{
+		ID:       directory.ID + "?summarise-directories",
+		Parentid: directory.ID,
+		Metadata: map[string]string{
+			"ActionID":    storageBlobActionSummarise,
+			"ContainerID": directory.Metadata["ContainerID"],
+			"ListID":      directory.Metadata["ListID"],
+			"Prefix":      "logs/",
+		},
+	}
+	result = e.ExecuteAction(context.Background(), action)
+	assert.NoError(t, result.Err)
+	assert.ElementsMatch(t, []string{"logs/|/", "logs/2020/|"}, listRequests)
+	assert.Equal(t, directory.ID, result.Nodes[0].Parentid)
+	assert.Contains(t, result.Nodes[0].Display, "2 blobs, 2.0 KiB")
+	assert.True(t, gock.IsDone())
+}
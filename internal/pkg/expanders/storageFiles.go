@@ -0,0 +1,702 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// NewStorageFilesExpander creates a new instance of StorageFilesExpander
+func NewStorageFilesExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *StorageFilesExpander {
+	return &StorageFilesExpander{
+		storageSharedKeyClient: newStorageSharedKeyClient(armclient),
+		gui:                    gui,
+		commandPanel:           commandPanel,
+	}
+}
+
+// Check interface
+var _ Expander = &StorageFilesExpander{}
+
+// storageShareListResponse is a partial representation of the List Shares response
+type storageShareListResponse struct {
+	XMLName xml.Name `xml:"EnumerationResults"`
+	Shares  []struct {
+		Name       string `xml:"Name"`
+		Snapshot   string `xml:"Snapshot"`
+		Properties struct {
+			LastModified string `xml:"Last-Modified"`
+			Quota        int    `xml:"Quota"`
+		} `xml:"Properties"`
+	} `xml:"Shares>Share"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// storageDirectoryListResponse is a partial representation of the List Directories and Files response
+type storageDirectoryListResponse struct {
+	XMLName     xml.Name `xml:"EnumerationResults"`
+	Directories []struct {
+		Name string `xml:"Name"`
+	} `xml:"Entries>Directory"`
+	Files []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			ContentLength int64 `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Entries>File"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+const (
+	storageFilesNodeListShares    = "files-share-list"
+	storageFilesNodeListSnapshots = "files-snapshot-list"
+	storageFilesNodeDirectory     = "files-directory"
+	storageFilesNodeFile          = "files-file"
+)
+
+const (
+	storageFilesActionShowProperties = "files-show-properties"
+	storageFilesActionDownload       = "files-download"
+	storageFilesActionUpload         = "files-upload"
+)
+
+const (
+	// storageFilesMaxDisplaySize is the largest file that will be shown in the content panel
+	storageFilesMaxDisplaySize = 1024 * 1024
+	// storageFilesRangeSize is the size of each range written when uploading (the maximum the API allows)
+	storageFilesRangeSize = 4 * 1024 * 1024
+)
+
+func (e *StorageFilesExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// StorageFilesExpander expands the file share data-plane aspects of a Storage Account
+type StorageFilesExpander struct {
+	ExpanderBase
+	storageSharedKeyClient
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+}
+
+// Name returns the name of the expander
+func (e *StorageFilesExpander) Name() string {
+	return "StorageFilesExpander"
+}
+
+// DoesExpand checks if this is a storage account
+func (e *StorageFilesExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "storageFiles" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the shares, directories and files in the StorageAccount
+func (e *StorageFilesExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "storageFiles" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+		newItems := []*TreeNode{
+			{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<shares>",
+				Namespace:             "storageFiles",
+				Name:                  "File Shares",
+				Display:               "File Shares",
+				ItemType:              storageFilesNodeListShares,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"AccountID": currentItem.ID, // save resourceID of the storage account
+				},
+			},
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "StorageFilesExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case storageFilesNodeListShares, storageFilesNodeListSnapshots:
+		return e.expandShareList(ctx, currentItem)
+	case storageFilesNodeDirectory:
+		return e.expandDirectory(ctx, currentItem)
+	case storageFilesNodeFile:
+		return e.expandFile(ctx, currentItem)
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "StorageFilesExpander request",
+	}
+}
+
+// HasActions returns true for directories and files
+func (e *StorageFilesExpander) HasActions(context context.Context, item *TreeNode) (bool, error) {
+	switch item.ItemType {
+	case storageFilesNodeDirectory, storageFilesNodeFile:
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListActions returns the actions for directories and files
+func (e *StorageFilesExpander) ListActions(context context.Context, item *TreeNode) ListActionsResult {
+	actions := []struct{ id, name string }{
+		{storageFilesActionShowProperties, "Show properties"},
+	}
+	switch item.ItemType {
+	case storageFilesNodeFile:
+		actions = append(actions, struct{ id, name string }{storageFilesActionDownload, "Download"})
+	case storageFilesNodeDirectory:
+		if item.Metadata["ShareSnapshot"] == "" { // snapshots are read-only
+			actions = append(actions, struct{ id, name string }{storageFilesActionUpload, "Upload file"})
+		}
+	default:
+		return ListActionsResult{
+			SourceDescription: "StorageFilesExpander",
+			Err:               fmt.Errorf("ListActions not supported for ItemType %q", item.ItemType),
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, action := range actions {
+		metadata := map[string]string{
+			"ActionID": action.id,
+			"ItemType": item.ItemType,
+		}
+		for k, v := range item.Metadata {
+			metadata[k] = v
+		}
+		nodes = append(nodes, &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + action.id,
+			Namespace:              "storageFiles",
+			Name:                   action.name,
+			Display:                action.name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               metadata,
+		})
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "StorageFilesExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the file action
+func (e *StorageFilesExpander) ExecuteAction(context context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case storageFilesActionShowProperties:
+		return e.showProperties(context, item)
+	case storageFilesActionDownload:
+		return e.downloadFile(item)
+	case storageFilesActionUpload:
+		return e.uploadFile(context, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "StorageFilesExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "StorageFilesExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *StorageFilesExpander) expandShareList(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	if err := e.ensureConnectionDetails(ctx, currentItem); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageFilesExpander request",
+		}
+	}
+
+	// List Shares docs: https://docs.microsoft.com/en-us/rest/api/storageservices/list-shares
+	listURL := currentItem.Metadata["FileEndpoint"] + "?comp=list&maxresults=50"
+	listingSnapshots := currentItem.ItemType == storageFilesNodeListSnapshots
+	if listingSnapshots {
+		listURL += "&include=snapshots&prefix=" + url.QueryEscape(currentItem.Metadata["ShareName"])
+	}
+	if marker := currentItem.Metadata["Marker"]; marker != "" {
+		listURL += "&marker=" + url.QueryEscape(marker)
+	}
+	accountName := currentItem.Metadata["AccountName"]
+	buf, err := e.doRequest(ctx, "GET", listURL, accountName, currentItem.Metadata["AccountKey"], "/"+accountName)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing shares: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+		}
+	}
+
+	response := &storageShareListResponse{}
+	err = xml.Unmarshal(buf, response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error Unmarshalling storageShareListResponse: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, share := range response.Shares {
+		if listingSnapshots != (share.Snapshot != "") || (listingSnapshots && share.Name != currentItem.Metadata["ShareName"]) {
+			continue
+		}
+		metadata := e.connectionMetadata(currentItem)
+		metadata["ShareName"] = share.Name
+		metadata["ShareSnapshot"] = share.Snapshot
+		metadata["Path"] = ""
+
+		name := share.Name
+		display := share.Name + " " + style.Subtle(fmt.Sprintf("(quota %d GiB)", share.Properties.Quota))
+		if listingSnapshots {
+			name = share.Snapshot
+			display = share.Snapshot
+		}
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageFiles",
+			ID:        currentItem.ID + "/" + name,
+			Name:      name,
+			Display:   display,
+			ItemType:  storageFilesNodeDirectory,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+	if response.NextMarker != "" {
+		metadata := e.connectionMetadata(currentItem)
+		metadata["ShareName"] = currentItem.Metadata["ShareName"]
+		metadata["Marker"] = response.NextMarker
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "storageFiles",
+			ID:            currentItem.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      currentItem.ItemType,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: interfaces.ResponseXML},
+		SourceDescription: "StorageFilesExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageFilesExpander) expandDirectory(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	// List Directories and Files docs: https://docs.microsoft.com/en-us/rest/api/storageservices/list-directories-and-files
+	listURL := e.getItemURL(currentItem) + "?restype=directory&comp=list&maxresults=100"
+	if snapshot := currentItem.Metadata["ShareSnapshot"]; snapshot != "" {
+		listURL += "&sharesnapshot=" + url.QueryEscape(snapshot)
+	}
+	if marker := currentItem.Metadata["Marker"]; marker != "" {
+		listURL += "&marker=" + url.QueryEscape(marker)
+	}
+	accountName := currentItem.Metadata["AccountName"]
+	buf, err := e.doRequest(ctx, "GET", listURL, accountName, currentItem.Metadata["AccountKey"], "/"+accountName)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing directory: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+		}
+	}
+
+	response := &storageDirectoryListResponse{}
+	err = xml.Unmarshal(buf, response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error Unmarshalling storageDirectoryListResponse: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+		}
+	}
+
+	// Nodes for continuations are expanded in place so use the path of the original directory
+	parentID := strings.TrimSuffix(currentItem.ID, "/...more")
+	nodes := []*TreeNode{}
+	newNode := func(name string, display string, itemType string) *TreeNode {
+		metadata := e.connectionMetadata(currentItem)
+		metadata["ShareName"] = currentItem.Metadata["ShareName"]
+		metadata["ShareSnapshot"] = currentItem.Metadata["ShareSnapshot"]
+		metadata["Path"] = strings.TrimPrefix(currentItem.Metadata["Path"]+"/"+name, "/")
+		return &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageFiles",
+			ID:        parentID + "/" + name,
+			Name:      name,
+			Display:   display,
+			ItemType:  itemType,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		}
+	}
+
+	if currentItem.Metadata["Path"] == "" && currentItem.Metadata["ShareSnapshot"] == "" && currentItem.Metadata["Marker"] == "" {
+		snapshotsNode := newNode("[Snapshots]", style.Subtle("[Snapshots]"), storageFilesNodeListSnapshots)
+		snapshotsNode.Metadata["Path"] = ""
+		nodes = append(nodes, snapshotsNode)
+	}
+	for _, directory := range response.Directories {
+		nodes = append(nodes, newNode(directory.Name, directory.Name+"/", storageFilesNodeDirectory))
+	}
+	for _, file := range response.Files {
+		node := newNode(file.Name, file.Name+" "+style.Subtle(formatBytes(file.Properties.ContentLength)), storageFilesNodeFile)
+		node.Metadata["ContentLength"] = strconv.FormatInt(file.Properties.ContentLength, 10)
+		nodes = append(nodes, node)
+	}
+	if response.NextMarker != "" {
+		metadata := e.connectionMetadata(currentItem)
+		metadata["ShareName"] = currentItem.Metadata["ShareName"]
+		metadata["ShareSnapshot"] = currentItem.Metadata["ShareSnapshot"]
+		metadata["Path"] = currentItem.Metadata["Path"]
+		metadata["Marker"] = response.NextMarker
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "storageFiles",
+			ID:            parentID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      storageFilesNodeDirectory,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: interfaces.ResponseXML},
+		SourceDescription: "StorageFilesExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandFile shows the content of small text files and the properties of other files
+func (e *StorageFilesExpander) expandFile(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	contentLength, _ := strconv.ParseInt(currentItem.Metadata["ContentLength"], 10, 64)
+	if contentLength <= storageFilesMaxDisplaySize {
+		// Get File docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-file
+		accountName := currentItem.Metadata["AccountName"]
+		buf, err := e.doRequest(ctx, "GET", e.getItemURLWithSnapshot(currentItem), accountName, currentItem.Metadata["AccountKey"], "/"+accountName)
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error getting file: %s", err),
+				SourceDescription: "StorageFilesExpander request",
+			}
+		}
+		if utf8.Valid(buf) {
+			return ExpanderResult{
+				Response:          ExpanderResponse{Response: string(buf), ResponseType: interfaces.ResponsePlainText},
+				SourceDescription: "StorageFilesExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+	}
+
+	// Not text or too large to show so display the properties instead
+	return e.showProperties(ctx, currentItem)
+}
+
+// showProperties shows the properties and metadata for a file or directory
+func (e *StorageFilesExpander) showProperties(ctx context.Context, item *TreeNode) ExpanderResult {
+	// Get File Properties docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-file-properties
+	// Get Directory Properties docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-directory-properties
+	itemType := item.ItemType
+	if itemType == ActionType {
+		itemType = item.Metadata["ItemType"]
+	}
+	verb := "HEAD"
+	query := url.Values{}
+	if itemType == storageFilesNodeDirectory {
+		verb = "GET"
+		query.Set("restype", "directory")
+		if item.Metadata["Path"] == "" {
+			// Get Share Properties docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-share-properties
+			query.Set("restype", "share")
+		}
+	}
+	if snapshot := item.Metadata["ShareSnapshot"]; snapshot != "" {
+		query.Set("sharesnapshot", snapshot)
+	}
+	propertiesURL := e.getItemURL(item)
+	if len(query) > 0 {
+		propertiesURL += "?" + query.Encode()
+	}
+
+	accountName := item.Metadata["AccountName"]
+	_, headers, err := e.doRequestWithHeadersIncludeResponseHeaders(ctx, verb, propertiesURL, accountName, item.Metadata["AccountKey"], "/"+accountName, map[string]string{})
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting properties: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	simpleHeaders := map[string]string{}
+	for k := range headers {
+		simpleHeaders[k] = headers.Get(k)
+	}
+	buf, err := json.Marshal(simpleHeaders)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error marshaling properties to JSON: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "StorageFilesExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageFilesExpander) downloadFile(item *TreeNode) ExpanderResult {
+	workingDir, _ := os.Getwd()
+	defaultPath := filepath.Join(workingDir, filepath.Base(item.Metadata["Path"]))
+	localPath, _ := promptInCommandPanel(e.gui, e.commandPanel, "Download to:", defaultPath, nil)
+	if localPath == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	e.downloadToFile(e.getItemURLWithSnapshot(item), item.Metadata["AccountName"], item.Metadata["AccountKey"], localPath)
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Downloading to " + localPath + " (progress is shown in the notification panel)", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageFilesExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageFilesExpander) uploadFile(ctx context.Context, item *TreeNode) ExpanderResult {
+	localPath, _ := promptInCommandPanel(e.gui, e.commandPanel, "Local file to upload:", "", nil)
+	if localPath == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if fileInfo.IsDir() {
+		return ExpanderResult{
+			Err:               fmt.Errorf("%s is a directory", localPath),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	fileURL := e.getItemURL(item) + "/" + url.PathEscape(fileInfo.Name())
+	accountName := item.Metadata["AccountName"]
+	accountKey := item.Metadata["AccountKey"]
+
+	// Creating the file replaces any existing file with an empty one so check before overwriting
+	// Get File Properties docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-file-properties
+	_, _, err = e.doRequestWithHeadersIncludeResponseHeaders(ctx, "HEAD", fileURL, accountName, accountKey, "/"+accountName, map[string]string{})
+	switch {
+	case err == nil:
+		options := []interfaces.CommandPanelListOption{
+			{ID: "cancel", DisplayText: "Cancel"},
+			{ID: "overwrite", DisplayText: "Overwrite " + fileInfo.Name()},
+		}
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, fileInfo.Name()+" already exists, overwrite it?", "", &options); selected != "overwrite" {
+			return ExpanderResult{
+				Err:               fmt.Errorf("User canceled"),
+				SourceDescription: "StorageFilesExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+	case !isStorageRequestStatus(err, http.StatusNotFound):
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error checking for an existing file: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Create File docs: https://docs.microsoft.com/en-us/rest/api/storageservices/create-file
+	_, err = e.doRequestWithHeaders(ctx, "PUT", fileURL, accountName, accountKey, "/"+accountName, map[string]string{
+		"x-ms-type":           "file",
+		"x-ms-content-length": strconv.FormatInt(fileInfo.Size(), 10),
+	})
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error creating file: %s", err),
+			SourceDescription: "StorageFilesExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		message := "Uploading " + localPath
+		event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+			InProgress: true,
+			IsToast:    true,
+			Message:    message,
+			Timeout:    time.Hour,
+		})
+		err := e.uploadRanges(localPath, fileURL, accountName, accountKey, &transferProgress{event: event, message: message, total: fileInfo.Size()})
+		event.InProgress = false
+		event.SetTimeout(time.Second * 15)
+		if err != nil {
+			event.Failure = true
+			// The file was created at its full size so the remote copy is incomplete
+			event.Message = fmt.Sprintf("Failed to upload %s: %s. The remote file %s is incomplete, upload it again or delete it", localPath, err, fileInfo.Name())
+		} else {
+			event.Message = fmt.Sprintf("Uploaded %s (%s)", localPath, formatBytes(fileInfo.Size()))
+		}
+		event.Update()
+	}()
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Uploading " + localPath + " (progress is shown in the notification panel)", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageFilesExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// uploadRanges writes the content of the local file to the (already created) file in chunks
+func (e *StorageFilesExpander) uploadRanges(localPath string, fileURL string, accountName string, accountKey string, progress *transferProgress) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint: errcheck
+
+	buf := make([]byte, storageFilesRangeSize)
+	var offset int64
+	for {
+		count, err := io.ReadFull(file, buf)
+		if count > 0 {
+			// Put Range docs: https://docs.microsoft.com/en-us/rest/api/storageservices/put-range
+			_, _, putErr := e.doRequestWithBody(context.Background(), "PUT", fileURL+"?comp=range", accountName, accountKey, map[string]string{
+				"x-ms-write": "update",
+				"x-ms-range": fmt.Sprintf("bytes=%d-%d", offset, offset+int64(count)-1),
+			}, buf[:count])
+			if putErr != nil {
+				return putErr
+			}
+			offset += int64(count)
+			progress.add(int64(count))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ensureConnectionDetails looks up the account name, key and file endpoint if they haven't been saved in the item metadata
+func (e *StorageFilesExpander) ensureConnectionDetails(ctx context.Context, item *TreeNode) error {
+	if item.Metadata["AccountKey"] != "" {
+		return nil
+	}
+
+	accountID := item.Metadata["AccountID"]
+	accountKey, err := e.getStorageAccountKey(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("Error getting account key: %s", err)
+	}
+	account, err := e.getStorageAccount(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("Error getting file endpoint: %s", err)
+	}
+	if account.Properties.PrimaryEndpoints.File == "" {
+		return fmt.Errorf("Storage account %q doesn't have a file endpoint", lastSegment(accountID))
+	}
+	item.Metadata["AccountName"] = lastSegment(accountID)
+	item.Metadata["AccountKey"] = accountKey
+	item.Metadata["FileEndpoint"] = account.Properties.PrimaryEndpoints.File
+	return nil
+}
+
+// connectionMetadata returns a copy of the metadata needed to connect to the file service
+func (e *StorageFilesExpander) connectionMetadata(item *TreeNode) map[string]string {
+	metadata := map[string]string{}
+	for _, key := range []string{"AccountID", "AccountName", "AccountKey", "FileEndpoint"} {
+		metadata[key] = item.Metadata[key]
+	}
+	return metadata
+}
+
+// getItemURL returns the URL for the share, directory or file in the item metadata
+func (e *StorageFilesExpander) getItemURL(item *TreeNode) string {
+	itemURL := item.Metadata["FileEndpoint"] + url.PathEscape(item.Metadata["ShareName"])
+	for _, segment := range strings.Split(item.Metadata["Path"], "/") {
+		if segment != "" {
+			itemURL += "/" + url.PathEscape(segment)
+		}
+	}
+	return itemURL
+}
+
+func (e *StorageFilesExpander) getItemURLWithSnapshot(item *TreeNode) string {
+	itemURL := e.getItemURL(item)
+	if snapshot := item.Metadata["ShareSnapshot"]; snapshot != "" {
+		itemURL += "?sharesnapshot=" + url.QueryEscape(snapshot)
+	}
+	return itemURL
+}
@@ -0,0 +1,186 @@
+package expanders
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StorageFiles_UploadAsksBeforeOverwriting(t *testing.T) {
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer ts.Close()
+
+	localPath := filepath.Join(t.TempDir(), "report.txt")
+	assert.NoError(t, os.WriteFile(localPath, []byte("new content"), 0600))
+
+	// Closing the prompt leaves the existing file untouched
+	g, commandPanel := newTestPrompt(t, localPath)
+	e := &StorageFilesExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+	directory := &TreeNode{
+		ItemType: ActionType,
+		Metadata: map[string]string{
+			"ActionID":     storageFilesActionUpload,
+			"ItemType":     storageFilesNodeDirectory,
+			"AccountName":  "account",
+			"AccountKey":   "a2V5",
+			"FileEndpoint": ts.URL + "/",
+			"ShareName":    "share",
+			"Path":         "docs",
+		},
+	}
+	result := e.ExecuteAction(context.Background(), directory)
+	assert.Error(t, result.Err)
+	assert.Equal(t, []string{"HEAD /share/docs/report.txt"}, requests)
+	assert.Equal(t, []string{"Local file to upload:", "report.txt already exists, overwrite it?"}, commandPanel.titles)
+}
+
+func newStorageFilesTestItem(ts *httptest.Server, itemType string, path string) *TreeNode {
+	return &TreeNode{
+		ID:       strings.TrimSuffix("/<shares>/share/"+path, "/"),
+		ItemType: itemType,
+		Metadata: map[string]string{
+			"AccountName":  "account",
+			"AccountKey":   "a2V5",
+			"FileEndpoint": ts.URL + "/",
+			"ShareName":    "share",
+			"Path":         path,
+		},
+	}
+}
+
+func Test_StorageFiles_ExpandDirectory(t *testing.T) {
+	queries := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("marker"))
+		if r.URL.Query().Get("marker") == "" {
+			_, _ = io.WriteString(w, `<EnumerationResults>
+				<Entries>
+					<Directory><Name>logs</Name></Directory>
+					<File><Name>a.txt</Name><Properties><Content-Length>12</Content-Length></Properties></File>
+				</Entries>
+				<NextMarker>page2</NextMarker>
+			</EnumerationResults>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<EnumerationResults>
+			<Entries><File><Name>b.txt</Name><Properties><Content-Length>3</Content-Length></Properties></File></Entries>
+			<NextMarker />
+		</EnumerationResults>`)
+	}))
+	defer ts.Close()
+
+	e := &StorageFilesExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}}
+	share := newStorageFilesTestItem(ts, storageFilesNodeDirectory, "")
+	result := e.Expand(context.Background(), share)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Nodes, 4)
+
+	// The root of a share lists its snapshots first
+	assert.Equal(t, storageFilesNodeListSnapshots, result.Nodes[0].ItemType)
+	assert.Equal(t, "", result.Nodes[0].Metadata["Path"])
+	assert.Equal(t, storageFilesNodeDirectory, result.Nodes[1].ItemType)
+	assert.Equal(t, "logs", result.Nodes[1].Metadata["Path"])
+	assert.Equal(t, storageFilesNodeFile, result.Nodes[2].ItemType)
+	assert.Equal(t, "a.txt", result.Nodes[2].Metadata["Path"])
+	assert.Equal(t, "12", result.Nodes[2].Metadata["ContentLength"])
+
+	more := result.Nodes[3]
+	assert.True(t, more.ExpandInPlace)
+	assert.Equal(t, "page2", more.Metadata["Marker"])
+
+	// Continuations keep the IDs of the original directory and don't repeat the snapshots node
+	result = e.Expand(context.Background(), more)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Nodes, 1)
+	assert.Equal(t, "/<shares>/share/b.txt", result.Nodes[0].ID)
+	assert.Equal(t, "b.txt", result.Nodes[0].Metadata["Path"])
+	assert.Equal(t, []string{"", "page2"}, queries)
+}
+
+func Test_StorageFiles_ItemURLs(t *testing.T) {
+	e := &StorageFilesExpander{}
+	item := &TreeNode{Metadata: map[string]string{
+		"FileEndpoint": "https://account.file.core.windows.net/",
+		"ShareName":    "my share",
+		"Path":         "dir one/file#1?.txt",
+	}}
+	assert.Equal(t, "https://account.file.core.windows.net/my%20share/dir%20one/file%231%3F.txt", e.getItemURL(item))
+	assert.Equal(t, e.getItemURL(item), e.getItemURLWithSnapshot(item))
+
+	item.Metadata["ShareSnapshot"] = "2023-01-02T03:04:05.0000000Z"
+	assert.Equal(t, "https://account.file.core.windows.net/my%20share/dir%20one/file%231%3F.txt?sharesnapshot=2023-01-02T03%3A04%3A05.0000000Z", e.getItemURLWithSnapshot(item))
+}
+
+func Test_StorageFiles_ExpandFile(t *testing.T) {
+	content := map[string]string{
+		"/share/text.txt":   "hello",
+		"/share/binary.bin": "\xff\xfe\x00",
+	}
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("x-ms-type", "File")
+		if r.Method == "GET" {
+			_, _ = io.WriteString(w, content[r.URL.Path])
+		}
+	}))
+	defer ts.Close()
+	e := &StorageFilesExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}}
+
+	// Small text files are shown
+	text := newStorageFilesTestItem(ts, storageFilesNodeFile, "text.txt")
+	text.Metadata["ContentLength"] = "5"
+	result := e.Expand(context.Background(), text)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "hello", result.Response.Response)
+	assert.Equal(t, []string{"GET /share/text.txt"}, requests)
+
+	// Binary content falls back to the properties
+	requests = []string{}
+	binary := newStorageFilesTestItem(ts, storageFilesNodeFile, "binary.bin")
+	binary.Metadata["ContentLength"] = "3"
+	result = e.Expand(context.Background(), binary)
+	assert.NoError(t, result.Err)
+	assert.True(t, strings.Contains(result.Response.Response, `"X-Ms-Type":"File"`))
+	assert.Equal(t, []string{"GET /share/binary.bin", "HEAD /share/binary.bin"}, requests)
+
+	// Large files aren't downloaded
+	requests = []string{}
+	large := newStorageFilesTestItem(ts, storageFilesNodeFile, "large.bin")
+	large.Metadata["ContentLength"] = "2000000"
+	result = e.Expand(context.Background(), large)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []string{"HEAD /share/large.bin"}, requests)
+}
+
+func Test_StorageFiles_NoUploadForSnapshots(t *testing.T) {
+	e := &StorageFilesExpander{}
+	actionIDs := func(item *TreeNode) []string {
+		result := e.ListActions(context.Background(), item)
+		assert.NoError(t, result.Err)
+		ids := []string{}
+		for _, node := range result.Nodes {
+			ids = append(ids, node.Metadata["ActionID"])
+		}
+		return ids
+	}
+
+	directory := &TreeNode{ItemType: storageFilesNodeDirectory, Metadata: map[string]string{"ShareName": "share"}}
+	assert.Equal(t, []string{storageFilesActionShowProperties, storageFilesActionUpload}, actionIDs(directory))
+
+	directory.Metadata["ShareSnapshot"] = "2023-01-02T03:04:05.0000000Z"
+	assert.Equal(t, []string{storageFilesActionShowProperties}, actionIDs(directory))
+
+	file := &TreeNode{ItemType: storageFilesNodeFile, Metadata: map[string]string{"ShareSnapshot": "2023-01-02T03:04:05.0000000Z"}}
+	assert.Equal(t, []string{storageFilesActionShowProperties, storageFilesActionDownload}, actionIDs(file))
+}
@@ -0,0 +1,690 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/editor"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+const storageAccountTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Storage/storageAccounts/{accountName}"
+
+// NewStorageQueueExpander creates a new instance of StorageQueueExpander
+func NewStorageQueueExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *StorageQueueExpander {
+	return &StorageQueueExpander{
+		storageSharedKeyClient: newStorageSharedKeyClient(armclient),
+		gui:                    gui,
+		commandPanel:           commandPanel,
+	}
+}
+
+// Check interface
+var _ Expander = &StorageQueueExpander{}
+
+// storageQueueListResponse is a partial representation of the List Queues response
+type storageQueueListResponse struct {
+	XMLName xml.Name `xml:"EnumerationResults"`
+	Queues  []struct {
+		Name string `xml:"Name"`
+	} `xml:"Queues>Queue"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// storageQueueMessage is a message returned from peeking or getting messages
+type storageQueueMessage struct {
+	MessageID       string `xml:"MessageId" json:"messageId"`
+	InsertionTime   string `xml:"InsertionTime" json:"insertionTime"`
+	ExpirationTime  string `xml:"ExpirationTime" json:"expirationTime"`
+	PopReceipt      string `xml:"PopReceipt" json:"-"`
+	TimeNextVisible string `xml:"TimeNextVisible" json:"timeNextVisible,omitempty"`
+	DequeueCount    int    `xml:"DequeueCount" json:"dequeueCount"`
+	MessageText     string `xml:"MessageText" json:"messageText"`
+}
+
+type storageQueueMessagesList struct {
+	XMLName  xml.Name              `xml:"QueueMessagesList"`
+	Messages []storageQueueMessage `xml:"QueueMessage"`
+}
+
+const (
+	storageQueueNodeListQueues = "queue-list"
+	storageQueueNodeQueue      = "queue"
+	storageQueueNodeMessage    = "queue-message"
+)
+
+const (
+	storageQueueActionAddMessage     = "queue-add-message"
+	storageQueueActionDequeueMessage = "queue-dequeue-message"
+	storageQueueActionClearMessages  = "queue-clear-messages"
+	storageQueueActionShowMetadata   = "queue-show-metadata"
+)
+
+// storageQueuePeekCount is the number of messages peeked when expanding a queue (the maximum the API allows)
+const storageQueuePeekCount = 32
+
+// storageQueueMaxMessagesAheadOfDelete is the most messages received (and so have their DequeueCount incremented)
+// to get the pop receipt of a message being deleted
+const storageQueueMaxMessagesAheadOfDelete = 5
+
+func (e *StorageQueueExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// StorageQueueExpander expands the queue data-plane aspects of a Storage Account
+type StorageQueueExpander struct {
+	ExpanderBase
+	storageSharedKeyClient
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+}
+
+// Name returns the name of the expander
+func (e *StorageQueueExpander) Name() string {
+	return "StorageQueueExpander"
+}
+
+// DoesExpand checks if this is a storage account
+func (e *StorageQueueExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "storageQueue" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns queues in the StorageAccount and the messages in them
+func (e *StorageQueueExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "storageQueue" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+		newItems := []*TreeNode{
+			{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<queues>",
+				Namespace:             "storageQueue",
+				Name:                  "Queues",
+				Display:               "Queues",
+				ItemType:              storageQueueNodeListQueues,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"AccountID": currentItem.ID, // save resourceID of the storage account
+				},
+			},
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "StorageQueueExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case storageQueueNodeListQueues:
+		return e.expandQueueList(ctx, currentItem)
+	case storageQueueNodeQueue:
+		return e.expandQueue(ctx, currentItem)
+	case storageQueueNodeMessage:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "StorageQueueExpander request",
+	}
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (e *StorageQueueExpander) Delete(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	if currentItem.ItemType == storageQueueNodeMessage {
+		return e.deleteMessage(ctx, currentItem)
+	}
+	return false, nil
+}
+
+// HasActions returns true for queues
+func (e *StorageQueueExpander) HasActions(context context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == storageQueueNodeQueue, nil
+}
+
+// ListActions returns the actions for working with messages in a queue
+func (e *StorageQueueExpander) ListActions(context context.Context, item *TreeNode) ListActionsResult {
+	if item.ItemType != storageQueueNodeQueue {
+		return ListActionsResult{
+			SourceDescription: "StorageQueueExpander",
+			Err:               fmt.Errorf("ListActions not supported for ItemType %q", item.ItemType),
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, action := range []struct{ id, name string }{
+		{storageQueueActionAddMessage, "Add message"},
+		{storageQueueActionDequeueMessage, "Dequeue message"},
+		{storageQueueActionClearMessages, "Clear messages"},
+		{storageQueueActionShowMetadata, "Show metadata"},
+	} {
+		nodes = append(nodes, &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + action.id,
+			Namespace:              "storageQueue",
+			Name:                   action.name,
+			Display:                action.name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata: map[string]string{
+				"ActionID":      action.id,
+				"AccountName":   item.Metadata["AccountName"],
+				"AccountKey":    item.Metadata["AccountKey"],
+				"QueueEndpoint": item.Metadata["QueueEndpoint"],
+				"QueueName":     item.Metadata["QueueName"],
+			},
+		})
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "StorageQueueExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the queue action
+func (e *StorageQueueExpander) ExecuteAction(context context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case storageQueueActionAddMessage:
+		return e.addMessage(context, item)
+	case storageQueueActionDequeueMessage:
+		return e.dequeueMessage(context, item)
+	case storageQueueActionClearMessages:
+		return e.clearMessages(context, item)
+	case storageQueueActionShowMetadata:
+		return e.showMetadata(context, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "StorageQueueExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "StorageQueueExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *StorageQueueExpander) expandQueueList(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	accountID := currentItem.Metadata["AccountID"]
+	accountName := lastSegment(accountID)
+	accountKey, err := e.getStorageAccountKey(ctx, accountID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting account key: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+		}
+	}
+	account, err := e.getStorageAccount(ctx, accountID)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting queue endpoint: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+		}
+	}
+	queueEndpoint := account.Properties.PrimaryEndpoints.Queue
+	if queueEndpoint == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Storage account %q doesn't have a queue endpoint", accountName),
+			SourceDescription: "StorageQueueExpander request",
+		}
+	}
+
+	// List Queues docs: https://docs.microsoft.com/en-us/rest/api/storageservices/list-queues1
+	listURL := queueEndpoint + "?comp=list&maxresults=50"
+	if marker := currentItem.Metadata["Marker"]; marker != "" {
+		listURL += "&marker=" + url.QueryEscape(marker)
+	}
+	buf, err := e.doRequest(ctx, "GET", listURL, accountName, accountKey, "/"+accountName)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing queues: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+		}
+	}
+
+	response := &storageQueueListResponse{}
+	err = xml.Unmarshal(buf, response)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error Unmarshalling storageQueueListResponse: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+		}
+	}
+
+	// The message count is only returned from the queue metadata so fetch them in parallel
+	messageCounts := make([]string, len(response.Queues))
+	var wg sync.WaitGroup
+	for i, queue := range response.Queues {
+		wg.Add(1)
+		go func(i int, queueName string) {
+			defer wg.Done()
+			headers, err := e.getQueueMetadata(ctx, accountName, accountKey, queueEndpoint, queueName)
+			if err == nil {
+				messageCounts[i] = headers.Get("x-ms-approximate-messages-count")
+			}
+		}(i, queue.Name)
+	}
+	wg.Wait()
+
+	nodes := []*TreeNode{}
+	for i, queue := range response.Queues {
+		display := queue.Name
+		if messageCounts[i] != "" {
+			display += " " + style.Subtle("(~"+messageCounts[i]+" messages)")
+		}
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageQueue",
+			ID:        accountID + "/<queues>/" + queue.Name,
+			Name:      queue.Name,
+			Display:   display,
+			ItemType:  storageQueueNodeQueue,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata: map[string]string{
+				"AccountID":     accountID,
+				"AccountName":   accountName,
+				"AccountKey":    accountKey,
+				"QueueEndpoint": queueEndpoint,
+				"QueueName":     queue.Name,
+			},
+		})
+	}
+	if response.NextMarker != "" {
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "storageQueue",
+			ID:            currentItem.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      storageQueueNodeListQueues,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata: map[string]string{
+				"AccountID": accountID,
+				"Marker":    response.NextMarker,
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: interfaces.ResponseXML},
+		SourceDescription: "StorageQueueExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+// expandQueue peeks the messages at the front of the queue (without changing their visibility)
+func (e *StorageQueueExpander) expandQueue(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	// Peek Messages docs: https://docs.microsoft.com/en-us/rest/api/storageservices/peek-messages
+	messages, err := e.getMessages(ctx, currentItem, fmt.Sprintf("peekonly=true&numofmessages=%d", storageQueuePeekCount))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error peeking messages: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, message := range messages {
+		message.MessageText = decodeQueueMessageText(message.MessageText)
+		content, err := json.MarshalIndent(message, "", "  ")
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error marshaling message: %s", err),
+				SourceDescription: "StorageQueueExpander request",
+			}
+		}
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageQueue",
+			ID:        currentItem.ID + "/" + message.MessageID,
+			Name:      message.MessageID,
+			Display:   message.MessageID + "\n  " + style.Subtle(fmt.Sprintf("Inserted: %s Dequeued: %d", message.InsertionTime, message.DequeueCount)),
+			ItemType:  storageQueueNodeMessage,
+			ExpandURL: ExpandURLNotSupported,
+			DeleteURL: currentItem.ID + "/" + message.MessageID,
+			Metadata: map[string]string{
+				"AccountName":   currentItem.Metadata["AccountName"],
+				"AccountKey":    currentItem.Metadata["AccountKey"],
+				"QueueEndpoint": currentItem.Metadata["QueueEndpoint"],
+				"QueueName":     currentItem.Metadata["QueueName"],
+				"MessageID":     message.MessageID,
+				"Content":       string(content),
+			},
+		})
+	}
+
+	response := fmt.Sprintf("Peeked %d messages (the first %d messages in the queue are shown)", len(messages), storageQueuePeekCount)
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: response, ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageQueueExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageQueueExpander) addMessage(ctx context.Context, item *TreeNode) ExpanderResult {
+	initialContent := "// Enter the message text then save and exit to add it to the queue. The text is base64 encoded when sent. To cancel, leave the message as-is or delete the content\n"
+	content, err := editor.OpenForContent(initialContent, ".txt")
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if strings.HasPrefix(content, "//") {
+		// remove the comment line we added!
+		newLineIndex := strings.Index(content, "\n")
+		content = content[newLineIndex+1:]
+	}
+	if strings.TrimSpace(content) == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var body bytes.Buffer
+	body.WriteString("<QueueMessage><MessageText>")
+	if err = xml.EscapeText(&body, []byte(base64.StdEncoding.EncodeToString([]byte(content)))); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	body.WriteString("</MessageText></QueueMessage>")
+
+	// Put Message docs: https://docs.microsoft.com/en-us/rest/api/storageservices/put-message
+	accountName := item.Metadata["AccountName"]
+	messagesURL := item.Metadata["QueueEndpoint"] + item.Metadata["QueueName"] + "/messages"
+	_, _, err = e.doRequestWithBody(ctx, "POST", messagesURL, accountName, item.Metadata["AccountKey"], map[string]string{headerContentType: "application/xml"}, body.Bytes())
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error adding message: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Message added.", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageQueueExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// dequeueMessage gets the next message from the queue and deletes it
+func (e *StorageQueueExpander) dequeueMessage(ctx context.Context, item *TreeNode) ExpanderResult {
+	if armclient.IsReadOnlyMode() {
+		// Getting the message hides it from other consumers so don't start if it can't be deleted
+		return ExpanderResult{
+			Err:               fmt.Errorf("Read-only mode: messages can't be dequeued"),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	if !e.confirmQueueName(item, "dequeue the next message from") {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Dequeue cancelled as the queue name wasn't confirmed"),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Get Messages docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-messages
+	messages, err := e.getMessages(ctx, item, "numofmessages=1&visibilitytimeout=30")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting message: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if len(messages) == 0 {
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: "The queue is empty.", ResponseType: interfaces.ResponsePlainText},
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	message := messages[0]
+	err = e.deleteMessageWithPopReceipt(ctx, item, message)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error deleting message: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	message.MessageText = decodeQueueMessageText(message.MessageText)
+	content, err := json.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error marshaling message: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(content), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "StorageQueueExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageQueueExpander) clearMessages(ctx context.Context, item *TreeNode) ExpanderResult {
+	if !e.confirmQueueName(item, "delete all messages in") {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Clear cancelled as the queue name wasn't confirmed"),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	// Clear Messages docs: https://docs.microsoft.com/en-us/rest/api/storageservices/clear-messages
+	accountName := item.Metadata["AccountName"]
+	messagesURL := item.Metadata["QueueEndpoint"] + item.Metadata["QueueName"] + "/messages"
+	_, err := e.doRequest(ctx, "DELETE", messagesURL, accountName, item.Metadata["AccountKey"], "/"+accountName+"/"+item.Metadata["QueueName"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error clearing messages: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Messages cleared.", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageQueueExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// confirmQueueName asks the user to type the queue name before a destructive action and returns whether it matched
+func (e *StorageQueueExpander) confirmQueueName(item *TreeNode, description string) bool {
+	queueName := item.Metadata["QueueName"]
+	confirmation, _ := promptInCommandPanel(e.gui, e.commandPanel, fmt.Sprintf("Type %q to %s the queue:", queueName, description), "", nil)
+	return confirmation == queueName
+}
+
+func (e *StorageQueueExpander) showMetadata(ctx context.Context, item *TreeNode) ExpanderResult {
+	headers, err := e.getQueueMetadata(ctx, item.Metadata["AccountName"], item.Metadata["AccountKey"], item.Metadata["QueueEndpoint"], item.Metadata["QueueName"])
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting queue metadata: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	simpleHeaders := map[string]string{}
+	for k := range headers {
+		simpleHeaders[k] = headers.Get(k)
+	}
+	buf, err := json.Marshal(simpleHeaders)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error marshaling queue metadata to JSON: %s", err),
+			SourceDescription: "StorageQueueExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(buf), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "StorageQueueExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// deleteMessage deletes a peeked message. Deleting requires a pop receipt, which peeking doesn't return.
+// The queue is peeked to find how far from the front the message is and then only that many messages are
+// retrieved to get its pop receipt, the messages ahead of it are made visible again but their DequeueCount
+// is still incremented, which can move them to a poison queue (e.g. maxDequeueCount in Functions), so the
+// user confirms this and at most storageQueueMaxMessagesAheadOfDelete messages ahead are received
+func (e *StorageQueueExpander) deleteMessage(ctx context.Context, item *TreeNode) (bool, error) {
+	messageID := item.Metadata["MessageID"]
+	peeked, err := e.getMessages(ctx, item, fmt.Sprintf("peekonly=true&numofmessages=%d", storageQueuePeekCount))
+	if err != nil {
+		return false, fmt.Errorf("Error peeking messages: %s", err)
+	}
+	position := -1
+	for i, message := range peeked {
+		if message.MessageID == messageID {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return false, fmt.Errorf("Message %s is no longer in the first %d messages of the queue", messageID, storageQueuePeekCount)
+	}
+	if position > storageQueueMaxMessagesAheadOfDelete {
+		return false, fmt.Errorf("Message %s has %d messages ahead of it, deleting it would receive them all and increase their dequeue count (at most %d are received)", messageID, position, storageQueueMaxMessagesAheadOfDelete)
+	}
+	if position > 0 {
+		options := []interfaces.CommandPanelListOption{
+			{ID: "cancel", DisplayText: "Cancel"},
+			{ID: "delete", DisplayText: fmt.Sprintf("Receive the %d message(s) ahead and delete", position)},
+		}
+		title := fmt.Sprintf("%d message(s) ahead will have their dequeue count increased, which may move them to a poison queue", position)
+		if _, selected := promptInCommandPanel(e.gui, e.commandPanel, title, "", &options); selected != "delete" {
+			return false, fmt.Errorf("User canceled")
+		}
+	}
+
+	messages, err := e.getMessages(ctx, item, fmt.Sprintf("numofmessages=%d&visibilitytimeout=30", position+1))
+	if err != nil {
+		return false, fmt.Errorf("Error getting messages: %s", err)
+	}
+
+	found := false
+	for _, message := range messages {
+		if message.MessageID == messageID {
+			if err = e.deleteMessageWithPopReceipt(ctx, item, message); err != nil {
+				return false, fmt.Errorf("Error deleting message: %s", err)
+			}
+			found = true
+			continue
+		}
+
+		// Update Message docs: https://docs.microsoft.com/en-us/rest/api/storageservices/update-message
+		accountName := item.Metadata["AccountName"]
+		updateURL := item.Metadata["QueueEndpoint"] + item.Metadata["QueueName"] + "/messages/" + message.MessageID +
+			"?popreceipt=" + url.QueryEscape(message.PopReceipt) + "&visibilitytimeout=0"
+		if _, err = e.doRequest(ctx, "PUT", updateURL, accountName, item.Metadata["AccountKey"], "/"+accountName+"/"+item.Metadata["QueueName"]); err != nil {
+			return false, fmt.Errorf("Error restoring visibility of message %s: %s", message.MessageID, err)
+		}
+	}
+
+	if !found {
+		// Another consumer got the message (or ones ahead of it) between the peek and the get
+		return false, fmt.Errorf("Message %s was not retrieved, it may have been received by another consumer", messageID)
+	}
+	return true, nil
+}
+
+func (e *StorageQueueExpander) deleteMessageWithPopReceipt(ctx context.Context, item *TreeNode, message storageQueueMessage) error {
+	// Delete Message docs: https://docs.microsoft.com/en-us/rest/api/storageservices/delete-message2
+	accountName := item.Metadata["AccountName"]
+	deleteURL := item.Metadata["QueueEndpoint"] + item.Metadata["QueueName"] + "/messages/" + message.MessageID +
+		"?popreceipt=" + url.QueryEscape(message.PopReceipt)
+	_, err := e.doRequest(ctx, "DELETE", deleteURL, accountName, item.Metadata["AccountKey"], "/"+accountName+"/"+item.Metadata["QueueName"])
+	return err
+}
+
+func (e *StorageQueueExpander) getMessages(ctx context.Context, item *TreeNode, query string) ([]storageQueueMessage, error) {
+	accountName := item.Metadata["AccountName"]
+	messagesURL := item.Metadata["QueueEndpoint"] + item.Metadata["QueueName"] + "/messages?" + query
+	buf, err := e.doRequest(ctx, "GET", messagesURL, accountName, item.Metadata["AccountKey"], "/"+accountName+"/"+item.Metadata["QueueName"])
+	if err != nil {
+		return nil, err
+	}
+	response := &storageQueueMessagesList{}
+	err = xml.Unmarshal(buf, response)
+	if err != nil {
+		return nil, fmt.Errorf("Error Unmarshalling storageQueueMessagesList: %s", err)
+	}
+	return response.Messages, nil
+}
+
+func (e *StorageQueueExpander) getQueueMetadata(ctx context.Context, accountName string, accountKey string, queueEndpoint string, queueName string) (http.Header, error) {
+	// Get Queue Metadata docs: https://docs.microsoft.com/en-us/rest/api/storageservices/get-queue-metadata
+	metadataURL := queueEndpoint + queueName + "?comp=metadata"
+	_, headers, err := e.doRequestWithHeadersIncludeResponseHeaders(ctx, "GET", metadataURL, accountName, accountKey, "/"+accountName+"/"+queueName, map[string]string{})
+	return headers, err
+}
+
+// decodeQueueMessageText returns the decoded message if it is base64 encoded text (as the SDKs send by default)
+func decodeQueueMessageText(text string) string {
+	decoded, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || !utf8.Valid(decoded) {
+		return text
+	}
+	return string(decoded)
+}
@@ -0,0 +1,163 @@
+package expanders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeQueueMessageText(t *testing.T) {
+	assert.Equal(t, "hello world", decodeQueueMessageText("aGVsbG8gd29ybGQ="))
+	assert.Equal(t, "not base64!", decodeQueueMessageText("not base64!"))
+	// Binary content isn't decoded
+	assert.Equal(t, "//79", decodeQueueMessageText("//79"))
+}
+
+func Test_StorageQueue_DeleteMessageOnlyGetsMessagesUpToTarget(t *testing.T) {
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		if r.Method != "GET" {
+			return
+		}
+		if r.URL.Query().Get("peekonly") == "true" {
+			_, _ = io.WriteString(w, `<QueueMessagesList>
+				<QueueMessage><MessageId>m1</MessageId></QueueMessage>
+				<QueueMessage><MessageId>m2</MessageId></QueueMessage>
+				<QueueMessage><MessageId>m3</MessageId></QueueMessage>
+			</QueueMessagesList>`)
+			return
+		}
+		messages := `<QueueMessage><MessageId>m1</MessageId><PopReceipt>r1</PopReceipt></QueueMessage>`
+		if r.URL.Query().Get("numofmessages") != "1" {
+			messages += `<QueueMessage><MessageId>m2</MessageId><PopReceipt>r2</PopReceipt></QueueMessage>`
+		}
+		_, _ = io.WriteString(w, "<QueueMessagesList>"+messages+"</QueueMessagesList>")
+	}))
+	defer ts.Close()
+
+	g, commandPanel := newTestPrompt(t)
+	commandPanel.responses = append(commandPanel.responses, interfaces.CommandPanelNotification{SelectedID: "delete", EnterPressed: true})
+	e := &StorageQueueExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+	message := &TreeNode{
+		Metadata: map[string]string{
+			"AccountName":   "account",
+			"AccountKey":    "a2V5",
+			"QueueEndpoint": ts.URL + "/",
+			"QueueName":     "queue",
+			"MessageID":     "m2",
+		},
+	}
+
+	deleted, err := e.deleteMessage(context.Background(), message)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []string{
+		"GET /queue/messages?peekonly=true&numofmessages=32",
+		"GET /queue/messages?numofmessages=2&visibilitytimeout=30",
+		"PUT /queue/messages/m1?popreceipt=r1&visibilitytimeout=0",
+		"DELETE /queue/messages/m2?popreceipt=r2",
+	}, requests)
+
+	assert.Equal(t, []string{"1 message(s) ahead will have their dequeue count increased, which may move them to a poison queue"}, commandPanel.titles)
+
+	// Cancelling the prompt doesn't get any messages
+	requests = []string{}
+	_, err = e.deleteMessage(context.Background(), message)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"GET /queue/messages?peekonly=true&numofmessages=32"}, requests)
+
+	// The first message is deleted without a prompt as no other message is received
+	requests = []string{}
+	commandPanel.titles = nil
+	message.Metadata["MessageID"] = "m1"
+	deleted, err = e.deleteMessage(context.Background(), message)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Empty(t, commandPanel.titles)
+	assert.Equal(t, []string{
+		"GET /queue/messages?peekonly=true&numofmessages=32",
+		"GET /queue/messages?numofmessages=1&visibilitytimeout=30",
+		"DELETE /queue/messages/m1?popreceipt=r1",
+	}, requests)
+
+	// A message beyond the peeked messages is refused without getting any messages
+	requests = []string{}
+	message.Metadata["MessageID"] = "m99"
+	_, err = e.deleteMessage(context.Background(), message)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"GET /queue/messages?peekonly=true&numofmessages=32"}, requests)
+}
+
+func Test_StorageQueue_DeleteMessageRefusesTooManyMessagesAhead(t *testing.T) {
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		messages := ""
+		for i := 0; i <= storageQueueMaxMessagesAheadOfDelete+1; i++ {
+			messages += fmt.Sprintf("<QueueMessage><MessageId>m%d</MessageId></QueueMessage>", i)
+		}
+		_, _ = io.WriteString(w, "<QueueMessagesList>"+messages+"</QueueMessagesList>")
+	}))
+	defer ts.Close()
+
+	g, commandPanel := newTestPrompt(t)
+	e := &StorageQueueExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+	message := &TreeNode{
+		Metadata: map[string]string{
+			"AccountName":   "account",
+			"AccountKey":    "a2V5",
+			"QueueEndpoint": ts.URL + "/",
+			"QueueName":     "queue",
+			"MessageID":     fmt.Sprintf("m%d", storageQueueMaxMessagesAheadOfDelete+1),
+		},
+	}
+
+	_, err := e.deleteMessage(context.Background(), message)
+	assert.Error(t, err)
+	assert.Empty(t, commandPanel.titles)
+	assert.Equal(t, []string{"GET /queue/messages?peekonly=true&numofmessages=32"}, requests)
+}
+
+func Test_StorageQueue_DestructiveActionsNeedQueueNameConfirmed(t *testing.T) {
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer ts.Close()
+
+	newAction := func(actionID string) *TreeNode {
+		return &TreeNode{
+			Metadata: map[string]string{
+				"ActionID":      actionID,
+				"AccountName":   "account",
+				"AccountKey":    "a2V5",
+				"QueueEndpoint": ts.URL + "/",
+				"QueueName":     "queue",
+			},
+		}
+	}
+
+	for _, actionID := range []string{storageQueueActionClearMessages, storageQueueActionDequeueMessage} {
+		// Closing the prompt or typing the wrong name sends nothing
+		for _, responses := range [][]string{{}, {"other-queue"}} {
+			g, commandPanel := newTestPrompt(t, responses...)
+			e := &StorageQueueExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+			result := e.ExecuteAction(context.Background(), newAction(actionID))
+			assert.Error(t, result.Err)
+			assert.Empty(t, requests)
+		}
+	}
+
+	g, commandPanel := newTestPrompt(t, "queue")
+	e := &StorageQueueExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+	result := e.ExecuteAction(context.Background(), newAction(storageQueueActionClearMessages))
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []string{"DELETE /queue/messages"}, requests)
+}
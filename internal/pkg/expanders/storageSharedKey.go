@@ -0,0 +1,516 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/tracing"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// StorageListKeyResponse is used to unmarshal a call to listKeys on a storage account
+type StorageListKeyResponse struct {
+	Keys []struct {
+		KeyName     string `json:"keyName"`
+		Value       string `json:"value"`
+		Permissions string `json:"permissions"`
+	} `json:"keys"`
+}
+
+// StorageAccountResponse is a partial representation of the storage account response
+type StorageAccountResponse struct {
+	Properties struct {
+		PrimaryEndpoints struct {
+			Blob  string `json:"blob"`
+			Dfs   string `json:"dfs"`
+			Queue string `json:"queue"`
+			Table string `json:"table"`
+			File  string `json:"file"`
+		} `json:"primaryEndpoints"`
+	} `json:"properties"`
+}
+
+// storageSharedKeyClient makes requests to the Storage Account data-plane APIs using SharedKey auth.
+// It is shared by the expanders for blobs, queues, tables and files
+type storageSharedKeyClient struct {
+	client    *http.Client
+	armClient *armclient.Client
+}
+
+func newStorageSharedKeyClient(armclient *armclient.Client) storageSharedKeyClient {
+	return storageSharedKeyClient{
+		client:    &http.Client{},
+		armClient: armclient,
+	}
+}
+
+// getStorageAccountKey gets the first key for the storage account with the given resource ID
+func (c *storageSharedKeyClient) getStorageAccountKey(ctx context.Context, accountID string) (string, error) {
+	listKeysURL := accountID + "/listKeys?api-version=2019-06-01"
+
+	data, err := c.armClient.DoRequest(ctx, "POST", listKeysURL)
+	if err != nil {
+		return "", fmt.Errorf("Error calling listKeys: %s", err)
+	}
+	response := StorageListKeyResponse{}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		err = fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, listKeysURL)
+		return "", err
+	}
+	if len(response.Keys) == 0 {
+		err = fmt.Errorf("No keys in response: %s", err)
+		return "", err
+	}
+
+	return response.Keys[0].Value, nil
+}
+
+// getStorageAccount gets the storage account with the given resource ID
+func (c *storageSharedKeyClient) getStorageAccount(ctx context.Context, accountID string) (*StorageAccountResponse, error) {
+	storageAccountURL := accountID + "?api-version=2019-06-01"
+
+	data, err := c.armClient.DoRequest(ctx, "GET", storageAccountURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting storage account: %s", err)
+	}
+	response := StorageAccountResponse{}
+	err = json.Unmarshal([]byte(data), &response)
+	if err != nil {
+		err = fmt.Errorf("Error unmarshalling response: %s\nURL:%s", err, storageAccountURL)
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (c *storageSharedKeyClient) doRequest(ctx context.Context, verb string, url string, accountName string, accountKey string, accountAndPath string) ([]byte, error) {
+	return c.doRequestWithHeaders(ctx, verb, url, accountName, accountKey, accountAndPath, map[string]string{})
+}
+func (c *storageSharedKeyClient) doRequestWithHeaders(ctx context.Context, verb string, url string, accountName string, accountKey string, accountAndPath string, headers map[string]string) ([]byte, error) {
+	buf, _, err := c.doRequestWithHeadersIncludeResponseHeaders(ctx, verb, url, accountName, accountKey, accountAndPath, headers)
+	return buf, err
+}
+func (c *storageSharedKeyClient) doRequestWithHeadersIncludeResponseHeaders(ctx context.Context, verb string, url string, accountName string, accountKey string, accountAndPath string, headers map[string]string) ([]byte, http.Header, error) {
+	return c.doRequestWithBody(ctx, verb, url, accountName, accountKey, headers, nil)
+}
+func (c *storageSharedKeyClient) doRequestWithBody(ctx context.Context, verb string, url string, accountName string, accountKey string, headers map[string]string, body []byte) ([]byte, http.Header, error) {
+	response, err := c.doRequestForResponse(ctx, verb, url, accountName, accountKey, headers, body)
+	if err != nil {
+		return []byte{}, nil, err
+	}
+
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return []byte{}, nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+
+	buf = c.stripBOM(buf)
+
+	return buf, response.Header, nil
+}
+
+// doRequestForResponse makes the request and returns the response so that the body can be streamed.
+// The caller must close the response body
+func (c *storageSharedKeyClient) doRequestForResponse(ctx context.Context, verb string, url string, accountName string, accountKey string, headers map[string]string, body []byte) (*http.Response, error) {
+
+	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(storage):"+url, tracing.SetTag("url", url))
+	defer span.Finish()
+
+	req, err := http.NewRequest(verb, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	if len(body) > 0 {
+		// Content-Length is part of the string to sign so needs to be in the headers
+		req.Header.Set(headerContentLength, strconv.Itoa(len(body)))
+	}
+	if req.Header.Get("x-ms-version") == "" {
+		req.Header.Set("x-ms-version", "2018-03-28")
+	}
+	dateString := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("x-ms-date", dateString)
+
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+
+	err = c.addAuthHeader(req, accountName, accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to add auth header: %s", err)
+	}
+
+	response, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		response.Body.Close() //nolint: errcheck
+		return nil, &storageRequestError{StatusCode: response.StatusCode, Status: response.Status, URL: url}
+	}
+
+	return response, nil
+}
+
+// storageRequestError is returned when a storage request gets an unsuccessful status code
+type storageRequestError struct {
+	StatusCode int
+	Status     string
+	URL        string
+}
+
+func (e *storageRequestError) Error() string {
+	return fmt.Sprintf("DoRequest failed %v for '%s'", e.Status, e.URL)
+}
+
+// isStorageRequestStatus returns true if the error is from a storage request which returned the status code
+func isStorageRequestStatus(err error, statusCode int) bool {
+	var requestError *storageRequestError
+	return errors.As(err, &requestError) && requestError.StatusCode == statusCode
+}
+
+// downloadToFile streams the content at url to localPath in the background, showing progress in the status bar
+func (c *storageSharedKeyClient) downloadToFile(url string, accountName string, accountKey string, localPath string) {
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
+			InProgress: true,
+			IsToast:    true,
+			Message:    "Downloading to " + localPath,
+			Timeout:    time.Hour,
+		})
+		written, err := c.download(url, accountName, accountKey, localPath, event)
+		event.InProgress = false
+		event.SetTimeout(time.Second * 15)
+		if err != nil {
+			event.Failure = true
+			event.Message = "Failed to download to " + localPath + ": " + err.Error()
+		} else {
+			event.Message = fmt.Sprintf("Downloaded %s to %s", formatBytes(written), localPath)
+		}
+		event.Update()
+	}()
+}
+
+func (c *storageSharedKeyClient) download(url string, accountName string, accountKey string, localPath string, event *eventing.StatusEvent) (int64, error) {
+	response, err := c.doRequestForResponse(context.Background(), "GET", url, accountName, accountKey, map[string]string{}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close() //nolint: errcheck
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return 0, err
+	}
+
+	progress := &transferProgress{
+		event:   event,
+		message: "Downloading to " + localPath,
+		total:   response.ContentLength,
+	}
+	written, err := io.Copy(file, io.TeeReader(response.Body, progress))
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Don't leave a truncated file behind which could be mistaken for the full download
+		os.Remove(localPath) //nolint: errcheck
+		return written, err
+	}
+	return written, nil
+}
+
+// transferProgress updates a status event with the number of bytes transferred
+type transferProgress struct {
+	event       *eventing.StatusEvent
+	message     string
+	total       int64
+	transferred int64
+	lastUpdate  time.Time
+}
+
+func (p *transferProgress) Write(buf []byte) (int, error) {
+	p.add(int64(len(buf)))
+	return len(buf), nil
+}
+
+func (p *transferProgress) add(count int64) {
+	p.transferred += count
+	if time.Since(p.lastUpdate) < time.Second {
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.event.Message = p.message + " (" + formatBytes(p.transferred)
+	if p.total > 0 {
+		p.event.Message += fmt.Sprintf(" of %s, %d%%", formatBytes(p.total), p.transferred*100/p.total)
+	}
+	p.event.Message += ")"
+	p.event.Update()
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func (c *storageSharedKeyClient) stripBOM(buf []byte) []byte {
+	if len(buf) < 3 {
+		return buf
+	}
+	if buf[0] == 0xEF && buf[1] == 0xBB && buf[2] == 0xBF {
+		return buf[3:]
+	}
+	return buf
+}
+
+// doTableRequest makes a request to the Table service (or the Cosmos DB Table API), returning the status code
+// rather than an error for unsuccessful responses so that callers can handle conflicts etc
+func (c *storageSharedKeyClient) doTableRequest(ctx context.Context, verb string, url string, accountName string, accountKey string, headers map[string]string, body []byte) (*doRequestResponse, error) {
+
+	span, _ := tracing.StartSpanFromContext(ctx, "doRequest(table):"+url, tracing.SetTag("url", url))
+	defer span.Finish()
+
+	req, err := http.NewRequest(verb, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %s", err)
+	}
+	req.Header.Set("x-ms-version", "2019-02-02")
+	req.Header.Set(headerXmsDate, time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Accept", "application/json;odata=minimalmetadata")
+	req.Header.Set("DataServiceVersion", "3.0;NetFx")
+	req.Header.Set("MaxDataServiceVersion", "3.0;NetFx")
+	if len(body) > 0 {
+		req.Header.Set(headerContentType, "application/json")
+	}
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+
+	err = c.addTableAuthHeader(req, accountName, accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to add auth header: %s", err)
+	}
+
+	response, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body: %s", err)
+	}
+
+	return &doRequestResponse{
+		Data:       c.stripBOM(buf),
+		StatusCode: response.StatusCode,
+		Headers:    response.Header,
+	}, nil
+}
+
+// addTableAuthHeader signs the request using the Table service variant of SharedKey
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#table-service-shared-key-authorization
+func (c *storageSharedKeyClient) addTableAuthHeader(request *http.Request, accountName string, accountKey string) error {
+	canonicalizedResource := "/" + accountName + request.URL.EscapedPath()
+	if comp := request.URL.Query().Get("comp"); comp != "" {
+		canonicalizedResource += "?comp=" + comp
+	}
+	stringToSign := strings.Join([]string{
+		request.Method,
+		request.Header.Get(headerContentMD5),
+		request.Header.Get(headerContentType),
+		request.Header.Get(headerXmsDate),
+		canonicalizedResource,
+	}, "\n")
+
+	signature, err := c.ComputeHMACSHA256(stringToSign, accountKey)
+	if err != nil {
+		return fmt.Errorf("Failed to compute signature: %s", err)
+	}
+	request.Header[headerAuthorization] = []string{"SharedKey " + accountName + ":" + signature}
+	return nil
+}
+
+// Auth helper code based on https://github.com/Azure/azure-storage-blob-go
+// (https://github.com/Azure/azure-storage-blob-go/blob/3efca72bd11c050222deab57e25ea90df03b9692/azblob/zc_credential_shared_key.go#L55)
+func (c *storageSharedKeyClient) addAuthHeader(request *http.Request, accountName string, accountKey string) error {
+
+	// Add a x-ms-date header if it doesn't already exist
+	if d := request.Header.Get(headerXmsDate); d == "" {
+		request.Header[headerXmsDate] = []string{time.Now().UTC().Format(http.TimeFormat)}
+	}
+	stringToSign, err := c.buildStringToSign(request, accountName)
+	if err != nil {
+		return fmt.Errorf("Failed to build string to sign: %s", err)
+	}
+	signature, err := c.ComputeHMACSHA256(stringToSign, accountKey)
+	if err != nil {
+		return fmt.Errorf("Failed to compute signature: %s", err)
+	}
+	authHeader := strings.Join([]string{"SharedKey ", accountName, ":", signature}, "")
+	request.Header[headerAuthorization] = []string{authHeader}
+	return nil
+}
+
+// Constants ensuring that header names are correctly spelled and consistently cased.
+const (
+	headerAuthorization     = "Authorization"
+	headerContentEncoding   = "Content-Encoding"
+	headerContentLanguage   = "Content-Language"
+	headerContentLength     = "Content-Length"
+	headerContentMD5        = "Content-MD5"
+	headerContentType       = "Content-Type"
+	headerIfMatch           = "If-Match"
+	headerIfModifiedSince   = "If-Modified-Since"
+	headerIfNoneMatch       = "If-None-Match"
+	headerIfUnmodifiedSince = "If-Unmodified-Since"
+	headerRange             = "Range"
+	headerXmsDate           = "x-ms-date"
+)
+
+// ComputeHMACSHA256 generates a hash signature for an HTTP request or for a SAS.
+func (c *storageSharedKeyClient) ComputeHMACSHA256(message string, accountKey string) (string, error) {
+	bytes, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode storage account key: %s", err)
+	}
+	h := hmac.New(sha256.New, bytes)
+	_, err = h.Write([]byte(message))
+	if err != nil {
+		return "", fmt.Errorf("Failed to write bytes: %s", err)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *storageSharedKeyClient) buildStringToSign(request *http.Request, accountName string) (string, error) {
+	// https://docs.microsoft.com/en-us/rest/api/storageservices/authentication-for-the-azure-storage-services
+	headers := request.Header
+	contentLength := headers.Get(headerContentLength)
+	if contentLength == "0" {
+		contentLength = ""
+	}
+
+	canonicalizedResource, err := c.buildCanonicalizedResource(request.URL, accountName)
+	if err != nil {
+		return "", err
+	}
+
+	stringToSign := strings.Join([]string{
+		request.Method,
+		headers.Get(headerContentEncoding),
+		headers.Get(headerContentLanguage),
+		contentLength,
+		headers.Get(headerContentMD5),
+		headers.Get(headerContentType),
+		"", // Empty date because x-ms-date is expected (as per web page above)
+		headers.Get(headerIfModifiedSince),
+		headers.Get(headerIfMatch),
+		headers.Get(headerIfNoneMatch),
+		headers.Get(headerIfUnmodifiedSince),
+		headers.Get(headerRange),
+		buildCanonicalizedHeader(headers),
+		canonicalizedResource,
+	}, "\n")
+	return stringToSign, nil
+}
+
+func buildCanonicalizedHeader(headers http.Header) string {
+	cm := map[string][]string{}
+	for k, v := range headers {
+		headerName := strings.TrimSpace(strings.ToLower(k))
+		if strings.HasPrefix(headerName, "x-ms-") {
+			cm[headerName] = v // NOTE: the value must not have any whitespace around it.
+		}
+	}
+	if len(cm) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(cm))
+	for key := range cm {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ch := bytes.NewBufferString("")
+	for i, key := range keys {
+		if i > 0 {
+			ch.WriteRune('\n')
+		}
+		ch.WriteString(key)
+		ch.WriteRune(':')
+		ch.WriteString(strings.Join(cm[key], ","))
+	}
+	return ch.String()
+}
+
+func (c *storageSharedKeyClient) buildCanonicalizedResource(u *url.URL, accountName string) (string, error) {
+	// https://docs.microsoft.com/en-us/rest/api/storageservices/authentication-for-the-azure-storage-services
+	cr := bytes.NewBufferString("/")
+	cr.WriteString(accountName)
+
+	if len(u.Path) > 0 {
+		// Any portion of the CanonicalizedResource string that is derived from
+		// the resource's URI should be encoded exactly as it is in the URI.
+		// -- https://msdn.microsoft.com/en-gb/library/azure/dd179428.aspx
+		cr.WriteString(u.EscapedPath())
+	} else {
+		// a slash is required to indicate the root path
+		cr.WriteString("/")
+	}
+
+	// params is a map[string][]string; param name is key; params values is []string
+	params, err := url.ParseQuery(u.RawQuery) // Returns URL decoded values
+	if err != nil {
+		return "", errors.New("parsing query parameters must succeed, otherwise there might be serious problems in the SDK/generated code")
+	}
+
+	if len(params) > 0 { // There is at least 1 query parameter
+		paramNames := []string{} // We use this to sort the parameter key names
+		for paramName := range params {
+			paramNames = append(paramNames, paramName) // paramNames must be lowercase
+		}
+		sort.Strings(paramNames)
+
+		for _, paramName := range paramNames {
+			paramValues := params[paramName]
+			sort.Strings(paramValues)
+
+			// Join the sorted key values separated by ','
+			// Then prepend "keyName:"; then add this string to the buffer
+			cr.WriteString("\n" + paramName + ":" + strings.Join(paramValues, ","))
+		}
+	}
+	return cr.String(), nil
+}
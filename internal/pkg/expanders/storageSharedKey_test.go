@@ -0,0 +1,36 @@
+package expanders
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_formatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.0 KiB", formatBytes(1024))
+	assert.Equal(t, "1.5 MiB", formatBytes(1536*1024))
+	assert.Equal(t, "2.0 GiB", formatBytes(2*1024*1024*1024))
+}
+
+func Test_download_RemovesTruncatedFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Claim more content than is sent so the copy fails part way through
+		w.Header().Set("Content-Length", "100")
+		_, _ = io.WriteString(w, "partial")
+	}))
+	defer ts.Close()
+
+	c := storageSharedKeyClient{client: ts.Client()}
+	localPath := filepath.Join(t.TempDir(), "download.txt")
+	_, err := c.download(ts.URL+"/container/blob", "account", "a2V5", localPath, &eventing.StatusEvent{})
+	assert.Error(t, err)
+	_, err = os.Stat(localPath)
+	assert.True(t, os.IsNotExist(err))
+}
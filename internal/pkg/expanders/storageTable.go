@@ -0,0 +1,618 @@
+package expanders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/editor"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const cosmosTableTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.DocumentDB/databaseAccounts/{accountName}/tables/{tableName}"
+
+// NewStorageTableExpander creates a new instance of StorageTableExpander
+func NewStorageTableExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel) *StorageTableExpander {
+	return &StorageTableExpander{
+		storageSharedKeyClient: newStorageSharedKeyClient(armclient),
+		gui:                    gui,
+		commandPanel:           commandPanel,
+	}
+}
+
+// Check interface
+var _ Expander = &StorageTableExpander{}
+
+const (
+	storageTableNodeListTables   = "table-list"
+	storageTableNodeListEntities = "table-entity-list"
+	storageTableNodeEntity       = "table-entity"
+)
+
+const (
+	storageTableActionFilter      = "table-filter"
+	storageTableActionClearFilter = "table-clear-filter"
+	storageTableActionAddEntity   = "table-add-entity"
+)
+
+// storageTablePageSize is the number of entities requested per page
+const storageTablePageSize = 50
+
+func (e *StorageTableExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// StorageTableExpander expands the table data-plane aspects of a Storage Account and Cosmos DB Table API accounts
+type StorageTableExpander struct {
+	ExpanderBase
+	storageSharedKeyClient
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+}
+
+// Name returns the name of the expander
+func (e *StorageTableExpander) Name() string {
+	return "StorageTableExpander"
+}
+
+// DoesExpand checks if this is a storage account or a Cosmos DB table
+func (e *StorageTableExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == storageAccountTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.ItemType == SubResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == cosmosTableTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "storageTable" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the tables and entities
+func (e *StorageTableExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "storageTable" && swaggerResourceType != nil {
+		newItems := []*TreeNode{}
+		switch swaggerResourceType.Endpoint.TemplateURL {
+		case storageAccountTemplateURL:
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<tables>",
+				Namespace:             "storageTable",
+				Name:                  "Tables",
+				Display:               "Tables",
+				ItemType:              storageTableNodeListTables,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"AccountID": currentItem.ID, // save resourceID of the storage account
+				},
+			})
+		case cosmosTableTemplateURL:
+			matchResult := swaggerResourceType.Endpoint.Match(currentItem.ID)
+			tablesIndex := strings.Index(currentItem.ID, "/tables/")
+			if !matchResult.IsMatch || tablesIndex < 0 {
+				return ExpanderResult{
+					Err:               fmt.Errorf("Endpoint should match"),
+					SourceDescription: "StorageTableExpander request",
+				}
+			}
+			newItems = append(newItems, &TreeNode{
+				Parentid:              currentItem.ID,
+				ID:                    currentItem.ID + "/<entities>",
+				Namespace:             "storageTable",
+				Name:                  "Entities",
+				Display:               "Entities",
+				ItemType:              storageTableNodeListEntities,
+				ExpandURL:             ExpandURLNotSupported,
+				SuppressSwaggerExpand: true,
+				SuppressGenericExpand: true,
+				Metadata: map[string]string{
+					"CosmosAccountName": matchResult.Values["accountName"],
+					"CosmosAccountID":   currentItem.ID[:tablesIndex],
+					"TableName":         matchResult.Values["tableName"],
+				},
+			})
+		}
+
+		return ExpanderResult{
+			Err:               nil,
+			Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+			SourceDescription: "StorageTableExpander request",
+			Nodes:             newItems,
+			IsPrimaryResponse: false,
+		}
+	}
+
+	switch currentItem.ItemType {
+	case storageTableNodeListTables:
+		return e.expandTableList(ctx, currentItem)
+	case storageTableNodeListEntities:
+		return e.expandEntityList(ctx, currentItem, currentItem.Metadata["Filter"])
+	case storageTableNodeEntity:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "StorageTableExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "StorageTableExpander request",
+	}
+}
+
+// CanUpdate indicates if the item can be updated
+func (e *StorageTableExpander) CanUpdate(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == storageTableNodeEntity, nil
+}
+
+// Update replaces the entity, failing if it has been changed since it was loaded
+func (e *StorageTableExpander) Update(ctx context.Context, item *TreeNode, updatedContent string) error {
+	if item.ItemType != storageTableNodeEntity {
+		return fmt.Errorf("Unsupported item type: %s", item.ItemType)
+	}
+
+	var entity map[string]interface{}
+	if err := json.Unmarshal([]byte(updatedContent), &entity); err != nil {
+		return fmt.Errorf("Error parsing entity: %s", err)
+	}
+	if entity["PartitionKey"] != item.Metadata["PartitionKey"] || entity["RowKey"] != item.Metadata["RowKey"] {
+		return fmt.Errorf("PartitionKey and RowKey can't be changed, use the 'Add entity' action to create a new entity")
+	}
+
+	// Update Entity docs: https://docs.microsoft.com/en-us/rest/api/storageservices/update-entity2
+	response, err := e.doTableRequest(ctx, "PUT", e.getEntityURL(item), item.Metadata["AccountName"], item.Metadata["AccountKey"],
+		map[string]string{headerIfMatch: item.Metadata["ETag"]}, []byte(updatedContent))
+	if err != nil {
+		return fmt.Errorf("Error updating entity: %s", err)
+	}
+	if response.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("The entity has been changed since it was loaded, refresh and try again")
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Error updating entity. StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	return nil
+}
+
+// Delete attempts to delete the item. Returns true if deleted, false if not handled, an error if an error occurred attempting to delete
+func (e *StorageTableExpander) Delete(ctx context.Context, item *TreeNode) (bool, error) {
+	if item.ItemType != storageTableNodeEntity {
+		return false, nil
+	}
+
+	// Delete Entity docs: https://docs.microsoft.com/en-us/rest/api/storageservices/delete-entity1
+	response, err := e.doTableRequest(ctx, "DELETE", e.getEntityURL(item), item.Metadata["AccountName"], item.Metadata["AccountKey"],
+		map[string]string{headerIfMatch: item.Metadata["ETag"]}, nil)
+	if err != nil {
+		return false, fmt.Errorf("Error deleting entity: %s", err)
+	}
+	if response.StatusCode == http.StatusPreconditionFailed {
+		return false, fmt.Errorf("The entity has been changed since it was loaded, refresh and try again")
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return false, fmt.Errorf("Error deleting entity. StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	return true, nil
+}
+
+// HasActions returns true for entity lists
+func (e *StorageTableExpander) HasActions(context context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == storageTableNodeListEntities, nil
+}
+
+// ListActions returns the actions for filtering and adding entities
+func (e *StorageTableExpander) ListActions(context context.Context, item *TreeNode) ListActionsResult {
+	if item.ItemType != storageTableNodeListEntities {
+		return ListActionsResult{
+			SourceDescription: "StorageTableExpander",
+			Err:               fmt.Errorf("ListActions not supported for ItemType %q", item.ItemType),
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, action := range []struct{ id, name string }{
+		{storageTableActionFilter, "Filter entities"},
+		{storageTableActionClearFilter, "Clear filter"},
+		{storageTableActionAddEntity, "Add entity"},
+	} {
+		metadata := map[string]string{
+			"ActionID": action.id,
+		}
+		for k, v := range item.Metadata {
+			if k != "NextPartitionKey" && k != "NextRowKey" {
+				metadata[k] = v
+			}
+		}
+		nodes = append(nodes, &TreeNode{
+			Parentid:               item.ID,
+			ID:                     item.ID + "?" + action.id,
+			Namespace:              "storageTable",
+			Name:                   action.name,
+			Display:                action.name,
+			ItemType:               ActionType,
+			SuppressGenericExpand:  true,
+			TimeoutOverrideSeconds: promptTimeout(),
+			Metadata:               metadata,
+		})
+	}
+	return ListActionsResult{
+		Nodes:             nodes,
+		SourceDescription: "StorageTableExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction runs the table action
+func (e *StorageTableExpander) ExecuteAction(context context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case storageTableActionFilter:
+		filter, _ := promptInCommandPanel(e.gui, e.commandPanel, "OData filter (e.g. PartitionKey eq 'abc'):", item.Metadata["Filter"], nil)
+		if filter == "" {
+			return ExpanderResult{
+				Err:               fmt.Errorf("User canceled"),
+				SourceDescription: "StorageTableExpander",
+				IsPrimaryResponse: true,
+			}
+		}
+		return e.expandEntityList(context, item, filter)
+	case storageTableActionClearFilter:
+		return e.expandEntityList(context, item, "")
+	case storageTableActionAddEntity:
+		return e.addEntity(context, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "StorageTableExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "StorageTableExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *StorageTableExpander) expandTableList(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	if err := e.ensureConnectionDetails(ctx, currentItem); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageTableExpander request",
+		}
+	}
+
+	// Query Tables docs: https://docs.microsoft.com/en-us/rest/api/storageservices/query-tables
+	listURL := currentItem.Metadata["TableEndpoint"] + "Tables"
+	if nextTableName := currentItem.Metadata["NextTableName"]; nextTableName != "" {
+		listURL += "?NextTableName=" + url.QueryEscape(nextTableName)
+	}
+	response, err := e.doTableRequest(ctx, "GET", listURL, currentItem.Metadata["AccountName"], currentItem.Metadata["AccountKey"], map[string]string{}, nil)
+	if err == nil && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		err = fmt.Errorf("StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing tables: %s", err),
+			SourceDescription: "StorageTableExpander request",
+		}
+	}
+
+	var tables struct {
+		Value []struct {
+			TableName string `json:"TableName"`
+		} `json:"value"`
+	}
+	if err = json.Unmarshal(response.Data, &tables); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling tables: %s", err),
+			SourceDescription: "StorageTableExpander request",
+		}
+	}
+
+	nodes := []*TreeNode{}
+	for _, table := range tables.Value {
+		metadata := e.connectionMetadata(currentItem)
+		metadata["TableName"] = table.TableName
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageTable",
+			ID:        currentItem.Metadata["AccountID"] + "/<tables>/" + table.TableName,
+			Name:      table.TableName,
+			Display:   table.TableName,
+			ItemType:  storageTableNodeListEntities,
+			ExpandURL: ExpandURLNotSupported,
+			Metadata:  metadata,
+		})
+	}
+	if nextTableName := response.Headers.Get("x-ms-continuation-NextTableName"); nextTableName != "" {
+		metadata := e.connectionMetadata(currentItem)
+		metadata["NextTableName"] = nextTableName
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "storageTable",
+			ID:            currentItem.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      storageTableNodeListTables,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: string(response.Data), ResponseType: interfaces.ResponseJSON},
+		SourceDescription: "StorageTableExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageTableExpander) expandEntityList(ctx context.Context, currentItem *TreeNode, filter string) ExpanderResult {
+	if err := e.ensureConnectionDetails(ctx, currentItem); err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageTableExpander request",
+		}
+	}
+
+	// Query Entities docs: https://docs.microsoft.com/en-us/rest/api/storageservices/query-entities
+	tableName := currentItem.Metadata["TableName"]
+	query := url.Values{}
+	query.Set("$top", fmt.Sprintf("%d", storageTablePageSize))
+	if filter != "" {
+		query.Set("$filter", filter)
+	}
+	if nextPartitionKey := currentItem.Metadata["NextPartitionKey"]; nextPartitionKey != "" {
+		query.Set("NextPartitionKey", nextPartitionKey)
+		if nextRowKey := currentItem.Metadata["NextRowKey"]; nextRowKey != "" {
+			query.Set("NextRowKey", nextRowKey)
+		}
+	}
+	listURL := currentItem.Metadata["TableEndpoint"] + tableName + "()?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+	response, err := e.doTableRequest(ctx, "GET", listURL, currentItem.Metadata["AccountName"], currentItem.Metadata["AccountKey"], map[string]string{}, nil)
+	if err == nil && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		err = fmt.Errorf("StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error querying entities: %s", err),
+			SourceDescription: "StorageTableExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	var entities struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	if err = json.Unmarshal(response.Data, &entities); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling entities: %s", err),
+			SourceDescription: "StorageTableExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	accountID := currentItem.Metadata["AccountID"]
+	if accountID == "" {
+		accountID = currentItem.Metadata["CosmosAccountID"]
+	}
+	nodes := []*TreeNode{}
+	for _, entity := range entities.Value {
+		etag, _ := entity["odata.etag"].(string)
+		delete(entity, "odata.etag")
+		partitionKey, _ := entity["PartitionKey"].(string)
+		rowKey, _ := entity["RowKey"].(string)
+		content, err := json.MarshalIndent(entity, "", "  ")
+		if err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error marshaling entity: %s", err),
+				SourceDescription: "StorageTableExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+
+		metadata := e.connectionMetadata(currentItem)
+		metadata["TableName"] = tableName
+		metadata["PartitionKey"] = partitionKey
+		metadata["RowKey"] = rowKey
+		metadata["ETag"] = etag
+		metadata["Content"] = string(content)
+		id := accountID + "/<tables>/" + tableName + "/" + partitionKey + "/" + rowKey
+		nodes = append(nodes, &TreeNode{
+			Parentid:  currentItem.ID,
+			Namespace: "storageTable",
+			ID:        id,
+			Name:      rowKey,
+			Display:   rowKey + "\n  " + style.Subtle("PartitionKey: "+partitionKey),
+			ItemType:  storageTableNodeEntity,
+			ExpandURL: ExpandURLNotSupported,
+			DeleteURL: id,
+			Metadata:  metadata,
+		})
+	}
+
+	if nextPartitionKey := response.Headers.Get("x-ms-continuation-NextPartitionKey"); nextPartitionKey != "" {
+		metadata := e.connectionMetadata(currentItem)
+		metadata["TableName"] = tableName
+		metadata["Filter"] = filter
+		metadata["NextPartitionKey"] = nextPartitionKey
+		metadata["NextRowKey"] = response.Headers.Get("x-ms-continuation-NextRowKey")
+		nodes = append(nodes, &TreeNode{
+			Parentid:      currentItem.ID,
+			Namespace:     "storageTable",
+			ID:            currentItem.ID + "/" + "...more",
+			Name:          "more...",
+			Display:       "more...",
+			ItemType:      storageTableNodeListEntities,
+			ExpandURL:     ExpandURLNotSupported,
+			ExpandInPlace: true,
+			Metadata:      metadata,
+		})
+	}
+
+	content := ExpanderResponse{Response: string(response.Data), ResponseType: interfaces.ResponseJSON}
+	if filter != "" {
+		content = ExpanderResponse{Response: fmt.Sprintf("Filter: %s\n\n%s", filter, response.Data), ResponseType: interfaces.ResponsePlainText}
+	}
+	return ExpanderResult{
+		Response:          content,
+		SourceDescription: "StorageTableExpander request",
+		Nodes:             nodes,
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *StorageTableExpander) addEntity(ctx context.Context, item *TreeNode) ExpanderResult {
+	initialContent := "// Fill out this entity and save and exit to insert it. To cancel, leave the entity as-is or delete the content\n" +
+		"{\n\t\"PartitionKey\": \"\",\n\t\"RowKey\": \"\"\n}"
+
+	content, err := editor.OpenForContent(initialContent, ".json")
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "StorageTableExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if content == initialContent || strings.TrimSpace(content) == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("User canceled"),
+			SourceDescription: "StorageTableExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if strings.HasPrefix(content, "//") {
+		// remove the comment line we added!
+		newLineIndex := strings.Index(content, "\n")
+		content = content[newLineIndex+1:]
+	}
+
+	// Insert Entity docs: https://docs.microsoft.com/en-us/rest/api/storageservices/insert-entity
+	insertURL := item.Metadata["TableEndpoint"] + item.Metadata["TableName"]
+	response, err := e.doTableRequest(ctx, "POST", insertURL, item.Metadata["AccountName"], item.Metadata["AccountKey"],
+		map[string]string{"Prefer": "return-no-content"}, []byte(content))
+	if err == nil && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		err = fmt.Errorf("StatusCode=%d, Response=%s", response.StatusCode, string(response.Data))
+	}
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error inserting entity: %s", err),
+			SourceDescription: "StorageTableExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: "Entity added.", ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "StorageTableExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ensureConnectionDetails looks up the account name, key and table endpoint if they haven't been saved in the item metadata
+func (e *StorageTableExpander) ensureConnectionDetails(ctx context.Context, item *TreeNode) error {
+	if item.Metadata["AccountKey"] != "" {
+		return nil
+	}
+
+	if cosmosAccountID := item.Metadata["CosmosAccountID"]; cosmosAccountID != "" {
+		data, err := e.armClient.DoRequest(ctx, "POST", cosmosAccountID+"/listKeys?api-version=2020-04-01")
+		if err != nil {
+			return fmt.Errorf("Error getting account key: %s", err)
+		}
+		var keys CosmosDbListKeyResponse
+		if err = json.Unmarshal([]byte(data), &keys); err != nil {
+			return fmt.Errorf("Error unmarshalling account keys: %s", err)
+		}
+		data, err = e.armClient.DoRequest(ctx, "GET", cosmosAccountID+"?api-version=2021-04-15")
+		if err != nil {
+			return fmt.Errorf("Error getting account: %s", err)
+		}
+		var account CosmosDbAccount
+		if err = json.Unmarshal([]byte(data), &account); err != nil {
+			return fmt.Errorf("Error unmarshalling account: %s", err)
+		}
+		tableEndpoint, err := cosmosTableEndpoint(&account)
+		if err != nil {
+			return err
+		}
+		item.Metadata["AccountName"] = item.Metadata["CosmosAccountName"]
+		item.Metadata["AccountKey"] = keys.PrimaryMasterKey
+		item.Metadata["TableEndpoint"] = tableEndpoint
+		return nil
+	}
+
+	accountID := item.Metadata["AccountID"]
+	accountKey, err := e.getStorageAccountKey(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("Error getting account key: %s", err)
+	}
+	account, err := e.getStorageAccount(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("Error getting table endpoint: %s", err)
+	}
+	if account.Properties.PrimaryEndpoints.Table == "" {
+		return fmt.Errorf("Storage account %q doesn't have a table endpoint", lastSegment(accountID))
+	}
+	item.Metadata["AccountName"] = lastSegment(accountID)
+	item.Metadata["AccountKey"] = accountKey
+	item.Metadata["TableEndpoint"] = account.Properties.PrimaryEndpoints.Table
+	return nil
+}
+
+// cosmosTableEndpoint returns the Table API endpoint for a Cosmos DB account. Accounts created for the
+// Table API report it as tableEndpoint, otherwise it is derived from the documentEndpoint so the
+// account's cloud (e.g. documents.azure.cn) is kept
+func cosmosTableEndpoint(account *CosmosDbAccount) (string, error) {
+	endpoint := account.Properties.TableEndpoint
+	if endpoint == "" {
+		documentEndpoint := account.Properties.DocumentEndpoint
+		if !strings.Contains(documentEndpoint, ".documents.") {
+			return "", fmt.Errorf("Unable to determine the table endpoint from document endpoint %q", documentEndpoint)
+		}
+		endpoint = strings.Replace(documentEndpoint, ".documents.", ".table.cosmos.", 1)
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	return endpoint, nil
+}
+
+// connectionMetadata returns a copy of the metadata needed to connect to the table service
+func (e *StorageTableExpander) connectionMetadata(item *TreeNode) map[string]string {
+	metadata := map[string]string{}
+	for _, key := range []string{"AccountID", "CosmosAccountID", "CosmosAccountName", "AccountName", "AccountKey", "TableEndpoint", "TableName"} {
+		if value, ok := item.Metadata[key]; ok {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+func (e *StorageTableExpander) getEntityURL(item *TreeNode) string {
+	escapeKey := func(key string) string {
+		return url.PathEscape(strings.ReplaceAll(key, "'", "''"))
+	}
+	return item.Metadata["TableEndpoint"] + item.Metadata["TableName"] +
+		"(PartitionKey='" + escapeKey(item.Metadata["PartitionKey"]) + "',RowKey='" + escapeKey(item.Metadata["RowKey"]) + "')"
+}
@@ -0,0 +1,99 @@
+package expanders
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func Test_cosmosTableEndpoint(t *testing.T) {
+	account := &CosmosDbAccount{}
+	account.Properties.TableEndpoint = "https://acct.table.cosmos.azure.com:443/"
+	account.Properties.DocumentEndpoint = "https://acct.documents.azure.com:443/"
+	endpoint, err := cosmosTableEndpoint(account)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://acct.table.cosmos.azure.com:443/", endpoint)
+
+	// Derived from the document endpoint, keeping the cloud
+	account.Properties.TableEndpoint = ""
+	account.Properties.DocumentEndpoint = "https://acct.documents.azure.cn:443"
+	endpoint, err = cosmosTableEndpoint(account)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://acct.table.cosmos.azure.cn:443/", endpoint)
+
+	account.Properties.DocumentEndpoint = ""
+	_, err = cosmosTableEndpoint(account)
+	assert.Error(t, err)
+}
+
+func Test_StorageTable_CosmosConnectionDetailsUseAccountEndpoint(t *testing.T) {
+	const accountID = "/subscriptions/1/resourceGroups/rg1/providers/Microsoft.DocumentDB/databaseAccounts/acct"
+	defer gock.Off()
+	gock.New("https://management.azure.com").
+		Post(accountID + "/listKeys").
+		Reply(200).
+		JSON(`{"primaryMasterKey": "a2V5"}`)
+	gock.New("https://management.azure.com").
+		Get(accountID).
+		Reply(200).
+		JSON(`{"properties": {"documentEndpoint": "https://acct.documents.azure.us:443/"}}`)
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	e := &StorageTableExpander{}
+	e.setClient(armclient.NewClientFromConfig(httpClient, DummyTokenFunc(), 5000))
+
+	item := &TreeNode{Metadata: map[string]string{
+		"CosmosAccountID":   accountID,
+		"CosmosAccountName": "acct",
+		"TableName":         "people",
+	}}
+	assert.NoError(t, e.ensureConnectionDetails(context.Background(), item))
+	assert.Equal(t, "acct", item.Metadata["AccountName"])
+	assert.Equal(t, "a2V5", item.Metadata["AccountKey"])
+	assert.Equal(t, "https://acct.table.cosmos.azure.us:443/", item.Metadata["TableEndpoint"])
+	assert.True(t, gock.IsDone())
+
+	item.Metadata["PartitionKey"] = "o'brien"
+	item.Metadata["RowKey"] = "a b"
+	assert.Equal(t, "https://acct.table.cosmos.azure.us:443/people(PartitionKey='o%27%27brien',RowKey='a%20b')", e.getEntityURL(item))
+}
+
+func Test_StorageTable_FilterActions(t *testing.T) {
+	filters := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("$filter"))
+		_, _ = io.WriteString(w, `{"value": []}`)
+	}))
+	defer ts.Close()
+
+	g, commandPanel := newTestPrompt(t, "PartitionKey eq 'abc'")
+	e := &StorageTableExpander{storageSharedKeyClient: storageSharedKeyClient{client: ts.Client()}, gui: g, commandPanel: commandPanel}
+	entities := &TreeNode{ItemType: storageTableNodeListEntities, Metadata: map[string]string{
+		"AccountName":   "account",
+		"AccountKey":    "a2V5",
+		"TableEndpoint": ts.URL + "/",
+		"TableName":     "people",
+	}}
+	actions := map[string]*TreeNode{}
+	for _, action := range e.ListActions(context.Background(), entities).Nodes {
+		actions[action.Metadata["ActionID"]] = action
+	}
+
+	result := e.ExecuteAction(context.Background(), actions[storageTableActionFilter])
+	assert.NoError(t, result.Err)
+
+	// Closing the prompt leaves the filter as it was rather than listing every entity
+	result = e.ExecuteAction(context.Background(), actions[storageTableActionFilter])
+	assert.EqualError(t, result.Err, "User canceled")
+
+	result = e.ExecuteAction(context.Background(), actions[storageTableActionClearFilter])
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []string{"PartitionKey eq 'abc'", ""}, filters)
+}
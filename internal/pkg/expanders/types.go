@@ -3,6 +3,7 @@ package expanders
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
@@ -87,6 +88,7 @@ func (e *ExpanderBase) ExecuteAction(context context.Context, item *TreeNode) Ex
 type ExpanderResponse struct {
 	Response     string                          // the response text
 	ResponseType interfaces.ExpanderResponseType // the response
+	Title        string                          // optional title for the content panel, defaults to the node name
 }
 
 // ExpanderResult used to wrap mult-value return for use in channels
@@ -132,6 +134,16 @@ type TreeNode struct {
 	ExpandInPlace          bool                  // Indicates that the node is a "More..." node. Must be the last in the list and will be removed and replaced with the expanded nodes
 }
 
+// IsARMResource returns true for resource group and resource nodes, these are deleted with an ARM
+// DELETE of their ID rather than by an expander
+func IsARMResource(item *TreeNode) bool {
+	if item.ItemType != ResourceType && item.ItemType != resourceGroupType {
+		return false
+	}
+	deletePath := strings.SplitN(item.DeleteURL, "?", 2)[0]
+	return strings.HasPrefix(item.ID, "/subscriptions/") && strings.EqualFold(deletePath, item.ID)
+}
+
 const (
 	// SubscriptionType defines a sub
 	SubscriptionType = "subscription"
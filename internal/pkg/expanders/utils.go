@@ -3,6 +3,8 @@ package expanders
 import (
 	"encoding/json"
 
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/valyala/fastjson"
 
 	"strings"
@@ -79,3 +81,53 @@ func getJSONPropertyFromString(jsonString string, properties ...string) (interfa
 
 	return getJSONProperty(jsonData, properties...)
 }
+
+// promptTimeoutSeconds is the expand timeout for nodes which wait on the command panel or editor,
+// allowing the user time to respond
+const promptTimeoutSeconds = 300
+
+// promptTimeout returns promptTimeoutSeconds for use as a TreeNode's TimeoutOverrideSeconds
+func promptTimeout() *int {
+	timeoutSeconds := promptTimeoutSeconds
+	return &timeoutSeconds
+}
+
+// PromptInCommandPanel allows views to prompt in the same way as expanders, see promptInCommandPanel
+func PromptInCommandPanel(gui *gocui.Gui, commandPanel interfaces.CommandPanel, title string, initialText string, options *[]interfaces.CommandPanelListOption) (string, string) {
+	return promptInCommandPanel(gui, commandPanel, title, initialText, options)
+}
+
+// promptInCommandPanel shows the command panel and blocks until the user presses enter or closes the panel.
+// It returns the text entered and, when options are supplied, the ID of the selected option.
+// Both are empty if the panel is closed without pressing enter
+func promptInCommandPanel(gui *gocui.Gui, commandPanel interfaces.CommandPanel, title string, initialText string, options *[]interfaces.CommandPanelListOption) (string, string) {
+	commandChannel := make(chan interfaces.CommandPanelNotification, 1)
+	commandPanelNotification := func(state interfaces.CommandPanelNotification) {
+		if state.Cancelled {
+			select {
+			case commandChannel <- interfaces.CommandPanelNotification{}:
+			default: // already have a response, don't block the UI thread
+			}
+			return
+		}
+		if state.EnterPressed {
+			select {
+			case commandChannel <- state:
+			default: // already have a response, don't block the UI thread
+			}
+			commandPanel.Hide()
+		}
+	}
+	commandPanel.ShowWithText(title, initialText, options, commandPanelNotification)
+	// Force UI to re-render to pickup
+	gui.Update(func(g *gocui.Gui) error {
+		return nil
+	})
+
+	state := <-commandChannel
+	// Force UI to re-render to pickup
+	gui.Update(func(g *gocui.Gui) error {
+		return nil
+	})
+	return strings.TrimSpace(state.CurrentText), state.SelectedID
+}
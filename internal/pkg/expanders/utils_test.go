@@ -0,0 +1,54 @@
+package expanders
+
+import (
+	"testing"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCommandPanel answers each prompt with the next of its responses, closing the panel once they run out
+type testCommandPanel struct {
+	responses []interfaces.CommandPanelNotification
+	titles    []string
+}
+
+func (p *testCommandPanel) Hide() {}
+
+func (p *testCommandPanel) ShowWithText(title string, s string, options *[]interfaces.CommandPanelListOption, handler interfaces.CommandPanelNotificationHandler) {
+	p.titles = append(p.titles, title)
+	if len(p.responses) == 0 {
+		handler(interfaces.CommandPanelNotification{Cancelled: true})
+		return
+	}
+	response := p.responses[0]
+	p.responses = p.responses[1:]
+	handler(response)
+}
+
+// newTestPrompt returns a simulated gui and a command panel which enters each of the responses in turn
+func newTestPrompt(t *testing.T, responses ...string) (*gocui.Gui, *testCommandPanel) {
+	g, err := gocui.NewGui(gocui.OutputSimulator, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commandPanel := &testCommandPanel{}
+	for _, response := range responses {
+		commandPanel.responses = append(commandPanel.responses, interfaces.CommandPanelNotification{CurrentText: response, EnterPressed: true})
+	}
+	return g, commandPanel
+}
+
+func Test_promptInCommandPanel(t *testing.T) {
+	g, commandPanel := newTestPrompt(t, "  some text ")
+
+	text, _ := promptInCommandPanel(g, commandPanel, "first:", "", nil)
+	assert.Equal(t, "some text", text)
+
+	// Closing the panel returns nothing
+	text, selected := promptInCommandPanel(g, commandPanel, "second:", "", nil)
+	assert.Equal(t, "", text)
+	assert.Equal(t, "", selected)
+	assert.Equal(t, []string{"first:", "second:"}, commandPanel.titles)
+}
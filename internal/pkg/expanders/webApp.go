@@ -0,0 +1,768 @@
+package expanders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const webAppTemplateURL = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Web/sites/{name}"
+
+const webAppAPIVersion = "2022-03-01"
+
+// WebAppNodeLogStream is the node the 'App Service: Stream logs' command is enabled for
+const WebAppNodeLogStream = "webapp-logstream"
+
+const (
+	webAppNodeAppSettings       = "webapp-appsettings"
+	webAppNodeConnectionStrings = "webapp-connectionstrings"
+	webAppNodeFunctions         = "webapp-functions"
+	webAppNodeFunction          = "webapp-function"
+)
+
+const webAppActionInvokeFunction = "webapp-invoke-function"
+
+const (
+	webAppAppSettingsHeader       = "# App settings (name = value), values in \"quotes\" are escaped, e.g. \\n for a new line"
+	webAppConnectionStringsHeader = "# Connection strings (name [type] = value), values in \"quotes\" are escaped, e.g. \\n for a new line"
+	// webAppDefaultConnectionStringType is used for connection strings added without a type
+	webAppDefaultConnectionStringType = "Custom"
+)
+
+// webAppSettingRegex matches 'name = value' or 'name [type] = value'
+var webAppSettingRegex = regexp.MustCompile(`^\s*(.+?)(?:\s+\[(\w+)\])?\s*= ?(.*)$`)
+
+// webAppSetting is an app setting or connection string (which also has a type)
+type webAppSetting struct {
+	Name  string
+	Value string
+	Type  string
+}
+
+type webAppResponse struct {
+	Kind       string `json:"kind"`
+	Properties struct {
+		HostNameSslStates []struct {
+			Name     string `json:"name"`
+			HostType string `json:"hostType"`
+		} `json:"hostNameSslStates"`
+	} `json:"properties"`
+}
+
+type webAppAppSettingsResponse struct {
+	Properties map[string]string `json:"properties"`
+}
+
+type webAppConnectionStringValue struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+type webAppConnectionStringsResponse struct {
+	Properties map[string]webAppConnectionStringValue `json:"properties"`
+}
+
+type webAppFunctionListResponse struct {
+	Value []json.RawMessage `json:"value"`
+}
+
+type webAppFunction struct {
+	ID         string `json:"id"`
+	Properties struct {
+		Name              string `json:"name"`
+		InvokeURLTemplate string `json:"invoke_url_template"`
+		IsDisabled        bool   `json:"isDisabled"`
+		Config            struct {
+			Bindings []struct {
+				Type      string   `json:"type"`
+				Direction string   `json:"direction"`
+				Name      string   `json:"name"`
+				AuthLevel string   `json:"authLevel"`
+				Methods   []string `json:"methods"`
+			} `json:"bindings"`
+		} `json:"config"`
+	} `json:"properties"`
+}
+
+// NewWebAppExpander creates a new instance of WebAppExpander
+func NewWebAppExpander(armclient *armclient.Client, gui *gocui.Gui, commandPanel interfaces.CommandPanel, contentPanel interfaces.ItemWidget) *WebAppExpander {
+	return &WebAppExpander{
+		armClient:    armclient,
+		client:       &http.Client{},
+		gui:          gui,
+		commandPanel: commandPanel,
+		contentPanel: contentPanel,
+	}
+}
+
+// WebAppExpanderInterface is used by the log stream command to authenticate with the SCM (Kudu) site
+type WebAppExpanderInterface interface {
+	GetAuthToken(ctx context.Context, currentItem *TreeNode) (string, error)
+}
+
+// Check interface
+var _ Expander = &WebAppExpander{}
+var _ WebAppExpanderInterface = &WebAppExpander{}
+
+// WebAppExpander expands the settings, functions and log stream of App Service and Function apps
+type WebAppExpander struct {
+	ExpanderBase
+	armClient    *armclient.Client
+	client       *http.Client
+	gui          *gocui.Gui
+	commandPanel interfaces.CommandPanel
+	contentPanel interfaces.ItemWidget
+}
+
+func (e *WebAppExpander) setClient(c *armclient.Client) {
+	e.armClient = c
+}
+
+// Name returns the name of the expander
+func (e *WebAppExpander) Name() string {
+	return "WebAppExpander"
+}
+
+// DoesExpand checks if this is a web app
+func (e *WebAppExpander) DoesExpand(ctx context.Context, currentItem *TreeNode) (bool, error) {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.ItemType == ResourceType && swaggerResourceType != nil {
+		if swaggerResourceType.Endpoint.TemplateURL == webAppTemplateURL {
+			return true, nil
+		}
+	}
+	if currentItem.Namespace == "webapp" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Expand returns the settings, functions and log stream nodes for the web app
+func (e *WebAppExpander) Expand(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	swaggerResourceType := currentItem.SwaggerResourceType
+	if currentItem.Namespace != "webapp" &&
+		swaggerResourceType != nil &&
+		swaggerResourceType.Endpoint.TemplateURL == webAppTemplateURL {
+		return e.expandWebApp(ctx, currentItem)
+	}
+
+	switch currentItem.ItemType {
+	case webAppNodeAppSettings:
+		settings, err := e.getAppSettings(ctx, currentItem.Metadata["SiteID"])
+		return e.settingsResult(formatWebAppSettings(webAppAppSettingsHeader, settings), err)
+	case webAppNodeConnectionStrings:
+		settings, err := e.getConnectionStrings(ctx, currentItem.Metadata["SiteID"])
+		return e.settingsResult(formatWebAppSettings(webAppConnectionStringsHeader, settings), err)
+	case webAppNodeFunctions:
+		return e.expandFunctions(ctx, currentItem)
+	case webAppNodeFunction:
+		return ExpanderResult{
+			Response:          ExpanderResponse{Response: currentItem.Metadata["Content"], ResponseType: interfaces.ResponseJSON},
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	case WebAppNodeLogStream:
+		return ExpanderResult{
+			Response: ExpanderResponse{
+				Response:     "Use the 'App Service: Stream logs' command (Ctrl+P) to stream the application and web server logs from " + currentItem.Metadata["LogStreamEndpoint"],
+				ResponseType: interfaces.ResponsePlainText,
+			},
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	return ExpanderResult{
+		Err:               fmt.Errorf("Error - unhandled Expand"),
+		Response:          ExpanderResponse{Response: "Error!"},
+		SourceDescription: "WebAppExpander request",
+	}
+}
+
+func (e *WebAppExpander) expandWebApp(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.ID+"?api-version="+webAppAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error getting web app: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: false,
+		}
+	}
+	var site webAppResponse
+	if err = json.Unmarshal([]byte(data), &site); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling web app response: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: false,
+		}
+	}
+
+	children := []struct{ name, itemType, path string }{
+		{"App Settings", webAppNodeAppSettings, "appsettings"},
+		{"Connection Strings", webAppNodeConnectionStrings, "connectionstrings"},
+	}
+	if strings.Contains(strings.ToLower(site.Kind), "functionapp") {
+		children = append(children, struct{ name, itemType, path string }{"Functions", webAppNodeFunctions, "functions"})
+	}
+	scmHost := ""
+	for _, hostName := range site.Properties.HostNameSslStates {
+		if strings.EqualFold(hostName.HostType, "Repository") {
+			scmHost = hostName.Name
+		}
+	}
+	if scmHost != "" {
+		children = append(children, struct{ name, itemType, path string }{"Log Stream", WebAppNodeLogStream, "logstream"})
+	}
+
+	newItems := []*TreeNode{}
+	for _, child := range children {
+		newItems = append(newItems, &TreeNode{
+			Parentid:              currentItem.ID,
+			ID:                    currentItem.ID + "/<" + child.path + ">",
+			Namespace:             "webapp",
+			Name:                  child.name,
+			Display:               child.name,
+			ItemType:              child.itemType,
+			ExpandURL:             ExpandURLNotSupported,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata: map[string]string{
+				"SiteID":            currentItem.ID,
+				"LogStreamEndpoint": "https://" + scmHost + "/api/logstream",
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Err:               nil,
+		Response:          ExpanderResponse{Response: ""}, // Swagger expander will supply the response
+		SourceDescription: "WebAppExpander request",
+		Nodes:             newItems,
+		IsPrimaryResponse: false,
+	}
+}
+
+func (e *WebAppExpander) settingsResult(content string, err error) ExpanderResult {
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: content, ResponseType: interfaces.ResponsePlainText},
+		SourceDescription: "WebAppExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+func (e *WebAppExpander) getAppSettings(ctx context.Context, siteID string) ([]webAppSetting, error) {
+	data, err := e.armClient.DoRequest(ctx, "POST", siteID+"/config/appsettings/list?api-version="+webAppAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing app settings: %s", err)
+	}
+	var response webAppAppSettingsResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling app settings: %s", err)
+	}
+	settings := []webAppSetting{}
+	for name, value := range response.Properties {
+		settings = append(settings, webAppSetting{Name: name, Value: value})
+	}
+	return settings, nil
+}
+
+func (e *WebAppExpander) getConnectionStrings(ctx context.Context, siteID string) ([]webAppSetting, error) {
+	data, err := e.armClient.DoRequest(ctx, "POST", siteID+"/config/connectionstrings/list?api-version="+webAppAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing connection strings: %s", err)
+	}
+	var response webAppConnectionStringsResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling connection strings: %s", err)
+	}
+	settings := []webAppSetting{}
+	for name, value := range response.Properties {
+		settings = append(settings, webAppSetting{Name: name, Value: value.Value, Type: value.Type})
+	}
+	return settings, nil
+}
+
+// CanUpdate returns true for app settings and connection strings
+func (e *WebAppExpander) CanUpdate(ctx context.Context, item *TreeNode) (bool, error) {
+	switch item.ItemType {
+	case webAppNodeAppSettings, webAppNodeConnectionStrings:
+		return true, nil
+	}
+	return false, nil
+}
+
+// Update shows the changes to the settings and saves them once confirmed
+func (e *WebAppExpander) Update(ctx context.Context, item *TreeNode, updatedContent string) error {
+	updated, err := parseWebAppSettings(updatedContent)
+	if err != nil {
+		return err
+	}
+
+	siteID := item.Metadata["SiteID"]
+	var current []webAppSetting
+	var header string
+	switch item.ItemType {
+	case webAppNodeAppSettings:
+		header = webAppAppSettingsHeader
+		current, err = e.getAppSettings(ctx, siteID)
+	case webAppNodeConnectionStrings:
+		header = webAppConnectionStringsHeader
+		current, err = e.getConnectionStrings(ctx, siteID)
+		for i := range updated {
+			if updated[i].Type == "" {
+				updated[i].Type = webAppDefaultConnectionStringType
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported item type for update: %s", item.ItemType)
+	}
+	if err != nil {
+		return err
+	}
+
+	changes := diffWebAppSettings(current, updated)
+	if len(changes) == 0 {
+		return fmt.Errorf("No changes to save")
+	}
+
+	// Show the changes and wait for the user to confirm them
+	e.showContent(strings.SplitN(header, " (", 2)[0]+" changes to save\n"+strings.Join(changes, "\n"), "Review changes")
+	options := []interfaces.CommandPanelListOption{
+		{ID: "save", DisplayText: fmt.Sprintf("Save %d change(s)", len(changes))},
+		{ID: "cancel", DisplayText: "Cancel"},
+	}
+	if _, selected := promptInCommandPanel(e.gui, e.commandPanel, "save changes?", "", &options); selected != "save" {
+		e.showContent(formatWebAppSettings(header, current), item.Name)
+		return fmt.Errorf("Changes not saved")
+	}
+
+	var body []byte
+	var url string
+	if item.ItemType == webAppNodeAppSettings {
+		properties := map[string]string{}
+		for _, setting := range updated {
+			properties[setting.Name] = setting.Value
+		}
+		body, err = json.Marshal(webAppAppSettingsResponse{Properties: properties})
+		url = siteID + "/config/appsettings?api-version=" + webAppAPIVersion
+	} else {
+		properties := map[string]webAppConnectionStringValue{}
+		for _, setting := range updated {
+			properties[setting.Name] = webAppConnectionStringValue{Value: setting.Value, Type: setting.Type}
+		}
+		body, err = json.Marshal(webAppConnectionStringsResponse{Properties: properties})
+		url = siteID + "/config/connectionstrings?api-version=" + webAppAPIVersion
+	}
+	if err != nil {
+		return fmt.Errorf("Error marshalling settings: %s", err)
+	}
+	if _, err = e.armClient.DoRequestWithBody(ctx, "PUT", url, string(body)); err != nil {
+		return fmt.Errorf("Error saving settings: %s", err)
+	}
+
+	e.showContent(formatWebAppSettings(header, updated), item.Name)
+	eventing.SendStatusEvent(&eventing.StatusEvent{
+		Message: fmt.Sprintf("Saved %d change(s) to %s", len(changes), strings.ToLower(item.Name)),
+		Timeout: time.Second * 5,
+	})
+	return nil
+}
+
+func (e *WebAppExpander) showContent(content string, title string) {
+	e.gui.Update(func(g *gocui.Gui) error {
+		e.contentPanel.SetContent(content, interfaces.ResponsePlainText, title)
+		return nil
+	})
+}
+
+func (e *WebAppExpander) expandFunctions(ctx context.Context, currentItem *TreeNode) ExpanderResult {
+	data, err := e.armClient.DoRequest(ctx, "GET", currentItem.Metadata["SiteID"]+"/functions?api-version="+webAppAPIVersion)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error listing functions: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	var response webAppFunctionListResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Error unmarshalling functions response: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	newItems := []*TreeNode{}
+	for _, value := range response.Value {
+		var function webAppFunction
+		if err = json.Unmarshal(value, &function); err != nil {
+			return ExpanderResult{
+				Err:               fmt.Errorf("Error unmarshalling function: %s", err),
+				SourceDescription: "WebAppExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+
+		trigger := ""
+		authLevel := ""
+		methods := []string{}
+		for _, binding := range function.Properties.Config.Bindings {
+			if strings.HasSuffix(strings.ToLower(binding.Type), "trigger") {
+				trigger = binding.Type
+				authLevel = binding.AuthLevel
+				methods = binding.Methods
+			}
+		}
+		display := style.Subtle("["+trigger+"]") + "\n  " + function.Properties.Name
+		if function.Properties.IsDisabled {
+			display += style.Subtle(" (disabled)")
+		}
+
+		newItems = append(newItems, &TreeNode{
+			Parentid:              currentItem.ID,
+			ID:                    function.ID,
+			Namespace:             "webapp",
+			Name:                  function.Properties.Name,
+			Display:               display,
+			ItemType:              webAppNodeFunction,
+			ExpandURL:             ExpandURLNotSupported,
+			SuppressSwaggerExpand: true,
+			SuppressGenericExpand: true,
+			Metadata: map[string]string{
+				"SiteID":      currentItem.Metadata["SiteID"],
+				"FunctionID":  function.ID,
+				"TriggerType": trigger,
+				"AuthLevel":   authLevel,
+				"Methods":     strings.Join(methods, ","),
+				"InvokeURL":   function.Properties.InvokeURLTemplate,
+				"Content":     string(value),
+			},
+		})
+	}
+
+	return ExpanderResult{
+		Response:          ExpanderResponse{Response: data, ResponseType: interfaces.ResponseJSON},
+		Nodes:             newItems,
+		SourceDescription: "WebAppExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// HasActions returns true for HTTP triggered functions
+func (e *WebAppExpander) HasActions(ctx context.Context, item *TreeNode) (bool, error) {
+	return item.ItemType == webAppNodeFunction && strings.EqualFold(item.Metadata["TriggerType"], "httpTrigger"), nil
+}
+
+// ListActions returns the invoke action for HTTP triggered functions
+func (e *WebAppExpander) ListActions(ctx context.Context, item *TreeNode) ListActionsResult {
+	metadata := copyMetadata(item.Metadata)
+	metadata["ActionID"] = webAppActionInvokeFunction
+	return ListActionsResult{
+		Nodes: []*TreeNode{
+			{
+				Parentid:               item.ID,
+				ID:                     item.ID + "?invoke",
+				Namespace:              "webapp",
+				Name:                   "Invoke Function",
+				Display:                "Invoke Function",
+				ItemType:               ActionType,
+				SuppressGenericExpand:  true,
+				TimeoutOverrideSeconds: promptTimeout(),
+				Metadata:               metadata,
+			},
+		},
+		SourceDescription: "WebAppExpander",
+		IsPrimaryResponse: true,
+	}
+}
+
+// ExecuteAction invokes the function
+func (e *WebAppExpander) ExecuteAction(ctx context.Context, item *TreeNode) ExpanderResult {
+	actionID := item.Metadata["ActionID"]
+
+	switch actionID {
+	case webAppActionInvokeFunction:
+		return e.invokeFunction(ctx, item)
+	case "":
+		return ExpanderResult{
+			SourceDescription: "WebAppExpander",
+			Err:               fmt.Errorf("ActionID metadata not set: %q", item.ID),
+		}
+	default:
+		return ExpanderResult{
+			SourceDescription: "WebAppExpander",
+			Err:               fmt.Errorf("Unhandled ActionID: %q", actionID),
+		}
+	}
+}
+
+func (e *WebAppExpander) invokeFunction(ctx context.Context, item *TreeNode) ExpanderResult {
+	url, _ := promptInCommandPanel(e.gui, e.commandPanel, "URL (fill in any route parameters):", item.Metadata["InvokeURL"], nil)
+	if url == "" {
+		return ExpanderResult{
+			Err:               fmt.Errorf("No URL entered"),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	methods := strings.Split(item.Metadata["Methods"], ",")
+	if item.Metadata["Methods"] == "" {
+		methods = []string{"get", "post", "put", "patch", "delete"}
+	}
+	method := strings.ToUpper(methods[0])
+	if len(methods) > 1 {
+		options := []interfaces.CommandPanelListOption{}
+		for _, m := range methods {
+			options = append(options, interfaces.CommandPanelListOption{ID: strings.ToUpper(m), DisplayText: strings.ToUpper(m)})
+		}
+		_, method = promptInCommandPanel(e.gui, e.commandPanel, "method:", "", &options)
+		if method == "" {
+			return ExpanderResult{
+				Err:               fmt.Errorf("No method selected"),
+				SourceDescription: "WebAppExpander request",
+				IsPrimaryResponse: true,
+			}
+		}
+	}
+
+	body := ""
+	if method != "GET" {
+		body, _ = promptInCommandPanel(e.gui, e.commandPanel, "body:", "", nil)
+	}
+
+	key, err := e.getFunctionKey(ctx, item)
+	if err != nil {
+		return ExpanderResult{
+			Err:               err,
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to create request: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	if key != "" {
+		req.Header.Set("x-functions-key", key)
+	}
+	if json.Valid([]byte(body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	response, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Request failed: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	defer response.Body.Close() //nolint: errcheck
+	buf, err := io.ReadAll(response.Body)
+	if err != nil {
+		return ExpanderResult{
+			Err:               fmt.Errorf("Failed to read body: %s", err),
+			SourceDescription: "WebAppExpander request",
+			IsPrimaryResponse: true,
+		}
+	}
+	duration := time.Since(start)
+
+	responseType := interfaces.ResponsePlainText
+	if len(buf) > 0 && json.Valid(buf) {
+		responseType = interfaces.ResponseJSON
+	}
+	return ExpanderResult{
+		Response: ExpanderResponse{
+			Response:     string(buf),
+			ResponseType: responseType,
+			Title:        fmt.Sprintf("%s %s (%dms)", method, response.Status, duration.Milliseconds()),
+		},
+		SourceDescription: "WebAppExpander request",
+		IsPrimaryResponse: true,
+	}
+}
+
+// getFunctionKey returns the key needed to call the function, the master key is used for admin functions
+func (e *WebAppExpander) getFunctionKey(ctx context.Context, item *TreeNode) (string, error) {
+	switch strings.ToLower(item.Metadata["AuthLevel"]) {
+	case "anonymous":
+		return "", nil
+	case "admin":
+		data, err := e.armClient.DoRequest(ctx, "POST", item.Metadata["SiteID"]+"/host/default/listkeys?api-version="+webAppAPIVersion)
+		if err != nil {
+			return "", fmt.Errorf("Error listing host keys: %s", err)
+		}
+		var keys struct {
+			MasterKey string `json:"masterKey"`
+		}
+		if err = json.Unmarshal([]byte(data), &keys); err != nil {
+			return "", fmt.Errorf("Error unmarshalling host keys: %s", err)
+		}
+		return keys.MasterKey, nil
+	}
+
+	data, err := e.armClient.DoRequest(ctx, "POST", item.Metadata["FunctionID"]+"/listkeys?api-version="+webAppAPIVersion)
+	if err != nil {
+		return "", fmt.Errorf("Error listing function keys: %s", err)
+	}
+	keys := map[string]string{}
+	if err = json.Unmarshal([]byte(data), &keys); err != nil {
+		return "", fmt.Errorf("Error unmarshalling function keys: %s", err)
+	}
+	if key, ok := keys["default"]; ok {
+		return key, nil
+	}
+	for _, key := range keys {
+		return key, nil
+	}
+	return "", fmt.Errorf("No keys found for function %s", item.Name)
+}
+
+// GetAuthToken returns an ARM token, which the SCM (Kudu) site accepts
+func (e *WebAppExpander) GetAuthToken(ctx context.Context, currentItem *TreeNode) (string, error) {
+	token, err := e.armClient.GetToken()
+	if err != nil {
+		return "", fmt.Errorf("Error getting token: %s", err)
+	}
+	return token.AccessToken, nil
+}
+
+// formatWebAppSettings renders the settings as an aligned 'name = value' table, sorted by name
+func formatWebAppSettings(header string, settings []webAppSetting) string {
+	sorted := append([]webAppSetting{}, settings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	names := []string{}
+	width := 0
+	for _, setting := range sorted {
+		name := setting.Name
+		if setting.Type != "" {
+			name += " [" + setting.Type + "]"
+		}
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	var buf strings.Builder
+	buf.WriteString(header + "\n")
+	for i, setting := range sorted {
+		fmt.Fprintf(&buf, "%-*s = %s\n", width, names[i], formatWebAppSettingValue(setting.Value))
+	}
+	return buf.String()
+}
+
+// formatWebAppSettingValue quotes values which wouldn't survive the one line 'name = value' format, e.g. multi-line
+// certificates or JSON, or values with leading or trailing spaces. Values starting with a quote are quoted too, so
+// that parseWebAppSettingValue can tell them apart
+func formatWebAppSettingValue(value string) string {
+	if strings.ContainsAny(value, "\r\n\t") || strings.HasPrefix(value, `"`) || value != strings.TrimSpace(value) {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// parseWebAppSettingValue reverses formatWebAppSettingValue
+func parseWebAppSettingValue(value string) (string, error) {
+	if !strings.HasPrefix(value, `"`) {
+		return value, nil
+	}
+	unquoted, err := strconv.Unquote(strings.TrimRight(value, " \t"))
+	if err != nil {
+		return "", fmt.Errorf("the quoted value isn't valid, use \\n for new lines and \\\" for quotes: %s", value)
+	}
+	return unquoted, nil
+}
+
+// parseWebAppSettings parses settings edited in the 'name = value' format, ignoring blank lines and comments
+func parseWebAppSettings(content string) ([]webAppSetting, error) {
+	settings := []webAppSetting{}
+	seen := map[string]bool{}
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		match := webAppSettingRegex.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("Line %d is not in the form 'name = value': %s", i+1, line)
+		}
+		if seen[match[1]] {
+			return nil, fmt.Errorf("Line %d sets %s again", i+1, match[1])
+		}
+		seen[match[1]] = true
+		value, err := parseWebAppSettingValue(match[3])
+		if err != nil {
+			return nil, fmt.Errorf("Line %d sets %s but %s", i+1, match[1], err)
+		}
+		settings = append(settings, webAppSetting{Name: match[1], Type: match[2], Value: value})
+	}
+	return settings, nil
+}
+
+// diffWebAppSettings describes the added (+), removed (-) and changed (~) settings
+func diffWebAppSettings(current []webAppSetting, updated []webAppSetting) []string {
+	describe := func(setting webAppSetting) string {
+		if setting.Type != "" {
+			return setting.Name + " [" + setting.Type + "] = " + formatWebAppSettingValue(setting.Value)
+		}
+		return setting.Name + " = " + formatWebAppSettingValue(setting.Value)
+	}
+
+	currentByName := map[string]webAppSetting{}
+	for _, setting := range current {
+		currentByName[setting.Name] = setting
+	}
+	updatedByName := map[string]webAppSetting{}
+	for _, setting := range updated {
+		updatedByName[setting.Name] = setting
+	}
+
+	changes := []string{}
+	for _, setting := range updated {
+		existing, ok := currentByName[setting.Name]
+		if !ok {
+			changes = append(changes, "+ "+describe(setting))
+		} else if existing != setting {
+			changes = append(changes, "~ "+describe(setting)+" (was "+existing.Value+")")
+		}
+	}
+	for _, setting := range current {
+		if _, ok := updatedByName[setting.Name]; !ok {
+			changes = append(changes, "- "+describe(setting))
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i][2:] < changes[j][2:] })
+	return changes
+}
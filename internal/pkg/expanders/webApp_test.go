@@ -0,0 +1,72 @@
+package expanders
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_webAppSettings_FormatAndParse(t *testing.T) {
+	settings := []webAppSetting{
+		{Name: "WEBSITE_RUN_FROM_PACKAGE", Value: "1"},
+		{Name: "API_URL", Value: "https://example.com/?a=b"},
+	}
+	content := formatWebAppSettings(webAppAppSettingsHeader, settings)
+	assert.Equal(t, webAppAppSettingsHeader+"\nAPI_URL                  = https://example.com/?a=b\nWEBSITE_RUN_FROM_PACKAGE = 1\n", content)
+
+	parsed, err := parseWebAppSettings(content)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, settings, parsed)
+
+	connectionStrings := []webAppSetting{{Name: "Db", Value: "Server=tcp:example;Password=x", Type: "SQLAzure"}}
+	parsed, err = parseWebAppSettings(formatWebAppSettings(webAppConnectionStringsHeader, connectionStrings))
+	assert.NoError(t, err)
+	assert.Equal(t, connectionStrings, parsed)
+
+	_, err = parseWebAppSettings("NO_VALUE")
+	assert.Error(t, err)
+	_, err = parseWebAppSettings("A = 1\nA = 2")
+	assert.Error(t, err)
+}
+
+func Test_webAppSettings_MultiLineValuesRoundTrip(t *testing.T) {
+	settings := []webAppSetting{
+		{Name: "CERT", Value: "-----BEGIN CERTIFICATE-----\nMIIB=\nabc=def\n-----END CERTIFICATE-----\n"},
+		{Name: "JSON", Value: "{\r\n  \"a\": \"b=c\"\r\n}"},
+		{Name: "QUOTED", Value: `"already quoted"`},
+		{Name: "SPACES", Value: " padded "},
+		{Name: "PLAIN", Value: "a=b"},
+	}
+	content := formatWebAppSettings(webAppAppSettingsHeader, settings)
+	// Every setting is on its own line, so later lines of a value can't be read as settings
+	assert.Len(t, strings.Split(strings.TrimSpace(content), "\n"), len(settings)+1)
+	assert.Contains(t, content, `CERT   = "-----BEGIN CERTIFICATE-----\nMIIB=\nabc=def\n-----END CERTIFICATE-----\n"`)
+	assert.Contains(t, content, "PLAIN  = a=b\n")
+
+	parsed, err := parseWebAppSettings(content)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, settings, parsed)
+
+	_, err = parseWebAppSettings(`CERT = "unterminated`)
+	assert.EqualError(t, err, `Line 1 sets CERT but the quoted value isn't valid, use \n for new lines and \" for quotes: "unterminated`)
+}
+
+func Test_diffWebAppSettings(t *testing.T) {
+	current := []webAppSetting{
+		{Name: "KEEP", Value: "1"},
+		{Name: "CHANGE", Value: "old"},
+		{Name: "REMOVE", Value: "x"},
+	}
+	updated := []webAppSetting{
+		{Name: "KEEP", Value: "1"},
+		{Name: "CHANGE", Value: "new"},
+		{Name: "ADD", Value: "y"},
+	}
+	assert.Equal(t, []string{
+		"+ ADD = y",
+		"~ CHANGE = new (was old)",
+		"- REMOVE = x",
+	}, diffWebAppSettings(current, updated))
+	assert.Empty(t, diffWebAppSettings(current, current))
+}
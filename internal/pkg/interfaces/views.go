@@ -17,6 +17,7 @@ type CommandPanelNotification struct {
 	CurrentText  string
 	SelectedID   string
 	EnterPressed bool
+	Cancelled    bool // the panel was closed without pressing enter
 }
 
 // CommandPanelNotificationHandler is the function signature for a panel changed notification handler
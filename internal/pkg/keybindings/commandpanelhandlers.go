@@ -20,7 +20,7 @@ func NewCloseCommandPanelHandler(commandPanelWidget *views.CommandPanelWidget) *
 
 func (h *CloseCommandPanelHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
 	return func(g *gocui.Gui, v *gocui.View) error {
-		h.commandPanelWidget.Hide()
+		h.commandPanelWidget.Cancel()
 		return nil
 	}
 }
@@ -42,4 +42,5 @@ var DefaultKeys = map[string]interface{}{
 	"filterfuzzy":         rune('/'),
 	"commandpanelclose":   gocui.KeyEsc,
 	"azuresearchquery":    gocui.KeyCtrlR,
+	"listwatch":           gocui.KeyCtrlW,
 }
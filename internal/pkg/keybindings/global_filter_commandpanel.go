@@ -72,6 +72,10 @@ func (h *CommandPanelFilterHandler) InvokeWithStartString(s string) error {
 	return nil
 }
 func (h *CommandPanelFilterHandler) CommandPanelNotification(state interfaces.CommandPanelNotification) {
+	if state.Cancelled {
+		// Closing the panel keeps the filter that has been typed
+		return
+	}
 	switch h.commandPanelWidget.PreviousViewName {
 	case "listWidget":
 		h.list.SetFilter(state.CurrentText, h.fuzzyFilter)
@@ -47,6 +47,10 @@ const (
 	HandlerIDToggleDemoMode          HandlerID = "toggledemomode"        //nolist:golint
 	HandlerIDListSort                HandlerID = "listsort"              //nolint:golint
 	HandlerIDContainerAppLogs        HandlerID = "containerapplogs"      //nolist:golint
+	HandlerIDWebAppLogs              HandlerID = "webapplogs"            //nolint:golint
+	HandlerIDContainerInstanceLogs   HandlerID = "containerinstancelogs" //nolint:golint
+	HandlerIDKubernetesPodLogs       HandlerID = "kubernetespodlogs"     //nolint:golint
+	HandlerIDListWatch               HandlerID = "listwatch"             //nolint:golint
 )
 
 // KeyHandler is an interface that all key handlers must implement
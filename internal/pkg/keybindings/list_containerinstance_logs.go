@@ -0,0 +1,71 @@
+package keybindings
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+)
+
+type CommandPanelContainerInstanceLogsHandler struct {
+	followLogsHandler
+}
+
+var _ Command = &CommandPanelContainerInstanceLogsHandler{}
+
+func NewCommandPanelContainerInstanceLogsHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, list *views.ListWidget) *CommandPanelContainerInstanceLogsHandler {
+	return &CommandPanelContainerInstanceLogsHandler{
+		followLogsHandler: newFollowLogsHandler(HandlerIDContainerInstanceLogs, commandPanelWidget, content, list),
+	}
+}
+
+func (h *CommandPanelContainerInstanceLogsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelContainerInstanceLogsHandler) DisplayText() string {
+	return "Container Instances: Follow logs"
+}
+
+func (h *CommandPanelContainerInstanceLogsHandler) IsEnabled() bool {
+	currentItem := h.list.CurrentItem()
+	if currentItem != nil && currentItem.ExpandReturnType == "containerInstance.logs" {
+		return true
+	}
+	return false
+}
+
+func (h *CommandPanelContainerInstanceLogsHandler) Invoke() error {
+
+	currentItem := h.list.CurrentItem()
+
+	containerInstanceExpander, ok := (currentItem.Expander).(expanders.ContainerInstanceExpanderInterface)
+	if !ok {
+		return fmt.Errorf("current item is not a ContainerInstanceExpanderInterface")
+	}
+
+	ctx, cancel := h.followUntilNavigated()
+
+	title := "Logs (following): " + currentItem.Name
+	lines := []string{}
+	err := containerInstanceExpander.FollowLogs(ctx, currentItem, func(newLines []string, err error) {
+		if err != nil {
+			h.showLogs(ctx, strings.Join(append(lines, "!! "+err.Error()), "\n"), title)
+			return
+		}
+		lines = appendFollowedLogLines(lines, newLines...)
+		h.showLogs(ctx, strings.Join(lines, "\n")+"\n", title)
+	})
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to get logs: %s", err)
+	}
+	return nil
+}
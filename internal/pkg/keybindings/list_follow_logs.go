@@ -0,0 +1,106 @@
+package keybindings
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+)
+
+// maxFollowedLogLines limits how much of a followed log is kept in the content panel
+const maxFollowedLogLines = 2000
+
+// followLogsHandler is shared by the commands which follow a log in the content panel
+// until the user navigates away
+type followLogsHandler struct {
+	ListHandler
+	commandPanelWidget *views.CommandPanelWidget
+	list               *views.ListWidget
+	content            *views.ItemWidget
+}
+
+func newFollowLogsHandler(id HandlerID, commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, list *views.ListWidget) followLogsHandler {
+	handler := followLogsHandler{
+		commandPanelWidget: commandPanelWidget,
+		content:            content,
+		list:               list,
+	}
+	handler.id = id
+	return handler
+}
+
+// followUntilNavigated returns a context which is cancelled when the user navigates away. It uses
+// prenavigate so following stops before the new content is shown
+func (h *followLogsHandler) followUntilNavigated() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	preNavigateChannel := eventing.SubscribeToTopic("list.prenavigate")
+	go func() {
+		select {
+		case <-preNavigateChannel:
+		case <-ctx.Done():
+		}
+		// Clean up subscription
+		eventing.Unsubscribe(preNavigateChannel)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// showLogs shows the content unless following has stopped
+func (h *followLogsHandler) showLogs(ctx context.Context, content string, title string) {
+	if ctx.Err() == nil {
+		h.content.SetContent(content, interfaces.ResponsePlainText, title)
+	}
+}
+
+// streamLogs requests the log stream with the token and follows it. The stream is closed when ctx is cancelled
+func (h *followLogsHandler) streamLogs(ctx context.Context, logStreamURL string, authToken string, title string) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", logStreamURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+authToken)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %s", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close() //nolint: errcheck
+		return fmt.Errorf("log stream returned %s", response.Status)
+	}
+
+	h.followStream(ctx, response.Body, title)
+	return nil
+}
+
+// followStream shows each line of the stream as it's received until the stream ends, which happens
+// when ctx is cancelled for streams requested with it. The stream is closed once it ends
+func (h *followLogsHandler) followStream(ctx context.Context, stream io.ReadCloser, title string) {
+	go func() {
+		defer stream.Close() //nolint: errcheck
+
+		lines := []string{}
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			lines = appendFollowedLogLines(lines, scanner.Text())
+			h.showLogs(ctx, strings.Join(lines, "\n")+"\n", title)
+		}
+		h.showLogs(ctx, strings.Join(append(lines, "!!Connection closed"), "\n"), title)
+	}()
+}
+
+// appendFollowedLogLines adds lines to a followed log, keeping the last maxFollowedLogLines lines
+func appendFollowedLogLines(lines []string, newLines ...string) []string {
+	lines = append(lines, newLines...)
+	if len(lines) > maxFollowedLogLines {
+		lines = lines[len(lines)-maxFollowedLogLines:]
+	}
+	return lines
+}
@@ -0,0 +1,78 @@
+package keybindings
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+)
+
+type CommandPanelKubernetesPodLogsHandler struct {
+	followLogsHandler
+}
+
+var _ Command = &CommandPanelKubernetesPodLogsHandler{}
+
+func NewCommandPanelKubernetesPodLogsHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, list *views.ListWidget) *CommandPanelKubernetesPodLogsHandler {
+	return &CommandPanelKubernetesPodLogsHandler{
+		followLogsHandler: newFollowLogsHandler(HandlerIDKubernetesPodLogs, commandPanelWidget, content, list),
+	}
+}
+
+func (h *CommandPanelKubernetesPodLogsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelKubernetesPodLogsHandler) DisplayText() string {
+	return "Kubernetes: Follow pod logs"
+}
+
+func (h *CommandPanelKubernetesPodLogsHandler) IsEnabled() bool {
+	currentItem := h.list.CurrentItem()
+	return currentItem != nil && expanders.IsKubernetesPod(currentItem)
+}
+
+func (h *CommandPanelKubernetesPodLogsHandler) Invoke() error {
+	currentItem := h.list.CurrentItem()
+
+	options, err := expanders.GetKubernetesPodContainers(context.Background(), currentItem)
+	if err != nil {
+		return fmt.Errorf("failed to get containers: %s", err)
+	}
+	if len(options) == 1 {
+		return h.followContainer(currentItem, options[0].ID)
+	}
+
+	// Invoke runs on the UI thread so the container is picked without waiting for the command panel
+	h.commandPanelWidget.ShowWithText("container:", "", &options, func(state interfaces.CommandPanelNotification) {
+		if !state.EnterPressed || state.SelectedID == "" {
+			return
+		}
+		h.commandPanelWidget.Hide()
+		if err := h.followContainer(currentItem, state.SelectedID); err != nil {
+			eventing.SendFailureStatusFromError("Failed to follow logs", err)
+		}
+	})
+	return nil
+}
+
+func (h *CommandPanelKubernetesPodLogsHandler) followContainer(currentItem *expanders.TreeNode, container string) error {
+	ctx, cancel := h.followUntilNavigated()
+	stream, err := expanders.StreamKubernetesPodLogs(ctx, currentItem, container)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to stream logs: %s", err)
+	}
+
+	h.followStream(ctx, stream, fmt.Sprintf("Logs (following): %s/%s", currentItem.Name, container))
+	return nil
+}
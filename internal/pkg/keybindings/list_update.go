@@ -15,6 +15,7 @@ import (
 	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 )
 
 type ListUpdateHandler struct {
@@ -65,6 +66,15 @@ func (h *ListUpdateHandler) IsEnabled() bool {
 }
 func (h *ListUpdateHandler) Invoke() error {
 	item := h.Content.GetNode()
+	if armclient.IsReadOnlyMode() {
+		eventing.SendStatusEvent(&eventing.StatusEvent{
+			InProgress: false,
+			Failure:    true,
+			Message:    "Updating is disabled in read-only mode",
+			Timeout:    time.Duration(time.Second * 2),
+		})
+		return nil
+	}
 	if !h.IsEnabled() {
 		eventing.SendStatusEvent(&eventing.StatusEvent{
 			InProgress: false,
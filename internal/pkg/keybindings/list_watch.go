@@ -0,0 +1,52 @@
+package keybindings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+)
+
+type ListWatchHandler struct {
+	ListHandler
+	List     *views.ListWidget
+	interval time.Duration
+}
+
+var _ Command = &ListWatchHandler{}
+
+func NewListWatchHandler(list *views.ListWidget, intervalSeconds int) *ListWatchHandler {
+	interval := views.DefaultWatchInterval
+	if intervalSeconds > 0 {
+		interval = time.Duration(intervalSeconds) * time.Second
+	}
+	handler := &ListWatchHandler{
+		List:     list,
+		interval: interval,
+	}
+	handler.id = HandlerIDListWatch
+	return handler
+}
+
+func (h ListWatchHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		return h.Invoke()
+	}
+}
+
+func (h *ListWatchHandler) DisplayText() string {
+	status := "off"
+	if h.List.IsWatching() {
+		status = "on"
+	}
+	return fmt.Sprintf("Toggle watch mode (currently %s)", status)
+}
+
+func (h *ListWatchHandler) IsEnabled() bool {
+	return h.List.CurrentExpandedItem() != nil
+}
+
+func (h *ListWatchHandler) Invoke() error {
+	return h.List.ToggleWatch(h.interval)
+}
@@ -0,0 +1,69 @@
+package keybindings
+
+import (
+	"fmt"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/views"
+)
+
+type CommandPanelWebAppLogsHandler struct {
+	followLogsHandler
+}
+
+var _ Command = &CommandPanelWebAppLogsHandler{}
+
+func NewCommandPanelWebAppLogsHandler(commandPanelWidget *views.CommandPanelWidget, content *views.ItemWidget, list *views.ListWidget) *CommandPanelWebAppLogsHandler {
+	return &CommandPanelWebAppLogsHandler{
+		followLogsHandler: newFollowLogsHandler(HandlerIDWebAppLogs, commandPanelWidget, content, list),
+	}
+}
+
+func (h *CommandPanelWebAppLogsHandler) Fn() func(g *gocui.Gui, v *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if h.IsEnabled() {
+			return h.Invoke()
+		}
+		return nil
+	}
+}
+
+func (h *CommandPanelWebAppLogsHandler) DisplayText() string {
+	return "App Service: Stream logs"
+}
+
+func (h *CommandPanelWebAppLogsHandler) IsEnabled() bool {
+	currentItem := h.list.CurrentItem()
+	if currentItem != nil && currentItem.ItemType == expanders.WebAppNodeLogStream {
+		return true
+	}
+	return false
+}
+
+func (h *CommandPanelWebAppLogsHandler) Invoke() error {
+
+	currentItem := h.list.CurrentItem()
+	logStreamEndpoint := currentItem.Metadata["LogStreamEndpoint"]
+	if logStreamEndpoint == "" {
+		return fmt.Errorf("no log stream endpoint found")
+	}
+
+	webAppExpander, ok := (currentItem.Expander).(expanders.WebAppExpanderInterface)
+	if !ok {
+		return fmt.Errorf("current item is not a WebAppExpanderInterface")
+	}
+
+	ctx, cancel := h.followUntilNavigated()
+	authToken, err := webAppExpander.GetAuthToken(ctx, currentItem)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to get auth token: %s", err)
+	}
+
+	if err := h.streamLogs(ctx, logStreamEndpoint, authToken, "Log Stream"); err != nil {
+		cancel()
+		return err
+	}
+	return nil
+}
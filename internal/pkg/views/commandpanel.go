@@ -50,8 +50,23 @@ func (w *CommandPanelWidget) Hide() {
 	w.visible = false
 }
 
+// Cancel hides the command panel and lets the handler know that the user closed it without pressing enter
+func (w *CommandPanelWidget) Cancel() {
+	handler := w.notificationHandler
+	wasVisible := w.visible
+	w.Hide()
+	w.notificationHandler = nil
+	if wasVisible && handler != nil {
+		handler(interfaces.CommandPanelNotification{Cancelled: true})
+	}
+}
+
 // ShowWithText launches the command panel pre-populated with some text
 func (w *CommandPanelWidget) ShowWithText(title string, s string, options *[]interfaces.CommandPanelListOption, handler interfaces.CommandPanelNotificationHandler) {
+	// A prompt replacing one that is still open won't get a response so let its handler know
+	if w.visible && w.notificationHandler != nil {
+		w.notificationHandler(interfaces.CommandPanelNotification{Cancelled: true})
+	}
 	// Ensure we put things back how we found them before the panel was launched
 	w.trackPreviousView()
 
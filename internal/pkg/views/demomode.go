@@ -2,6 +2,7 @@ package views
 
 import (
 	"regexp"
+	"strings"
 )
 
 // NameAndNodeType represents fields `name` and `type` from a node structure
@@ -13,6 +14,12 @@ type NameAndNodeType struct {
 // Matcher for connectionstrings config
 var globalConnectionStringsConfig = NameAndNodeType{"connectionstrings", "Microsoft.Web/sites/config"}
 
+// Matcher for Key Vault secret bundles
+var keyVaultSecretIDRegex = regexp.MustCompile(`"id":\s*"https://[^"]+/secrets/`)
+
+// Matcher for the values in App Service settings tables (name = value)
+var webAppSettingValueRegex = regexp.MustCompile(`(?m)^([^#\n][^=\n]*=).*$`)
+
 // getNameAndType of a json object, if possible.
 func getNameAndType(s string) (NameAndNodeType, bool) {
 	typRe := regexp.MustCompile(`"type":\s*"(.+?(?:\\"|[^"])*)"`)
@@ -31,6 +38,18 @@ func getNameAndType(s string) (NameAndNodeType, bool) {
 
 // StripSecretVals removes secret values
 func StripSecretVals(s string) string {
+	// App Service settings are shown as plain text tables rather than JSON
+	if strings.HasPrefix(s, "# App settings") || strings.HasPrefix(s, "# Connection strings") {
+		s = webAppSettingValueRegex.ReplaceAllString(s, "${1} HIDDEN")
+	}
+
+	// Key Vault secret bundles hold the secret in "value" so this must run before the id is obfuscated
+	if keyVaultSecretIDRegex.MatchString(s) {
+		// Match escaped characters explicitly so an empty value doesn't run on into the next property
+		valueRegex := regexp.MustCompile(`"value":\s*"(?:\\.|[^"\\])*"`)
+		s = valueRegex.ReplaceAllString(s, `"value": "HIDDEN-SECRET"`)
+	}
+
 	guidRegex := regexp.MustCompile(`[{(]?[0-9a-f]{8}[-]?([0-9a-f]{4}[-]?){3}[0-9a-f]{12}[)}]?`)
 	s = guidRegex.ReplaceAllString(s, "00000000-0000-0000-0000-HIDDEN000000")
 
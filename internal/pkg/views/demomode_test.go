@@ -602,6 +602,55 @@ var successfullyReplaceSecretsTestData = []struct {
 			"nextLink": null
 		}`,
 	},
+	{
+		desc: "keyvault/secret",
+		input: `
+		{
+			"value": "super-secret-value",
+			"contentType": "text/plain",
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79",
+			"attributes": {
+				"enabled": true
+			}
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN-SECRET",
+			"contentType": "text/plain",
+			"id": "HIDDEN",
+			"attributes": {
+				"enabled": true
+			}
+		}`,
+	},
+	{
+		desc: "keyvault/secret-empty",
+		input: `
+		{
+			"value": "",
+			"contentType": "text/plain",
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79"
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN-SECRET",
+			"contentType": "text/plain",
+			"id": "HIDDEN"
+		}`,
+	},
+	{
+		desc: "keyvault/secret-escaped",
+		input: `
+		{
+			"value": "has \"quotes\" in it",
+			"id": "https://myvault.vault.azure.net/secrets/mysecret/4387e9f3d6e14c459867679a90fd0f79"
+		}`,
+		expected: `
+		{
+			"value": "HIDDEN-SECRET",
+			"id": "HIDDEN"
+		}`,
+	},
 	{
 		desc: "search/listAdminKeys",
 		input: `
@@ -643,4 +692,24 @@ var successfullyReplaceSecretsTestData = []struct {
 		}
 		`,
 	},
+	{
+		desc: "webapp/appsettings",
+		input: `# App settings (name = value)
+API_URL        = https://example.com/api?code=abc=
+STORAGE_SECRET = s3cr3t
+`,
+		expected: `# App settings (name = value)
+API_URL        = HIDDEN
+STORAGE_SECRET = HIDDEN
+`,
+	},
+	{
+		desc: "webapp/connectionstrings",
+		input: `# Connection strings (name [type] = value)
+Db [SQLAzure] = Server=tcp:example.database.windows.net;Password=abc
+`,
+		expected: `# Connection strings (name [type] = value)
+Db [SQLAzure] = HIDDEN
+`,
+	},
 }
@@ -286,6 +286,17 @@ func (w *ItemWidget) SetContentWithNode(node *expanders.TreeNode, content string
 	w.g.Update(func(*gocui.Gui) error { return nil })
 }
 
+// SetContentWithChangesHighlighted displays the string in the itemview and
+// highlights any lines which weren't present in the previously displayed content
+func (w *ItemWidget) SetContentWithChangesHighlighted(node *expanders.TreeNode, content string, contentType interfaces.ExpanderResponseType, title string) {
+	previousContent := w.content
+	if w.unfilteredContent != "" {
+		previousContent = w.unfilteredContent
+	}
+	w.SetContentWithNode(node, content, contentType, title)
+	w.content = highlightChangedLines(previousContent, w.content)
+}
+
 // GetContent returns the current content
 func (w *ItemWidget) GetContent() string {
 	return w.originalContent
@@ -45,6 +45,11 @@ type ListWidget struct {
 	isNavigating bool
 	refreshLock  sync.Mutex
 	isRefreshing bool
+	// Tracks the node being auto-refreshed by watch mode (nil when not watching)
+	watchLock sync.Mutex
+	watch     *watchState
+	// Headline metrics drawn as sparklines against resources (nil when disabled)
+	headlineMetrics *headlineMetrics
 }
 
 // ListNavigatedEventState captures the state when raising a `list.navigated` event
@@ -179,6 +184,8 @@ func (w *ListWidget) Layout(g *gocui.Gui) error {
 		}
 
 		linesUsedCount := 0
+		// Tracks the line holding the end of each item's display text so a sparkline can be added to it
+		itemEndLines := []int{}
 		completeString := strings.Builder{}
 		completeString.WriteString(style.Separator("  ---\n"))
 		for i, s := range w.itemsToShow() {
@@ -197,6 +204,8 @@ func (w *ListWidget) Layout(g *gocui.Gui) error {
 
 			itemToShow = itemToShow + highlightText(s.Display, w.currentPage.FilterString, w.currentPage.FilterFuzzy) + " " + s.StatusIndicator + "\n" + style.Separator("  ---") + "\n"
 
+			// +1 for the separator drawn before the first item
+			itemEndLines = append(itemEndLines, linesUsedCount+1+strings.Count(s.Display, "\n"))
 			linesUsedCount += strings.Count(itemToShow, "\n")
 			itemHitbox.end = linesUsedCount
 			itemHitbox.itemIndex = i
@@ -240,6 +249,17 @@ func (w *ListWidget) Layout(g *gocui.Gui) error {
 			bottomIndex -= diff
 		}
 
+		// Add sparklines to the visible items only so metrics are only fetched for what's on screen
+		if w.headlineMetrics != nil {
+			viewWidth, _ := v.Size()
+			items := w.itemsToShow()
+			for i, line := range itemEndLines {
+				if line >= topIndex && line <= bottomIndex && line < len(lines) {
+					lines[line] = appendSparklineColumn(lines[line], w.headlineSparkline(items[i]), viewWidth-1)
+				}
+			}
+		}
+
 		// Draw the lines which should be shown in the view based on the top and bottom index
 		for index := topIndex; index < bottomIndex+1; index++ {
 			if index < len(lines) {
@@ -462,6 +482,9 @@ func (w *ListWidget) Navigate(nodes []*expanders.TreeNode, content *expanders.Ex
 		}
 	}
 	if content != nil {
+		if content.Title != "" {
+			title = content.Title
+		}
 		w.contentView.SetContentWithNode(currentItem, content.Response, content.ResponseType, title)
 	}
 
@@ -10,6 +10,7 @@ import (
 
 	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
 	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 	"github.com/lawrencegripper/azbrowse/pkg/armclient"
 
@@ -30,6 +31,9 @@ type NotificationWidget struct {
 	deleteInProgress              bool
 	gui                           *gocui.Gui
 	client                        *armclient.Client
+	commandPanel                  interfaces.CommandPanel
+	deleteImpacts                 map[string]*deleteImpact // impact review results keyed by DeleteURL
+	impactMutex                   sync.Mutex
 }
 
 // AddPendingDelete queues deletes for
@@ -44,6 +48,15 @@ func (w *NotificationWidget) AddPendingDelete(item *expanders.TreeNode) {
 		return
 	}
 
+	if armclient.IsReadOnlyMode() {
+		eventing.SendStatusEvent(&eventing.StatusEvent{
+			Failure: true,
+			Message: "Deleting is disabled in read-only mode",
+			Timeout: time.Second * 5,
+		})
+		return
+	}
+
 	if item.DeleteURL == "" {
 		eventing.SendStatusEvent(&eventing.StatusEvent{
 			Failure: true,
@@ -80,6 +93,7 @@ func (w *NotificationWidget) AddPendingDelete(item *expanders.TreeNode) {
 	}
 
 	w.pendingDeletes = append(w.pendingDeletes, item)
+	w.startImpactAnalysis(item)
 
 	eventing.SendStatusEvent(&eventing.StatusEvent{
 		Message: "Item `" + item.Name + "` added to delete list",
@@ -98,19 +112,21 @@ func (w *NotificationWidget) ConfirmDelete() {
 		return
 	}
 
+	if w.isAnalysingImpact() {
+		eventing.SendStatusEvent(&eventing.StatusEvent{
+			Failure: true,
+			Message: "Still reviewing the impact of the pending deletes. Please wait for completion.",
+			Timeout: time.Second * 5,
+		})
+		return
+	}
+
 	w.deleteMutex.Lock()
 	w.deleteInProgress = true
 
 	// Take a copy of the current pending deletes
 	pending := make([]*expanders.TreeNode, len(w.pendingDeletes))
 	copy(pending, w.pendingDeletes)
-	// Clear the pending deletes list while we delete things
-	w.pendingDeletes = []*expanders.TreeNode{}
-
-	// Force UI to re-render to pickup
-	w.gui.Update(func(g *gocui.Gui) error {
-		return nil
-	})
 
 	w.deleteMutex.Unlock()
 
@@ -123,6 +139,25 @@ func (w *NotificationWidget) ConfirmDelete() {
 			w.deleteInProgress = false
 		}()
 
+		// Items with locks or dependents need their name typing to confirm the delete.
+		// The pending list stays visible while confirming so the warnings can be seen
+		if !w.confirmDeletesWithImpact(pending) {
+			eventing.SendStatusEvent(&eventing.StatusEvent{
+				Failure: true,
+				Message: "Delete cancelled as the resource name wasn't confirmed",
+				Timeout: time.Second * 5,
+			})
+			return
+		}
+
+		// Remove the items being deleted from the pending list, keeping any queued while confirming
+		w.removePendingDeletes(pending)
+
+		// Force UI to re-render to pickup
+		w.gui.Update(func(g *gocui.Gui) error {
+			return nil
+		})
+
 		event, _ := eventing.SendStatusEvent(&eventing.StatusEvent{
 			InProgress: true,
 			Message:    "Starting to delete items",
@@ -152,7 +187,7 @@ func (w *NotificationWidget) ConfirmDelete() {
 				event.Message = "Failed to delete `" + i.Name + "` with error:" + err.Error()
 				event.Update()
 
-				w.pendingDeletes = []*expanders.TreeNode{}
+				w.removeImpacts(pending)
 				// In the event that a delete fails in the
 				// batch of pending deletes lets give up on the rest
 				// as something might have gone wrong and best
@@ -164,6 +199,7 @@ func (w *NotificationWidget) ConfirmDelete() {
 			event.Update()
 		}
 
+		w.removeImpacts(pending)
 		event.Message = "Delete request sent"
 		event.InProgress = false
 		event.SetTimeout(time.Second * 2)
@@ -171,6 +207,22 @@ func (w *NotificationWidget) ConfirmDelete() {
 	}()
 }
 
+func (w *NotificationWidget) removePendingDeletes(items []*expanders.TreeNode) {
+	w.deleteMutex.Lock()
+	defer w.deleteMutex.Unlock()
+	remove := map[string]bool{}
+	for _, item := range items {
+		remove[item.DeleteURL] = true
+	}
+	remaining := []*expanders.TreeNode{}
+	for _, item := range w.pendingDeletes {
+		if !remove[item.DeleteURL] {
+			remaining = append(remaining, item)
+		}
+	}
+	w.pendingDeletes = remaining
+}
+
 // ClearPendingDeletes removes all pending deletes
 func (w *NotificationWidget) ClearPendingDeletes() {
 	w.deleteMutex.Lock()
@@ -183,6 +235,7 @@ func (w *NotificationWidget) ClearPendingDeletes() {
 		})
 
 		w.pendingDeletes = []*expanders.TreeNode{}
+		w.clearImpacts()
 		w.deleteMutex.Unlock()
 		done()
 
@@ -200,10 +253,13 @@ func NewNotificationWidget(x, y, w int, g *gocui.Gui, client *armclient.Client)
 		gui:                g,
 		pendingDeletes:     []*expanders.TreeNode{},
 		toastNotifications: map[string]*eventing.StatusEvent{},
+		deleteImpacts:      map[string]*deleteImpact{},
 		client:             client,
 	}
 
 	newEvents := eventing.SubscribeToStatusEvents()
+	// Expanders can't reference the widget so queue deletes by publishing nodes to this topic
+	pendingDeleteEvents := eventing.SubscribeToTopic("notifications.pendingdelete")
 	// Start loop for showing loading in statusbar
 	go func() {
 		// recover from panic, if one occurrs, and leave terminal usable
@@ -220,6 +276,9 @@ func NewNotificationWidget(x, y, w int, g *gocui.Gui, client *armclient.Client)
 					changesMade = true
 					widget.toastNotifications[eventObj.ID()] = eventObj
 				}
+			case itemRaw := <-pendingDeleteEvents:
+				widget.AddPendingDelete(itemRaw.(*expanders.TreeNode))
+				changesMade = true
 			case <-timeout:
 				// Update the UI
 			}
@@ -251,6 +310,12 @@ func (w *NotificationWidget) Layout(g *gocui.Gui) error {
 	}
 
 	height := len(w.pendingDeletes) + len(w.toastNotifications)
+	for _, i := range w.pendingDeletes {
+		height = height + len(w.impactLines(i))
+	}
+	if w.hasImpactWarnings() {
+		height = height + 1
+	}
 	if len(w.pendingDeletes) > 0 {
 		// Add padding for extra lines
 		height = height + 7
@@ -289,9 +354,15 @@ func (w *NotificationWidget) layoutInternal(v io.Writer) error {
 		fmt.Fprintln(v, style.Title("Pending Deletes:"))
 		for _, i := range pending {
 			fmt.Fprintln(v, " - "+i.Name)
+			for _, line := range w.impactLines(i) {
+				fmt.Fprintln(v, line)
+			}
 		}
 		fmt.Fprintln(v, "")
 		fmt.Fprintln(v, "Do you want to delete these items?")
+		if w.hasImpactWarnings() {
+			fmt.Fprintln(v, style.Warning("You'll need to type the name of items marked ⚠"))
+		}
 		fmt.Fprintln(v, style.Warning("Press "+strings.ToUpper(w.ConfirmDeleteKeyBinding)+" to DELETE"))
 		fmt.Fprintln(v, style.Highlight("Press "+strings.ToUpper(w.ClearPendingDeletesKeyBinding)+" to CANCEL"))
 		fmt.Fprintln(v, style.Subtle("Tip: You can add multiple items"))
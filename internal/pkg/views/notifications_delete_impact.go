@@ -0,0 +1,276 @@
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/interfaces"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+// maxImpactItemsShown limits how many dependents are listed for each pending delete
+const maxImpactItemsShown = 3
+
+// deleteImpact holds the result of reviewing what else is affected by deleting an item
+type deleteImpact struct {
+	analysing   bool
+	warnings    []string
+	reviewError string // set if the review couldn't complete, shown but doesn't need the name typing
+}
+
+type graphResource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type graphResourceResponse struct {
+	Data []graphResource `json:"data"`
+}
+
+// SetCommandPanel sets the command panel used to confirm deletes of items with dependents or locks
+func (w *NotificationWidget) SetCommandPanel(commandPanel interfaces.CommandPanel) {
+	w.commandPanel = commandPanel
+}
+
+// startImpactAnalysis looks up locks and dependents for ARM resources added to the pending deletes.
+// Data-plane items (blobs, messages, Kubernetes objects etc) don't have ARM locks or dependents so are skipped
+func (w *NotificationWidget) startImpactAnalysis(item *expanders.TreeNode) {
+	if w.client == nil || !expanders.IsARMResource(item) {
+		return
+	}
+
+	impact := &deleteImpact{analysing: true}
+	w.impactMutex.Lock()
+	w.deleteImpacts[item.DeleteURL] = impact
+	w.impactMutex.Unlock()
+
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		warnings, err := analyseDeleteImpact(ctx, w.client, item.ID)
+
+		w.impactMutex.Lock()
+		impact.warnings = warnings
+		if err != nil {
+			impact.reviewError = err.Error()
+		}
+		impact.analysing = false
+		w.impactMutex.Unlock()
+
+		w.gui.Update(func(g *gocui.Gui) error {
+			return nil
+		})
+	}()
+}
+
+func (w *NotificationWidget) getImpact(item *expanders.TreeNode) (analysing bool, warnings []string, reviewError string) {
+	w.impactMutex.Lock()
+	defer w.impactMutex.Unlock()
+	impact, exists := w.deleteImpacts[item.DeleteURL]
+	if !exists {
+		return false, nil, ""
+	}
+	return impact.analysing, impact.warnings, impact.reviewError
+}
+
+func (w *NotificationWidget) clearImpacts() {
+	w.impactMutex.Lock()
+	defer w.impactMutex.Unlock()
+	w.deleteImpacts = map[string]*deleteImpact{}
+}
+
+func (w *NotificationWidget) removeImpacts(items []*expanders.TreeNode) {
+	w.impactMutex.Lock()
+	defer w.impactMutex.Unlock()
+	for _, item := range items {
+		delete(w.deleteImpacts, item.DeleteURL)
+	}
+}
+
+// impactLines returns the lines to draw under a pending delete
+func (w *NotificationWidget) impactLines(item *expanders.TreeNode) []string {
+	analysing, warnings, reviewError := w.getImpact(item)
+	if analysing {
+		return []string{style.Subtle("   reviewing impact...")}
+	}
+	lines := []string{}
+	for _, warning := range warnings {
+		lines = append(lines, style.Warning("   ⚠ "+warning))
+	}
+	if reviewError != "" {
+		lines = append(lines, style.Subtle("   impact review incomplete: "+reviewError))
+	}
+	return lines
+}
+
+// isAnalysingImpact returns true if any pending delete is still being reviewed
+func (w *NotificationWidget) isAnalysingImpact() bool {
+	for _, item := range w.pendingDeletes {
+		if analysing, _, _ := w.getImpact(item); analysing {
+			return true
+		}
+	}
+	return false
+}
+
+// hasImpactWarnings returns true if any pending delete has locks or dependents
+func (w *NotificationWidget) hasImpactWarnings() bool {
+	for _, item := range w.pendingDeletes {
+		if _, warnings, _ := w.getImpact(item); len(warnings) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmDeletesWithImpact asks the user to type the name of each item that has dependents or locks.
+// Returns false if any of the names aren't confirmed
+func (w *NotificationWidget) confirmDeletesWithImpact(pending []*expanders.TreeNode) bool {
+	for _, item := range pending {
+		if _, warnings, _ := w.getImpact(item); len(warnings) == 0 {
+			continue
+		}
+		if w.commandPanel == nil {
+			return false
+		}
+		name, _ := expanders.PromptInCommandPanel(w.gui, w.commandPanel, "Type '"+item.Name+"' to delete it:", "", nil)
+		if name != item.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// analyseDeleteImpact finds the locks, child resources and references from other resources
+// which would be affected by deleting the resource (or resource group) with the given ID
+func analyseDeleteImpact(ctx context.Context, client *armclient.Client, resourceID string) ([]string, error) {
+	warnings := []string{}
+
+	locks, err := getLocks(ctx, client, resourceID)
+	if err != nil {
+		return warnings, fmt.Errorf("failed to get locks: %s", err)
+	}
+	warnings = append(warnings, locks...)
+
+	subscriptionID := armclient.GetSubscriptionIDFromResourceID(resourceID)
+	escapedID := strings.ReplaceAll(resourceID, "'", "\\'")
+
+	children, err := queryGraphResources(ctx, client, subscriptionID,
+		"Resources | where id startswith '"+escapedID+"/' | project id, name, type")
+	if err != nil {
+		return warnings, fmt.Errorf("failed to find child resources: %s", err)
+	}
+	if len(children) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d child resources: %s", len(children), summariseResources(children)))
+	}
+
+	references, err := queryGraphResources(ctx, client, subscriptionID,
+		"Resources | where id !startswith '"+escapedID+"' and tostring(properties) contains '"+escapedID+"' | project id, name, type")
+	if err != nil {
+		return warnings, fmt.Errorf("failed to find references: %s", err)
+	}
+	for i, reference := range references {
+		if i == maxImpactItemsShown {
+			warnings = append(warnings, fmt.Sprintf("...and %d more references", len(references)-i))
+			break
+		}
+		warnings = append(warnings, "Used by "+reference.Name+" ["+lastTypeSegment(reference.Type)+"]")
+	}
+
+	// Diagnostic settings aren't removed with the resource so call them out
+	if !isResourceGroupID(resourceID) {
+		for _, setting := range getDiagnosticSettings(ctx, client, resourceID) {
+			warnings = append(warnings, "Diagnostic setting '"+setting+"' is left behind")
+		}
+	}
+
+	return warnings, nil
+}
+
+func getLocks(ctx context.Context, client *armclient.Client, resourceID string) ([]string, error) {
+	data, err := client.DoRequest(ctx, "GET", resourceID+"/providers/Microsoft.Authorization/locks?api-version=2016-09-01")
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Value []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Level string `json:"level"`
+			} `json:"properties"`
+		} `json:"value"`
+	}
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, err
+	}
+	locks := []string{}
+	for _, lock := range response.Value {
+		locks = append(locks, "Locked by '"+lock.Name+"' ("+lock.Properties.Level+")")
+	}
+	return locks, nil
+}
+
+func getDiagnosticSettings(ctx context.Context, client *armclient.Client, resourceID string) []string {
+	// Not all resource types support diagnostic settings so errors are ignored
+	data, err := client.DoRequest(ctx, "GET", resourceID+"/providers/microsoft.insights/diagnosticSettings?api-version=2021-05-01-preview")
+	if err != nil {
+		return nil
+	}
+	var response struct {
+		Value []struct {
+			Name string `json:"name"`
+		} `json:"value"`
+	}
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return nil
+	}
+	settings := []string{}
+	for _, setting := range response.Value {
+		settings = append(settings, setting.Name)
+	}
+	return settings
+}
+
+func queryGraphResources(ctx context.Context, client *armclient.Client, subscriptionID string, query string) ([]graphResource, error) {
+	data, err := client.DoResourceGraphQueryReturningObjectArray(ctx, []string{subscriptionID}, query)
+	if err != nil {
+		return nil, err
+	}
+	var response graphResourceResponse
+	if err = json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func summariseResources(resources []graphResource) string {
+	names := []string{}
+	for i, resource := range resources {
+		if i == maxImpactItemsShown {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, resource.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func isResourceGroupID(id string) bool {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	return len(parts) == 4 && strings.EqualFold(parts[2], "resourceGroups")
+}
+
+func lastTypeSegment(armType string) string {
+	return armType[strings.LastIndex(armType, "/")+1:]
+}
@@ -231,3 +231,108 @@ func clearEvents(statusEvents chan interface{}) {
 		<-statusEvents
 	}
 }
+
+func Test_Delete_ShowsImpactWarnings(t *testing.T) {
+	g, err := gocui.NewGui(gocui.OutputSimulator, false)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer g.Close()
+
+	notView := NewNotificationWidget(0, 0, 47, g, nil)
+	g.SetManager(notView)
+
+	notView.AddPendingDelete(&expanders.TreeNode{Name: "nic1", DeleteURL: "http://delete/nic1"})
+	notView.AddPendingDelete(&expanders.TreeNode{Name: "s2", DeleteURL: "http://delete/s2"})
+	notView.deleteImpacts["http://delete/nic1"] = &deleteImpact{warnings: []string{"Used by vm1 [virtualMachines]"}}
+
+	builder := &strings.Builder{}
+	err = notView.layoutInternal(builder)
+	if err != nil {
+		t.Error(err)
+	}
+
+	viewResult := builder.String()
+	if !strings.Contains(viewResult, "Used by vm1 [virtualMachines]") {
+		t.Error("Missing impact warning")
+	}
+	if !strings.Contains(viewResult, "type the name") {
+		t.Error("Missing confirmation message")
+	}
+	if notView.isAnalysingImpact() {
+		t.Error("Expected no impact analysis for items without an ARM ID")
+	}
+}
+
+func Test_Delete_IsResourceGroupID(t *testing.T) {
+	if !isResourceGroupID("/subscriptions/1234/resourceGroups/rg1") {
+		t.Error("Expected resource group ID to be detected")
+	}
+	if isResourceGroupID("/subscriptions/1234/resourceGroups/rg1/providers/Microsoft.Network/networkInterfaces/nic1") {
+		t.Error("Expected resource ID not to be a resource group")
+	}
+}
+
+func Test_Delete_ReviewErrorDoesNotNeedConfirmation(t *testing.T) {
+	g, err := gocui.NewGui(gocui.OutputSimulator, false)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer g.Close()
+
+	notView := NewNotificationWidget(0, 0, 47, g, nil)
+	g.SetManager(notView)
+
+	notView.AddPendingDelete(&expanders.TreeNode{Name: "rg1", DeleteURL: "http://delete/rg1"})
+	notView.deleteImpacts["http://delete/rg1"] = &deleteImpact{reviewError: "failed to get locks"}
+
+	if notView.hasImpactWarnings() {
+		t.Error("Expected a failed review not to require the name to be typed")
+	}
+	if !notView.confirmDeletesWithImpact(notView.pendingDeletes) {
+		t.Error("Expected delete to be confirmed without prompting")
+	}
+	lines := notView.impactLines(notView.pendingDeletes[0])
+	if len(lines) != 1 || !strings.Contains(lines[0], "failed to get locks") {
+		t.Errorf("Expected review error to be shown, got %v", lines)
+	}
+}
+
+func Test_Delete_RemovePendingDeletesKeepsNewItems(t *testing.T) {
+	g, err := gocui.NewGui(gocui.OutputSimulator, false)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer g.Close()
+
+	notView := NewNotificationWidget(0, 0, 47, g, nil)
+	g.SetManager(notView)
+
+	first := &expanders.TreeNode{Name: "s1", DeleteURL: "http://delete/s1"}
+	notView.AddPendingDelete(first)
+	notView.AddPendingDelete(&expanders.TreeNode{Name: "s2", DeleteURL: "http://delete/s2"})
+
+	notView.removePendingDeletes([]*expanders.TreeNode{first})
+
+	if len(notView.pendingDeletes) != 1 || notView.pendingDeletes[0].Name != "s2" {
+		t.Errorf("Expected only s2 to remain, got %v", notView.pendingDeletes)
+	}
+}
+
+func Test_Delete_OnlyARMResourcesAreReviewed(t *testing.T) {
+	rg := &expanders.TreeNode{ItemType: "resourcegroup", ID: "/subscriptions/1234/resourceGroups/rg1", DeleteURL: "/subscriptions/1234/resourceGroups/rg1?api-version=2017-05-10"}
+	if !expanders.IsARMResource(rg) {
+		t.Error("Expected resource group to be reviewed")
+	}
+	blob := &expanders.TreeNode{ItemType: "storageBlob.blob", ID: "/subscriptions/1234/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa/<blobs>/a.txt", DeleteURL: "/subscriptions/1234/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa/<blobs>/a.txt"}
+	if expanders.IsARMResource(blob) {
+		t.Error("Expected blob not to be reviewed")
+	}
+	manifest := &expanders.TreeNode{ItemType: expanders.ResourceType, ID: "/subscriptions/1234/x", DeleteURL: "/subscriptions/1234/x/sha256:abc"}
+	if expanders.IsARMResource(manifest) {
+		t.Error("Expected node deleting something other than its ID not to be reviewed")
+	}
+}
@@ -0,0 +1,213 @@
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/config"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/lawrencegripper/azbrowse/pkg/armclient"
+)
+
+const (
+	// headlineMetricCacheTTL is how long a sparkline is shown before it is fetched again
+	headlineMetricCacheTTL = 2 * time.Minute
+	// headlineMetricMaxRequests limits the number of metric requests in flight at once
+	headlineMetricMaxRequests = 4
+	// headlineMetricMaxCacheEntries bounds the cache, the resources shown least recently are evicted first
+	headlineMetricMaxCacheEntries = 500
+	headlineMetricTimeRange       = time.Hour
+	headlineMetricInterval        = "PT5M"
+)
+
+var sparklineBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// defaultHeadlineMetrics are used for resource types not set in the user config
+var defaultHeadlineMetrics = map[string]config.HeadlineMetricConfig{
+	"microsoft.compute/virtualmachines":     {Metric: "Percentage CPU", Aggregation: "average"},
+	"microsoft.web/sites":                   {Metric: "Requests", Aggregation: "total"},
+	"microsoft.documentdb/databaseaccounts": {Metric: "TotalRequestUnits", Aggregation: "total"},
+}
+
+type headlineMetricEntry struct {
+	values   []float64
+	fetched  time.Time
+	shown    time.Time
+	inFlight bool
+}
+
+// headlineMetrics tracks the configured headline metrics and caches their values by resource ID
+type headlineMetrics struct {
+	lock      sync.Mutex
+	metrics   map[string]config.HeadlineMetricConfig
+	cache     map[string]*headlineMetricEntry
+	semaphore chan struct{}
+}
+
+// SetHeadlineMetrics enables sparklines in the list for the configured resource types.
+// User config is merged over the defaults and an empty metric name disables a type
+func (w *ListWidget) SetHeadlineMetrics(userMetrics map[string]config.HeadlineMetricConfig) {
+	metrics := map[string]config.HeadlineMetricConfig{}
+	for armType, metric := range defaultHeadlineMetrics {
+		metrics[armType] = metric
+	}
+	for armType, metric := range userMetrics {
+		armType = strings.ToLower(armType)
+		if metric.Metric == "" {
+			delete(metrics, armType)
+			continue
+		}
+		if metric.Aggregation == "" {
+			metric.Aggregation = "average"
+		}
+		metrics[armType] = metric
+	}
+
+	w.headlineMetrics = &headlineMetrics{
+		metrics:   metrics,
+		cache:     map[string]*headlineMetricEntry{},
+		semaphore: make(chan struct{}, headlineMetricMaxRequests),
+	}
+}
+
+// headlineSparkline returns the cached sparkline for the item and queues a fetch
+// if there isn't a fresh one. It should only be called for rows that are visible
+func (w *ListWidget) headlineSparkline(item *expanders.TreeNode) string {
+	if w.headlineMetrics == nil || item.ItemType != expanders.ResourceType {
+		return ""
+	}
+	metric, ok := w.headlineMetrics.metrics[strings.ToLower(item.ArmType)]
+	if !ok {
+		return ""
+	}
+
+	h := w.headlineMetrics
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	entry := h.getEntry(item.ID, time.Now())
+	if !entry.inFlight && time.Since(entry.fetched) > headlineMetricCacheTTL {
+		entry.inFlight = true
+		go w.fetchHeadlineMetric(item.ID, metric, entry)
+	}
+	return renderSparkline(entry.values)
+}
+
+// getEntry returns the cache entry for the resource, adding one if needed and evicting the entry shown least
+// recently when the cache is full. The caller must hold the lock
+func (h *headlineMetrics) getEntry(resourceID string, now time.Time) *headlineMetricEntry {
+	entry, exists := h.cache[resourceID]
+	if !exists {
+		if len(h.cache) >= headlineMetricMaxCacheEntries {
+			oldestID := ""
+			for id, candidate := range h.cache {
+				if oldestID == "" || candidate.shown.Before(h.cache[oldestID].shown) {
+					oldestID = id
+				}
+			}
+			// A fetch in flight for the evicted entry updates it harmlessly
+			delete(h.cache, oldestID)
+		}
+		entry = &headlineMetricEntry{}
+		h.cache[resourceID] = entry
+	}
+	entry.shown = now
+	return entry
+}
+
+func (w *ListWidget) fetchHeadlineMetric(resourceID string, metric config.HeadlineMetricConfig, entry *headlineMetricEntry) {
+	// recover from panic, if one occurrs, and leave terminal usable
+	defer errorhandling.RecoveryWithCleanup()
+
+	h := w.headlineMetrics
+	h.semaphore <- struct{}{}
+	values, err := getHeadlineMetricValues(w.ctx, resourceID, metric)
+	<-h.semaphore
+
+	h.lock.Lock()
+	entry.inFlight = false
+	entry.fetched = time.Now()
+	if err == nil {
+		entry.values = values
+	}
+	h.lock.Unlock()
+
+	if err == nil && w.g != nil {
+		// Trigger a layout so the sparkline is drawn
+		w.g.Update(func(g *gocui.Gui) error { return nil })
+	}
+}
+
+func getHeadlineMetricValues(ctx context.Context, resourceID string, metric config.HeadlineMetricConfig) ([]float64, error) {
+	now := time.Now().UTC()
+	metricsURL := resourceID + "/providers/microsoft.Insights/metrics?timespan=" +
+		now.Add(-headlineMetricTimeRange).Format("2006-01-02T15:04:05.000Z") + "/" + now.Format("2006-01-02T15:04:05.000Z") +
+		"&interval=" + headlineMetricInterval +
+		"&metricnames=" + url.QueryEscape(metric.Metric) +
+		"&aggregation=" + url.QueryEscape(metric.Aggregation) +
+		"&api-version=2018-01-01"
+
+	data, err := armclient.LegacyInstance.DoRequest(ctx, "GET", metricsURL)
+	if err != nil {
+		return nil, err
+	}
+	var metricResponse armclient.MetricResponse
+	if err = json.Unmarshal([]byte(data), &metricResponse); err != nil {
+		return nil, err
+	}
+
+	values := []float64{}
+	for _, value := range metricResponse.Value {
+		for _, timeseries := range value.Timeseries {
+			for _, datapoint := range timeseries.Data {
+				v, _ := datapoint[strings.ToLower(metric.Aggregation)].(float64)
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// renderSparkline draws the values as a line of unicode blocks scaled between their min and max
+func renderSparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+
+	sparkline := strings.Builder{}
+	for _, value := range values {
+		index := 0
+		if max > min {
+			index = int((value - min) / (max - min) * float64(len(sparklineBlocks)-1))
+		}
+		sparkline.WriteRune(sparklineBlocks[index])
+	}
+	return sparkline.String()
+}
+
+// appendSparklineColumn right aligns the sparkline on the last line of text within width
+func appendSparklineColumn(text string, sparkline string, width int) string {
+	if sparkline == "" {
+		return text
+	}
+	lastLine := text[strings.LastIndex(text, "\n")+1:]
+	padding := width - utf8.RuneCountInString(ansiEscapeRegex.ReplaceAllString(lastLine, "")) - utf8.RuneCountInString(sparkline)
+	if padding < 1 {
+		padding = 1
+	}
+	return text + strings.Repeat(" ", padding) + style.Subtle(sparkline)
+}
@@ -0,0 +1,41 @@
+package views
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RenderSparkline(t *testing.T) {
+	assert.Equal(t, "", renderSparkline(nil))
+	assert.Equal(t, "▁▄█", renderSparkline([]float64{0, 5, 10}))
+	// A flat line is drawn at the bottom rather than dividing by zero
+	assert.Equal(t, "▁▁▁", renderSparkline([]float64{3, 3, 3}))
+}
+
+func Test_AppendSparklineColumn(t *testing.T) {
+	assert.Equal(t, "header\n  name", appendSparklineColumn("header\n  name", "", 20))
+	// Padding ignores colour codes and only considers the last line
+	assert.Equal(t, "a long header\n  "+style.Subtle("name")+"     "+style.Subtle("▁█"), appendSparklineColumn("a long header\n  "+style.Subtle("name"), "▁█", 13))
+	// Always leave a space when the line is too long to align
+	assert.Equal(t, "  long name "+style.Subtle("▁█"), appendSparklineColumn("  long name", "▁█", 5))
+}
+
+func Test_HeadlineMetrics_EvictsLeastRecentlyShown(t *testing.T) {
+	h := &headlineMetrics{cache: map[string]*headlineMetricEntry{}}
+	start := time.Now()
+	for i := 0; i < headlineMetricMaxCacheEntries; i++ {
+		h.getEntry(fmt.Sprintf("/resource%d", i), start.Add(time.Duration(i)*time.Second))
+	}
+	// Showing the first resource again keeps it, so the second is now the least recently shown
+	first := h.getEntry("/resource0", start.Add(time.Hour))
+
+	h.getEntry("/new", start.Add(2*time.Hour))
+	assert.Len(t, h.cache, headlineMetricMaxCacheEntries)
+	assert.Same(t, first, h.cache["/resource0"])
+	assert.NotContains(t, h.cache, "/resource1")
+	assert.Contains(t, h.cache, "/new")
+}
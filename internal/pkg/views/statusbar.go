@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/awesome-gocui/gocui"
@@ -21,6 +22,9 @@ type StatusbarWidget struct {
 	messages        map[string]*eventing.StatusEvent
 	currentMessage  *eventing.StatusEvent
 	messageAddition string
+	watchStatus     string
+	watchLock       sync.Mutex // watchStatus is set from the watch goroutine
+	readOnly        bool
 	HelpKeyBinding  string
 }
 
@@ -146,6 +150,12 @@ func (w *StatusbarWidget) Layout(g *gocui.Gui) error {
 	}
 	v.Clear()
 	v.Title = "Status"
+	if w.readOnly {
+		v.Title += " " + style.Warning("[🔒 READ-ONLY]")
+	}
+	if watchStatus := w.getWatchStatus(); watchStatus != "" {
+		v.Title += " [👁 " + watchStatus + "]"
+	}
 	v.Subtitle = fmt.Sprintf(`[%s -> Help]`, strings.ToUpper(w.HelpKeyBinding))
 	v.Wrap = true
 
@@ -178,3 +188,21 @@ func (w *StatusbarWidget) Status(message string, loading bool) func() {
 func (w *StatusbarWidget) SetHideGuids(value bool) {
 	w.hideGuids = value
 }
+
+// SetReadOnly sets whether the read-only badge is shown in the statusbar title
+func (w *StatusbarWidget) SetReadOnly(value bool) {
+	w.readOnly = value
+}
+
+// SetWatchStatus sets the watch mode message shown in the statusbar title (empty to clear)
+func (w *StatusbarWidget) SetWatchStatus(value string) {
+	w.watchLock.Lock()
+	defer w.watchLock.Unlock()
+	w.watchStatus = value
+}
+
+func (w *StatusbarWidget) getWatchStatus() string {
+	w.watchLock.Lock()
+	defer w.watchLock.Unlock()
+	return w.watchStatus
+}
@@ -1,5 +1,14 @@
 package views
 
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
+)
+
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 type Measurable interface {
 	Size() (x int, y int)
 }
@@ -39,3 +48,30 @@ func getViewBounds(g Measurable, x int, y int, width int, height int) (x0, y0, x
 	}
 	return
 }
+
+// highlightChangedLines highlights the lines in current which don't appear in previous.
+// Lines are compared without terminal color codes so reformatting doesn't count as a change
+func highlightChangedLines(previous string, current string) string {
+	if previous == "" {
+		return current
+	}
+
+	previousLines := map[string]int{}
+	for _, line := range strings.Split(previous, "\n") {
+		previousLines[ansiEscapeRegex.ReplaceAllString(line, "")]++
+	}
+
+	lines := strings.Split(current, "\n")
+	for i, line := range lines {
+		plainLine := ansiEscapeRegex.ReplaceAllString(line, "")
+		if previousLines[plainLine] > 0 {
+			previousLines[plainLine]--
+			continue
+		}
+		if strings.TrimSpace(plainLine) == "" {
+			continue
+		}
+		lines[i] = style.Highlight(plainLine)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,8 +1,10 @@
 package views
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/lawrencegripper/azbrowse/internal/pkg/style"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -86,3 +88,21 @@ func (m *ViewTestData) ExpectBounds(t *testing.T, x0 int, y0 int, x1 int, y1 int
 	assert.Equal(t, x1, actualX1, "validate x1")
 	assert.Equal(t, y1, actualY1, "validate y1")
 }
+
+func Test_HighlightChangedLines(t *testing.T) {
+	previous := "{\n  \"state\": \"Creating\",\n  \"name\": \"vm1\"\n}"
+	current := "{\n  \"state\": \"Succeeded\",\n  \"name\": \"vm1\"\n}"
+
+	result := strings.Split(highlightChangedLines(previous, current), "\n")
+
+	assert.Equal(t, "{", result[0])
+	assert.Equal(t, style.Highlight("  \"state\": \"Succeeded\","), result[1])
+	assert.Equal(t, "  \"name\": \"vm1\"", result[2])
+}
+
+func Test_HighlightChangedLines_IgnoresColorCodes(t *testing.T) {
+	previous := "\x1b[32m\"name\"\x1b[0m: \"vm1\""
+	current := "\"name\": \"vm1\""
+
+	assert.Equal(t, current, highlightChangedLines(previous, current))
+}
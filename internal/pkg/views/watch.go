@@ -0,0 +1,173 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/errorhandling"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+	"github.com/lawrencegripper/azbrowse/internal/pkg/expanders"
+)
+
+// DefaultWatchInterval is used when no interval is configured for watch mode
+const DefaultWatchInterval = 10 * time.Second
+
+// maxWatchBackoff caps the delay between refreshes when a watch keeps failing
+const maxWatchBackoff = 5 * time.Minute
+
+// watchState tracks the node being watched and how to stop the watch
+type watchState struct {
+	node     *expanders.TreeNode
+	page     *Page
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+// IsWatching returns true if the currently expanded node is being watched
+func (w *ListWidget) IsWatching() bool {
+	w.watchLock.Lock()
+	defer w.watchLock.Unlock()
+	return w.watch != nil
+}
+
+// ToggleWatch starts watching the currently expanded node or stops the active watch
+func (w *ListWidget) ToggleWatch(interval time.Duration) error {
+	if w.IsWatching() {
+		w.StopWatch()
+		return nil
+	}
+	return w.StartWatch(interval)
+}
+
+// StartWatch re-expands the currently expanded node every interval
+// until the user navigates away or the watch is stopped
+func (w *ListWidget) StartWatch(interval time.Duration) error {
+	node := w.CurrentExpandedItem()
+	if node == nil || w.currentPage == nil {
+		return fmt.Errorf("no expanded item to watch")
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w.watchLock.Lock()
+	defer w.watchLock.Unlock()
+	if w.watch != nil {
+		w.watch.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+	watch := &watchState{
+		node:     node,
+		page:     w.currentPage,
+		interval: interval,
+		cancel:   cancel,
+	}
+	w.watch = watch
+	w.statusView.SetWatchStatus(fmt.Sprintf("Watching %s every %s", node.Name, interval))
+
+	// Subscribe before returning so a navigation straight after starting the watch isn't missed
+	navigatingChannel := eventing.SubscribeToTopic("list.prenavigate")
+	go func() {
+		// recover from panic, if one occurrs, and leave terminal usable
+		defer errorhandling.RecoveryWithCleanup()
+		defer eventing.Unsubscribe(navigatingChannel)
+
+		delay := watch.interval
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-navigatingChannel:
+				w.stopWatch(watch)
+				return
+			case <-time.After(delay):
+			}
+
+			err := w.refreshWatchedNode(ctx, watch)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				// Back off while the node is failing to avoid hammering the API
+				delay = delay * 2
+				if delay > maxWatchBackoff {
+					delay = maxWatchBackoff
+				}
+				w.statusView.SetWatchStatus(fmt.Sprintf("Watching %s (retrying in %s)", watch.node.Name, delay))
+				eventing.SendFailureStatusFromError("Watch refresh failed for "+watch.node.Name, err)
+				continue
+			}
+			if delay != watch.interval {
+				delay = watch.interval
+				w.statusView.SetWatchStatus(fmt.Sprintf("Watching %s every %s", watch.node.Name, delay))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// StopWatch stops the active watch, if there is one
+func (w *ListWidget) StopWatch() {
+	w.watchLock.Lock()
+	watch := w.watch
+	w.watchLock.Unlock()
+	if watch != nil {
+		w.stopWatch(watch)
+	}
+}
+
+func (w *ListWidget) stopWatch(watch *watchState) {
+	w.watchLock.Lock()
+	defer w.watchLock.Unlock()
+
+	watch.cancel()
+	if w.watch != watch {
+		// A newer watch has already replaced this one
+		return
+	}
+	w.watch = nil
+	w.statusView.SetWatchStatus("")
+}
+
+// refreshWatchedNode expands the watched node again and applies the results
+// to the page and content panel if the user is still looking at them
+func (w *ListWidget) refreshWatchedNode(ctx context.Context, watch *watchState) error {
+	content, nodes, err := expanders.ExpandItem(ctx, watch.node)
+	if err != nil {
+		return err
+	}
+	if content == nil || (content.Response == "" && len(nodes) == 0) {
+		return fmt.Errorf("no response returned for %s", watch.node.ID)
+	}
+
+	w.g.Update(func(g *gocui.Gui) error {
+		if ctx.Err() != nil || w.currentPage != watch.page {
+			return nil
+		}
+
+		latestNodes := map[string]*expanders.TreeNode{}
+		for _, node := range nodes {
+			latestNodes[node.ID] = node
+		}
+		for _, item := range w.currentPage.Items {
+			if latest, ok := latestNodes[item.ID]; ok {
+				item.StatusIndicator = latest.StatusIndicator
+			}
+		}
+
+		if content.Response != "" {
+			w.currentPage.Data = content.Response
+			w.currentPage.DataType = content.ResponseType
+			if w.contentView.GetNode() == watch.node {
+				w.contentView.SetContentWithChangesHighlighted(watch.node, content.Response, content.ResponseType, "Response")
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package armclient
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lawrencegripper/azbrowse/internal/pkg/eventing"
+)
+
+var (
+	readOnlyEnabled       atomic.Bool
+	readOnlyAllowListKeys atomic.Bool
+	installTransportOnce  sync.Once
+)
+
+// readOnlyPostPaths are POST endpoints known not to change anything
+var readOnlyPostPaths = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)/providers/Microsoft\.ResourceGraph/resources$`),                    // Resource Graph queries
+	regexp.MustCompile(`(?i)^/v1/workspaces/[^/]+/query$`),                                      // Log Analytics queries (api.loganalytics.io)
+	regexp.MustCompile(`(?i)/providers/Microsoft\.OperationalInsights/workspaces/[^/]+/query$`), // Log Analytics queries via ARM
+	regexp.MustCompile(`(?i)^/v1/apps/[^/]+/query$`),                                            // App Insights queries (api.applicationinsights.io)
+	regexp.MustCompile(`(?i)/providers/Microsoft\.Insights/components/[^/]+/query$`),            // App Insights queries via ARM
+	regexp.MustCompile(`(?i)/providers/Microsoft\.CostManagement/(query|forecast)$`),            // Cost Management queries
+	regexp.MustCompile(`(?i)/oauth2/(exchange|token)$`),                                         // Container registry token exchange
+}
+
+// listKeysPaths are POST endpoints which only read secrets, these are allowed when opted in to
+var listKeysPaths = regexp.MustCompile(`(?i)/(list\w*(keys|credential|credentials|secrets|connectionstrings)|readonlykeys|config/(appsettings|connectionstrings)/list)$`)
+
+// SetReadOnlyMode enables or disables refusing requests which could change resources
+func SetReadOnlyMode(enabled bool, allowListKeys bool) {
+	readOnlyEnabled.Store(enabled)
+	readOnlyAllowListKeys.Store(allowListKeys)
+}
+
+// IsReadOnlyMode returns true if requests which could change resources are refused
+func IsReadOnlyMode() bool {
+	return readOnlyEnabled.Load()
+}
+
+// InstallReadOnlyTransport wraps http.DefaultTransport so read-only mode is enforced for all
+// HTTP clients which don't set their own transport
+func InstallReadOnlyTransport() {
+	installTransportOnce.Do(func() {
+		http.DefaultTransport = NewReadOnlyTransport(http.DefaultTransport)
+	})
+}
+
+// NewReadOnlyTransport wraps a transport to refuse requests which could change resources
+// while read-only mode is enabled
+func NewReadOnlyTransport(inner http.RoundTripper) http.RoundTripper {
+	return &readOnlyTransport{inner: inner}
+}
+
+type readOnlyTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if IsReadOnlyMode() && !isReadOnlyRequest(req) {
+		message := fmt.Sprintf("Read-only mode: refused %s request to %s", req.Method, req.URL.Host+req.URL.Path)
+		eventing.SendStatusEvent(&eventing.StatusEvent{
+			Failure: true,
+			Message: message,
+			Timeout: time.Second * 10,
+		})
+		return nil, fmt.Errorf("%s", message)
+	}
+	return t.inner.RoundTrip(req)
+}
+
+// isReadOnlyRequest returns true for requests which won't change anything
+func isReadOnlyRequest(req *http.Request) bool {
+	switch strings.ToUpper(req.Method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		path := strings.TrimSuffix(req.URL.Path, "/")
+		for _, readOnlyPath := range readOnlyPostPaths {
+			if readOnlyPath.MatchString(path) {
+				return true
+			}
+		}
+		// Cosmos DB queries are sent as POSTs
+		if strings.EqualFold(req.Header.Get("x-ms-documentdb-isquery"), "true") {
+			return true
+		}
+		return readOnlyAllowListKeys.Load() && listKeysPaths.MatchString(path)
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package armclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_ReadOnly_IsReadOnlyRequest(t *testing.T) {
+	defer SetReadOnlyMode(false, false)
+	SetReadOnlyMode(true, false)
+
+	newRequest := func(method string, url string) *http.Request {
+		req, _ := http.NewRequest(method, url, nil)
+		return req
+	}
+
+	assert.Assert(t, isReadOnlyRequest(newRequest("GET", "https://management.azure.com/subscriptions/1/resourceGroups/rg1")))
+	assert.Assert(t, isReadOnlyRequest(newRequest("POST", "https://management.azure.com/providers/Microsoft.ResourceGraph/resources?api-version=2018-09-01-preview")))
+	assert.Assert(t, isReadOnlyRequest(newRequest("POST", "https://api.loganalytics.io/v1/workspaces/00000000-0000-0000-0000-000000000000/query")))
+	assert.Assert(t, isReadOnlyRequest(newRequest("POST", "https://api.applicationinsights.io/v1/apps/00000000-0000-0000-0000-000000000000/query")))
+	assert.Assert(t, isReadOnlyRequest(newRequest("POST", "https://management.azure.com/subscriptions/1/resourceGroups/rg1/providers/Microsoft.OperationalInsights/workspaces/ws1/query?api-version=2020-08-01")))
+	assert.Assert(t, isReadOnlyRequest(newRequest("POST", "https://management.azure.com/subscriptions/1/resourceGroups/rg1/providers/microsoft.insights/components/ai1/query?api-version=2018-04-20")))
+	assert.Assert(t, isReadOnlyRequest(newRequest("POST", "https://management.azure.com/subscriptions/1/providers/Microsoft.CostManagement/query?api-version=2023-03-01")))
+	assert.Assert(t, !isReadOnlyRequest(newRequest("POST", "https://management.azure.com/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Example/things/thing1/query")))
+	assert.Assert(t, !isReadOnlyRequest(newRequest("POST", "https://api.loganalytics.io/v1/workspaces/ws1/other/query")))
+	assert.Assert(t, !isReadOnlyRequest(newRequest("DELETE", "https://management.azure.com/subscriptions/1/resourceGroups/rg1")))
+	assert.Assert(t, !isReadOnlyRequest(newRequest("PUT", "https://account.blob.core.windows.net/container/blob")))
+	assert.Assert(t, !isReadOnlyRequest(newRequest("POST", "https://management.azure.com/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1/restart")))
+
+	cosmosQuery := newRequest("POST", "https://account.documents.azure.com/dbs/db/colls/coll/docs")
+	cosmosQuery.Header.Set("x-ms-documentdb-isquery", "true")
+	assert.Assert(t, isReadOnlyRequest(cosmosQuery))
+
+	listKeys := newRequest("POST", "https://management.azure.com/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa/listKeys")
+	assert.Assert(t, !isReadOnlyRequest(listKeys))
+	listAppSettings := newRequest("POST", "https://management.azure.com/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Web/sites/app1/config/appsettings/list")
+	assert.Assert(t, !isReadOnlyRequest(listAppSettings))
+	SetReadOnlyMode(true, true)
+	assert.Assert(t, isReadOnlyRequest(listKeys))
+	assert.Assert(t, isReadOnlyRequest(listAppSettings))
+}
+
+func Test_ReadOnly_TransportRefusesWrites(t *testing.T) {
+	defer SetReadOnlyMode(false, false)
+
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count = count + 1
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: NewReadOnlyTransport(http.DefaultTransport)}
+
+	SetReadOnlyMode(true, false)
+	_, err := client.Post(ts.URL+"/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/restart", "application/json", nil)
+	assert.ErrorContains(t, err, "Read-only mode")
+	response, err := client.Get(ts.URL + "/subscriptions/1/resourceGroups/rg1")
+	assert.NilError(t, err)
+	response.Body.Close() //nolint: errcheck
+
+	SetReadOnlyMode(false, false)
+	response, err = client.Post(ts.URL+"/subscriptions/1/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/restart", "application/json", nil)
+	assert.NilError(t, err)
+	response.Body.Close() //nolint: errcheck
+
+	assert.Equal(t, count, 2)
+}